@@ -0,0 +1,985 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/scientistnik/invest-agents/internal/app"
+	"github.com/scientistnik/invest-agents/internal/app/domain"
+	"github.com/shopspring/decimal"
+)
+
+// operator is the caller of user commands, the cli works with the database
+// directly, so its operator is an admin.
+var operator = domain.User{Role: domain.AdminUserRole}
+
+func newFlagSet(name string) *flag.FlagSet {
+	return flag.NewFlagSet(name, flag.ContinueOnError)
+}
+
+func getUser(actions *app.Actions, userId int64) (*domain.User, error) {
+	if userId == 0 {
+		return nil, errors.New("-user is required")
+	}
+
+	user, err := actions.GetUser(userId)
+	if err != nil {
+		return nil, err
+	}
+
+	if user == nil {
+		return nil, fmt.Errorf("user(id=%d) not found", userId)
+	}
+
+	return user, nil
+}
+
+func getUserAgent(actions *app.Actions, userId int64, agentId int64) (*domain.User, *domain.Agent, error) {
+	user, err := getUser(actions, userId)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	agent, err := actions.GetUserAgent(*user, agentId)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return user, agent, nil
+}
+
+func strategyList(actions *app.Actions, args []string) error {
+	for _, info := range actions.GetStrategies() {
+		fmt.Printf("id=%d name=%s\n", info.Id, info.Name)
+		printSchemas(info.Schema, "  ")
+	}
+
+	return nil
+}
+
+func parseRole(name string) (domain.UserRole, error) {
+	role, err := domain.ParseUserRole(name)
+	if err != nil {
+		return 0, fmt.Errorf("bad -role: %w", err)
+	}
+
+	return role, nil
+}
+
+func userAdd(actions *app.Actions, args []string) error {
+	flags := newFlagSet("user add")
+	telegram := flags.Int64("telegram", 0, "telegram chat id")
+	roleName := flags.String("role", "trader", "user role: viewer, trader or admin")
+	err := flags.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	if *telegram == 0 {
+		return errors.New("-telegram is required")
+	}
+
+	role, err := parseRole(*roleName)
+	if err != nil {
+		return err
+	}
+
+	user, err := actions.UserAdd(operator, app.UserLinks{Telegram: *telegram}, role)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("user id: %d role: %s\n", user.Id, domain.UserRoleNames[user.Role])
+	return nil
+}
+
+func userList(actions *app.Actions, args []string) error {
+	users, err := actions.FindUsers(operator)
+	if err != nil {
+		return err
+	}
+
+	for _, user := range users {
+		fmt.Printf("id=%d role=%s name=%q\n", user.Id, domain.UserRoleNames[user.Role], user.Name)
+	}
+
+	return nil
+}
+
+func userRole(actions *app.Actions, args []string) error {
+	flags := newFlagSet("user role")
+	userId := flags.Int64("user", 0, "user id")
+	roleName := flags.String("role", "", "user role: viewer, trader or admin")
+	err := flags.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	role, err := parseRole(*roleName)
+	if err != nil {
+		return err
+	}
+
+	user, err := getUser(actions, *userId)
+	if err != nil {
+		return err
+	}
+
+	return actions.SetUserRole(operator, user.Id, role)
+}
+
+func userInvite(actions *app.Actions, args []string) error {
+	flags := newFlagSet("user invite")
+	roleName := flags.String("role", "viewer", "role of the invited user: viewer, trader or admin")
+	err := flags.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	role, err := parseRole(*roleName)
+	if err != nil {
+		return err
+	}
+
+	invite, err := actions.CreateInvite(operator, role)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("invite code: %s role: %s expires: %s\n", invite.Code, domain.UserRoleNames[invite.Role], invite.Expires.Format(time.RFC3339))
+	return nil
+}
+
+func printProfile(profile app.UserProfile) {
+	fmt.Printf(
+		"id=%d role=%s name=%q timezone=%q quote=%q telegram=%d email=%q\n",
+		profile.User.Id,
+		domain.UserRoleNames[profile.User.Role],
+		profile.User.Name,
+		profile.User.Timezone,
+		profile.User.QuoteAsset,
+		profile.Links.Telegram,
+		profile.Links.Email,
+	)
+}
+
+func userShow(actions *app.Actions, args []string) error {
+	flags := newFlagSet("user show")
+	userId := flags.Int64("user", 0, "user id")
+	err := flags.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	user, err := getUser(actions, *userId)
+	if err != nil {
+		return err
+	}
+
+	profile, err := actions.GetUserProfile(*user)
+	if err != nil {
+		return err
+	}
+
+	printProfile(*profile)
+	return nil
+}
+
+func userProfile(actions *app.Actions, args []string) error {
+	flags := newFlagSet("user profile")
+	userId := flags.Int64("user", 0, "user id")
+	name := flags.String("name", "", "display name")
+	timezone := flags.String("timezone", "", "IANA timezone of reports, e.g. Europe/Berlin")
+	quoteAsset := flags.String("quote", "", "quote asset reports are valued in, e.g. USDT")
+	email := flags.String("email", "", "email to link")
+	err := flags.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	user, err := getUser(actions, *userId)
+	if err != nil {
+		return err
+	}
+
+	profile, err := actions.GetUserProfile(*user)
+	if err != nil {
+		return err
+	}
+
+	// only flags given on the command line change the profile
+	updated := profile.User
+	setProfile := false
+	flags.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "name":
+			updated.Name, setProfile = *name, true
+		case "timezone":
+			updated.Timezone, setProfile = *timezone, true
+		case "quote":
+			updated.QuoteAsset, setProfile = *quoteAsset, true
+		}
+	})
+
+	if setProfile {
+		_, err = actions.UserUpdateProfile(*user, updated.Name, updated.Timezone, updated.QuoteAsset)
+		if err != nil {
+			return err
+		}
+	}
+
+	if *email != "" {
+		err = actions.UserLinkEmail(*user, *email)
+		if err != nil {
+			return err
+		}
+	}
+
+	profile, err = actions.GetUserProfile(*user)
+	if err != nil {
+		return err
+	}
+
+	printProfile(*profile)
+	return nil
+}
+
+func userMerge(actions *app.Actions, args []string) error {
+	flags := newFlagSet("user merge")
+	userId := flags.Int64("user", 0, "user id")
+	otherId := flags.Int64("with", 0, "id of the other account of the same person")
+	err := flags.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	user, err := getUser(actions, *userId)
+	if err != nil {
+		return err
+	}
+
+	if *otherId == 0 {
+		return errors.New("-with is required")
+	}
+
+	other, err := getUser(actions, *otherId)
+	if err != nil {
+		return err
+	}
+
+	merged, err := actions.MergeUsers(operator, user.Id, other.Id)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("merged into user id: %d\n", merged.Id)
+	return nil
+}
+
+func userApiKey(actions *app.Actions, args []string) error {
+	flags := newFlagSet("user apikey")
+	userId := flags.Int64("user", 0, "user id")
+	err := flags.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	user, err := getUser(actions, *userId)
+	if err != nil {
+		return err
+	}
+
+	apiKey, err := actions.UserCreateApiKey(*user)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(apiKey)
+	return nil
+}
+
+// readExchangeData returns the -data or, when given, the -data-file flag value.
+func readExchangeData(data string, dataFile string) ([]byte, error) {
+	if dataFile != "" {
+		return buildStrategyData(nil, dataFile, nil)
+	}
+
+	return []byte(data), nil
+}
+
+func exchangeAdd(actions *app.Actions, args []string) error {
+	flags := newFlagSet("exchange add")
+	userId := flags.Int64("user", 0, "user id")
+	name := flags.String("name", "", "account name, the exchange name by default")
+	number := flags.Int("number", 0, "exchange number")
+	data := flags.String("data", "", "exchange json data")
+	dataFile := flags.String("data-file", "", "exchange json data file")
+	err := flags.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	user, err := getUser(actions, *userId)
+	if err != nil {
+		return err
+	}
+
+	exchangeData, err := readExchangeData(*data, *dataFile)
+	if err != nil {
+		return err
+	}
+
+	if *number == 0 || len(exchangeData) == 0 {
+		return errors.New("-number and -data or -data-file are required")
+	}
+
+	exchange, err := actions.AddExchange(*user, *name, *number, exchangeData)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("exchange id: %d name: %s\n", exchange.Id, exchange.Name)
+	return nil
+}
+
+func printExchangeAccount(account app.ExchangeAccount) {
+	fmt.Printf(
+		"id=%d user=%d name=%q number=%d exchange=%s data=%s agents=%v\n",
+		account.Id,
+		account.UserId,
+		account.Name,
+		account.Number,
+		account.Exchange,
+		account.MaskedData,
+		account.AgentIds,
+	)
+}
+
+func exchangeList(actions *app.Actions, args []string) error {
+	flags := newFlagSet("exchange list")
+	userId := flags.Int64("user", 0, "user id")
+	err := flags.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	user, err := getUser(actions, *userId)
+	if err != nil {
+		return err
+	}
+
+	accounts, err := actions.ListExchanges(*user)
+	if err != nil {
+		return err
+	}
+
+	for _, account := range accounts {
+		printExchangeAccount(account)
+	}
+
+	return nil
+}
+
+func exchangeRename(actions *app.Actions, args []string) error {
+	flags := newFlagSet("exchange rename")
+	userId := flags.Int64("user", 0, "user id")
+	exchangeId := flags.Int("id", 0, "exchange id")
+	name := flags.String("name", "", "new account name")
+	err := flags.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	user, err := getUser(actions, *userId)
+	if err != nil {
+		return err
+	}
+
+	account, err := actions.RenameExchange(*user, *exchangeId, *name)
+	if err != nil {
+		return err
+	}
+
+	printExchangeAccount(*account)
+	return nil
+}
+
+func exchangeRotate(actions *app.Actions, args []string) error {
+	flags := newFlagSet("exchange rotate")
+	userId := flags.Int64("user", 0, "user id")
+	exchangeId := flags.Int("id", 0, "exchange id")
+	data := flags.String("data", "", "exchange json data with the new keys")
+	dataFile := flags.String("data-file", "", "exchange json data file with the new keys")
+	err := flags.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	user, err := getUser(actions, *userId)
+	if err != nil {
+		return err
+	}
+
+	exchangeData, err := readExchangeData(*data, *dataFile)
+	if err != nil {
+		return err
+	}
+
+	if len(exchangeData) == 0 {
+		return errors.New("-data or -data-file is required")
+	}
+
+	account, err := actions.RotateExchangeKeys(*user, *exchangeId, exchangeData)
+	if err != nil {
+		return err
+	}
+
+	printExchangeAccount(*account)
+	return nil
+}
+
+func exchangeRemove(actions *app.Actions, args []string) error {
+	flags := newFlagSet("exchange remove")
+	userId := flags.Int64("user", 0, "user id")
+	exchangeId := flags.Int("id", 0, "exchange id")
+	err := flags.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	user, err := getUser(actions, *userId)
+	if err != nil {
+		return err
+	}
+
+	return actions.RemoveExchange(*user, *exchangeId)
+}
+
+func parseIds(value string) ([]int, error) {
+	ids := []int{}
+	for _, part := range strings.Split(value, ",") {
+		if part == "" {
+			continue
+		}
+
+		id, err := strconv.Atoi(part)
+		if err != nil {
+			return nil, fmt.Errorf("bad id %q", part)
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, nil
+}
+
+func agentCreate(actions *app.Actions, args []string) error {
+	var params paramFlags
+
+	flags := newFlagSet("agent create")
+	userId := flags.Int64("user", 0, "user id")
+	strategyId := flags.Int("strategy", int(domain.SimpleStratedy), "strategy id")
+	exchangeIds := flags.String("exchanges", "", "comma separated exchange ids")
+	file := flags.String("file", "", "strategy parameters JSON or YAML file")
+	flags.Var(&params, "param", "strategy parameter key=value, may be repeated")
+	err := flags.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	user, err := getUser(actions, *userId)
+	if err != nil {
+		return err
+	}
+
+	ids, err := parseIds(*exchangeIds)
+	if err != nil {
+		return err
+	}
+
+	exchanges, err := actions.GetUserExchanges(*user, ids)
+	if err != nil {
+		return err
+	}
+
+	data, err := buildStrategyData(nil, *file, params)
+	if err != nil {
+		return err
+	}
+
+	agent, err := actions.AgentCreate(*user, domain.StrategyId(*strategyId), data, exchanges)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("agent id: %d\n", agent.Id)
+	return nil
+}
+
+func agentList(actions *app.Actions, args []string) error {
+	flags := newFlagSet("agent list")
+	userId := flags.Int64("user", 0, "user id")
+	err := flags.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	user, err := getUser(actions, *userId)
+	if err != nil {
+		return err
+	}
+
+	agents, err := actions.GetUserAgents(*user)
+	if err != nil {
+		return err
+	}
+
+	for _, agent := range agents {
+		strategyName := ""
+		if info := actions.GetAgentInfo(agent); info != nil {
+			strategyName = info.StrategyName
+		}
+
+		fmt.Printf("id=%d status=%s dry_run=%t strategy=%s\n", agent.Id, domain.AgentStatusNames[agent.Status], agent.DryRun, strategyName)
+	}
+
+	return nil
+}
+
+func agentFlags(name string) (*flag.FlagSet, *int64, *int64) {
+	flags := newFlagSet(name)
+	userId := flags.Int64("user", 0, "user id")
+	agentId := flags.Int64("id", 0, "agent id")
+	return flags, userId, agentId
+}
+
+func agentShow(actions *app.Actions, args []string) error {
+	flags, userId, agentId := agentFlags("agent show")
+	err := flags.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	_, agent, err := getUserAgent(actions, *userId, *agentId)
+	if err != nil {
+		return err
+	}
+
+	info := actions.GetAgentInfo(*agent)
+	if info == nil {
+		return fmt.Errorf("agent(id=%d) has bad strategy data", agent.Id)
+	}
+
+	fmt.Printf("Name: %s\nStatus: %s\nDry run: %t\nExchanges: %s\nStrategy: %s\n",
+		info.Name,
+		domain.AgentStatusNames[agent.Status],
+		agent.DryRun,
+		strings.Join(info.Exchanges, ","),
+		info.StrategyName,
+	)
+	for _, param := range info.Parameters {
+		fmt.Printf("  %s: %s\n", param.Name, domain.FormatParameter(param))
+	}
+	fmt.Printf("Data: %s\n", agent.StrategyData)
+
+	return nil
+}
+
+func agentSetStatus(name string, status domain.AgentStatus) command {
+	return func(actions *app.Actions, args []string) error {
+		flags, userId, agentId := agentFlags(name)
+		err := flags.Parse(args)
+		if err != nil {
+			return err
+		}
+
+		user, agent, err := getUserAgent(actions, *userId, *agentId)
+		if err != nil {
+			return err
+		}
+
+		return actions.AgentSetStatus(*user, agent, status)
+	}
+}
+
+var agentActivate = agentSetStatus("agent activate", domain.ActiveAgentStatus)
+var agentDisable = agentSetStatus("agent disable", domain.DisableAgentStatus)
+
+func agentDryRun(actions *app.Actions, args []string) error {
+	flags, userId, agentId := agentFlags("agent dryrun")
+	off := flags.Bool("off", false, "trade on the real exchange again")
+	err := flags.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	user, agent, err := getUserAgent(actions, *userId, *agentId)
+	if err != nil {
+		return err
+	}
+
+	return actions.AgentSetDryRun(*user, agent, !*off)
+}
+
+func agentUpdate(actions *app.Actions, args []string) error {
+	var params paramFlags
+
+	flags, userId, agentId := agentFlags("agent update")
+	file := flags.String("file", "", "strategy parameters JSON or YAML file")
+	flags.Var(&params, "param", "strategy parameter key=value, may be repeated")
+	err := flags.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	user, agent, err := getUserAgent(actions, *userId, *agentId)
+	if err != nil {
+		return err
+	}
+
+	data, err := buildStrategyData(agent.StrategyData, *file, params)
+	if err != nil {
+		return err
+	}
+
+	return actions.AgentUpdateData(*user, agent, data)
+}
+
+func agentHistory(actions *app.Actions, args []string) error {
+	flags, userId, agentId := agentFlags("agent history")
+	err := flags.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	user, agent, err := getUserAgent(actions, *userId, *agentId)
+	if err != nil {
+		return err
+	}
+
+	changes, err := actions.GetAgentDataHistory(*user, agent.Id)
+	if err != nil {
+		return err
+	}
+
+	location := app.UserLocation(*user)
+	for _, change := range changes {
+		fmt.Printf("id=%d user=%d datetime=%s data=%s\n", change.Id, change.UserId, change.Datetime.In(location).Format(time.RFC3339), change.Data)
+	}
+
+	return nil
+}
+
+func agentRollback(actions *app.Actions, args []string) error {
+	flags, userId, agentId := agentFlags("agent rollback")
+	changeId := flags.Int64("change", 0, "history change id")
+	err := flags.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	user, agent, err := getUserAgent(actions, *userId, *agentId)
+	if err != nil {
+		return err
+	}
+
+	_, err = actions.AgentRollbackData(*user, agent.Id, *changeId)
+	return err
+}
+
+func agentShare(actions *app.Actions, args []string) error {
+	flags, userId, agentId := agentFlags("agent share")
+	withId := flags.Int64("with", 0, "id of the user to share with")
+	roleName := flags.String("role", "viewer", "role on the agent: viewer or trader")
+	err := flags.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	role, err := parseRole(*roleName)
+	if err != nil {
+		return err
+	}
+
+	user, err := getUser(actions, *userId)
+	if err != nil {
+		return err
+	}
+
+	return actions.ShareAgent(*user, *agentId, *withId, role)
+}
+
+func agentUnshare(actions *app.Actions, args []string) error {
+	flags, userId, agentId := agentFlags("agent unshare")
+	withId := flags.Int64("with", 0, "id of the user to stop sharing with")
+	err := flags.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	user, err := getUser(actions, *userId)
+	if err != nil {
+		return err
+	}
+
+	return actions.UnshareAgent(*user, *agentId, *withId)
+}
+
+func agentShares(actions *app.Actions, args []string) error {
+	flags, userId, agentId := agentFlags("agent shares")
+	err := flags.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	user, err := getUser(actions, *userId)
+	if err != nil {
+		return err
+	}
+
+	shares, err := actions.GetAgentShares(*user, *agentId)
+	if err != nil {
+		return err
+	}
+
+	for _, share := range shares {
+		fmt.Printf("user=%d role=%s\n", share.UserId, domain.UserRoleNames[share.Role])
+	}
+
+	return nil
+}
+
+func tradesList(actions *app.Actions, args []string) error {
+	flags := newFlagSet("trades list")
+	userId := flags.Int64("user", 0, "user id")
+	agentId := flags.Int64("agent", 0, "agent id")
+	statuses := flags.String("status", "", "comma separated statuses: buy,sell,finish")
+	err := flags.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	_, agent, err := getUserAgent(actions, *userId, *agentId)
+	if err != nil {
+		return err
+	}
+
+	simpleStorage, ok := actions.GetAgentStorage(*agent).(domain.SimpleStorage)
+	if !ok {
+		return fmt.Errorf("trades are not supported for agent(id=%d) strategy", agent.Id)
+	}
+
+	filter := domain.SimpleTradeFilter{}
+	for _, name := range strings.Split(*statuses, ",") {
+		if name == "" {
+			continue
+		}
+
+		found := false
+		for status, statusName := range domain.SimpleTradeStatusNames {
+			if statusName == name {
+				filter.Statuses = append(filter.Statuses, status)
+				found = true
+			}
+		}
+
+		if !found {
+			return fmt.Errorf("unknown trade status %q", name)
+		}
+	}
+
+	trades, err := simpleStorage.GetTrades(&filter)
+	if err != nil {
+		return err
+	}
+
+	for _, trade := range trades {
+		fmt.Printf(
+			"id=%d pair=%s status=%s amount=%s buy=(%s %s @ %s) sell=(%s %s @ %s)\n",
+			trade.Id,
+			trade.Pair,
+			domain.SimpleTradeStatusNames[trade.Status],
+			trade.Amount,
+			trade.Buy.Datetime,
+			trade.Buy.OrderId,
+			trade.Buy.Price,
+			trade.Sell.Datetime,
+			trade.Sell.OrderId,
+			trade.Sell.Price,
+		)
+	}
+
+	return nil
+}
+
+func tradesResolve(actions *app.Actions, args []string) error {
+	flags := newFlagSet("trades resolve")
+	userId := flags.Int64("user", 0, "user id")
+	agentId := flags.Int64("agent", 0, "agent id")
+	tradeId := flags.Int("trade", 0, "trade id")
+	side := flags.String("side", "", "trade side waiting for its order: buy or sell")
+	orderId := flags.String("order", "", "exchange order id placed for the side, none when it wasn't placed")
+	err := flags.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	if *side != domain.BuyOrderSide && *side != domain.SellOrderSide {
+		return fmt.Errorf("bad -side %q", *side)
+	}
+
+	user, err := getUser(actions, *userId)
+	if err != nil {
+		return err
+	}
+
+	trade, err := actions.ResolveTrade(*user, *agentId, *tradeId, *side, *orderId)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("trade id=%d %s order=%q\n", trade.Id, *side, *orderId)
+	return nil
+}
+
+func riskShow(actions *app.Actions, args []string) error {
+	flags := newFlagSet("risk show")
+	userId := flags.Int64("user", 0, "user id")
+	agentId := flags.Int64("agent", 0, "agent id, user limits without it")
+	err := flags.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	user, err := getUser(actions, *userId)
+	if err != nil {
+		return err
+	}
+
+	limits, err := actions.GetRiskLimits(*user, *agentId)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("max_quote_allocation=%s max_orders_per_hour=%d max_daily_loss=%s\n",
+		limits.MaxQuoteAllocation,
+		limits.MaxOrdersPerHour,
+		limits.MaxDailyLoss,
+	)
+	for asset, amount := range limits.MaxExposure {
+		fmt.Printf("max_exposure %s=%s\n", asset, amount)
+	}
+
+	return nil
+}
+
+func riskSet(actions *app.Actions, args []string) error {
+	flags := newFlagSet("risk set")
+	userId := flags.Int64("user", 0, "user id")
+	agentId := flags.Int64("agent", 0, "agent id, user limits without it")
+	maxAllocation := flags.String("max-allocation", "0", "max quote allocation of an agent, 0 for no limit")
+	maxOrders := flags.Int("max-orders-hour", 0, "max orders per hour of an agent, 0 for no limit")
+	maxLoss := flags.String("max-daily-loss", "0", "max daily loss of an agent in quote, 0 for no limit")
+	maxExposure := flags.String("max-exposure", "", "comma separated ASSET=AMOUNT, user limits only, empty for no limit")
+	err := flags.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	user, err := getUser(actions, *userId)
+	if err != nil {
+		return err
+	}
+
+	// limits without a flag keep their stored values
+	limits, err := actions.GetRiskLimits(*user, *agentId)
+	if err != nil {
+		return err
+	}
+
+	set := map[string]bool{}
+	flags.Visit(func(f *flag.Flag) { set[f.Name] = true })
+
+	if set["max-orders-hour"] {
+		limits.MaxOrdersPerHour = *maxOrders
+	}
+
+	if set["max-allocation"] {
+		limits.MaxQuoteAllocation, err = decimal.NewFromString(*maxAllocation)
+		if err != nil {
+			return fmt.Errorf("bad -max-allocation: %w", err)
+		}
+	}
+
+	if set["max-daily-loss"] {
+		limits.MaxDailyLoss, err = decimal.NewFromString(*maxLoss)
+		if err != nil {
+			return fmt.Errorf("bad -max-daily-loss: %w", err)
+		}
+	}
+
+	if set["max-exposure"] {
+		limits.MaxExposure = nil
+		if *maxExposure != "" {
+			limits.MaxExposure = map[string]decimal.Decimal{}
+		}
+		for _, item := range strings.Split(*maxExposure, ",") {
+			if item == "" {
+				continue
+			}
+
+			parts := strings.SplitN(item, "=", 2)
+			if len(parts) != 2 {
+				return fmt.Errorf("bad -max-exposure item %q", item)
+			}
+
+			amount, err := decimal.NewFromString(parts[1])
+			if err != nil {
+				return fmt.Errorf("bad -max-exposure item %q: %w", item, err)
+			}
+
+			limits.MaxExposure[strings.ToUpper(parts[0])] = amount
+		}
+	}
+
+	return actions.SetRiskLimits(*user, *agentId, *limits)
+}
+
+func agentPanic(actions *app.Actions, args []string) error {
+	flags := newFlagSet("agent panic")
+	userId := flags.Int64("user", 0, "user id")
+	global := flags.Bool("all", false, "halt agents of every user")
+	sell := flags.Bool("sell", false, "sell positions of open agent trades")
+	err := flags.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	user, err := getUser(actions, *userId)
+	if err != nil {
+		return err
+	}
+
+	// the cli works with the database directly, so its operator is an admin
+	if *global {
+		user.Role = domain.AdminUserRole
+	}
+
+	report, err := actions.Panic(*user, app.PanicOptions{Global: *global, SellPositions: *sell})
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(report.Summary())
+	for _, balance := range report.Sold {
+		fmt.Printf("sold %s %s\n", balance.Amount, balance.Asset)
+	}
+	for _, message := range report.Errors {
+		fmt.Println("error:", message)
+	}
+
+	return nil
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/joho/godotenv"
+	"github.com/scientistnik/invest-agents/internal/app"
+	"github.com/scientistnik/invest-agents/internal/config"
+	"github.com/scientistnik/invest-agents/internal/exchanges"
+	"github.com/scientistnik/invest-agents/internal/loggers"
+	"github.com/scientistnik/invest-agents/internal/storage"
+)
+
+const usage = `Usage: cli [-config FILE] [-db DSN] <command> <subcommand> [flags]
+
+Commands:
+  strategy list
+  user add -telegram ID [-role viewer|trader|admin]
+  user list
+  user role -user ID -role viewer|trader|admin
+  user invite [-role viewer|trader|admin]
+  user apikey -user ID
+  user show -user ID
+  user profile -user ID [-name NAME] [-timezone TZ] [-quote ASSET] [-email EMAIL]
+  user merge -user ID -with ID
+  exchange add -user ID [-name NAME] -number N (-data JSON | -data-file FILE)
+  exchange list -user ID
+  exchange rename -user ID -id N -name NAME
+  exchange rotate -user ID -id N (-data JSON | -data-file FILE)
+  exchange remove -user ID -id N
+  agent create -user ID -strategy N -exchanges ID[,ID] [-file FILE] [-param key=value ...]
+  agent list -user ID
+  agent show -user ID -id N
+  agent activate -user ID -id N
+  agent disable -user ID -id N
+  agent dryrun -user ID -id N [-off]
+  agent update -user ID -id N [-file FILE] [-param key=value ...]
+  agent history -user ID -id N
+  agent rollback -user ID -id N -change N
+  agent share -user ID -id N -with ID [-role viewer|trader]
+  agent unshare -user ID -id N -with ID
+  agent shares -user ID -id N
+  agent panic -user ID [-all] [-sell]
+  trades list -user ID -agent N [-status buy,sell,finish]
+  trades resolve -user ID -agent N -trade N -side buy|sell [-order ID]
+  risk show -user ID [-agent N]
+  risk set -user ID [-agent N] [-max-allocation X] [-max-orders-hour N] [-max-daily-loss X] [-max-exposure ASSET=X,...]
+
+The storage is read from the config file and STORAGE_DSN like the service
+does, -db overrides it.
+
+Commands with -user act as that user with its role, user commands and the
+agent panic -all run as an admin.
+
+A trade whose order isn't found by its client order id waits for trades
+resolve: -order is the order placed for it, without -order the strategy
+places the order again.
+
+Strategy parameters are read from a JSON or YAML file and may be overridden
+with -param, where value is parsed as JSON and falls back to a plain string.
+`
+
+type command func(actions *app.Actions, args []string) error
+
+var commands = map[string]command{
+	"strategy list":   strategyList,
+	"user add":        userAdd,
+	"user list":       userList,
+	"user role":       userRole,
+	"user invite":     userInvite,
+	"user apikey":     userApiKey,
+	"user show":       userShow,
+	"user profile":    userProfile,
+	"user merge":      userMerge,
+	"exchange add":    exchangeAdd,
+	"exchange list":   exchangeList,
+	"exchange rename": exchangeRename,
+	"exchange rotate": exchangeRotate,
+	"exchange remove": exchangeRemove,
+	"agent create":    agentCreate,
+	"agent list":      agentList,
+	"agent show":      agentShow,
+	"agent activate":  agentActivate,
+	"agent disable":   agentDisable,
+	"agent dryrun":    agentDryRun,
+	"agent update":    agentUpdate,
+	"agent history":   agentHistory,
+	"agent rollback":  agentRollback,
+	"agent share":     agentShare,
+	"agent unshare":   agentUnshare,
+	"agent shares":    agentShares,
+	"agent panic":     agentPanic,
+	"trades list":     tradesList,
+	"trades resolve":  tradesResolve,
+	"risk show":       riskShow,
+	"risk set":        riskSet,
+}
+
+func main() {
+	godotenv.Load()
+
+	flags := flag.NewFlagSet("cli", flag.ExitOnError)
+	flags.Usage = func() { fmt.Fprint(os.Stderr, usage) }
+	configFile := flags.String("config", os.Getenv("CONFIG_FILE"), "path to YAML config file")
+	dsn := flags.String("db", "", "storage DSN")
+	flags.Parse(os.Args[1:])
+
+	args := flags.Args()
+	if len(args) < 2 {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	cmd, ok := commands[args[0]+" "+args[1]]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n", strings.Join(args[:2], " "))
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	storageConfig, err := config.LoadStorage(*configFile)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	flags.Visit(func(f *flag.Flag) {
+		if f.Name == "db" {
+			storageConfig.Dsn = *dsn
+		}
+	})
+
+	appStorage, err := storage.GetSqliteAppStorage(storageConfig.Dsn)
+	if err != nil {
+		fmt.Println("error in creation", err)
+		os.Exit(1)
+	}
+
+	err = appStorage.Connect()
+	if err != nil {
+		fmt.Println("error in connection", err)
+		os.Exit(1)
+	}
+
+	defer appStorage.Disconnect()
+
+	actions := app.GetAppActions(appStorage, exchanges.AppExchange{}, loggers.ConstructorConsoleLogger{Color: true})
+
+	err = cmd(actions, args[2:])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "error:", err)
+		appStorage.Disconnect()
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/scientistnik/invest-agents/internal/app/domain"
+	"gopkg.in/yaml.v3"
+)
+
+type paramFlags []string
+
+func (p *paramFlags) String() string {
+	return strings.Join(*p, ",")
+}
+
+func (p *paramFlags) Set(value string) error {
+	if !strings.Contains(value, "=") {
+		return fmt.Errorf("parameter %q must look like key=value", value)
+	}
+
+	*p = append(*p, value)
+	return nil
+}
+
+func readDataFile(filename string) (map[string]interface{}, error) {
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	data := map[string]interface{}{}
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(content, &data)
+	default:
+		err = json.Unmarshal(content, &data)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("parse %s: %w", filename, err)
+	}
+
+	return data, nil
+}
+
+// buildStrategyData merges the file and -param values over base strategy json.
+func buildStrategyData(base []byte, filename string, params paramFlags) ([]byte, error) {
+	data := map[string]interface{}{}
+	if len(base) > 0 {
+		err := json.Unmarshal(base, &data)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if filename != "" {
+		fileData, err := readDataFile(filename)
+		if err != nil {
+			return nil, err
+		}
+
+		for key, value := range fileData {
+			data[key] = value
+		}
+	}
+
+	for _, param := range params {
+		parts := strings.SplitN(param, "=", 2)
+
+		var value interface{}
+		err := json.Unmarshal([]byte(parts[1]), &value)
+		if err != nil {
+			value = parts[1]
+		}
+
+		data[parts[0]] = value
+	}
+
+	return json.Marshal(data)
+}
+
+// describeSchema renders a strategy parameter schema as one line.
+func describeSchema(schema domain.ParameterSchema) string {
+	text := fmt.Sprintf("%s (%s", schema.Key, domain.StrategyParameterTypeNames[schema.Type])
+	if schema.Required {
+		text += ", required"
+	}
+	if schema.Min != nil {
+		text += ", min " + schema.Min.String()
+	}
+	if schema.Max != nil {
+		text += ", max " + schema.Max.String()
+	}
+	if len(schema.Choices) > 0 {
+		text += ", one of " + strings.Join(schema.Choices, "|")
+	}
+	if schema.Default != nil {
+		text += fmt.Sprintf(", default %v", schema.Default)
+	}
+
+	return text + "): " + schema.Description
+}
+
+// printSchemas prints parameters one per line, list items are nested under
+// their list.
+func printSchemas(schemas []domain.ParameterSchema, indent string) {
+	for _, schema := range schemas {
+		fmt.Printf("%s%s\n", indent, describeSchema(schema))
+		printSchemas(schema.Items, indent+"  ")
+	}
+}
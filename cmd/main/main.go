@@ -2,31 +2,74 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"github.com/joho/godotenv"
 	"github.com/scientistnik/invest-agents/internal/app"
+	"github.com/scientistnik/invest-agents/internal/app/domain"
+	"github.com/scientistnik/invest-agents/internal/config"
 	"github.com/scientistnik/invest-agents/internal/exchanges"
+	"github.com/scientistnik/invest-agents/internal/httpapi"
 	"github.com/scientistnik/invest-agents/internal/loggers"
+	"github.com/scientistnik/invest-agents/internal/metrics"
 	"github.com/scientistnik/invest-agents/internal/storage"
 	"github.com/scientistnik/invest-agents/internal/telegram"
+	"io"
+	"io/fs"
+	"net/http"
 	"os"
 	"os/signal"
 	"sync"
+	"syscall"
 )
 
+func getLogger(cfg config.LoggerConfig) (domain.LoggerRepo, error) {
+	level, err := domain.ParseLogLevel(cfg.Level)
+	if err != nil {
+		return nil, err
+	}
+
+	if cfg.Format != "json" {
+		return loggers.ConstructorConsoleLogger{Color: cfg.Color, Level: level}, nil
+	}
+
+	agentLevels := map[int64]domain.LogLevel{}
+	for agentId, agentLevel := range cfg.AgentLevels {
+		agentLevels[agentId], err = domain.ParseLogLevel(agentLevel)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var writer io.Writer = os.Stdout
+	if cfg.File != "" {
+		file, err := loggers.OpenRotateFile(cfg.File, int64(cfg.MaxSizeMb)*1024*1024, cfg.MaxBackups)
+		if err != nil {
+			return nil, err
+		}
+		writer = file
+	}
+
+	return loggers.NewConstructorJsonLogger(writer, level, agentLevels), nil
+}
+
 func main() {
 	ctx, cancel := context.WithCancel(context.Background())
 	var wg sync.WaitGroup
 
 	err := godotenv.Load()
-	if err != nil {
-		fmt.Println("Error loading .env file")
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		fmt.Println("Error loading .env file", err)
 		return
 	}
 
-	telegramToken := os.Getenv("TELEGRAM_TOKEN")
+	cfg, err := config.Load(os.Args[1:])
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(2)
+	}
 
-	appStorage, err := storage.GetSqliteAppStorage("database.db")
+	appStorage, err := storage.GetSqliteAppStorage(cfg.Storage.Dsn)
 	if err != nil {
 		fmt.Println("error in creation", err)
 		return
@@ -40,30 +83,134 @@ func main() {
 
 	defer appStorage.Disconnect()
 
-	actions := app.GetAppActions(appStorage, exchanges.AppExchange{}, loggers.ConstructorConsoleLogger{Color: true})
+	logger, err := getLogger(cfg.Logger)
+	if err != nil {
+		fmt.Println("error in logger", err)
+		return
+	}
 
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
+	limiter := domain.NewExchangeLimiter(domain.ExchangeLimits{
+		Rate:       cfg.Exchanges.RateLimit,
+		Burst:      cfg.Exchanges.Burst,
+		Retries:    cfg.Exchanges.Retries,
+		RetryDelay: cfg.Exchanges.RetryDelay,
+	})
+
+	actions := app.GetAppActions(appStorage, exchanges.AppExchange{Limiter: limiter, Streams: cfg.Features.ExchangeStreams}, logger)
+	actions.SetAgentsSettings(domain.AgentsSettings{
+		Interval:        cfg.Agents.Interval,
+		ShutdownTimeout: cfg.Agents.ShutdownTimeout,
+		PriceMaxAge:     cfg.Agents.PriceMaxAge,
+	})
+	actions.SetAccessSettings(app.AccessSettings{
+		Registration: app.RegistrationMode(cfg.Access.Registration),
+		Allowlist:    cfg.Access.Allowlist,
+	})
+
+	err = actions.PromoteAdmins(cfg.Admins)
+	if err != nil {
+		fmt.Println("error in admins", err)
+		return
+	}
 
-		err = actions.StartAgents(ctx)
+	if cfg.Features.AgentLogs {
+		// the level is checked by the config validation
+		minLevel, _ := domain.ParseLogLevel(cfg.Features.AgentLogsLevel)
+		actions.SetAgentLogLimits(app.AgentLogLimits{
+			MaxCount: cfg.Features.AgentLogsMaxCount,
+			MaxAge:   cfg.Features.AgentLogsMaxAge,
+			MinLevel: minLevel,
+		})
+	} else {
+		actions.DisableAgentLogs()
+	}
+
+	if cfg.Telegram.Enabled {
+		notifier, err := telegram.NewNotifier(cfg.Telegram.Token)
 		if err != nil {
-			fmt.Printf("\n%#v\n", err)
+			fmt.Println("notifications are off:", err)
+		} else {
+			actions.SetNotifier(notifier)
 		}
-	}()
+	}
+
+	if cfg.Metrics.Enabled {
+		registry := metrics.NewRegistry()
+		actions.SetMetrics(metrics.ConstructorAgentMetrics{Registry: registry})
+
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", registry)
+		server := &http.Server{Addr: cfg.Metrics.Addr, Handler: mux}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			err := server.ListenAndServe()
+			if err != nil && err != http.ErrServerClosed {
+				fmt.Println("metrics server error", err)
+			}
+		}()
+
+		go func() {
+			<-ctx.Done()
+			server.Close()
+		}()
+	}
 
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
-		telegram.Start(ctx, telegramToken, actions)
+
+		err := actions.StartAgents(ctx)
+		if err != nil {
+			fmt.Println("agents error:", err)
+		}
 	}()
 
+	if cfg.Telegram.Enabled {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			err := telegram.Start(ctx, cfg.Telegram.Token, actions)
+			if err != nil {
+				fmt.Println(err)
+			}
+		}()
+	}
+
+	if cfg.Http.Enabled {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			err := httpapi.Start(ctx, cfg.Http.Addr, actions)
+			if err != nil {
+				fmt.Println(err)
+			}
+		}()
+	}
+
 	cancelCannel := make(chan os.Signal, 1)
-	signal.Notify(cancelCannel, os.Interrupt)
+	signal.Notify(cancelCannel, os.Interrupt, syscall.SIGTERM)
 
 	<-cancelCannel
+	fmt.Println("shutting down, waiting for agents to finish their cycles...")
 
 	cancel()
 
-	wg.Wait()
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-cancelCannel:
+		fmt.Println("forced shutdown, in-flight exchange operations may be lost")
+		appStorage.Disconnect()
+		os.Exit(1)
+	}
 }
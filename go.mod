@@ -11,6 +11,7 @@ require (
 	github.com/rubenv/sql-migrate v1.1.1
 	github.com/scientistnik/currency.com v0.5.1
 	github.com/shopspring/decimal v1.3.1
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
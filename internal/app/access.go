@@ -0,0 +1,348 @@
+package app
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/scientistnik/invest-agents/internal/app/domain"
+)
+
+var ErrForbidden = errors.New("forbidden")
+var ErrNotRegistered = errors.New("not registered")
+var ErrBadInvite = errors.New("invite code is unknown, used or expired")
+var ErrUserNotFound = errors.New("user not found")
+
+type RegistrationMode string
+
+const (
+	// OpenRegistration registers anyone as a trader.
+	OpenRegistration RegistrationMode = "open"
+	// InviteRegistration registers allowlisted Telegram chats as traders and
+	// others only with an invite code.
+	InviteRegistration RegistrationMode = "invite"
+)
+
+type AccessSettings struct {
+	Registration RegistrationMode
+	// Allowlist are Telegram chat ids registered without an invite.
+	Allowlist []int64
+}
+
+var DefaultAccessSettings = AccessSettings{Registration: InviteRegistration}
+
+// inviteTtl is how long an invite code may be redeemed.
+const inviteTtl = 7 * 24 * time.Hour
+
+// Invite registers one new user with the role.
+type Invite struct {
+	Code      string
+	Role      domain.UserRole
+	CreatedBy int64
+	Expires   time.Time
+}
+
+// AgentShare gives a user other than the owner access to the agent, Role is
+// ViewerUserRole or TraderUserRole.
+type AgentShare struct {
+	AgentId int64
+	UserId  int64
+	Role    domain.UserRole
+}
+
+type AgentShareFilter struct {
+	AgentId int64
+	UserId  int64
+}
+
+func (a *Actions) SetAccessSettings(settings AccessSettings) {
+	a.access = settings
+}
+
+// UserFind returns the registered user with the links, nil when there is none.
+func (a Actions) UserFind(links UserLinks) (*domain.User, error) {
+	return a.storage.UserFind(links)
+}
+
+// UserRegister returns the user with the links. A link code adds the links to
+// the account that created it. A new user is registered when the registration
+// mode, the allowlist or the invite code allows it, otherwise ErrNotRegistered
+// or ErrBadInvite is returned.
+func (a Actions) UserRegister(links UserLinks, code string) (*domain.User, error) {
+	user, err := a.storage.UserFind(links)
+	if err != nil || user != nil {
+		return user, err
+	}
+
+	if code != "" {
+		user, err = a.linkNewIdentity(links, code)
+		if err != nil || user != nil {
+			return user, err
+		}
+	}
+
+	role := domain.TraderUserRole
+	switch {
+	case a.access.Registration == OpenRegistration:
+	case links.Telegram != 0 && containsId(a.access.Allowlist, links.Telegram):
+	case code != "":
+		invite, err := a.storage.UseInvite(code, time.Now())
+		if err != nil {
+			return nil, err
+		}
+
+		if invite == nil {
+			return nil, ErrBadInvite
+		}
+
+		role = invite.Role
+	default:
+		return nil, ErrNotRegistered
+	}
+
+	return a.storage.UserCreate(links, role)
+}
+
+// linkNewIdentity adds the links to the owner of the link code, it returns nil
+// when the code is no link code.
+func (a Actions) linkNewIdentity(links UserLinks, code string) (*domain.User, error) {
+	linkCode, err := a.storage.UseLinkCode(code, time.Now())
+	if err != nil || linkCode == nil {
+		return nil, err
+	}
+
+	err = a.storage.UserAddLinks(linkCode.UserId, links)
+	if err != nil {
+		return nil, err
+	}
+
+	return a.storage.UserGet(linkCode.UserId)
+}
+
+// UserAdd registers the user bypassing the registration rules, an existing
+// user is returned as is.
+func (a Actions) UserAdd(caller domain.User, links UserLinks, role domain.UserRole) (*domain.User, error) {
+	if caller.Role != domain.AdminUserRole {
+		return nil, ErrForbidden
+	}
+
+	if _, ok := domain.UserRoleNames[role]; !ok {
+		return nil, ValidationError{Message: fmt.Sprintf("bad user role %d", role)}
+	}
+
+	user, err := a.storage.UserFind(links)
+	if err != nil || user != nil {
+		return user, err
+	}
+
+	return a.storage.UserCreate(links, role)
+}
+
+func (a Actions) FindUsers(caller domain.User) ([]domain.User, error) {
+	if caller.Role != domain.AdminUserRole {
+		return nil, ErrForbidden
+	}
+
+	return a.storage.FindUsers()
+}
+
+func (a Actions) SetUserRole(caller domain.User, userId int64, role domain.UserRole) error {
+	if caller.Role != domain.AdminUserRole {
+		return ErrForbidden
+	}
+
+	if _, ok := domain.UserRoleNames[role]; !ok {
+		return ValidationError{Message: fmt.Sprintf("bad user role %d", role)}
+	}
+
+	// the last admin could lock everyone out
+	if caller.Id == userId && role != domain.AdminUserRole {
+		return ValidationError{Message: "admins can't drop their own role"}
+	}
+
+	user, err := a.storage.UserGet(userId)
+	if err != nil {
+		return err
+	}
+
+	if user == nil {
+		return fmt.Errorf("user(id=%d): %w", userId, ErrUserNotFound)
+	}
+
+	return a.storage.UserSetRole(user.Id, role)
+}
+
+// PromoteAdmins gives the admin role to users from the config.
+func (a Actions) PromoteAdmins(userIds []int64) error {
+	for _, userId := range userIds {
+		err := a.SetUserRole(domain.User{Role: domain.AdminUserRole}, userId, domain.AdminUserRole)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// CreateInvite returns a one-time code that registers a new user with the role.
+func (a Actions) CreateInvite(caller domain.User, role domain.UserRole) (*Invite, error) {
+	if caller.Role != domain.AdminUserRole {
+		return nil, ErrForbidden
+	}
+
+	if _, ok := domain.UserRoleNames[role]; !ok {
+		return nil, ValidationError{Message: fmt.Sprintf("bad user role %d", role)}
+	}
+
+	code := make([]byte, 12)
+	_, err := rand.Read(code)
+	if err != nil {
+		return nil, err
+	}
+
+	invite := Invite{Code: hex.EncodeToString(code), Role: role, CreatedBy: caller.Id, Expires: time.Now().Add(inviteTtl)}
+	err = a.storage.AddInvite(invite)
+	if err != nil {
+		return nil, err
+	}
+
+	return &invite, nil
+}
+
+// AgentRole returns the role of the user on the agent, 0 without access. A
+// share never gives more than the role of the user itself.
+func (a Actions) AgentRole(user domain.User, agent domain.Agent) (domain.UserRole, error) {
+	if user.Role == domain.AdminUserRole || agent.UserId == user.Id {
+		return user.Role, nil
+	}
+
+	shares, err := a.storage.FindAgentShares(AgentShareFilter{AgentId: agent.Id, UserId: user.Id})
+	if err != nil {
+		return 0, err
+	}
+
+	if len(shares) == 0 {
+		return 0, nil
+	}
+
+	if shares[0].Role < user.Role {
+		return shares[0].Role, nil
+	}
+
+	return user.Role, nil
+}
+
+// checkAgentRole returns ErrForbidden when the user has less than the role on
+// the agent.
+func (a Actions) checkAgentRole(user domain.User, agent domain.Agent, role domain.UserRole) error {
+	agentRole, err := a.AgentRole(user, agent)
+	if err != nil {
+		return err
+	}
+
+	if agentRole < role {
+		return ErrForbidden
+	}
+
+	return nil
+}
+
+// getAgent returns the agent when the user has at least the role on it,
+// agents the user can't see are not found.
+func (a Actions) getAgent(user domain.User, agentId int64, role domain.UserRole) (*domain.Agent, error) {
+	agents, err := a.storage.FindAgents(AgentFilter{Id: agentId})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(agents) == 0 {
+		return nil, ErrAgentNotFound
+	}
+
+	agentRole, err := a.AgentRole(user, agents[0])
+	if err != nil {
+		return nil, err
+	}
+
+	if agentRole == 0 {
+		return nil, ErrAgentNotFound
+	}
+
+	if agentRole < role {
+		return nil, ErrForbidden
+	}
+
+	return &agents[0], nil
+}
+
+// getOwnAgent returns the agent when the user owns it or is an admin.
+func (a Actions) getOwnAgent(user domain.User, agentId int64) (*domain.Agent, error) {
+	agent, err := a.getAgent(user, agentId, domain.ViewerUserRole)
+	if err != nil {
+		return nil, err
+	}
+
+	if agent.UserId != user.Id && user.Role != domain.AdminUserRole {
+		return nil, ErrForbidden
+	}
+
+	return agent, nil
+}
+
+func (a Actions) GetAgentShares(user domain.User, agentId int64) ([]AgentShare, error) {
+	agent, err := a.getOwnAgent(user, agentId)
+	if err != nil {
+		return nil, err
+	}
+
+	return a.storage.FindAgentShares(AgentShareFilter{AgentId: agent.Id})
+}
+
+// ShareAgent gives another user access to the agent or changes the role of an
+// existing share. Only owners and admins share agents.
+func (a Actions) ShareAgent(user domain.User, agentId int64, userId int64, role domain.UserRole) error {
+	agent, err := a.getOwnAgent(user, agentId)
+	if err != nil {
+		return err
+	}
+
+	if role != domain.ViewerUserRole && role != domain.TraderUserRole {
+		return ValidationError{Message: "agents are shared with the viewer or the trader role"}
+	}
+
+	if userId == agent.UserId {
+		return ValidationError{Message: "the agent owner needs no share"}
+	}
+
+	target, err := a.storage.UserGet(userId)
+	if err != nil {
+		return err
+	}
+
+	if target == nil {
+		return fmt.Errorf("user(id=%d): %w", userId, ErrUserNotFound)
+	}
+
+	return a.storage.SetAgentShare(AgentShare{AgentId: agent.Id, UserId: target.Id, Role: role})
+}
+
+func (a Actions) UnshareAgent(user domain.User, agentId int64, userId int64) error {
+	agent, err := a.getOwnAgent(user, agentId)
+	if err != nil {
+		return err
+	}
+
+	return a.storage.RemoveAgentShare(agent.Id, userId)
+}
+
+func containsId(ids []int64, id int64) bool {
+	for _, item := range ids {
+		if item == id {
+			return true
+		}
+	}
+
+	return false
+}
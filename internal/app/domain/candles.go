@@ -0,0 +1,114 @@
+package domain
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// candlePages limits requests of one GetCandles call, a backfill that needs
+// more continues on the next call.
+const candlePages = 20
+
+func candleExchanges(exchanges []Exchange, repo CandleRepo) []Exchange {
+	stored := []Exchange{}
+	for _, exchange := range exchanges {
+		stored = append(stored, &candleExchange{Exchange: exchange, repo: repo, now: time.Now})
+	}
+
+	return stored
+}
+
+// candleExchange serves candles from the repo. Candles before the kept ones
+// are backfilled, newer ones are asked from the last kept candle, which may
+// have been still open when it was saved.
+type candleExchange struct {
+	Exchange
+	repo CandleRepo
+	now  func() time.Time
+}
+
+func (c *candleExchange) GetCandles(pair Pair, interval CandleInterval, since time.Time) ([]Candle, error) {
+	duration, ok := CandleIntervals[interval]
+	if !ok {
+		return nil, fmt.Errorf("unknown candle interval %q", interval)
+	}
+
+	first, last, err := c.repo.CandleRange(c.Name(), pair, interval)
+	if err != nil {
+		return nil, err
+	}
+
+	from := last
+	if first.IsZero() || since.Before(first.Add(-duration)) {
+		from = since
+	}
+
+	err = c.update(pair, interval, from, duration)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.repo.FindCandles(c.Name(), pair, interval, since)
+}
+
+func (c *candleExchange) update(pair Pair, interval CandleInterval, from time.Time, duration time.Duration) error {
+	for page := 0; page < candlePages && !from.After(c.now()); page++ {
+		candles, err := c.Exchange.GetCandles(pair, interval, from)
+		if err != nil {
+			return err
+		}
+
+		if len(candles) == 0 {
+			return nil
+		}
+
+		err = c.repo.SaveCandles(c.Name(), pair, interval, candles)
+		if err != nil {
+			return err
+		}
+
+		next := candles[len(candles)-1].OpenTime
+		if !next.After(from) {
+			return nil
+		}
+
+		// the last candle is asked again while it may be open
+		if next.Add(duration).After(c.now()) {
+			return nil
+		}
+
+		from = next
+	}
+
+	return nil
+}
+
+// CandleCloses, CandleHighs and CandleLows make series for indicators.
+func CandleCloses(candles []Candle) []decimal.Decimal {
+	values := []decimal.Decimal{}
+	for _, candle := range candles {
+		values = append(values, candle.Close)
+	}
+
+	return values
+}
+
+func CandleHighs(candles []Candle) []decimal.Decimal {
+	values := []decimal.Decimal{}
+	for _, candle := range candles {
+		values = append(values, candle.High)
+	}
+
+	return values
+}
+
+func CandleLows(candles []Candle) []decimal.Decimal {
+	values := []decimal.Decimal{}
+	for _, candle := range candles {
+		values = append(values, candle.Low)
+	}
+
+	return values
+}
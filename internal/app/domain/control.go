@@ -0,0 +1,80 @@
+package domain
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// AgentControl stops agents started by StartAgents in this process. Agents
+// of other processes notice their new status before the next cycle.
+type AgentControl struct {
+	mu     sync.Mutex
+	agents map[int64]*controlledAgent
+}
+
+type controlledAgent struct {
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+func NewAgentControl() *AgentControl {
+	return &AgentControl{agents: map[int64]*controlledAgent{}}
+}
+
+func (c *AgentControl) register(agentId int64, cancel context.CancelFunc) func() {
+	agent := &controlledAgent{cancel: cancel, done: make(chan struct{})}
+
+	c.mu.Lock()
+	c.agents[agentId] = agent
+	c.mu.Unlock()
+
+	return func() {
+		c.mu.Lock()
+		if c.agents[agentId] == agent {
+			delete(c.agents, agentId)
+		}
+		c.mu.Unlock()
+
+		close(agent.done)
+	}
+}
+
+// Halt cancels the running agents and waits up to timeout for them to stop.
+// It returns ids of agents that are still running.
+func (c *AgentControl) Halt(agentIds []int64, timeout time.Duration) []int64 {
+	halted := map[int64]*controlledAgent{}
+
+	c.mu.Lock()
+	for _, agentId := range agentIds {
+		if agent, ok := c.agents[agentId]; ok {
+			agent.cancel()
+			halted[agentId] = agent
+		}
+	}
+	c.mu.Unlock()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	expired := false
+	running := []int64{}
+	for agentId, agent := range halted {
+		if !expired {
+			select {
+			case <-agent.done:
+				continue
+			case <-timer.C:
+				expired = true
+			}
+		}
+
+		select {
+		case <-agent.done:
+		default:
+			running = append(running, agentId)
+		}
+	}
+
+	return running
+}
@@ -1,6 +1,14 @@
 package domain
 
-import "github.com/shopspring/decimal"
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
 
 type AgentStatus int
 
@@ -8,22 +16,67 @@ const (
 	ErrorAgentStatus   = iota
 	ActiveAgentStatus  = iota
 	DisableAgentStatus = iota
+	// PausedAgentStatus is set by agents themselves, e.g. on a risk limit.
+	PausedAgentStatus = iota
 )
 
+var AgentStatusNames = map[AgentStatus]string{
+	ErrorAgentStatus:   "error",
+	ActiveAgentStatus:  "active",
+	DisableAgentStatus: "disable",
+	PausedAgentStatus:  "paused",
+}
+
 type Agent struct {
 	Id           int64
 	UserId       int64
 	Status       AgentStatus
 	StrategyId   StrategyId
 	StrategyData []byte
+	// DryRun agents trade on a simulated exchange fed by real prices.
+	DryRun bool
 	//Storage    interface{} //Storage
 	//Exchange   []Exchange
 	//Logger     Logger
 }
 
+// UserRole orders what a user may do, a higher role may do everything a lower
+// one may.
+type UserRole int
+
+const (
+	_ UserRole = iota
+	// ViewerUserRole sees agents shared with the user and changes nothing.
+	ViewerUserRole
+	TraderUserRole
+	// AdminUserRole manages users and sees and controls agents of everyone.
+	AdminUserRole
+)
+
+var UserRoleNames = map[UserRole]string{
+	ViewerUserRole: "viewer",
+	TraderUserRole: "trader",
+	AdminUserRole:  "admin",
+}
+
+func ParseUserRole(name string) (UserRole, error) {
+	for role, roleName := range UserRoleNames {
+		if roleName == strings.ToLower(name) {
+			return role, nil
+		}
+	}
+
+	return 0, fmt.Errorf("unknown user role %q", name)
+}
+
 type User struct {
 	Id   int64
 	Name string
+	Role UserRole
+	// Timezone is an IANA name used for times in reports, UTC when empty.
+	Timezone string
+	// QuoteAsset values positions in reports, e.g. USD.
+	QuoteAsset string
 }
 
 type OrderStatus = int
@@ -35,11 +88,60 @@ const (
 	CanceledOrderStatus OrderStatus = iota
 )
 
+type OrderSide = string
+
+const (
+	BuyOrderSide  OrderSide = "buy"
+	SellOrderSide OrderSide = "sell"
+)
+
+type OrderType = string
+
+const (
+	MarketOrderType OrderType = "market"
+	LimitOrderType  OrderType = "limit"
+)
+
+type TimeInForce = string
+
+const (
+	GoodTillCanceled  TimeInForce = "GTC"
+	ImmediateOrCancel TimeInForce = "IOC"
+	FillOrKill        TimeInForce = "FOK"
+)
+
 type Order struct {
-	Id         string
-	Status     OrderStatus
-	Price      decimal.Decimal
-	Amount     decimal.Decimal
-	Pair       Pair
-	Commission Balance
+	Id     string
+	Status OrderStatus
+	// Side is empty when the exchange doesn't tell it.
+	Side          OrderSide
+	Price         decimal.Decimal
+	Amount        decimal.Decimal
+	Pair          Pair
+	Commission    Balance
+	ClientOrderId string
+	// Time is when the order was placed, or filled for history orders. It is
+	// zero when the exchange doesn't tell it.
+	Time time.Time
+}
+
+// OrderRequest describes an order to place. Price is ignored by market
+// orders, an empty TimeInForce means GoodTillCanceled. ClientOrderId lets to
+// find the order when the result of the placement is lost.
+type OrderRequest struct {
+	Pair          Pair
+	Side          OrderSide
+	Type          OrderType
+	Amount        decimal.Decimal
+	Price         decimal.Decimal
+	TimeInForce   TimeInForce
+	ClientOrderId string
+}
+
+// NewClientOrderId returns a random id for OrderRequest.ClientOrderId.
+func NewClientOrderId() string {
+	id := make([]byte, 12)
+	_, _ = rand.Read(id)
+
+	return "ia" + hex.EncodeToString(id)
 }
@@ -0,0 +1,98 @@
+package domain
+
+import "errors"
+
+// Errors of exchanges that adapters map their own errors to, strategies
+// check them with errors.Is.
+var (
+	ErrInsufficientFunds = errors.New("insufficient funds")
+	ErrMinNotional       = errors.New("order value is below the exchange minimum")
+	ErrOrderNotFound     = errors.New("order not found")
+	ErrRateLimited       = errors.New("rate limited")
+	ErrAuth              = errors.New("exchange authentication failed")
+	ErrMarketClosed      = errors.New("market is closed")
+	// ErrClientOrderIdUnsupported is returned by GetOrderByClientId of
+	// exchanges that don't keep client order ids.
+	ErrClientOrderIdUnsupported = errors.New("order lookup by client id is not supported")
+)
+
+type ExchangeErrorKind int
+
+const (
+	_ ExchangeErrorKind = iota
+	// RetryableExchangeError is a network failure, a server error or a rate
+	// limit, the same call may succeed later.
+	RetryableExchangeError
+	AuthExchangeError
+	InsufficientFundsExchangeError
+	InvalidOrderExchangeError
+)
+
+var ExchangeErrorKindNames = map[ExchangeErrorKind]string{
+	RetryableExchangeError:         "retryable",
+	AuthExchangeError:              "auth",
+	InsufficientFundsExchangeError: "insufficient funds",
+	InvalidOrderExchangeError:      "invalid order",
+}
+
+var exchangeErrorReasonKinds = map[error]ExchangeErrorKind{
+	ErrInsufficientFunds: InsufficientFundsExchangeError,
+	ErrMinNotional:       InvalidOrderExchangeError,
+	ErrOrderNotFound:     InvalidOrderExchangeError,
+	ErrRateLimited:       RetryableExchangeError,
+	ErrAuth:              AuthExchangeError,
+	ErrMarketClosed:      InvalidOrderExchangeError,
+}
+
+// ExchangeError is an error of an exchange classified by its adapter.
+// Reason is one of the Err* errors above when the adapter knows it.
+type ExchangeError struct {
+	Kind   ExchangeErrorKind
+	Reason error
+	Err    error
+}
+
+// NewExchangeError classifies err by one of the Err* errors above.
+func NewExchangeError(reason error, err error) ExchangeError {
+	return ExchangeError{Kind: exchangeErrorReasonKinds[reason], Reason: reason, Err: err}
+}
+
+func (e ExchangeError) Error() string {
+	if e.Reason != nil {
+		return e.Reason.Error() + ": " + e.Err.Error()
+	}
+
+	return ExchangeErrorKindNames[e.Kind] + ": " + e.Err.Error()
+}
+
+func (e ExchangeError) Unwrap() error {
+	return e.Err
+}
+
+func (e ExchangeError) Is(target error) bool {
+	return e.Reason != nil && target == e.Reason
+}
+
+// ExchangeErrorKindOf returns the kind of a classified error, 0 for others.
+func ExchangeErrorKindOf(err error) ExchangeErrorKind {
+	var exchangeErr ExchangeError
+	if errors.As(err, &exchangeErr) {
+		return exchangeErr.Kind
+	}
+
+	return 0
+}
+
+// orderRefused tells that the exchange or the risk guard surely didn't place
+// the order. The order of other errors may be placed and is looked up later.
+func orderRefused(err error) bool {
+	switch ExchangeErrorKindOf(err) {
+	case InsufficientFundsExchangeError, InvalidOrderExchangeError:
+		return true
+	}
+
+	return errors.Is(err, ErrInsufficientFunds) ||
+		errors.Is(err, ErrMinNotional) ||
+		errors.Is(err, ErrMarketClosed) ||
+		errors.Is(err, ErrRiskLimit)
+}
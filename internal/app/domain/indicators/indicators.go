@@ -0,0 +1,211 @@
+// Package indicators calculates technical indicators over price series. Input
+// series are oldest first, results are aligned to the end of the input and
+// start at the first value that has enough data.
+package indicators
+
+import (
+	"errors"
+
+	"github.com/shopspring/decimal"
+)
+
+var ErrNotEnoughData = errors.New("not enough data")
+
+var (
+	one     = decimal.NewFromInt(1)
+	two     = decimal.NewFromInt(2)
+	hundred = decimal.NewFromInt(100)
+)
+
+func check(length int, period int) error {
+	if period < 1 {
+		return errors.New("period must be positive")
+	}
+
+	if length < period {
+		return ErrNotEnoughData
+	}
+
+	return nil
+}
+
+// Last returns the last value of a series.
+func Last(series []decimal.Decimal) decimal.Decimal {
+	if len(series) == 0 {
+		return decimal.Zero
+	}
+
+	return series[len(series)-1]
+}
+
+// SMA is the simple moving average, len(values)-period+1 values.
+func SMA(values []decimal.Decimal, period int) ([]decimal.Decimal, error) {
+	err := check(len(values), period)
+	if err != nil {
+		return nil, err
+	}
+
+	count := decimal.NewFromInt(int64(period))
+	sum := decimal.Zero
+	result := []decimal.Decimal{}
+	for i, value := range values {
+		sum = sum.Add(value)
+		if i >= period {
+			sum = sum.Sub(values[i-period])
+		}
+
+		if i >= period-1 {
+			result = append(result, sum.Div(count))
+		}
+	}
+
+	return result, nil
+}
+
+// EMA is the exponential moving average seeded with the SMA of the first
+// period, len(values)-period+1 values.
+func EMA(values []decimal.Decimal, period int) ([]decimal.Decimal, error) {
+	err := check(len(values), period)
+	if err != nil {
+		return nil, err
+	}
+
+	seed, _ := SMA(values[:period], period)
+
+	k := two.Div(decimal.NewFromInt(int64(period + 1)))
+	result := []decimal.Decimal{seed[0]}
+	for _, value := range values[period:] {
+		previous := result[len(result)-1]
+		result = append(result, value.Sub(previous).Mul(k).Add(previous))
+	}
+
+	return result, nil
+}
+
+// wilder smooths values with Wilder's moving average seeded with the SMA of
+// the first period.
+func wilder(values []decimal.Decimal, period int) []decimal.Decimal {
+	count := decimal.NewFromInt(int64(period))
+	previous := decimal.Zero
+	for _, value := range values[:period] {
+		previous = previous.Add(value)
+	}
+	previous = previous.Div(count)
+
+	result := []decimal.Decimal{previous}
+	for _, value := range values[period:] {
+		previous = previous.Mul(count.Sub(one)).Add(value).Div(count)
+		result = append(result, previous)
+	}
+
+	return result
+}
+
+// RSI is the relative strength index with Wilder smoothing, values from 0 to
+// 100, len(values)-period values.
+func RSI(values []decimal.Decimal, period int) ([]decimal.Decimal, error) {
+	err := check(len(values)-1, period)
+	if err != nil {
+		return nil, err
+	}
+
+	gains := []decimal.Decimal{}
+	losses := []decimal.Decimal{}
+	for i := 1; i < len(values); i++ {
+		change := values[i].Sub(values[i-1])
+		gains = append(gains, decimal.Max(change, decimal.Zero))
+		losses = append(losses, decimal.Max(change.Neg(), decimal.Zero))
+	}
+
+	averageGains := wilder(gains, period)
+	averageLosses := wilder(losses, period)
+
+	result := []decimal.Decimal{}
+	for i := range averageGains {
+		if averageLosses[i].IsZero() {
+			result = append(result, hundred)
+			continue
+		}
+
+		rs := averageGains[i].Div(averageLosses[i])
+		result = append(result, hundred.Sub(hundred.Div(one.Add(rs))))
+	}
+
+	return result, nil
+}
+
+type Band struct {
+	Middle decimal.Decimal
+	Upper  decimal.Decimal
+	Lower  decimal.Decimal
+}
+
+// Bollinger are SMA bands of width deviations times the population standard
+// deviation, len(values)-period+1 values.
+func Bollinger(values []decimal.Decimal, period int, deviations decimal.Decimal) ([]Band, error) {
+	middles, err := SMA(values, period)
+	if err != nil {
+		return nil, err
+	}
+
+	count := decimal.NewFromInt(int64(period))
+	result := []Band{}
+	for i, middle := range middles {
+		variance := decimal.Zero
+		for _, value := range values[i : i+period] {
+			diff := value.Sub(middle)
+			variance = variance.Add(diff.Mul(diff))
+		}
+
+		deviation := sqrt(variance.Div(count)).Mul(deviations)
+		result = append(result, Band{Middle: middle, Upper: middle.Add(deviation), Lower: middle.Sub(deviation)})
+	}
+
+	return result, nil
+}
+
+// ATR is the average true range with Wilder smoothing, len(closes)-period
+// values. highs, lows and closes must have the same length.
+func ATR(highs []decimal.Decimal, lows []decimal.Decimal, closes []decimal.Decimal, period int) ([]decimal.Decimal, error) {
+	if len(highs) != len(closes) || len(lows) != len(closes) {
+		return nil, errors.New("highs, lows and closes have different length")
+	}
+
+	err := check(len(closes)-1, period)
+	if err != nil {
+		return nil, err
+	}
+
+	ranges := []decimal.Decimal{}
+	for i := 1; i < len(closes); i++ {
+		ranges = append(ranges, decimal.Max(
+			highs[i].Sub(lows[i]),
+			highs[i].Sub(closes[i-1]).Abs(),
+			lows[i].Sub(closes[i-1]).Abs(),
+		))
+	}
+
+	return wilder(ranges, period), nil
+}
+
+// sqrt uses Newton's method, decimal has no square root.
+func sqrt(value decimal.Decimal) decimal.Decimal {
+	if !value.IsPositive() {
+		return decimal.Zero
+	}
+
+	guess := value
+	if value.LessThan(one) {
+		guess = one
+	}
+
+	for i := 0; i < 100; i++ {
+		next := guess.Add(value.Div(guess)).Div(two)
+		if next.Sub(guess).Abs().LessThan(decimal.New(1, -12)) {
+			return next
+		}
+		guess = next
+	}
+
+	return guess
+}
@@ -0,0 +1,120 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// intentClockSkew is how much earlier than the saved intent an exchange may
+// date the order, the clocks of the exchange and the agent differ.
+const intentClockSkew = time.Minute
+
+// orderIntent is an order saved before it was placed. The placement result
+// may be lost, so the order is looked up by the intent.
+type orderIntent struct {
+	Pair          Pair
+	Side          OrderSide
+	Amount        decimal.Decimal
+	ClientOrderId string
+	// Datetime is the RFC3339 time the intent was saved.
+	Datetime string
+}
+
+// findIntentOrder returns the order placed for the intent, nil when it was
+// never placed. Exchanges without client order ids are searched for an open
+// or a filled order of the pair, side and amount that is placed after the
+// intent and is not known to other trades. Several such orders are an error,
+// the trade can't be resumed then.
+func findIntentOrder(exchange Exchange, intent orderIntent, known map[string]bool) (*Order, error) {
+	order, err := exchange.GetOrderByClientId(intent.Pair, intent.ClientOrderId)
+	if !errors.Is(err, ErrClientOrderIdUnsupported) {
+		return order, err
+	}
+
+	since, err := time.Parse(time.RFC3339, intent.Datetime)
+	if err != nil {
+		return nil, fmt.Errorf("time of client order id %s: %w", intent.ClientOrderId, err)
+	}
+	since = since.Add(-intentClockSkew)
+
+	matches := func(order Order) bool {
+		return order.Pair == intent.Pair && order.Side == intent.Side && !known[order.Id] && !order.Time.Before(since)
+	}
+
+	openOrders, err := exchange.GetOpenOrders(&OrderFilter{Pairs: []Pair{intent.Pair}})
+	if err != nil {
+		return nil, err
+	}
+
+	candidates := []Order{}
+	open := map[string]bool{}
+	for _, order := range openOrders {
+		open[order.Id] = true
+		if matches(order) && order.Amount.Equal(intent.Amount) {
+			candidates = append(candidates, order)
+		}
+	}
+
+	history, err := exchange.GetHistoryOrders([]Pair{intent.Pair})
+	if err != nil {
+		return nil, err
+	}
+
+	// history orders are fills, an order filled in parts is summed up
+	filled := map[string]*Order{}
+	ids := []string{}
+	for _, fill := range history {
+		if open[fill.Id] || !matches(fill) {
+			continue
+		}
+
+		order, ok := filled[fill.Id]
+		if !ok {
+			copied := fill
+			filled[fill.Id] = &copied
+			ids = append(ids, fill.Id)
+			continue
+		}
+
+		order.Amount = order.Amount.Add(fill.Amount)
+		order.Commission.Amount = order.Commission.Amount.Add(fill.Commission.Amount)
+	}
+
+	for _, id := range ids {
+		if filled[id].Amount.Equal(intent.Amount) {
+			candidates = append(candidates, *filled[id])
+		}
+	}
+
+	switch len(candidates) {
+	case 0:
+		return nil, nil
+	case 1:
+		return &candidates[0], nil
+	}
+
+	found := []string{}
+	for _, order := range candidates {
+		found = append(found, order.Id)
+	}
+
+	return nil, fmt.Errorf("orders(ids=%s) match client order id %s, resolve the trade by hand", strings.Join(found, ","), intent.ClientOrderId)
+}
+
+// knownOrderIds returns the order ids the trades keep.
+func knownOrderIds(trades []SimpleTrade) map[string]bool {
+	known := map[string]bool{}
+	for _, trade := range trades {
+		for _, id := range []string{trade.Buy.OrderId, trade.Sell.OrderId} {
+			if id != "" {
+				known[id] = true
+			}
+		}
+	}
+
+	return known
+}
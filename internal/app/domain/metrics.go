@@ -0,0 +1,131 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+func meterExchanges(exchanges []Exchange, metrics Metrics) []Exchange {
+	metered := []Exchange{}
+	for _, exchange := range exchanges {
+		metered = append(metered, &meteredExchange{exchange: exchange, metrics: metrics})
+	}
+
+	return metered
+}
+
+func reportTrades(storage interface{}, metrics Metrics, logger Logger) {
+	counter, ok := storage.(TradeCounter)
+	if !ok {
+		return
+	}
+
+	counts, err := counter.CountTrades()
+	if err != nil {
+		logger.Warn("count trades error: " + err.Error())
+		return
+	}
+
+	for status, count := range counts {
+		metrics.Trades(status, count)
+	}
+}
+
+type meteredExchange struct {
+	exchange Exchange
+	metrics  Metrics
+}
+
+var _ Exchange = (*meteredExchange)(nil)
+
+func (m *meteredExchange) observe(method string, started time.Time, err error) {
+	m.metrics.ExchangeCall(m.exchange.Name(), method, time.Since(started), err)
+}
+
+func (m *meteredExchange) Name() string {
+	return m.exchange.Name()
+}
+
+func (m *meteredExchange) Balances(assets []string) ([]Balance, error) {
+	started := time.Now()
+	balances, err := m.exchange.Balances(assets)
+	m.observe("Balances", started, err)
+
+	for _, balance := range balances {
+		m.metrics.Balance(balance.Asset, balance.Amount)
+	}
+
+	return balances, err
+}
+
+func (m *meteredExchange) GetOpenOrders(filter *OrderFilter) ([]Order, error) {
+	started := time.Now()
+	orders, err := m.exchange.GetOpenOrders(filter)
+	m.observe("GetOpenOrders", started, err)
+	return orders, err
+}
+
+func (m *meteredExchange) GetHistoryOrders(pairs []Pair) ([]Order, error) {
+	started := time.Now()
+	orders, err := m.exchange.GetHistoryOrders(pairs)
+	m.observe("GetHistoryOrders", started, err)
+	return orders, err
+}
+
+func (m *meteredExchange) LastPrice(pair Pair) (decimal.Decimal, error) {
+	started := time.Now()
+	price, err := m.exchange.LastPrice(pair)
+	m.observe("LastPrice", started, err)
+	return price, err
+}
+
+func (m *meteredExchange) Buy(pair Pair, amount decimal.Decimal) (*Order, error) {
+	started := time.Now()
+	order, err := m.exchange.Buy(pair, amount)
+	m.observe("Buy", started, err)
+	return order, err
+}
+
+func (m *meteredExchange) Sell(pair Pair, amount decimal.Decimal, price decimal.Decimal) (*Order, error) {
+	started := time.Now()
+	order, err := m.exchange.Sell(pair, amount, price)
+	m.observe("Sell", started, err)
+	return order, err
+}
+
+func (m *meteredExchange) PlaceOrder(request OrderRequest) (*Order, error) {
+	started := time.Now()
+	order, err := m.exchange.PlaceOrder(request)
+	m.observe("PlaceOrder", started, err)
+	return order, err
+}
+
+func (m *meteredExchange) GetOrderByClientId(pair Pair, clientOrderId string) (*Order, error) {
+	started := time.Now()
+	order, err := m.exchange.GetOrderByClientId(pair, clientOrderId)
+	m.observe("GetOrderByClientId", started, err)
+	return order, err
+}
+
+func (m *meteredExchange) CancelOrder(orderId string, pair Pair) error {
+	started := time.Now()
+	err := m.exchange.CancelOrder(orderId, pair)
+	m.observe("CancelOrder", started, err)
+	return err
+}
+
+func (m *meteredExchange) GetOrderFee(pair Pair, amount decimal.Decimal, price decimal.Decimal) (Balance, error) {
+	return m.exchange.GetOrderFee(pair, amount, price)
+}
+
+func (m *meteredExchange) GetPairFee(pair Pair) (Balance, error) {
+	return m.exchange.GetPairFee(pair)
+}
+
+func (m *meteredExchange) GetCandles(pair Pair, interval CandleInterval, since time.Time) ([]Candle, error) {
+	started := time.Now()
+	candles, err := m.exchange.GetCandles(pair, interval, since)
+	m.observe("GetCandles", started, err)
+	return candles, err
+}
@@ -0,0 +1,207 @@
+package domain
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// paperExchange reads balances and prices from the real exchange and fills
+// orders of a dry-run agent itself: market orders at once at the last price,
+// limit sells when the last price rises to the order price and limit buys
+// when it falls to it.
+type paperExchange struct {
+	Exchange
+	agentId int64
+	repo    PaperRepo
+	now     func() time.Time
+}
+
+func paperExchanges(exchanges []Exchange, agentId int64, repo PaperRepo) []Exchange {
+	papers := []Exchange{}
+	for _, exchange := range exchanges {
+		papers = append(papers, &paperExchange{Exchange: exchange, agentId: agentId, repo: repo, now: time.Now})
+	}
+
+	return papers
+}
+
+func (p *paperExchange) newOrderId() string {
+	return fmt.Sprintf("paper-%d-%d", p.agentId, p.now().UnixNano())
+}
+
+// fill fills pending orders whose price is reached.
+func (p *paperExchange) fill() error {
+	orders, err := p.repo.FindPaperOrders(p.agentId, p.Name(), []OrderStatus{PendingOrderStatus})
+	if err != nil {
+		return err
+	}
+
+	prices := map[Pair]decimal.Decimal{}
+	for _, order := range orders {
+		price, ok := prices[order.Pair]
+		if !ok {
+			price, err = p.Exchange.LastPrice(order.Pair)
+			if err != nil {
+				return err
+			}
+			prices[order.Pair] = price
+		}
+
+		if order.Side == BuyOrderSide && price.GreaterThan(order.Price) {
+			continue
+		}
+
+		if order.Side != BuyOrderSide && price.LessThan(order.Price) {
+			continue
+		}
+
+		order.Commission, err = p.Exchange.GetOrderFee(order.Pair, order.Amount, order.Price)
+		if err != nil {
+			return err
+		}
+
+		order.Status = FillOrderStatus
+		err = p.repo.SavePaperOrder(p.agentId, p.Name(), order)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (p *paperExchange) findOrders(statuses []OrderStatus, pairs []Pair, ids []string) ([]Order, error) {
+	err := p.fill()
+	if err != nil {
+		return nil, fmt.Errorf("paper fill: %w", err)
+	}
+
+	orders, err := p.repo.FindPaperOrders(p.agentId, p.Name(), statuses)
+	if err != nil {
+		return nil, err
+	}
+
+	found := []Order{}
+	for _, order := range orders {
+		if len(pairs) > 0 && !containsPair(pairs, order.Pair) {
+			continue
+		}
+
+		if len(ids) > 0 && !containsString(ids, order.Id) {
+			continue
+		}
+
+		found = append(found, order)
+	}
+
+	return found, nil
+}
+
+func (p *paperExchange) GetOpenOrders(filter *OrderFilter) ([]Order, error) {
+	if filter == nil {
+		filter = &OrderFilter{}
+	}
+
+	return p.findOrders([]OrderStatus{PendingOrderStatus}, filter.Pairs, filter.Ids)
+}
+
+func (p *paperExchange) GetHistoryOrders(pairs []Pair) ([]Order, error) {
+	return p.findOrders([]OrderStatus{FillOrderStatus, CanceledOrderStatus}, pairs, nil)
+}
+
+func (p *paperExchange) GetOrderByClientId(pair Pair, clientOrderId string) (*Order, error) {
+	orders, err := p.findOrders(nil, []Pair{pair}, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, order := range orders {
+		if order.ClientOrderId == clientOrderId {
+			return &order, nil
+		}
+	}
+
+	return nil, nil
+}
+
+func (p *paperExchange) Buy(pair Pair, amount decimal.Decimal) (*Order, error) {
+	return p.PlaceOrder(OrderRequest{Pair: pair, Side: BuyOrderSide, Type: MarketOrderType, Amount: amount})
+}
+
+func (p *paperExchange) Sell(pair Pair, amount decimal.Decimal, price decimal.Decimal) (*Order, error) {
+	return p.PlaceOrder(OrderRequest{Pair: pair, Side: SellOrderSide, Type: LimitOrderType, Amount: amount, Price: price})
+}
+
+// PlaceOrder fills market orders at once, limit orders wait for fill. IOC and
+// FOK limit orders are canceled when the price is not reached already.
+func (p *paperExchange) PlaceOrder(request OrderRequest) (*Order, error) {
+	order := Order{
+		Id:            p.newOrderId(),
+		Status:        PendingOrderStatus,
+		Side:          request.Side,
+		Price:         request.Price,
+		Amount:        request.Amount,
+		Pair:          request.Pair,
+		ClientOrderId: request.ClientOrderId,
+	}
+
+	if request.Type == MarketOrderType || request.TimeInForce == ImmediateOrCancel || request.TimeInForce == FillOrKill {
+		price, err := p.Exchange.LastPrice(request.Pair)
+		if err != nil {
+			return nil, err
+		}
+
+		reached := request.Type == MarketOrderType ||
+			(request.Side == BuyOrderSide && !price.GreaterThan(request.Price)) ||
+			(request.Side == SellOrderSide && !price.LessThan(request.Price))
+
+		order.Status = CanceledOrderStatus
+		if reached {
+			order.Status = FillOrderStatus
+			order.Price = price
+			if request.Type == LimitOrderType {
+				order.Price = request.Price
+			}
+
+			order.Commission, err = p.Exchange.GetOrderFee(order.Pair, order.Amount, order.Price)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	err := p.repo.SavePaperOrder(p.agentId, p.Name(), order)
+	if err != nil {
+		return nil, err
+	}
+
+	return &order, nil
+}
+
+func (p *paperExchange) CancelOrder(orderId string, pair Pair) error {
+	orders, err := p.repo.FindPaperOrders(p.agentId, p.Name(), []OrderStatus{PendingOrderStatus})
+	if err != nil {
+		return err
+	}
+
+	for _, order := range orders {
+		if order.Id == orderId {
+			order.Status = CanceledOrderStatus
+			return p.repo.SavePaperOrder(p.agentId, p.Name(), order)
+		}
+	}
+
+	return NewExchangeError(ErrOrderNotFound, fmt.Errorf("paper order(id=%s) is not open", orderId))
+}
+
+func containsPair(pairs []Pair, pair Pair) bool {
+	for _, p := range pairs {
+		if p == pair {
+			return true
+		}
+	}
+
+	return false
+}
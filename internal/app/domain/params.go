@@ -0,0 +1,311 @@
+package domain
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/shopspring/decimal"
+)
+
+var StrategyParameterTypeNames = map[StrategyParameterType]string{
+	BoolParameterType:    "bool",
+	IntParameterType:     "int",
+	StringParameterType:  "string",
+	PercentParameterType: "percent",
+	PairParameterType:    "pair",
+	BalanceParameterType: "balance",
+	ListParameterType:    "list",
+}
+
+// ParameterSchema describes one key of the strategy json data. Frontends use
+// it to render forms, actions use it to validate data before it is stored.
+// Percent values are fractions (0.01 is 1%), Min and Max are inclusive and
+// use the same units as the value. A list value is an array of objects
+// described by Items, Required on a list means it can't be empty.
+type ParameterSchema struct {
+	Key         string
+	Name        string
+	Type        StrategyParameterType
+	Description string
+	Required    bool
+	Min         *decimal.Decimal
+	Max         *decimal.Decimal
+	Choices     []string
+	Default     interface{}
+	Items       []ParameterSchema
+}
+
+type ParameterError struct {
+	Key     string
+	Message string
+}
+
+type ParametersError []ParameterError
+
+func (e ParametersError) Error() string {
+	messages := []string{}
+	for _, err := range e {
+		messages = append(messages, err.Key+": "+err.Message)
+	}
+
+	return strings.Join(messages, "; ")
+}
+
+func parameterLimit(value string) *decimal.Decimal {
+	limit := decimal.RequireFromString(value)
+	return &limit
+}
+
+// parameterDecimal accepts both decoded json values and the typed values
+// strategies put into StrategyParameter.
+// findSchema returns the schema of the key, nil when there is none.
+func findSchema(schemas []ParameterSchema, key string) *ParameterSchema {
+	for index := range schemas {
+		if schemas[index].Key == key {
+			return &schemas[index]
+		}
+	}
+
+	return nil
+}
+
+func parameterDecimal(value interface{}) (decimal.Decimal, bool) {
+	switch val := value.(type) {
+	case decimal.Decimal:
+		return val, true
+	case Balance:
+		return val.Amount, true
+	case int:
+		return decimal.NewFromInt(int64(val)), true
+	case float64:
+		return decimal.NewFromFloat(val), true
+	case json.Number:
+		number, err := decimal.NewFromString(val.String())
+		return number, err == nil
+	case string:
+		number, err := decimal.NewFromString(val)
+		return number, err == nil
+	}
+
+	return decimal.Decimal{}, false
+}
+
+// Check returns a problem description for value or an empty string.
+func (p ParameterSchema) Check(value interface{}) string {
+	switch p.Type {
+	case BoolParameterType:
+		if _, ok := value.(bool); !ok {
+			return "must be true or false"
+		}
+		return ""
+
+	case StringParameterType:
+		str, ok := value.(string)
+		if !ok {
+			return "must be a string"
+		}
+		if p.Required && str == "" {
+			return "must not be empty"
+		}
+		if len(p.Choices) > 0 {
+			for _, choice := range p.Choices {
+				if choice == str {
+					return ""
+				}
+			}
+			return "must be one of " + strings.Join(p.Choices, ", ")
+		}
+		return ""
+
+	case PairParameterType:
+		var pair Pair
+		switch val := value.(type) {
+		case Pair:
+			pair = val
+		case map[string]interface{}:
+			pair.BaseAsset, _ = val["base_asset"].(string)
+			pair.QuoteAsset, _ = val["quote_asset"].(string)
+		default:
+			return "must be an object with base_asset and quote_asset"
+		}
+		if pair.BaseAsset == "" || pair.QuoteAsset == "" {
+			return "base_asset and quote_asset are required"
+		}
+		if len(p.Choices) > 0 {
+			for _, choice := range p.Choices {
+				if choice == pair.String() {
+					return ""
+				}
+			}
+			return "must be one of " + strings.Join(p.Choices, ", ")
+		}
+		return ""
+
+	case IntParameterType:
+		number, ok := parameterDecimal(value)
+		if !ok || !number.IsInteger() {
+			return "must be an integer"
+		}
+		return p.checkLimits(number)
+
+	case PercentParameterType, BalanceParameterType:
+		number, ok := parameterDecimal(value)
+		if !ok {
+			return "must be a number"
+		}
+		return p.checkLimits(number)
+
+	case ListParameterType:
+		list, ok := value.([]interface{})
+		if !ok {
+			return "must be a list"
+		}
+		if p.Required && len(list) == 0 {
+			return "must not be empty"
+		}
+		problems := ParametersError{}
+		for i, item := range list {
+			object, ok := item.(map[string]interface{})
+			if !ok {
+				problems = append(problems, ParameterError{Key: fmt.Sprintf("[%d]", i), Message: "must be an object"})
+				continue
+			}
+			problems = append(problems, checkObject(p.Items, object, fmt.Sprintf("[%d].", i))...)
+		}
+		if len(problems) > 0 {
+			return problems.Error()
+		}
+		return ""
+	}
+
+	return fmt.Sprintf("unknown parameter type %d", p.Type)
+}
+
+func (p ParameterSchema) checkLimits(number decimal.Decimal) string {
+	if p.Min != nil && number.LessThan(*p.Min) {
+		return "must be at least " + p.Min.String()
+	}
+
+	if p.Max != nil && number.GreaterThan(*p.Max) {
+		return "must be at most " + p.Max.String()
+	}
+
+	return ""
+}
+
+// checkObject checks the keys of object against schemas and fills in
+// defaults, prefix is put before keys in problems.
+func checkObject(schemas []ParameterSchema, object map[string]interface{}, prefix string) ParametersError {
+	problems := ParametersError{}
+	known := map[string]bool{}
+	for _, schema := range schemas {
+		known[schema.Key] = true
+
+		value, ok := object[schema.Key]
+		if !ok || value == nil {
+			if schema.Default != nil {
+				object[schema.Key] = schema.Default
+			} else if schema.Required {
+				problems = append(problems, ParameterError{Key: prefix + schema.Key, Message: "is required"})
+			}
+			continue
+		}
+
+		if problem := schema.Check(value); problem != "" {
+			problems = append(problems, ParameterError{Key: prefix + schema.Key, Message: problem})
+		}
+	}
+
+	unknown := []string{}
+	for key := range object {
+		if !known[key] {
+			unknown = append(unknown, key)
+		}
+	}
+	sort.Strings(unknown)
+	for _, key := range unknown {
+		problems = append(problems, ParameterError{Key: prefix + key, Message: "unknown parameter"})
+	}
+
+	return problems
+}
+
+// ValidateStrategyData checks json data against the strategy schema, fills
+// in defaults and runs the cross-field validation of the strategy. The
+// exchanges are optional, checks that need them are skipped without them.
+// Data is upgraded to the current version first. It returns the normalized
+// data that should be stored.
+func ValidateStrategyData(id StrategyId, data []byte, exchanges []Exchange) ([]byte, Strategy, error) {
+	info, ok := GetStrategyInfo(id)
+	if !ok {
+		return nil, nil, fmt.Errorf("unknown strategy id %d", id)
+	}
+
+	data, err := upgradeStrategyData(info, data)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	given, err := decodeStrategyData(data)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	version := given[StrategyDataVersionKey]
+	delete(given, StrategyDataVersionKey)
+
+	problems := checkObject(info.Schema, given, "")
+	if len(problems) > 0 {
+		return nil, nil, problems
+	}
+
+	if version != nil {
+		given[StrategyDataVersionKey] = version
+	}
+
+	normalized, err := json.Marshal(given)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	strategy, err := info.FromJson(normalized)
+	if err != nil {
+		return nil, nil, fmt.Errorf("bad strategy data: %w", err)
+	}
+
+	if info.Validate != nil {
+		problems = info.Validate(strategy, exchanges)
+		if len(problems) > 0 {
+			return nil, nil, problems
+		}
+	}
+
+	return normalized, strategy, nil
+}
+
+// FormatParameter renders a parameter value for people, values of an
+// unexpected type are printed as is.
+func FormatParameter(param StrategyParameter) string {
+	switch val := param.Value.(type) {
+	case bool:
+		return strconv.FormatBool(val)
+	case int:
+		return strconv.Itoa(val)
+	case string:
+		return val
+	case Pair:
+		return val.String()
+	case Balance:
+		return fmt.Sprintf("%s %s", val.Amount, val.Asset)
+	case decimal.Decimal:
+		if param.Type == PercentParameterType {
+			return val.Mul(decimal.NewFromInt(100)).String() + " %"
+		}
+		return val.String()
+	}
+
+	return fmt.Sprintf("%v", param.Value)
+}
@@ -1,6 +1,11 @@
 package domain
 
-import "github.com/shopspring/decimal"
+import (
+	"context"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
 
 type OrderFilter struct {
 	Ids      []string
@@ -16,9 +21,25 @@ type Exchange interface {
 	LastPrice(pair Pair) (decimal.Decimal, error)
 	Buy(pair Pair, amount decimal.Decimal) (*Order, error)
 	Sell(pair Pair, amount decimal.Decimal, price decimal.Decimal) (*Order, error)
+	// PlaceOrder places an order of any type and side, Buy is a market buy
+	// and Sell is a limit sell.
+	PlaceOrder(request OrderRequest) (*Order, error)
+	// GetOrderByClientId returns nil without an error when no order of the
+	// pair has the client order id, i.e. it was never placed.
+	GetOrderByClientId(pair Pair, clientOrderId string) (*Order, error)
 	CancelOrder(orderId string, pair Pair) error
 	GetOrderFee(pair Pair, amount decimal.Decimal, price decimal.Decimal) (Balance, error)
 	GetPairFee(pair Pair) (Balance, error)
+	// GetCandles returns candles opened since, oldest first. Exchanges may
+	// return only a part of them, the rest is asked from the last candle.
+	GetCandles(pair Pair, interval CandleInterval, since time.Time) ([]Candle, error)
+}
+
+// StreamExchange is implemented by exchanges that push updates. The channel
+// is closed when ctx is done, lost connections are restored and resubscribed
+// by the exchange itself.
+type StreamExchange interface {
+	Stream(ctx context.Context, pairs []Pair) (<-chan StreamEvent, error)
 }
 
 type Storage interface{}
@@ -30,20 +51,17 @@ type Logger interface {
 	Debug(message string)
 }
 
-type UserFindFilter struct {
-	Ids   []string
-	Links map[string]interface{}
-}
-
-type UserRepo interface {
-	Find(filter UserFindFilter) ([]User, error)
-	Create(linkName string, linkValue interface{}) (*User, error)
-	Update(userId string, newName string) (*User, error)
+type LoggerLabels struct {
+	AgentId  int64
+	Strategy string
+	Pair     string
 }
 
 type AgentRepo interface {
 	//GetActiveAgents() []Agent
 	FindAgents(active bool) ([]Agent, error)
+	GetAgentStatus(agentId int64) (AgentStatus, error)
+	SetAgentStatus(agent Agent, status AgentStatus) error
 }
 
 type StrategyRepo interface {
@@ -56,8 +74,65 @@ type StorageRepo interface {
 
 type ExchangeRepo interface {
 	GetAgentExchanges(agentId int64) ([]Exchange, error)
+	// AgentExchangesVersion changes with the account data of the agent
+	// exchanges, running agents reload the exchanges when it does.
+	AgentExchangesVersion(agentId int64) (string, error)
 }
 
 type LoggerRepo interface {
-	New(agentId int64) Logger
+	New(labels LoggerLabels) Logger
+}
+
+type Metrics interface {
+	CycleFinished(duration time.Duration, err error)
+	ExchangeCall(exchange string, method string, duration time.Duration, err error)
+	Balance(asset string, amount decimal.Decimal)
+	Trades(status string, count int)
+}
+
+type MetricsRepo interface {
+	New(agentId int64) Metrics
+}
+
+// TradeCounter is implemented by strategy storages that can report their open
+// trades grouped by status.
+type TradeCounter interface {
+	CountTrades() (map[string]int, error)
+}
+
+type RiskRepo interface {
+	// GetRiskLimits returns limits of the agent and of its user.
+	GetRiskLimits(agent Agent) (RiskLimits, RiskLimits, error)
+	AddRiskOrder(order RiskOrder) error
+	RemoveRiskOrder(agentId int64, orderId string) error
+	FindRiskOrders(filter RiskOrderFilter) ([]RiskOrder, error)
+}
+
+// PriceFeed serves last prices shared by all agents. Prices are public, so
+// exchanges with the same name share them.
+type PriceFeed interface {
+	LastPrice(exchange Exchange, pair Pair) (decimal.Decimal, error)
+	// Update keeps a price pushed by an exchange stream.
+	Update(exchange string, pair Pair, price decimal.Decimal)
+}
+
+// CandleRepo keeps candles of exchanges, exchange is the exchange name.
+type CandleRepo interface {
+	FindCandles(exchange string, pair Pair, interval CandleInterval, since time.Time) ([]Candle, error)
+	// CandleRange returns open times of the first and the last kept candles,
+	// zero times when there are none.
+	CandleRange(exchange string, pair Pair, interval CandleInterval) (time.Time, time.Time, error)
+	SaveCandles(exchange string, pair Pair, interval CandleInterval, candles []Candle) error
+}
+
+// PaperRepo keeps orders of the simulated exchange used by dry-run agents,
+// exchange is the name of the real exchange the orders are simulated on.
+type PaperRepo interface {
+	FindPaperOrders(agentId int64, exchange string, statuses []OrderStatus) ([]Order, error)
+	SavePaperOrder(agentId int64, exchange string, order Order) error
+}
+
+// Notifier delivers messages about agents to their users.
+type Notifier interface {
+	Notify(userId int64, message string) error
 }
@@ -0,0 +1,99 @@
+package domain
+
+import (
+	"sync"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// PriceCache is a PriceFeed that asks the exchange at most once per maxAge
+// for a pair, agents asking at the same time wait for one request.
+type PriceCache struct {
+	maxAge time.Duration
+	now    func() time.Time
+
+	mu      sync.Mutex
+	entries map[priceKey]*priceEntry
+}
+
+var _ PriceFeed = (*PriceCache)(nil)
+
+type priceKey struct {
+	exchange string
+	pair     Pair
+}
+
+type priceEntry struct {
+	mu      sync.Mutex
+	price   decimal.Decimal
+	updated time.Time
+}
+
+func NewPriceCache(maxAge time.Duration) *PriceCache {
+	return &PriceCache{maxAge: maxAge, now: time.Now, entries: map[priceKey]*priceEntry{}}
+}
+
+func (c *PriceCache) entry(exchange string, pair Pair) *priceEntry {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	key := priceKey{exchange: exchange, pair: pair}
+	entry, ok := c.entries[key]
+	if !ok {
+		entry = &priceEntry{}
+		c.entries[key] = entry
+	}
+
+	return entry
+}
+
+func (c *PriceCache) LastPrice(exchange Exchange, pair Pair) (decimal.Decimal, error) {
+	entry := c.entry(exchange.Name(), pair)
+
+	entry.mu.Lock()
+	defer entry.mu.Unlock()
+
+	if !entry.updated.IsZero() && c.now().Sub(entry.updated) <= c.maxAge {
+		return entry.price, nil
+	}
+
+	price, err := exchange.LastPrice(pair)
+	if err != nil {
+		return decimal.Decimal{}, err
+	}
+
+	entry.price = price
+	entry.updated = c.now()
+
+	return price, nil
+}
+
+func (c *PriceCache) Update(exchange string, pair Pair, price decimal.Decimal) {
+	entry := c.entry(exchange, pair)
+
+	entry.mu.Lock()
+	defer entry.mu.Unlock()
+
+	entry.price = price
+	entry.updated = c.now()
+}
+
+func feedExchanges(exchanges []Exchange, feed PriceFeed) []Exchange {
+	fed := []Exchange{}
+	for _, exchange := range exchanges {
+		fed = append(fed, &feedExchange{Exchange: exchange, feed: feed})
+	}
+
+	return fed
+}
+
+// feedExchange takes last prices from the shared feed.
+type feedExchange struct {
+	Exchange
+	feed PriceFeed
+}
+
+func (f *feedExchange) LastPrice(pair Pair) (decimal.Decimal, error) {
+	return f.feed.LastPrice(f.Exchange, pair)
+}
@@ -0,0 +1,67 @@
+package domain
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// StrategyInfo describes a strategy for the registry. Ids are stored with
+// agents, so a registered id must never change or be reused. Validate is
+// optional and checks rules that involve several parameters.
+//
+// Upgrades[i] moves stored data from version i to version i+1, so the current
+// data version is len(Upgrades). Upgrades are only ever appended.
+type StrategyInfo struct {
+	Id         StrategyId
+	Name       string
+	FromJson   func(data []byte) (Strategy, error)
+	Parameters func() []StrategyParameter
+	Schema     []ParameterSchema
+	Validate   func(strategy Strategy, exchanges []Exchange) []ParameterError
+	Upgrades   []StrategyUpgrade
+}
+
+// StrategyUpgrade changes decoded strategy data in place. Data sent by users
+// without a version goes through all upgrades too, so an upgrade must keep
+// data that is already in the new format as is.
+type StrategyUpgrade func(data map[string]interface{}) error
+
+var strategiesMu sync.RWMutex
+var strategies = map[StrategyId]StrategyInfo{}
+
+func RegisterStrategy(info StrategyInfo) {
+	strategiesMu.Lock()
+	defer strategiesMu.Unlock()
+
+	if info.Id == 0 || info.FromJson == nil {
+		panic(fmt.Sprintf("strategy %q: id and FromJson are required", info.Name))
+	}
+
+	if _, ok := strategies[info.Id]; ok {
+		panic(fmt.Sprintf("strategy id %d is already registered", info.Id))
+	}
+
+	strategies[info.Id] = info
+}
+
+func GetStrategyInfo(id StrategyId) (StrategyInfo, bool) {
+	strategiesMu.RLock()
+	defer strategiesMu.RUnlock()
+
+	info, ok := strategies[id]
+	return info, ok
+}
+
+func Strategies() []StrategyInfo {
+	strategiesMu.RLock()
+	defer strategiesMu.RUnlock()
+
+	result := []StrategyInfo{}
+	for _, info := range strategies {
+		result = append(result, info)
+	}
+
+	sort.Slice(result, func(i, j int) bool { return result[i].Id < result[j].Id })
+	return result
+}
@@ -0,0 +1,448 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// RiskLimits are checked before an agent places an order, zero values mean
+// no limit. MaxQuoteAllocation, MaxOrdersPerHour and MaxDailyLoss apply to
+// every agent, MaxExposure (base asset -> amount) applies to all agents of a
+// user together and is only read from user limits.
+type RiskLimits struct {
+	MaxQuoteAllocation decimal.Decimal            `json:"max_quote_allocation"`
+	MaxOrdersPerHour   int                        `json:"max_orders_per_hour"`
+	MaxDailyLoss       decimal.Decimal            `json:"max_daily_loss"`
+	MaxExposure        map[string]decimal.Decimal `json:"max_exposure,omitempty"`
+}
+
+func (l RiskLimits) IsZero() bool {
+	return l.MaxQuoteAllocation.IsZero() && l.MaxOrdersPerHour == 0 && l.MaxDailyLoss.IsZero() && len(l.MaxExposure) == 0
+}
+
+// Merge returns agent limits with unset values taken from user limits.
+func (l RiskLimits) Merge(user RiskLimits) RiskLimits {
+	if l.MaxQuoteAllocation.IsZero() {
+		l.MaxQuoteAllocation = user.MaxQuoteAllocation
+	}
+	if l.MaxOrdersPerHour == 0 {
+		l.MaxOrdersPerHour = user.MaxOrdersPerHour
+	}
+	if l.MaxDailyLoss.IsZero() {
+		l.MaxDailyLoss = user.MaxDailyLoss
+	}
+	l.MaxExposure = user.MaxExposure
+
+	return l
+}
+
+type RiskOrderSide = OrderSide
+
+const (
+	BuyRiskOrderSide  RiskOrderSide = BuyOrderSide
+	SellRiskOrderSide RiskOrderSide = SellOrderSide
+)
+
+// RiskOrder is an order placed by an agent, kept to check the limits.
+type RiskOrder struct {
+	Id       int64
+	AgentId  int64
+	UserId   int64
+	OrderId  string
+	Datetime time.Time
+	Side     RiskOrderSide
+	Pair     Pair
+	Amount   decimal.Decimal
+	Price    decimal.Decimal
+}
+
+type RiskOrderFilter struct {
+	AgentId int64
+	UserId  int64
+	Since   time.Time
+}
+
+var ErrRiskLimit = errors.New("risk limit")
+
+type RiskLimitError struct {
+	Limit   string
+	Message string
+}
+
+func (e RiskLimitError) Error() string {
+	return fmt.Sprintf("risk limit %s: %s", e.Limit, e.Message)
+}
+
+func (e RiskLimitError) Is(target error) bool {
+	return target == ErrRiskLimit
+}
+
+// riskGuard is shared by the guarded exchanges of one agent and remembers
+// the first breached limit.
+type riskGuard struct {
+	agent  Agent
+	repo   RiskRepo
+	logger Logger
+	now    func() time.Time
+
+	mu     sync.Mutex
+	breach *RiskLimitError
+}
+
+func guardExchanges(exchanges []Exchange, guard *riskGuard) []Exchange {
+	guarded := []Exchange{}
+	for _, exchange := range exchanges {
+		guarded = append(guarded, &riskExchange{Exchange: exchange, guard: guard})
+	}
+
+	return guarded
+}
+
+func (g *riskGuard) Breach() *RiskLimitError {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	return g.breach
+}
+
+func (g *riskGuard) fail(limit string, message string) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	err := RiskLimitError{Limit: limit, Message: message}
+	if g.breach == nil {
+		g.breach = &err
+	}
+
+	return err
+}
+
+func (g *riskGuard) limits() (RiskLimits, error) {
+	agentLimits, userLimits, err := g.repo.GetRiskLimits(g.agent)
+	if err != nil {
+		return RiskLimits{}, fmt.Errorf("get risk limits: %w", err)
+	}
+
+	return agentLimits.Merge(userLimits), nil
+}
+
+func (g *riskGuard) checkOrdersPerHour(limits RiskLimits) error {
+	if limits.MaxOrdersPerHour == 0 {
+		return nil
+	}
+
+	orders, err := g.repo.FindRiskOrders(RiskOrderFilter{AgentId: g.agent.Id, Since: g.now().Add(-time.Hour)})
+	if err != nil {
+		return err
+	}
+
+	if len(orders) >= limits.MaxOrdersPerHour {
+		return g.fail("max_orders_per_hour", fmt.Sprintf("%d orders in the last hour", len(orders)))
+	}
+
+	return nil
+}
+
+func (g *riskGuard) checkBuy(exchange Exchange, pair Pair, amount decimal.Decimal) error {
+	limits, err := g.limits()
+	if err != nil {
+		return err
+	}
+
+	if limits.IsZero() {
+		return nil
+	}
+
+	err = g.checkOrdersPerHour(limits)
+	if err != nil {
+		return err
+	}
+
+	if !limits.MaxQuoteAllocation.IsZero() {
+		orders, err := g.repo.FindRiskOrders(RiskOrderFilter{AgentId: g.agent.Id})
+		if err != nil {
+			return err
+		}
+
+		price, err := exchange.LastPrice(pair)
+		if err != nil {
+			return err
+		}
+
+		allocation := decimal.Max(quoteAllocation(orders), decimal.Zero).Add(amount.Mul(price))
+		if allocation.GreaterThan(limits.MaxQuoteAllocation) {
+			return g.fail("max_quote_allocation", fmt.Sprintf("%s > %s", allocation, limits.MaxQuoteAllocation))
+		}
+	}
+
+	if maxExposure, ok := limits.MaxExposure[pair.BaseAsset]; ok {
+		orders, err := g.repo.FindRiskOrders(RiskOrderFilter{UserId: g.agent.UserId})
+		if err != nil {
+			return err
+		}
+
+		exposure := baseExposure(orders, pair.BaseAsset).Add(amount)
+		if exposure.GreaterThan(maxExposure) {
+			return g.fail("max_exposure", fmt.Sprintf("%s %s > %s", exposure, pair.BaseAsset, maxExposure))
+		}
+	}
+
+	if !limits.MaxDailyLoss.IsZero() {
+		year, month, day := g.now().UTC().Date()
+		orders, err := g.repo.FindRiskOrders(RiskOrderFilter{
+			AgentId: g.agent.Id,
+			Since:   time.Date(year, month, day, 0, 0, 0, 0, time.UTC),
+		})
+		if err != nil {
+			return err
+		}
+
+		pnl, err := dailyPnl(exchange, orders)
+		if err != nil {
+			return err
+		}
+
+		if pnl.Neg().GreaterThanOrEqual(limits.MaxDailyLoss) {
+			return g.fail("max_daily_loss", fmt.Sprintf("lost %s today", pnl.Neg()))
+		}
+	}
+
+	return nil
+}
+
+func (g *riskGuard) checkSell() error {
+	limits, err := g.limits()
+	if err != nil {
+		return err
+	}
+
+	return g.checkOrdersPerHour(limits)
+}
+
+// record must not fail the order, the strategy has to save it anyway. The
+// requested amount is recorded, because exchanges report only the filled part
+// of a placed order, and canceled shrinks it to the filled part. Market orders
+// without a price are valued at the last price.
+func (g *riskGuard) record(exchange Exchange, side RiskOrderSide, order *Order, pair Pair, amount decimal.Decimal, price decimal.Decimal) {
+	if price.IsZero() {
+		price = order.Price
+	}
+
+	if price.IsZero() {
+		lastPrice, err := exchange.LastPrice(pair)
+		if err != nil {
+			g.logger.Warn(fmt.Sprintf("order(id=%s) is recorded for risk limits without a price: %s", order.Id, err))
+		}
+		price = lastPrice
+	}
+
+	err := g.repo.AddRiskOrder(RiskOrder{
+		AgentId:  g.agent.Id,
+		UserId:   g.agent.UserId,
+		OrderId:  order.Id,
+		Datetime: g.now(),
+		Side:     side,
+		Pair:     pair,
+		Amount:   amount,
+		Price:    price,
+	})
+	if err != nil {
+		g.logger.Error(fmt.Sprintf("order(id=%s) is not recorded for risk limits: %s", order.Id, err))
+	}
+}
+
+// canceled keeps only the filled part of the canceled order. When the fills
+// are unknown the whole order stays, the limits are better too strict than
+// too loose.
+func (g *riskGuard) canceled(exchange Exchange, orderId string, pair Pair) {
+	filled, _, err := orderFills(exchange, pair, orderId)
+	if err != nil {
+		g.logger.Error(fmt.Sprintf("order(id=%s) stays in risk limits, its fills are unknown: %s", orderId, err))
+		return
+	}
+
+	orders, err := g.repo.FindRiskOrders(RiskOrderFilter{AgentId: g.agent.Id})
+	if err != nil {
+		g.logger.Error(fmt.Sprintf("order(id=%s) is not updated in risk limits: %s", orderId, err))
+		return
+	}
+
+	for _, order := range orders {
+		if order.OrderId != orderId {
+			continue
+		}
+
+		err = g.repo.RemoveRiskOrder(g.agent.Id, orderId)
+		if err == nil && filled.IsPositive() {
+			order.Id = 0
+			order.Amount = decimal.Min(order.Amount, filled)
+			err = g.repo.AddRiskOrder(order)
+		}
+		if err != nil {
+			g.logger.Error(fmt.Sprintf("order(id=%s) is not updated in risk limits: %s", orderId, err))
+		}
+
+		return
+	}
+}
+
+// orderFills sums the amount and the commission of the order fills in the
+// history, exchanges report an order filled in parts as several history
+// orders.
+func orderFills(exchange Exchange, pair Pair, orderId string) (decimal.Decimal, Balance, error) {
+	orders, err := exchange.GetHistoryOrders([]Pair{pair})
+	if err != nil {
+		return decimal.Decimal{}, Balance{}, err
+	}
+
+	var filled decimal.Decimal
+	commission := Balance{Asset: pair.QuoteAsset}
+	for _, order := range orders {
+		if order.Id != orderId {
+			continue
+		}
+
+		filled = filled.Add(order.Amount)
+		if order.Commission.Asset != "" {
+			commission.Asset = order.Commission.Asset
+			commission.Amount = commission.Amount.Add(order.Commission.Amount)
+		}
+	}
+
+	return filled, commission, nil
+}
+
+// quoteAllocation is the quote spent on buys minus the quote of sells.
+func quoteAllocation(orders []RiskOrder) decimal.Decimal {
+	var allocation decimal.Decimal
+	for _, order := range orders {
+		quote := order.Amount.Mul(order.Price)
+		if order.Side == BuyRiskOrderSide {
+			allocation = allocation.Add(quote)
+		} else {
+			allocation = allocation.Sub(quote)
+		}
+	}
+
+	return allocation
+}
+
+// baseExposure is the base asset bought minus the base asset sold.
+func baseExposure(orders []RiskOrder, asset string) decimal.Decimal {
+	var exposure decimal.Decimal
+	for _, order := range orders {
+		if order.Pair.BaseAsset != asset {
+			continue
+		}
+
+		if order.Side == BuyRiskOrderSide {
+			exposure = exposure.Add(order.Amount)
+		} else {
+			exposure = exposure.Sub(order.Amount)
+		}
+	}
+
+	return exposure
+}
+
+// dailyPnl values the orders of the day at the last price. Placed sell orders
+// count as filled, so the result is an estimate in the quote asset.
+func dailyPnl(exchange Exchange, orders []RiskOrder) (decimal.Decimal, error) {
+	byPair := map[Pair][]RiskOrder{}
+	for _, order := range orders {
+		byPair[order.Pair] = append(byPair[order.Pair], order)
+	}
+
+	var pnl decimal.Decimal
+	for pair, pairOrders := range byPair {
+		position := baseExposure(pairOrders, pair.BaseAsset)
+		pnl = pnl.Sub(quoteAllocation(pairOrders))
+
+		if !position.IsZero() {
+			price, err := exchange.LastPrice(pair)
+			if err != nil {
+				return decimal.Decimal{}, err
+			}
+
+			pnl = pnl.Add(position.Mul(price))
+		}
+	}
+
+	return pnl, nil
+}
+
+// riskExchange checks the limits of the agent before placing orders and
+// records placed orders.
+type riskExchange struct {
+	Exchange
+	guard *riskGuard
+}
+
+func (r *riskExchange) Buy(pair Pair, amount decimal.Decimal) (*Order, error) {
+	err := r.guard.checkBuy(r.Exchange, pair, amount)
+	if err != nil {
+		return nil, err
+	}
+
+	order, err := r.Exchange.Buy(pair, amount)
+	if err != nil {
+		return nil, err
+	}
+
+	r.guard.record(r.Exchange, BuyRiskOrderSide, order, pair, amount, decimal.Zero)
+	return order, nil
+}
+
+func (r *riskExchange) Sell(pair Pair, amount decimal.Decimal, price decimal.Decimal) (*Order, error) {
+	err := r.guard.checkSell()
+	if err != nil {
+		return nil, err
+	}
+
+	order, err := r.Exchange.Sell(pair, amount, price)
+	if err != nil {
+		return nil, err
+	}
+
+	r.guard.record(r.Exchange, SellRiskOrderSide, order, pair, amount, price)
+	return order, nil
+}
+
+func (r *riskExchange) PlaceOrder(request OrderRequest) (*Order, error) {
+	var err error
+	if request.Side == BuyOrderSide {
+		err = r.guard.checkBuy(r.Exchange, request.Pair, request.Amount)
+	} else {
+		err = r.guard.checkSell()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	order, err := r.Exchange.PlaceOrder(request)
+	if err != nil {
+		return nil, err
+	}
+
+	price := decimal.Zero
+	if request.Type == LimitOrderType {
+		price = request.Price
+	}
+
+	r.guard.record(r.Exchange, request.Side, order, request.Pair, request.Amount, price)
+	return order, nil
+}
+
+func (r *riskExchange) CancelOrder(orderId string, pair Pair) error {
+	err := r.Exchange.CancelOrder(orderId, pair)
+	if err != nil {
+		return err
+	}
+
+	r.guard.canceled(r.Exchange, orderId, pair)
+	return nil
+}
@@ -2,6 +2,9 @@ package domain
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"strings"
 	"sync"
 	"time"
 )
@@ -12,53 +15,281 @@ type Repos struct {
 	Storage  StorageRepo
 	Exchange ExchangeRepo
 	Logger   LoggerRepo
+	Metrics  MetricsRepo
+	Risk     RiskRepo
+	Notifier Notifier
+	Paper    PaperRepo
+	Candles  CandleRepo
+	// Prices defaults to a PriceCache when settings.PriceMaxAge is set.
+	Prices  PriceFeed
+	Control *AgentControl
 }
 
-func StartAgents(ctx context.Context, repos Repos) error {
+type AgentsSettings struct {
+	Interval        time.Duration
+	ShutdownTimeout time.Duration
+	// PriceMaxAge is how old a shared last price may be, 0 turns sharing off.
+	PriceMaxAge time.Duration
+}
+
+var DefaultAgentsSettings = AgentsSettings{Interval: 60 * time.Second, ShutdownTimeout: 30 * time.Second, PriceMaxAge: 10 * time.Second}
+
+// StartAgents runs active agents until ctx is canceled. After that no new
+// cycles are started and running cycles get settings.ShutdownTimeout to finish.
+// An agent that can't be started is logged and skipped, the others still run.
+func StartAgents(ctx context.Context, repos Repos, settings AgentsSettings) error {
 	var wg sync.WaitGroup
+	trackers := map[int64]*agentTracker{}
+	loggers := map[int64]Logger{}
 
 	agents, err := repos.Agent.FindAgents(true)
 	if err != nil {
 		return err
 	}
 
+	prices := repos.Prices
+	if prices == nil && settings.PriceMaxAge > 0 {
+		prices = NewPriceCache(settings.PriceMaxAge)
+	}
+
 	for _, agent := range agents {
-		strategy := GetStrategyFromJson(agent.StrategyId, agent.StrategyData)
+		agent := agent
+		skip := func(reason string) {
+			repos.Logger.New(LoggerLabels{AgentId: agent.Id}).Error("agent not started: " + reason)
+		}
+
+		strategy, err := GetStrategyFromJson(agent.StrategyId, agent.StrategyData)
+		if err != nil {
+			skip(err.Error())
+			continue
+		}
+
 		storage := repos.Storage.GetAgentStorage(agent)
-		exchanges, err := repos.Exchange.GetAgentExchanges(agent.Id)
+		if storage == nil {
+			skip(fmt.Sprintf("no storage for strategy id %d", agent.StrategyId))
+			continue
+		}
+
+		if agent.DryRun && repos.Paper == nil {
+			skip("no paper repo for dry run")
+			continue
+		}
+
+		version, err := repos.Exchange.AgentExchangesVersion(agent.Id)
 		if err != nil {
-			return err
+			skip(err.Error())
+			continue
+		}
+
+		accounts, err := repos.Exchange.GetAgentExchanges(agent.Id)
+		if err != nil {
+			skip(err.Error())
+			continue
+		}
+		logger := repos.Logger.New(LoggerLabels{
+			AgentId:  agent.Id,
+			Strategy: strategy.Name(),
+			Pair:     strategyPairs(strategy),
+		})
+
+		var metrics Metrics
+		if repos.Metrics != nil {
+			metrics = repos.Metrics.New(agent.Id)
 		}
-		logger := repos.Logger.New(agent.Id)
+
+		// simulated orders don't risk funds and must not count in user limits
+		var guard *riskGuard
+		if repos.Risk != nil && !agent.DryRun {
+			guard = &riskGuard{agent: agent, repo: repos.Risk, logger: logger, now: time.Now}
+		}
+
+		// wrap builds the exchanges of the strategy from the accounts, again
+		// when their keys are rotated
+		wrap := func(exchanges []Exchange) []Exchange {
+			if metrics != nil {
+				exchanges = meterExchanges(exchanges, metrics)
+			}
+
+			if prices != nil {
+				exchanges = feedExchanges(exchanges, prices)
+			}
+
+			if repos.Candles != nil {
+				exchanges = candleExchanges(exchanges, repos.Candles)
+			}
+
+			if agent.DryRun {
+				exchanges = paperExchanges(exchanges, agent.Id, repos.Paper)
+			}
+
+			if guard != nil {
+				exchanges = guardExchanges(exchanges, guard)
+			}
+
+			return exchanges
+		}
+		exchanges := wrap(accounts)
+
+		tracker := &agentTracker{state: AgentState{AgentId: agent.Id, Running: true}}
+		trackers[agent.Id] = tracker
+		loggers[agent.Id] = logger
+		agentCtx, cancelAgent := context.WithCancel(ctx)
+		runCtx := context.WithValue(agentCtx, trackerKey{}, tracker)
 
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
+			defer cancelAgent()
+			if repos.Control != nil {
+				defer repos.Control.register(agent.Id, cancelAgent)()
+			}
+			defer func() {
+				tracker.update(func(state *AgentState) { state.Running = false })
+				logger.Info(strings.TrimSpace("agent stopped: " + tracker.State().Summary() + " " + tradesSummary(storage)))
+			}()
+
+			if agent.DryRun {
+				logger.Info("agent started (dry run)")
+			} else {
+				logger.Info("agent started")
+			}
 
-			logger.Info("agent started")
+			wake := make(chan struct{}, 1)
+			// streams are started again with rotated keys, the streams of
+			// the old keys are stopped
+			cancelStreams := func() {}
+			startStreams := func(accounts []Exchange) {
+				cancelStreams()
+				streams := agentStreams(accounts)
+				if len(streams) == 0 {
+					return
+				}
+
+				var streamCtx context.Context
+				streamCtx, cancelStreams = context.WithCancel(agentCtx)
+				watchStreams(streamCtx, streams, strategyPairList(strategy), prices, wake, logger)
+			}
+			startStreams(accounts)
+			defer func() { cancelStreams() }()
 
 			workCycle := true
 
 			for workCycle {
 
-				err = strategy.Run(ctx, storage, exchanges, logger)
+				status, err := repos.Agent.GetAgentStatus(agent.Id)
+				if err != nil {
+					logger.Error("can't check agent status: " + err.Error())
+				} else if status != ActiveAgentStatus {
+					logger.Info("agent halted: status " + AgentStatusNames[status])
+					break
+				}
+
+				current, err := repos.Exchange.AgentExchangesVersion(agent.Id)
+				if err != nil {
+					logger.Error("can't check exchange accounts: " + err.Error())
+				} else if current != version {
+					accounts, err := repos.Exchange.GetAgentExchanges(agent.Id)
+					if err != nil {
+						logger.Error("can't reload exchange accounts: " + err.Error())
+					} else {
+						exchanges = wrap(accounts)
+						startStreams(accounts)
+						version = current
+						logger.Info("exchange accounts changed, the new keys are used")
+					}
+				}
+
+				started := time.Now()
+				err = strategy.Run(runCtx, storage, exchanges, logger)
 				if err != nil {
 					logger.Error(err.Error())
 				}
 
+				tracker.update(func(state *AgentState) {
+					state.Cycles++
+					state.LastCycle = started
+					state.LastError = err
+				})
+
+				if metrics != nil {
+					metrics.CycleFinished(time.Since(started), err)
+					reportTrades(storage, metrics, logger)
+				}
+
+				if guard != nil && guard.Breach() != nil {
+					pauseAgent(repos, agent, guard.Breach().Error(), logger)
+					break
+				}
+
+				// other cycles would fail the same way until the keys are fixed
+				if errors.Is(err, ErrAuth) {
+					failAgent(repos, agent, err.Error(), logger)
+					break
+				}
+
 				select {
-				case <-ctx.Done():
+				case <-agentCtx.Done():
 					workCycle = false
-				case <-time.After(60 * time.Second):
+				case <-wake:
+					continue
+				case <-time.After(settings.Interval):
 					continue
 				}
 
 			}
-
-			logger.Info("agent stopped")
 		}()
 	}
 
-	wg.Wait()
-	return nil
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+	}
+
+	select {
+	case <-done:
+		return nil
+	case <-time.After(settings.ShutdownTimeout):
+	}
+
+	for agentId, tracker := range trackers {
+		state := tracker.State()
+		if state.Running {
+			loggers[agentId].Error("agent didn't stop in time: " + state.Summary())
+		}
+	}
+
+	return ErrShutdownTimeout
+}
+
+// pauseAgent stops the agent from being started again and tells its user why.
+func pauseAgent(repos Repos, agent Agent, reason string, logger Logger) {
+	logger.Warn("agent paused: " + reason)
+	stopAgent(repos, agent, PausedAgentStatus, fmt.Sprintf("Agent %d is paused: %s", agent.Id, reason), logger)
+}
+
+// failAgent is pauseAgent for errors the agent can't recover from itself.
+func failAgent(repos Repos, agent Agent, reason string, logger Logger) {
+	logger.Error("agent stopped: " + reason)
+	stopAgent(repos, agent, ErrorAgentStatus, fmt.Sprintf("Agent %d is stopped by an error: %s", agent.Id, reason), logger)
+}
+
+func stopAgent(repos Repos, agent Agent, status AgentStatus, message string, logger Logger) {
+	err := repos.Agent.SetAgentStatus(agent, status)
+	if err != nil {
+		logger.Error("can't set agent status: " + err.Error())
+	}
+
+	if repos.Notifier != nil {
+		err = repos.Notifier.Notify(agent.UserId, message)
+		if err != nil {
+			logger.Error("can't notify user: " + err.Error())
+		}
+	}
 }
@@ -0,0 +1,96 @@
+package domain
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+var ErrShutdownTimeout = errors.New("agents didn't stop before shutdown timeout")
+
+type AgentState struct {
+	AgentId   int64
+	Running   bool
+	Cycles    int
+	LastCycle time.Time
+	LastError error
+	Critical  string
+}
+
+type agentTracker struct {
+	mu    sync.Mutex
+	state AgentState
+}
+
+func (t *agentTracker) update(fn func(state *AgentState)) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	fn(&t.state)
+}
+
+func (t *agentTracker) State() AgentState {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.state
+}
+
+type trackerKey struct{}
+
+// CriticalSection runs fn as a unit that shutdown waits for, e.g. an order
+// placed on the exchange and not saved to storage yet. Strategies must not
+// check ctx inside fn.
+func CriticalSection(ctx context.Context, name string, fn func() error) error {
+	tracker, ok := ctx.Value(trackerKey{}).(*agentTracker)
+	if !ok {
+		return fn()
+	}
+
+	tracker.update(func(state *AgentState) { state.Critical = name })
+	defer tracker.update(func(state *AgentState) { state.Critical = "" })
+
+	return fn()
+}
+
+func (s AgentState) Summary() string {
+	parts := []string{fmt.Sprintf("cycles=%d", s.Cycles)}
+
+	if !s.LastCycle.IsZero() {
+		parts = append(parts, "last_cycle="+s.LastCycle.Format(time.RFC3339))
+	}
+
+	if s.LastError != nil {
+		parts = append(parts, "last_error="+s.LastError.Error())
+	}
+
+	if s.Critical != "" {
+		parts = append(parts, "critical="+s.Critical)
+	}
+
+	return strings.Join(parts, " ")
+}
+
+func tradesSummary(storage interface{}) string {
+	counter, ok := storage.(TradeCounter)
+	if !ok {
+		return ""
+	}
+
+	counts, err := counter.CountTrades()
+	if err != nil {
+		return "trades=error(" + err.Error() + ")"
+	}
+
+	statuses := []string{}
+	for status, count := range counts {
+		statuses = append(statuses, fmt.Sprintf("%s:%d", status, count))
+	}
+	sort.Strings(statuses)
+
+	return "trades=" + strings.Join(statuses, ",")
+}
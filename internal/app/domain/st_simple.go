@@ -8,21 +8,304 @@ import (
 	"strconv"
 	"time"
 
+	"github.com/scientistnik/invest-agents/internal/app/domain/indicators"
 	"github.com/shopspring/decimal"
 )
 
 var Version string = "v0.3.0"
 
+// SimpleStrategy trades every pair of Pairs on its own, BaseQuantity and
+// MaxTrades are defaults for pairs without overrides. QuoteBudget caps the
+// quote spent on all open trades together, zero means no cap.
+//
+// Entry fields are optional conditions of a buy calculated on candles of
+// EntryInterval: the last price is below the EMA, the RSI is below
+// EntryRsiBelow and the ATR is at least EntryMinAtrPercent of the price.
+// A zero period turns its condition off.
+//
+// A limit entry is placed EntryLimitOffset below the last price and is
+// repriced when it isn't filled in EntryLimitTimeout minutes.
 type SimpleStrategy struct {
-	Pair            Pair            `json:"pair"`
-	BaseQuality     decimal.Decimal `json:"base_quality"`
-	MaxTrades       int             `json:"max_trades"`
-	ProfitPercent   decimal.Decimal `json:"profit_percent"`
-	FarPricePercent decimal.Decimal `json:"far_price_percent"`
+	Pairs              []SimplePair    `json:"pairs"`
+	BaseQuantity       decimal.Decimal `json:"base_quantity"`
+	MaxTrades          int             `json:"max_trades"`
+	QuoteBudget        decimal.Decimal `json:"quote_budget"`
+	ProfitPercent      decimal.Decimal `json:"profit_percent"`
+	FarPricePercent    decimal.Decimal `json:"far_price_percent"`
+	EntryInterval      CandleInterval  `json:"entry_interval,omitempty"`
+	EntryEmaPeriod     int             `json:"entry_ema_period,omitempty"`
+	EntryRsiPeriod     int             `json:"entry_rsi_period,omitempty"`
+	EntryRsiBelow      int             `json:"entry_rsi_below,omitempty"`
+	EntryAtrPeriod     int             `json:"entry_atr_period,omitempty"`
+	EntryMinAtrPercent decimal.Decimal `json:"entry_min_atr_percent,omitempty"`
+	EntryOrderType     OrderType       `json:"entry_order_type,omitempty"`
+	EntryLimitOffset   decimal.Decimal `json:"entry_limit_offset,omitempty"`
+	EntryLimitTimeout  int             `json:"entry_limit_timeout,omitempty"`
+}
+
+type SimplePair struct {
+	Pair         Pair             `json:"pair"`
+	BaseQuantity *decimal.Decimal `json:"base_quantity,omitempty"`
+	MaxTrades    *int             `json:"max_trades,omitempty"`
 }
 
 var _ Strategy = (*SimpleStrategy)(nil)
 
+func init() {
+	RegisterStrategy(StrategyInfo{
+		Id:   SimpleStratedy,
+		Name: SimpleStrategy{}.Name(),
+		FromJson: func(data []byte) (Strategy, error) {
+			return NewSimpleStrategyFromJson(data)
+		},
+		Parameters: SimpleStrategy{}.Parameters,
+		Schema:     simpleSchema,
+		Validate:   validateSimpleStrategy,
+		Upgrades: []StrategyUpgrade{
+			// v1: base_quality was renamed to base_quantity.
+			func(data map[string]interface{}) error {
+				if value, ok := data["base_quality"]; ok {
+					if _, ok := data["base_quantity"]; !ok {
+						data["base_quantity"] = value
+					}
+					delete(data, "base_quality")
+				}
+				return nil
+			},
+			// v2: a single pair became a list of pairs.
+			func(data map[string]interface{}) error {
+				if value, ok := data["pair"]; ok {
+					if _, ok := data["pairs"]; !ok {
+						data["pairs"] = []interface{}{map[string]interface{}{"pair": value}}
+					}
+					delete(data, "pair")
+				}
+				return nil
+			},
+		},
+	})
+}
+
+var simpleSchema = []ParameterSchema{
+	{
+		Key:         "pairs",
+		Name:        "Pairs",
+		Type:        ListParameterType,
+		Description: "Traded pairs, the strategy buys the base asset for the quote asset",
+		Required:    true,
+		Items: []ParameterSchema{
+			{
+				Key:         "pair",
+				Name:        "Pair",
+				Type:        PairParameterType,
+				Description: "Traded pair",
+				Required:    true,
+			},
+			{
+				Key:         "base_quantity",
+				Name:        "BaseQuantity",
+				Type:        BalanceParameterType,
+				Description: "Amount of the base asset bought by one trade of the pair",
+				Min:         parameterLimit("0.0001"),
+			},
+			{
+				Key:         "max_trades",
+				Name:        "MaxTrades",
+				Type:        IntParameterType,
+				Description: "Maximum number of open trades of the pair",
+				Min:         parameterLimit("1"),
+				Max:         parameterLimit("1000"),
+			},
+		},
+	},
+	{
+		Key:         "base_quantity",
+		Name:        "BaseQuantity",
+		Type:        BalanceParameterType,
+		Description: "Amount of the base asset bought by one trade",
+		Required:    true,
+		Min:         parameterLimit("0.0001"),
+	},
+	{
+		Key:         "max_trades",
+		Name:        "MaxTrades",
+		Type:        IntParameterType,
+		Description: "Maximum number of trades open at the same time for one pair",
+		Min:         parameterLimit("1"),
+		Max:         parameterLimit("1000"),
+		Default:     1,
+	},
+	{
+		Key:         "quote_budget",
+		Name:        "QuoteBudget",
+		Type:        BalanceParameterType,
+		Description: "Quote asset spent on all open trades together, 0 for no limit",
+		Min:         parameterLimit("0"),
+	},
+	{
+		Key:         "profit_percent",
+		Name:        "Profit",
+		Type:        PercentParameterType,
+		Description: "Profit of a trade over its buy price, must cover the round-trip fees",
+		Required:    true,
+		Min:         parameterLimit("0"),
+		Max:         parameterLimit("1"),
+	},
+	{
+		Key:         "far_price_percent",
+		Name:        "FarPricePercent",
+		Type:        PercentParameterType,
+		Description: "Minimal distance between the last price and open trades to buy again",
+		Min:         parameterLimit("0"),
+		Max:         parameterLimit("1"),
+		Default:     "0.01",
+	},
+	{
+		Key:         "entry_interval",
+		Name:        "EntryInterval",
+		Type:        StringParameterType,
+		Description: "Candle interval of the entry conditions",
+		Choices:     candleIntervalChoices(),
+		Default:     string(HourCandleInterval),
+	},
+	{
+		Key:         "entry_ema_period",
+		Name:        "EntryEmaPeriod",
+		Type:        IntParameterType,
+		Description: "Buy only below the EMA of this many candles, 0 to turn off",
+		Min:         parameterLimit("0"),
+		Max:         parameterLimit("500"),
+	},
+	{
+		Key:         "entry_rsi_period",
+		Name:        "EntryRsiPeriod",
+		Type:        IntParameterType,
+		Description: "Buy only when the RSI of this many candles is below entry_rsi_below, 0 to turn off",
+		Min:         parameterLimit("0"),
+		Max:         parameterLimit("500"),
+	},
+	{
+		Key:         "entry_rsi_below",
+		Name:        "EntryRsiBelow",
+		Type:        IntParameterType,
+		Description: "RSI level to buy below, e.g. 30 for oversold",
+		Min:         parameterLimit("0"),
+		Max:         parameterLimit("100"),
+	},
+	{
+		Key:         "entry_atr_period",
+		Name:        "EntryAtrPeriod",
+		Type:        IntParameterType,
+		Description: "Buy only when the ATR of this many candles reaches entry_min_atr_percent, 0 to turn off",
+		Min:         parameterLimit("0"),
+		Max:         parameterLimit("500"),
+	},
+	{
+		Key:         "entry_min_atr_percent",
+		Name:        "EntryMinAtrPercent",
+		Type:        PercentParameterType,
+		Description: "Minimal ATR as a share of the last price",
+		Min:         parameterLimit("0"),
+		Max:         parameterLimit("1"),
+	},
+	{
+		Key:         "entry_order_type",
+		Name:        "EntryOrderType",
+		Type:        StringParameterType,
+		Description: "Order type of buys, limit orders pay maker fees",
+		Choices:     []string{MarketOrderType, LimitOrderType},
+		Default:     MarketOrderType,
+	},
+	{
+		Key:         "entry_limit_offset",
+		Name:        "EntryLimitOffset",
+		Type:        PercentParameterType,
+		Description: "Distance of a limit buy below the last price",
+		Min:         parameterLimit("0"),
+		Max:         parameterLimit("0.1"),
+		Default:     "0.001",
+	},
+	{
+		Key:         "entry_limit_timeout",
+		Name:        "EntryLimitTimeout",
+		Type:        IntParameterType,
+		Description: "Minutes a limit buy waits for fill before it is repriced",
+		Min:         parameterLimit("1"),
+		Max:         parameterLimit("1440"),
+		Default:     10,
+	},
+}
+
+func candleIntervalChoices() []string {
+	choices := []string{}
+	for _, interval := range []CandleInterval{
+		MinuteCandleInterval,
+		FiveMinutesCandleInterval,
+		FifteenMinutesCandleInterval,
+		HalfHourCandleInterval,
+		HourCandleInterval,
+		FourHoursCandleInterval,
+		DayCandleInterval,
+		WeekCandleInterval,
+	} {
+		choices = append(choices, string(interval))
+	}
+
+	return choices
+}
+
+func validateSimpleStrategy(strategy Strategy, exchanges []Exchange) []ParameterError {
+	s, ok := strategy.(*SimpleStrategy)
+	if !ok {
+		return nil
+	}
+
+	problems := []ParameterError{}
+	seen := map[Pair]bool{}
+	for i, config := range s.Pairs {
+		if seen[config.Pair] {
+			problems = append(problems, ParameterError{Key: fmt.Sprintf("pairs[%d].pair", i), Message: "is duplicated"})
+		}
+		seen[config.Pair] = true
+
+		if s.QuoteBudget.IsPositive() && config.Pair.QuoteAsset != s.Pairs[0].Pair.QuoteAsset {
+			problems = append(problems, ParameterError{
+				Key:     fmt.Sprintf("pairs[%d].pair", i),
+				Message: "quote_budget needs the same quote asset for all pairs",
+			})
+		}
+	}
+
+	if s.EntryRsiPeriod > 0 && s.EntryRsiBelow == 0 {
+		problems = append(problems, ParameterError{Key: "entry_rsi_below", Message: "is required with entry_rsi_period"})
+	}
+
+	if s.EntryAtrPeriod > 0 && !s.EntryMinAtrPercent.IsPositive() {
+		problems = append(problems, ParameterError{Key: "entry_min_atr_percent", Message: "is required with entry_atr_period"})
+	}
+
+	if len(problems) > 0 || len(exchanges) == 0 {
+		return problems
+	}
+
+	for _, config := range s.Pairs {
+		fee, err := exchanges[0].GetPairFee(config.Pair)
+		if err != nil {
+			return []ParameterError{{Key: "profit_percent", Message: "can't get pair fee: " + err.Error()}}
+		}
+
+		roundTrip := fee.Amount.Mul(decimal.NewFromInt(2))
+		if !s.ProfitPercent.GreaterThan(roundTrip) {
+			return []ParameterError{{
+				Key:     "profit_percent",
+				Message: fmt.Sprintf("must exceed round-trip fees %s of %s", roundTrip.String(), config.Pair),
+			}}
+		}
+	}
+
+	return nil
+}
+
 type SimpleTradeStatus = int
 
 const (
@@ -30,8 +313,18 @@ const (
 	SimpleTradeStatusBuy    SimpleTradeStatus = iota
 	SimpleTradeStatusSell   SimpleTradeStatus = iota
 	SimpleTradeStatusFinish SimpleTradeStatus = iota
+	// SimpleTradeStatusCanceled trades were saved before the buy and the
+	// exchange refused it.
+	SimpleTradeStatusCanceled SimpleTradeStatus = iota
 )
 
+var SimpleTradeStatusNames = map[SimpleTradeStatus]string{
+	SimpleTradeStatusBuy:      "buy",
+	SimpleTradeStatusSell:     "sell",
+	SimpleTradeStatusFinish:   "finish",
+	SimpleTradeStatusCanceled: "canceled",
+}
+
 type SimpleTradeFilter struct {
 	Statuses []SimpleTradeStatus
 }
@@ -48,10 +341,14 @@ type SimpleTradeOrder struct {
 	Price    decimal.Decimal
 	//amount     decimal.Decimal
 	Commission Balance
+	// ClientOrderId is saved before the order is placed, a trade without
+	// OrderId looks its order up by it.
+	ClientOrderId string
 }
 
 type SimpleTrade struct {
 	Id     int
+	Pair   Pair
 	Status SimpleTradeStatus
 	Amount decimal.Decimal
 	Buy    SimpleTradeOrder
@@ -77,22 +374,54 @@ func (s SimpleStrategy) Name() string {
 	return "Simple"
 }
 
+func (s SimpleStrategy) pairBaseQuantity(config SimplePair) decimal.Decimal {
+	if config.BaseQuantity != nil {
+		return *config.BaseQuantity
+	}
+
+	return s.BaseQuantity
+}
+
+func (s SimpleStrategy) pairMaxTrades(config SimplePair) int {
+	if config.MaxTrades != nil {
+		return *config.MaxTrades
+	}
+
+	return s.MaxTrades
+}
+
 func (s SimpleStrategy) Parameters() []StrategyParameter {
-	return []StrategyParameter{
-		StrategyParameter{
-			Type:  PairParameterType,
-			Name:  "Pair",
-			Value: s.Pair,
-		},
+	params := []StrategyParameter{}
+	for _, config := range s.Pairs {
+		params = append(params,
+			StrategyParameter{
+				Type:  PairParameterType,
+				Name:  "Pair",
+				Value: config.Pair,
+			},
+			StrategyParameter{
+				Type:  BalanceParameterType,
+				Name:  "BaseQuantity",
+				Value: Balance{Asset: config.Pair.BaseAsset, Amount: s.pairBaseQuantity(config)},
+			},
+			StrategyParameter{
+				Type:  IntParameterType,
+				Name:  "MaxTrades",
+				Value: s.pairMaxTrades(config),
+			},
+		)
+	}
+
+	var quoteAsset string
+	if len(s.Pairs) > 0 {
+		quoteAsset = s.Pairs[0].Pair.QuoteAsset
+	}
+
+	params = append(params,
 		StrategyParameter{
 			Type:  BalanceParameterType,
-			Name:  "BaseQuantity",
-			Value: Balance{Asset: s.Pair.BaseAsset, Amount: s.BaseQuality},
-		},
-		StrategyParameter{
-			Type:  IntParameterType,
-			Name:  "MaxTrades",
-			Value: s.MaxTrades,
+			Name:  "QuoteBudget",
+			Value: Balance{Asset: quoteAsset, Amount: s.QuoteBudget},
 		},
 		StrategyParameter{
 			Type:  PercentParameterType,
@@ -104,11 +433,41 @@ func (s SimpleStrategy) Parameters() []StrategyParameter {
 			Name:  "FarPricePercent",
 			Value: s.FarPricePercent,
 		},
+	)
+	if s.EntryOrderType == LimitOrderType {
+		params = append(params,
+			StrategyParameter{Type: StringParameterType, Name: "EntryOrderType", Value: s.EntryOrderType},
+			StrategyParameter{Type: PercentParameterType, Name: "EntryLimitOffset", Value: s.EntryLimitOffset},
+			StrategyParameter{Type: IntParameterType, Name: "EntryLimitTimeout", Value: s.EntryLimitTimeout},
+		)
+	}
+
+	if s.entryCandles() == 0 {
+		return params
 	}
+
+	return append(params,
+		StrategyParameter{Type: StringParameterType, Name: "EntryInterval", Value: string(s.EntryInterval)},
+		StrategyParameter{Type: IntParameterType, Name: "EntryEmaPeriod", Value: s.EntryEmaPeriod},
+		StrategyParameter{Type: IntParameterType, Name: "EntryRsiPeriod", Value: s.EntryRsiPeriod},
+		StrategyParameter{Type: IntParameterType, Name: "EntryRsiBelow", Value: s.EntryRsiBelow},
+		StrategyParameter{Type: IntParameterType, Name: "EntryAtrPeriod", Value: s.EntryAtrPeriod},
+		StrategyParameter{Type: PercentParameterType, Name: "EntryMinAtrPercent", Value: s.EntryMinAtrPercent},
+	)
 }
 
 func (s SimpleStrategy) ValidateParameter(param StrategyParameter) bool {
-	return true
+	schemas := append([]ParameterSchema{}, simpleSchema...)
+	if pairs := findSchema(simpleSchema, "pairs"); pairs != nil {
+		schemas = append(schemas, pairs.Items...)
+	}
+	for _, schema := range schemas {
+		if schema.Name == param.Name {
+			return schema.Type == param.Type && schema.Check(param.Value) == ""
+		}
+	}
+
+	return false
 }
 
 func (s *SimpleStrategy) Run(ctx context.Context, _storage interface{}, exchanges []Exchange, logger Logger) error {
@@ -124,27 +483,29 @@ func (s *SimpleStrategy) Run(ctx context.Context, _storage interface{}, exchange
 
 	logger.Info("new cycle " + Version)
 
-	balances, err := exchange.Balances([]string{s.Pair.BaseAsset, s.Pair.QuoteAsset})
+	pairs := []Pair{}
+	assets := []string{}
+	for _, config := range s.Pairs {
+		pairs = append(pairs, config.Pair)
+		for _, asset := range []string{config.Pair.BaseAsset, config.Pair.QuoteAsset} {
+			if !containsString(assets, asset) {
+				assets = append(assets, asset)
+			}
+		}
+	}
+
+	balances, err := exchange.Balances(assets)
 	if err != nil {
 		return fmt.Errorf("balance error: %w", err)
 	}
 
-	var baseBalance Balance
-	var quoteBalance Balance
+	funds := map[string]decimal.Decimal{}
 	for _, balance := range balances {
-		if balance.Asset == s.Pair.BaseAsset {
-			baseBalance = balance
-		}
-
-		if balance.Asset == s.Pair.QuoteAsset {
-			quoteBalance = balance
-		}
+		funds[balance.Asset] = balance.Amount
+		logger.Debug(balance.Asset + "= " + balance.Amount.String())
 	}
 
-	logger.Debug(s.Pair.BaseAsset + "= " + baseBalance.Amount.String())
-	logger.Debug(s.Pair.QuoteAsset + "= " + quoteBalance.Amount.String())
-
-	openOrders, err := exchange.GetOpenOrders(&OrderFilter{Pairs: []Pair{s.Pair}})
+	openOrders, err := exchange.GetOpenOrders(&OrderFilter{Pairs: pairs})
 	if err != nil {
 		return fmt.Errorf("don't get open orders with error: %w", err)
 	}
@@ -155,6 +516,13 @@ func (s *SimpleStrategy) Run(ctx context.Context, _storage interface{}, exchange
 		return fmt.Errorf("get trades error: %w", err)
 	}
 
+	// trades saved before pairs became a list have no pair
+	for i := range trades {
+		if trades[i].Pair == (Pair{}) && len(s.Pairs) > 0 {
+			trades[i].Pair = s.Pairs[0].Pair
+		}
+	}
+
 	sellOpenOrders := []SimpleTrade{}
 	buyOpenOrders := []SimpleTrade{}
 	for _, trade := range trades {
@@ -168,7 +536,7 @@ func (s *SimpleStrategy) Run(ctx context.Context, _storage interface{}, exchange
 	}
 
 	if len(sellOpenOrders) > 0 || len(buyOpenOrders) > 0 {
-		historyOrders, err := exchange.GetHistoryOrders([]Pair{s.Pair})
+		historyOrders, err := exchange.GetHistoryOrders(pairs)
 		if err != nil {
 			return fmt.Errorf("get history orders error: %w", err)
 		}
@@ -201,6 +569,18 @@ func (s *SimpleStrategy) Run(ctx context.Context, _storage interface{}, exchange
 				if trade.Buy.OrderId == hOrder.Id {
 					if hOrder.Status == FillOrderStatus {
 						trade.Status = SimpleTradeStatusSell
+						if trade.Buy.Commission.Asset == "" {
+							trade.Buy.Commission = hOrder.Commission
+						}
+
+						err := storage.SaveTrade(&trade)
+						if err != nil {
+							return err
+						}
+					} else if hOrder.Status == CanceledOrderStatus {
+						// the buy is placed again next cycle
+						trade.Buy.OrderId = ""
+						trade.Buy.ClientOrderId = ""
 
 						err := storage.SaveTrade(&trade)
 						if err != nil {
@@ -212,6 +592,56 @@ func (s *SimpleStrategy) Run(ctx context.Context, _storage interface{}, exchange
 		}
 	}
 
+	err = s.resumeEntries(ctx, trades, openOrders, storage, exchange, logger)
+	if err != nil {
+		return err
+	}
+
+	var committed decimal.Decimal
+	for _, trade := range trades {
+		if trade.Status != SimpleTradeStatusFinish {
+			committed = committed.Add(trade.Buy.Price.Mul(trade.Amount))
+		}
+	}
+
+	for _, config := range s.Pairs {
+		select {
+		case <-ctx.Done():
+			return nil
+		default:
+		}
+
+		pairTrades := []SimpleTrade{}
+		for _, trade := range trades {
+			if trade.Pair == config.Pair {
+				pairTrades = append(pairTrades, trade)
+			}
+		}
+
+		err = s.runPair(ctx, config, pairTrades, funds, &committed, storage, exchange, logger)
+		if err != nil {
+			return fmt.Errorf("pair %s: %w", config.Pair, err)
+		}
+	}
+
+	return nil
+}
+
+// runPair buys and sells one pair, funds and committed are shared by pairs
+// and updated after a buy.
+func (s *SimpleStrategy) runPair(
+	ctx context.Context,
+	config SimplePair,
+	trades []SimpleTrade,
+	funds map[string]decimal.Decimal,
+	committed *decimal.Decimal,
+	storage SimpleStorage,
+	exchange Exchange,
+	logger Logger,
+) error {
+	pair := config.Pair
+	maxTrades := s.pairMaxTrades(config)
+
 	processedTrades := []SimpleTrade{}
 	for _, trade := range trades {
 		if trade.Status != SimpleTradeStatusFinish {
@@ -219,19 +649,28 @@ func (s *SimpleStrategy) Run(ctx context.Context, _storage interface{}, exchange
 		}
 	}
 
-	lastPrice, err := exchange.LastPrice(s.Pair)
+	fund := funds[pair.QuoteAsset]
+	if s.QuoteBudget.IsPositive() {
+		left := s.QuoteBudget.Sub(*committed)
+		if left.LessThan(fund) {
+			fund = left
+		}
+	}
+
+	lastPrice, err := exchange.LastPrice(pair)
 	if err != nil {
 		return fmt.Errorf("Exchange last price error: %w", err)
 	}
-	logger.Info("current price: " + lastPrice.String())
+	logger.Info(pair.String() + " current price: " + lastPrice.String())
 
-	amount, isAvailableFunds := s.availableFundCheck(
-		quoteBalance.Amount,
+	amount, isAvailableFunds := availableFundCheck(
+		s.pairBaseQuantity(config),
+		fund,
 		decimal.NewFromFloat(0.0001),
 		lastPrice,
 		decimal.NewFromInt(10),
 		func(amount decimal.Decimal) decimal.Decimal {
-			fee, _ := exchange.GetOrderFee(s.Pair, amount, lastPrice)
+			fee, _ := exchange.GetOrderFee(pair, amount, lastPrice)
 			return fee.Amount
 		},
 	)
@@ -246,67 +685,144 @@ func (s *SimpleStrategy) Run(ctx context.Context, _storage interface{}, exchange
 
 	farPrice := minSpread.GreaterThan(s.FarPricePercent)
 
+	// candles are asked only when everything else allows a buy
+	entry, entryReason := true, "off"
+	if len(processedTrades) < maxTrades && isAvailableFunds && farPrice && s.entryCandles() > 0 {
+		entry, entryReason, err = s.entryAllowed(pair, lastPrice, exchange)
+		if err != nil {
+			logger.Warn(fmt.Sprintf("%s entry conditions: %s", pair, err))
+			entry, entryReason = false, "error"
+		}
+	}
+
 	logger.Debug(fmt.Sprintf(
-		"need new order: max_trades=%t (%d<%d), funds=%t (%s), farPrice=%t (%s>%s)",
-		len(processedTrades) < s.MaxTrades,
+		"%s need new order: max_trades=%t (%d<%d), funds=%t (%s), farPrice=%t (%s>%s), entry=%t (%s)",
+		pair,
+		len(processedTrades) < maxTrades,
 		len(processedTrades),
-		s.MaxTrades,
+		maxTrades,
 		isAvailableFunds,
 		amount.String(),
 		farPrice,
 		minSpread.String(),
 		s.FarPricePercent.String(),
+		entry,
+		entryReason,
 	))
 
-	if len(processedTrades) < s.MaxTrades && isAvailableFunds && farPrice {
+	if len(processedTrades) < maxTrades && isAvailableFunds && farPrice && entry {
 		select {
 		case <-ctx.Done():
 			return nil
 		default:
 		}
 
-		logger.Info("buy: " + s.BaseQuality.String())
-
-		buyOrder, err := exchange.Buy(s.Pair, amount)
-		if err != nil {
-			return fmt.Errorf("exchange buy error: %w", err)
-		}
+		logger.Info(pair.String() + " buy: " + amount.String())
 
 		trade := SimpleTrade{
+			Pair:   pair,
 			Status: SimpleTradeStatusBuy,
-			Amount: buyOrder.Amount,
+			Amount: amount,
 			Buy: SimpleTradeOrder{
-				OrderId:    buyOrder.Id,
-				Datetime:   time.Now().Format(time.RFC3339),
-				Price:      buyOrder.Price,
-				Commission: buyOrder.Commission,
+				Datetime:      time.Now().Format(time.RFC3339),
+				Price:         lastPrice,
+				ClientOrderId: NewClientOrderId(),
 			},
 		}
 
-		if buyOrder.Status == FillOrderStatus {
-			trade.Status = SimpleTradeStatusSell
-		}
+		var buyErr error
+		err = CriticalSection(ctx, "buy", func() error {
+			// saved before the buy, so a restart finds the order by its client id
+			err := storage.SaveTrade(&trade)
+			if err != nil {
+				return fmt.Errorf("storage save trades error, buy is not placed: %w", err)
+			}
+
+			buyOrder, err := s.placeEntry(pair, amount, lastPrice, trade.Buy.ClientOrderId, exchange)
+			if err != nil {
+				buyErr = err
+				// the order of other errors may be placed, resumeEntries looks it up
+				if !orderRefused(err) {
+					return nil
+				}
+
+				trade.Status = SimpleTradeStatusCanceled
+				return storage.SaveTrade(&trade)
+			}
+
+			adoptBuyOrder(&trade, buyOrder)
+			err = storage.SaveTrade(&trade)
+			if err != nil {
+				return fmt.Errorf("storage save trades error, order(id=%s) is not saved: %w", buyOrder.Id, err)
+			}
 
-		err = storage.SaveTrade(&trade)
+			return nil
+		})
 		if err != nil {
-			return fmt.Errorf("storage save trades error: %w", err)
+			return err
 		}
 
-		logger.Info(fmt.Sprintf(
-			"bought: trade(id=%d), order(id=%s, price=%s)",
-			trade.Id,
-			trade.Buy.OrderId,
-			trade.Buy.Price.String(),
-		))
+		switch {
+		// the exchange disagrees about funds or the order, the next cycle checks again
+		case errors.Is(buyErr, ErrInsufficientFunds), errors.Is(buyErr, ErrMinNotional), errors.Is(buyErr, ErrMarketClosed):
+			logger.Warn(fmt.Sprintf("%s buy skipped: %s", pair, buyErr))
+		case buyErr != nil:
+			return fmt.Errorf("exchange buy error: %w", buyErr)
+		default:
+			logger.Info(fmt.Sprintf(
+				"bought: trade(id=%d), order(id=%s, price=%s)",
+				trade.Id,
+				trade.Buy.OrderId,
+				trade.Buy.Price.String(),
+			))
+
+			spent := trade.Buy.Price.Mul(trade.Amount)
+			*committed = committed.Add(spent)
+			if trade.Buy.Commission.Asset == pair.QuoteAsset {
+				spent = spent.Add(trade.Buy.Commission.Amount)
+			}
+			funds[pair.QuoteAsset] = funds[pair.QuoteAsset].Sub(spent)
 
-		trades = append(trades, trade)
+			trades = append(trades, trade)
+		}
 	}
 
+	known := knownOrderIds(trades)
 	for _, trade := range trades {
 		if trade.Status == SimpleTradeStatusSell {
 
 			if trade.Sell.OrderId == "" { // sell order didn't created
 
+				if trade.Sell.ClientOrderId != "" {
+					order, err := findIntentOrder(exchange, orderIntent{
+						Pair:          pair,
+						Side:          SellOrderSide,
+						Amount:        trade.Amount,
+						ClientOrderId: trade.Sell.ClientOrderId,
+						Datetime:      trade.Sell.Datetime,
+					}, known)
+					if err != nil {
+						logger.Error(fmt.Sprintf("can't resume sell of trade(id=%d) with client order id %s: %s", trade.Id, trade.Sell.ClientOrderId, err))
+						continue
+					}
+
+					if order != nil && order.Status != CanceledOrderStatus {
+						adoptSellOrder(&trade, order)
+						err = storage.SaveTrade(&trade)
+						if err != nil {
+							return fmt.Errorf("storage save trades error, order(id=%s) is not saved: %w", order.Id, err)
+						}
+
+						known[order.Id] = true
+						logger.Info(fmt.Sprintf("trade(id=%d) sell order(id=%s) is found by client order id", trade.Id, order.Id))
+						continue
+					}
+
+					if order != nil {
+						trade.Sell.ClientOrderId = ""
+					}
+				}
+
 				sellPrice, err := s.getSellPrice(&trade, &exchange)
 				if err != nil {
 					logger.Warn(err.Error())
@@ -325,21 +841,51 @@ func (s *SimpleStrategy) Run(ctx context.Context, _storage interface{}, exchange
 					trade.Amount.String(),
 					sellPrice.String(),
 				))
-				sellOrder, err := exchange.Sell(s.Pair, trade.Amount, *sellPrice)
+				var sellErr error
+				err = CriticalSection(ctx, "sell", func() error {
+					// saved before the sell, so a restart finds the order by its client id
+					if trade.Sell.ClientOrderId == "" {
+						trade.Sell.ClientOrderId = NewClientOrderId()
+					}
+					trade.Sell.Datetime = time.Now().Format(time.RFC3339)
+					err := storage.SaveTrade(&trade)
+					if err != nil {
+						return fmt.Errorf("storage save trades error, sell is not placed: %w", err)
+					}
+
+					sellOrder, err := exchange.PlaceOrder(OrderRequest{
+						Pair:          pair,
+						Side:          SellOrderSide,
+						Type:          LimitOrderType,
+						Amount:        trade.Amount,
+						Price:         *sellPrice,
+						ClientOrderId: trade.Sell.ClientOrderId,
+					})
+					if err != nil {
+						sellErr = err
+						if !orderRefused(err) {
+							return nil
+						}
+
+						trade.Sell = SimpleTradeOrder{}
+						return storage.SaveTrade(&trade)
+					}
+
+					adoptSellOrder(&trade, sellOrder)
+					err = storage.SaveTrade(&trade)
+					if err != nil {
+						return fmt.Errorf("storage save trades error, order(id=%s) is not saved: %w", sellOrder.Id, err)
+					}
+
+					return nil
+				})
 				if err != nil {
-					logger.Error(fmt.Sprintf("exchange sell error, trade(id=%d): %#v", trade.Id, err))
-					continue
+					return err
 				}
 
-				trade.Sell = SimpleTradeOrder{
-					OrderId:    sellOrder.Id,
-					Price:      sellOrder.Price,
-					Datetime:   time.Now().Format(time.RFC3339),
-					Commission: sellOrder.Commission,
-				}
-				err = storage.SaveTrade(&trade)
-				if err != nil {
-					return fmt.Errorf("storage save trades error: %w", err)
+				if sellErr != nil {
+					logger.Error(fmt.Sprintf("exchange sell error, trade(id=%d): %s", trade.Id, sellErr))
+					continue
 				}
 
 				logger.Info(fmt.Sprintf(
@@ -357,6 +903,12 @@ func (s *SimpleStrategy) Run(ctx context.Context, _storage interface{}, exchange
 				}
 
 				if sellPrice.GreaterThan(trade.Sell.Price) {
+					select {
+					case <-ctx.Done():
+						return nil
+					default:
+					}
+
 					logger.Info(fmt.Sprintf(
 						"cancelOrder: trade(id=%d), order(id=%s, price=%s), calc price=%s",
 						trade.Id,
@@ -364,31 +916,343 @@ func (s *SimpleStrategy) Run(ctx context.Context, _storage interface{}, exchange
 						trade.Sell.Price.String(),
 						sellPrice.String(),
 					))
-					err = exchange.CancelOrder(trade.Sell.OrderId, s.Pair)
+					err = CriticalSection(ctx, "cancel", func() error {
+						err := exchange.CancelOrder(trade.Sell.OrderId, pair)
+						if err != nil && !errors.Is(err, ErrOrderNotFound) {
+							logger.Warn(err.Error())
+							return nil
+						}
+
+						// an order that is not open may be filled, history finishes the trade then
+						if err != nil {
+							filled, _, fillsErr := orderFills(exchange, pair, trade.Sell.OrderId)
+							if fillsErr != nil || filled.IsPositive() {
+								logger.Warn(fmt.Sprintf("trade(id=%d) sell order(id=%s) is not open, history decides next cycle: %s", trade.Id, trade.Sell.OrderId, err))
+								return nil
+							}
+
+							logger.Warn(fmt.Sprintf("trade(id=%d) sell order(id=%s) is gone without fills, sell again: %s", trade.Id, trade.Sell.OrderId, err))
+						}
+
+						trade.Sell.OrderId = ""
+						trade.Sell.ClientOrderId = ""
+						return storage.SaveTrade(&trade)
+					})
 					if err != nil {
-						logger.Warn(err.Error())
-						continue
+						return fmt.Errorf("storage save trades error: %w", err)
+					}
+				}
+
+			}
+		}
+	}
+
+	return nil
+}
+
+// placeEntry buys at market or places a limit buy below the last price.
+func (s SimpleStrategy) placeEntry(pair Pair, amount decimal.Decimal, lastPrice decimal.Decimal, clientOrderId string, exchange Exchange) (*Order, error) {
+	request := OrderRequest{
+		Pair:          pair,
+		Side:          BuyOrderSide,
+		Type:          MarketOrderType,
+		Amount:        amount,
+		ClientOrderId: clientOrderId,
+	}
+
+	if s.EntryOrderType == LimitOrderType {
+		request.Type = LimitOrderType
+		request.Price = lastPrice.Mul(decimal.NewFromInt(1).Sub(s.EntryLimitOffset)).Round(8)
+	}
+
+	return exchange.PlaceOrder(request)
+}
+
+// adoptBuyOrder keeps the placed buy in the trade. Open orders of some
+// exchanges tell only the executed amount, so the amount is taken on fill.
+func adoptBuyOrder(trade *SimpleTrade, order *Order) {
+	trade.Buy.OrderId = order.Id
+	trade.Buy.Price = order.Price
+	if order.Commission.Asset != "" {
+		trade.Buy.Commission = order.Commission
+	}
+
+	if order.Status == FillOrderStatus {
+		trade.Status = SimpleTradeStatusSell
+		if order.Amount.IsPositive() {
+			trade.Amount = order.Amount
+		}
+	}
+}
+
+// adoptSellOrder keeps the placed sell in the trade.
+func adoptSellOrder(trade *SimpleTrade, order *Order) {
+	trade.Sell.OrderId = order.Id
+	trade.Sell.Price = order.Price
+	if order.Commission.Asset != "" {
+		trade.Sell.Commission = order.Commission
+	}
+}
+
+// resumeEntries places buys of trades that have no buy order. A trade saved
+// before a crash looks its order up by the client id first, so the buy isn't
+// placed twice. Limit buys open longer than EntryLimitTimeout are canceled
+// and the unfilled part is placed again at the current price, the filled part
+// becomes a trade of its own.
+func (s *SimpleStrategy) resumeEntries(
+	ctx context.Context,
+	trades []SimpleTrade,
+	openOrders []Order,
+	storage SimpleStorage,
+	exchange Exchange,
+	logger Logger,
+) error {
+	open := map[string]bool{}
+	for _, order := range openOrders {
+		open[order.Id] = true
+	}
+
+	known := knownOrderIds(trades)
+	timeout := time.Duration(s.EntryLimitTimeout) * time.Minute
+	for i := range trades {
+		trade := &trades[i]
+		if trade.Status != SimpleTradeStatusBuy {
+			continue
+		}
+
+		expired := false
+		if trade.Buy.OrderId != "" {
+			if s.EntryOrderType != LimitOrderType || !open[trade.Buy.OrderId] {
+				continue
+			}
+
+			placed, err := time.Parse(time.RFC3339, trade.Buy.Datetime)
+			if err == nil && time.Since(placed) < timeout {
+				continue
+			}
+			expired = true
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		default:
+		}
+
+		var buyErr error
+		err := CriticalSection(ctx, "buy", func() error {
+			if expired {
+				err := exchange.CancelOrder(trade.Buy.OrderId, trade.Pair)
+				if err != nil {
+					// the order may be filled meanwhile, history tells it next cycle
+					buyErr = fmt.Errorf("cancel order(id=%s): %w", trade.Buy.OrderId, err)
+					return nil
+				}
+
+				filled, commission, err := orderFills(exchange, trade.Pair, trade.Buy.OrderId)
+				if err != nil {
+					// history tells the fills of the canceled order next cycle
+					buyErr = fmt.Errorf("fills of canceled order(id=%s): %w", trade.Buy.OrderId, err)
+					return nil
+				}
+
+				if filled.GreaterThanOrEqual(trade.Amount) {
+					trade.Status = SimpleTradeStatusSell
+					trade.Buy.Commission = commission
+					return storage.SaveTrade(trade)
+				}
+
+				if filled.IsPositive() {
+					part := SimpleTrade{
+						Pair:   trade.Pair,
+						Status: SimpleTradeStatusSell,
+						Amount: filled,
+						Buy: SimpleTradeOrder{
+							Datetime:   trade.Buy.Datetime,
+							OrderId:    trade.Buy.OrderId,
+							Price:      trade.Buy.Price,
+							Commission: commission,
+						},
 					}
+					err = storage.SaveTrade(&part)
+					if err != nil {
+						return fmt.Errorf("storage save trades error, filled part of order(id=%s) is not saved: %w", trade.Buy.OrderId, err)
+					}
+
+					logger.Info(fmt.Sprintf("trade(id=%d) filled %s of order(id=%s) before reprice", part.Id, filled, trade.Buy.OrderId))
+					trade.Amount = trade.Amount.Sub(filled)
+				}
+
+				trade.Buy.OrderId = ""
+				trade.Buy.ClientOrderId = ""
+				err = storage.SaveTrade(trade)
+				if err != nil {
+					return fmt.Errorf("storage save trades error: %w", err)
+				}
+			}
+
+			if trade.Buy.ClientOrderId != "" {
+				order, err := findIntentOrder(exchange, orderIntent{
+					Pair:          trade.Pair,
+					Side:          BuyOrderSide,
+					Amount:        trade.Amount,
+					ClientOrderId: trade.Buy.ClientOrderId,
+					Datetime:      trade.Buy.Datetime,
+				}, known)
+				if err != nil {
+					buyErr = fmt.Errorf("can't resume buy with client order id %s: %w", trade.Buy.ClientOrderId, err)
+					return nil
+				}
+
+				if order != nil && order.Status != CanceledOrderStatus {
+					adoptBuyOrder(trade, order)
+					known[order.Id] = true
+					return storage.SaveTrade(trade)
+				}
+
+				if order != nil {
+					trade.Buy.ClientOrderId = ""
+				}
+			}
+
+			lastPrice, err := exchange.LastPrice(trade.Pair)
+			if err != nil {
+				buyErr = err
+				return nil
+			}
 
-					trade.Sell.OrderId = ""
-					storage.SaveTrade(&trade)
+			if trade.Buy.ClientOrderId == "" {
+				trade.Buy.ClientOrderId = NewClientOrderId()
+			}
+			trade.Buy.Price = lastPrice
+			trade.Buy.Datetime = time.Now().Format(time.RFC3339)
+			err = storage.SaveTrade(trade)
+			if err != nil {
+				return fmt.Errorf("storage save trades error, buy is not placed: %w", err)
+			}
+
+			order, err := s.placeEntry(trade.Pair, trade.Amount, lastPrice, trade.Buy.ClientOrderId, exchange)
+			if err != nil {
+				buyErr = err
+				// the order of other errors may be placed, it is looked up next cycle
+				if !orderRefused(err) {
+					return nil
 				}
 
+				// refused orders are not looked up again
+				trade.Buy.ClientOrderId = ""
+				return storage.SaveTrade(trade)
 			}
+
+			adoptBuyOrder(trade, order)
+			err = storage.SaveTrade(trade)
+			if err != nil {
+				return fmt.Errorf("storage save trades error, order(id=%s) is not saved: %w", order.Id, err)
+			}
+
+			return nil
+		})
+		if err != nil {
+			return err
 		}
+
+		if buyErr != nil {
+			logger.Error(fmt.Sprintf("exchange buy error, trade(id=%d): %s", trade.Id, buyErr))
+			continue
+		}
+
+		logger.Info(fmt.Sprintf(
+			"bought: trade(id=%d), order(id=%s, price=%s)",
+			trade.Id,
+			trade.Buy.OrderId,
+			trade.Buy.Price.String(),
+		))
 	}
 
 	return nil
 }
 
-func (s *SimpleStrategy) availableFundCheck(
+// entryCandles is the number of candles the entry conditions need, 0 when
+// they are off. EMA and Wilder averages get three periods to settle.
+func (s SimpleStrategy) entryCandles() int {
+	period := 0
+	for _, p := range []int{s.EntryEmaPeriod, s.EntryRsiPeriod, s.EntryAtrPeriod} {
+		if p > period {
+			period = p
+		}
+	}
+
+	if period == 0 {
+		return 0
+	}
+
+	return (period + 1) * 3
+}
+
+// entryAllowed checks the entry conditions, the reason tells the values.
+func (s SimpleStrategy) entryAllowed(pair Pair, lastPrice decimal.Decimal, exchange Exchange) (bool, string, error) {
+	interval := s.EntryInterval
+	if interval == "" {
+		interval = HourCandleInterval
+	}
+
+	duration, ok := CandleIntervals[interval]
+	if !ok {
+		return false, "", fmt.Errorf("unknown candle interval %q", interval)
+	}
+
+	candles, err := exchange.GetCandles(pair, interval, time.Now().Add(-duration*time.Duration(s.entryCandles())))
+	if err != nil {
+		return false, "", err
+	}
+	closes := CandleCloses(candles)
+
+	if s.EntryEmaPeriod > 0 {
+		ema, err := indicators.EMA(closes, s.EntryEmaPeriod)
+		if err != nil {
+			return false, "", fmt.Errorf("ema: %w", err)
+		}
+
+		if !lastPrice.LessThan(indicators.Last(ema)) {
+			return false, fmt.Sprintf("price %s >= ema %s", lastPrice, indicators.Last(ema).Round(8)), nil
+		}
+	}
+
+	if s.EntryRsiPeriod > 0 {
+		rsi, err := indicators.RSI(closes, s.EntryRsiPeriod)
+		if err != nil {
+			return false, "", fmt.Errorf("rsi: %w", err)
+		}
+
+		if !indicators.Last(rsi).LessThan(decimal.NewFromInt(int64(s.EntryRsiBelow))) {
+			return false, fmt.Sprintf("rsi %s >= %d", indicators.Last(rsi).Round(2), s.EntryRsiBelow), nil
+		}
+	}
+
+	if s.EntryAtrPeriod > 0 {
+		atr, err := indicators.ATR(CandleHighs(candles), CandleLows(candles), closes, s.EntryAtrPeriod)
+		if err != nil {
+			return false, "", fmt.Errorf("atr: %w", err)
+		}
+
+		percent := indicators.Last(atr).Div(lastPrice)
+		if percent.LessThan(s.EntryMinAtrPercent) {
+			return false, fmt.Sprintf("atr %s < %s of price", percent.Round(4), s.EntryMinAtrPercent), nil
+		}
+	}
+
+	return true, "passed", nil
+}
+
+func availableFundCheck(
+	baseQuantity decimal.Decimal,
 	fund decimal.Decimal,
 	minAmount decimal.Decimal,
 	price decimal.Decimal,
 	delta decimal.Decimal,
 	getFeeFunc func(amount decimal.Decimal) decimal.Decimal,
 ) (decimal.Decimal, bool) {
-	amount := s.BaseQuality.Copy()
+	amount := baseQuantity.Copy()
 	var isAvailable bool
 
 	for amount.GreaterThanOrEqual(minAmount) {
@@ -405,10 +1269,10 @@ func (s *SimpleStrategy) getSellPrice(trade *SimpleTrade, exchange *Exchange) (*
 	paidQuote := trade.Buy.Price.Mul(trade.Amount)
 
 	var paidFeeQuote decimal.Decimal
-	if trade.Buy.Commission.Asset == s.Pair.QuoteAsset {
+	if trade.Buy.Commission.Asset == trade.Pair.QuoteAsset {
 		paidFeeQuote = trade.Buy.Commission.Amount
 
-	} else if trade.Buy.Commission.Asset == s.Pair.BaseAsset {
+	} else if trade.Buy.Commission.Asset == trade.Pair.BaseAsset {
 		paidFeeQuote = trade.Buy.Commission.Amount.Mul(trade.Buy.Price)
 
 	} else {
@@ -419,8 +1283,8 @@ func (s *SimpleStrategy) getSellPrice(trade *SimpleTrade, exchange *Exchange) (*
 
 	profit := s.ProfitPercent.Mul(paidQuote)
 
-	//fee, err := (*exchange).GetOrderFee(s.Pair, trade.Amount, trade.Sell.Price)
-	fee, err := (*exchange).GetPairFee(s.Pair)
+	//fee, err := (*exchange).GetOrderFee(trade.Pair, trade.Amount, trade.Sell.Price)
+	fee, err := (*exchange).GetPairFee(trade.Pair)
 	if err != nil {
 		return nil, fmt.Errorf("exchange get order fee error, %w", err)
 	}
@@ -428,3 +1292,13 @@ func (s *SimpleStrategy) getSellPrice(trade *SimpleTrade, exchange *Exchange) (*
 	sellPrice := decimal.Sum(buyPaid, profit).Div(trade.Amount.Mul(decimal.NewFromInt(1).Sub(fee.Amount))).RoundUp(2)
 	return &sellPrice, nil
 }
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
@@ -1,13 +1,21 @@
 package domain
 
-import "context"
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
 
 type StrategyId int
 
+// Built-in strategy ids, strategies from other packages pick their own ids
+// and call RegisterStrategy.
 const (
-	_                            = iota
-	SimpleStratedy    StrategyId = iota
-	maxStrategyNumber StrategyId = iota
+	_                         = iota
+	SimpleStratedy StrategyId = iota
 )
 
 type Strategy interface {
@@ -17,17 +25,109 @@ type Strategy interface {
 	Run(ctx context.Context, storage interface{}, exchanges []Exchange, logger Logger) error
 }
 
-func GetStrategyFromJson(id StrategyId, data []byte) Strategy {
-	switch id {
-	case SimpleStratedy:
-		strategy, err := NewSimpleStrategyFromJson(data)
+func GetStrategyFromJson(id StrategyId, data []byte) (Strategy, error) {
+	info, ok := GetStrategyInfo(id)
+	if !ok {
+		return nil, fmt.Errorf("unknown strategy id %d", id)
+	}
+
+	data, err := upgradeStrategyData(info, data)
+	if err != nil {
+		return nil, err
+	}
+
+	strategy, err := info.FromJson(data)
+	if err != nil {
+		return nil, fmt.Errorf("bad strategy data: %w", err)
+	}
+
+	return strategy, nil
+}
+
+// StrategyDataVersionKey is the json key of the strategy data version.
+const StrategyDataVersionKey = "version"
+
+func decodeStrategyData(data []byte) (map[string]interface{}, error) {
+	decoded := map[string]interface{}{}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	err := decoder.Decode(&decoded)
+	if err != nil {
+		return nil, fmt.Errorf("bad strategy data: %w", err)
+	}
+
+	return decoded, nil
+}
+
+func strategyDataVersion(data map[string]interface{}) (int, error) {
+	value, ok := data[StrategyDataVersionKey]
+	if !ok || value == nil {
+		return 0, nil
+	}
+
+	number, ok := value.(json.Number)
+	if !ok {
+		return 0, fmt.Errorf("bad strategy data version %v", value)
+	}
+
+	version, err := strconv.Atoi(number.String())
+	if err != nil || version < 0 {
+		return 0, fmt.Errorf("bad strategy data version %v", value)
+	}
+
+	return version, nil
+}
+
+// upgradeStrategyData runs the registered upgrades over stored or user data
+// and stamps it with the current version.
+func upgradeStrategyData(info StrategyInfo, data []byte) ([]byte, error) {
+	decoded, err := decodeStrategyData(data)
+	if err != nil {
+		return nil, err
+	}
+
+	version, err := strategyDataVersion(decoded)
+	if err != nil {
+		return nil, err
+	}
+
+	if version > len(info.Upgrades) {
+		return nil, fmt.Errorf("strategy data version %d is newer than supported %d", version, len(info.Upgrades))
+	}
+
+	if version == len(info.Upgrades) {
+		return data, nil
+	}
+
+	for ; version < len(info.Upgrades); version++ {
+		err = info.Upgrades[version](decoded)
 		if err != nil {
-			panic(err)
+			return nil, fmt.Errorf("upgrade strategy data to version %d: %w", version+1, err)
+		}
+	}
+	decoded[StrategyDataVersionKey] = version
+
+	return json.Marshal(decoded)
+}
+
+func strategyPairs(strategy Strategy) string {
+	pairs := []string{}
+	for _, pair := range strategyPairList(strategy) {
+		pairs = append(pairs, pair.String())
+	}
+
+	return strings.Join(pairs, ",")
+}
+
+func strategyPairList(strategy Strategy) []Pair {
+	pairs := []Pair{}
+	for _, param := range strategy.Parameters() {
+		if pair, ok := param.Value.(Pair); ok && param.Type == PairParameterType {
+			pairs = append(pairs, pair)
 		}
-		return strategy
 	}
 
-	return nil
+	return pairs
 }
 
 type StrategyParameterType = int
@@ -40,6 +140,7 @@ const (
 	PercentParameterType = iota
 	PairParameterType    = iota
 	BalanceParameterType = iota
+	ListParameterType    = iota
 )
 
 type StrategyParameter struct {
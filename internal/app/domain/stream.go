@@ -0,0 +1,82 @@
+package domain
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/shopspring/decimal"
+)
+
+type StreamEventType int
+
+const (
+	_ StreamEventType = iota
+	PriceStreamEvent
+	OrderStreamEvent
+	BalanceStreamEvent
+	// ErrorStreamEvent tells that the connection is lost, the exchange
+	// reconnects by itself.
+	ErrorStreamEvent
+)
+
+type StreamEvent struct {
+	Type    StreamEventType
+	Pair    Pair
+	Price   decimal.Decimal
+	Order   Order
+	Balance Balance
+	Err     error
+}
+
+type agentStream struct {
+	name   string
+	stream StreamExchange
+}
+
+// agentStreams returns exchanges that can stream, it must get exchanges
+// before they are wrapped.
+func agentStreams(exchanges []Exchange) []agentStream {
+	streams := []agentStream{}
+	for _, exchange := range exchanges {
+		if stream, ok := exchange.(StreamExchange); ok {
+			streams = append(streams, agentStream{name: exchange.Name(), stream: stream})
+		}
+	}
+
+	return streams
+}
+
+// watchStreams keeps pushed prices in the feed and wakes the agent up when
+// one of its orders is filled, so it doesn't wait for the next interval.
+func watchStreams(ctx context.Context, streams []agentStream, pairs []Pair, prices PriceFeed, wake chan<- struct{}, logger Logger) {
+	for _, stream := range streams {
+		events, err := stream.stream.Stream(ctx, pairs)
+		if err != nil {
+			logger.Warn(fmt.Sprintf("%s stream: %s", stream.name, err))
+			continue
+		}
+
+		go func(name string, events <-chan StreamEvent) {
+			for event := range events {
+				switch event.Type {
+				case PriceStreamEvent:
+					if prices != nil {
+						prices.Update(name, event.Pair, event.Price)
+					}
+				case OrderStreamEvent:
+					if event.Order.Status != FillOrderStatus {
+						continue
+					}
+
+					logger.Debug(fmt.Sprintf("%s stream: order(id=%s) is filled", name, event.Order.Id))
+					select {
+					case wake <- struct{}{}:
+					default:
+					}
+				case ErrorStreamEvent:
+					logger.Warn(fmt.Sprintf("%s stream: %s", name, event.Err))
+				}
+			}
+		}(stream.name, events)
+	}
+}
@@ -0,0 +1,69 @@
+package test_domain
+
+import (
+	"fmt"
+
+	"github.com/golang/mock/gomock"
+	"github.com/scientistnik/invest-agents/internal/app/domain"
+	mock_domain "github.com/scientistnik/invest-agents/internal/app/domain/tests/mocks"
+)
+
+// simpleAgent returns an active agent of the simple strategy trading BTC/USD.
+func simpleAgent(maxTrades int) domain.Agent {
+	return domain.Agent{
+		Id:           1,
+		UserId:       2,
+		Status:       domain.ActiveAgentStatus,
+		StrategyId:   domain.SimpleStratedy,
+		StrategyData: []byte(fmt.Sprintf(`{"pairs":[{"pair":{"base_asset":"BTC","quote_asset":"USD"}}],"base_quantity":"1","max_trades":%d,"profit_percent":"0.01","far_price_percent":"0.01"}`, maxTrades)),
+	}
+}
+
+// agentRun holds the repos StartAgents needs to run one agent on the
+// exchange. Tests add the optional repos to Repos and expectations to
+// Storage and Exchanges.
+type agentRun struct {
+	Repos     domain.Repos
+	Agents    *mock_domain.MockAgentRepo
+	Exchanges *mock_domain.MockExchangeRepo
+	Storage   *mock_domain.MockSimpleStorage
+	// ExchangesVersion is the version of the agent exchanges, a change makes
+	// the agent reload them.
+	ExchangesVersion *string
+}
+
+func newAgentRun(ctrl *gomock.Controller, agent domain.Agent, exchange domain.Exchange) agentRun {
+	mAgents := mock_domain.NewMockAgentRepo(ctrl)
+	mStorages := mock_domain.NewMockStorageRepo(ctrl)
+	mExchanges := mock_domain.NewMockExchangeRepo(ctrl)
+	mLoggers := mock_domain.NewMockLoggerRepo(ctrl)
+	mLogger := mock_domain.NewMockLogger(ctrl)
+	mStorage := mock_domain.NewMockSimpleStorage(ctrl)
+
+	mAgents.EXPECT().FindAgents(true).Return([]domain.Agent{agent}, nil)
+	mAgents.EXPECT().GetAgentStatus(agent.Id).Return(domain.AgentStatus(domain.ActiveAgentStatus), nil).AnyTimes()
+	mStorages.EXPECT().GetAgentStorage(agent).Return(mStorage)
+	mExchanges.EXPECT().GetAgentExchanges(agent.Id).Return([]domain.Exchange{exchange}, nil)
+	version := "1"
+	mExchanges.EXPECT().AgentExchangesVersion(agent.Id).DoAndReturn(func(agentId int64) (string, error) {
+		return version, nil
+	}).AnyTimes()
+	mLoggers.EXPECT().New(gomock.Any()).Return(mLogger)
+	mLogger.EXPECT().Info(gomock.Any()).AnyTimes()
+	mLogger.EXPECT().Debug(gomock.Any()).AnyTimes()
+	mLogger.EXPECT().Warn(gomock.Any()).AnyTimes()
+	mLogger.EXPECT().Error(gomock.Any()).AnyTimes()
+
+	return agentRun{
+		Repos: domain.Repos{
+			Agent:    mAgents,
+			Storage:  mStorages,
+			Exchange: mExchanges,
+			Logger:   mLoggers,
+		},
+		Agents:           mAgents,
+		Exchanges:        mExchanges,
+		Storage:          mStorage,
+		ExchangesVersion: &version,
+	}
+}
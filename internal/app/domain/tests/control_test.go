@@ -0,0 +1,121 @@
+package test_domain
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/scientistnik/invest-agents/internal/app/domain"
+	mock_domain "github.com/scientistnik/invest-agents/internal/app/domain/tests/mocks"
+	"github.com/shopspring/decimal"
+)
+
+func TestAgentControlHalt(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	agent := simpleAgent(2)
+	mExchange := mock_domain.NewMockExchange(ctrl)
+	run := newAgentRun(ctrl, agent, mExchange)
+
+	running := make(chan struct{})
+
+	mExchange.EXPECT().Balances(gomock.Any()).DoAndReturn(func(assets []string) ([]domain.Balance, error) {
+		close(running)
+		return []domain.Balance{{Asset: "USD", Amount: decimal.NewFromInt(1000)}}, nil
+	})
+	mExchange.EXPECT().GetOpenOrders(gomock.Any()).Return([]domain.Order{}, nil).AnyTimes()
+	mExchange.EXPECT().GetHistoryOrders(gomock.Any()).Return([]domain.Order{}, nil).AnyTimes()
+	mExchange.EXPECT().LastPrice(gomock.Any()).Return(decimal.NewFromInt(100), nil).AnyTimes()
+	mExchange.EXPECT().GetOrderFee(gomock.Any(), gomock.Any(), gomock.Any()).Return(domain.Balance{}, nil).AnyTimes()
+	run.Storage.EXPECT().GetTrades(gomock.Any()).Return([]domain.SimpleTrade{}, nil).AnyTimes()
+
+	control := domain.NewAgentControl()
+	stillRunning := make(chan []int64, 1)
+	go func() {
+		<-running
+		stillRunning <- control.Halt([]int64{agent.Id}, time.Second)
+	}()
+
+	run.Repos.Control = control
+	err := domain.StartAgents(context.Background(), run.Repos, domain.AgentsSettings{Interval: time.Hour, ShutdownTimeout: time.Second})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if ids := <-stillRunning; len(ids) != 0 {
+		t.Fatalf("agents %v are still running", ids)
+	}
+}
+
+func TestAgentReloadsRotatedKeys(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	agent := simpleAgent(2)
+	oldKeys := mock_domain.NewMockExchange(ctrl)
+	newKeys := mock_domain.NewMockExchange(ctrl)
+	run := newAgentRun(ctrl, agent, oldKeys)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// the keys are rotated during the first cycle
+	oldKeys.EXPECT().Balances(gomock.Any()).DoAndReturn(func(assets []string) ([]domain.Balance, error) {
+		*run.ExchangesVersion = "2"
+		return nil, errors.New("old keys")
+	})
+	run.Exchanges.EXPECT().GetAgentExchanges(agent.Id).Return([]domain.Exchange{newKeys}, nil)
+	newKeys.EXPECT().Balances(gomock.Any()).DoAndReturn(func(assets []string) ([]domain.Balance, error) {
+		cancel()
+		return nil, errors.New("stop")
+	})
+
+	err := domain.StartAgents(ctx, run.Repos, domain.AgentsSettings{Interval: time.Millisecond, ShutdownTimeout: time.Second})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestBrokenAgentDoesNotStopOthers(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	broken := simpleAgent(2)
+	broken.Id = 5
+	broken.StrategyData = []byte(`{"pairs":`)
+	agent := simpleAgent(2)
+	mExchange := mock_domain.NewMockExchange(ctrl)
+	run := newAgentRun(ctrl, agent, mExchange)
+
+	// the broken agent is found before the agent of the helper
+	mAgents := mock_domain.NewMockAgentRepo(ctrl)
+	mAgents.EXPECT().FindAgents(true).DoAndReturn(func(active bool) ([]domain.Agent, error) {
+		agents, err := run.Agents.FindAgents(active)
+		return append([]domain.Agent{broken}, agents...), err
+	})
+	mAgents.EXPECT().GetAgentStatus(agent.Id).DoAndReturn(run.Agents.GetAgentStatus).AnyTimes()
+	run.Repos.Agent = mAgents
+
+	mLogger := mock_domain.NewMockLogger(ctrl)
+	mLogger.EXPECT().Error(gomock.Any())
+	mLoggers := mock_domain.NewMockLoggerRepo(ctrl)
+	mLoggers.EXPECT().New(domain.LoggerLabels{AgentId: broken.Id}).Return(mLogger)
+	mLoggers.EXPECT().New(gomock.Any()).DoAndReturn(run.Repos.Logger.New)
+	run.Repos.Logger = mLoggers
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	mExchange.EXPECT().Balances(gomock.Any()).DoAndReturn(func(assets []string) ([]domain.Balance, error) {
+		cancel()
+		return nil, errors.New("stop")
+	})
+
+	err := domain.StartAgents(ctx, run.Repos, domain.AgentsSettings{Interval: time.Hour, ShutdownTimeout: time.Second})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,75 @@
+package test_domain
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/scientistnik/invest-agents/internal/app/domain/indicators"
+	"github.com/shopspring/decimal"
+)
+
+func decimals(values ...float64) []decimal.Decimal {
+	result := []decimal.Decimal{}
+	for _, value := range values {
+		result = append(result, decimal.NewFromFloat(value))
+	}
+
+	return result
+}
+
+func checkSeries(t *testing.T, name string, got []decimal.Decimal, want []decimal.Decimal) {
+	t.Helper()
+
+	if len(got) != len(want) {
+		t.Fatalf("%s: got %v, want %v", name, got, want)
+	}
+
+	for i := range got {
+		if !got[i].Round(8).Equal(want[i]) {
+			t.Fatalf("%s: got %v, want %v", name, got, want)
+		}
+	}
+}
+
+func TestIndicators(t *testing.T) {
+	sma, err := indicators.SMA(decimals(1, 2, 3, 4, 5), 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkSeries(t, "sma", sma, decimals(2, 3, 4))
+
+	ema, err := indicators.EMA(decimals(1, 2, 3, 4, 5), 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkSeries(t, "ema", ema, decimals(2, 3, 4))
+
+	rsi, err := indicators.RSI(decimals(1, 2, 1, 2, 1), 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkSeries(t, "rsi", rsi, decimals(50, 75, 37.5))
+
+	rsi, err = indicators.RSI(decimals(1, 2, 3), 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkSeries(t, "rsi without losses", rsi, decimals(100))
+
+	bands, err := indicators.Bollinger(decimals(2, 4, 4, 4, 5, 5, 7, 9), 8, decimal.NewFromInt(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkSeries(t, "bollinger", []decimal.Decimal{bands[0].Lower, bands[0].Middle, bands[0].Upper}, decimals(1, 5, 9))
+
+	atr, err := indicators.ATR(decimals(10, 12, 11), decimals(8, 9, 9), decimals(9, 11, 10), 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkSeries(t, "atr", atr, decimals(2.5))
+
+	_, err = indicators.EMA(decimals(1, 2), 3)
+	if !errors.Is(err, indicators.ErrNotEnoughData) {
+		t.Fatalf("ema of short series: %v", err)
+	}
+}
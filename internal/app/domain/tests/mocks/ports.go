@@ -5,7 +5,9 @@
 package mock_domain
 
 import (
+	context "context"
 	reflect "reflect"
+	time "time"
 
 	gomock "github.com/golang/mock/gomock"
 	domain "github.com/scientistnik/invest-agents/internal/app/domain"
@@ -65,6 +67,35 @@ func (mr *MockExchangeMockRecorder) Buy(pair, amount interface{}) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Buy", reflect.TypeOf((*MockExchange)(nil).Buy), pair, amount)
 }
 
+// CancelOrder mocks base method.
+func (m *MockExchange) CancelOrder(orderId string, pair domain.Pair) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CancelOrder", orderId, pair)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CancelOrder indicates an expected call of CancelOrder.
+func (mr *MockExchangeMockRecorder) CancelOrder(orderId, pair interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CancelOrder", reflect.TypeOf((*MockExchange)(nil).CancelOrder), orderId, pair)
+}
+
+// GetCandles mocks base method.
+func (m *MockExchange) GetCandles(pair domain.Pair, interval domain.CandleInterval, since time.Time) ([]domain.Candle, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetCandles", pair, interval, since)
+	ret0, _ := ret[0].([]domain.Candle)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetCandles indicates an expected call of GetCandles.
+func (mr *MockExchangeMockRecorder) GetCandles(pair, interval, since interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetCandles", reflect.TypeOf((*MockExchange)(nil).GetCandles), pair, interval, since)
+}
+
 // GetHistoryOrders mocks base method.
 func (m *MockExchange) GetHistoryOrders(pairs []domain.Pair) ([]domain.Order, error) {
 	m.ctrl.T.Helper()
@@ -81,33 +112,63 @@ func (mr *MockExchangeMockRecorder) GetHistoryOrders(pairs interface{}) *gomock.
 }
 
 // GetOpenOrders mocks base method.
-func (m *MockExchange) GetOpenOrders() ([]domain.Order, error) {
+func (m *MockExchange) GetOpenOrders(filter *domain.OrderFilter) ([]domain.Order, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetOpenOrders")
+	ret := m.ctrl.Call(m, "GetOpenOrders", filter)
 	ret0, _ := ret[0].([]domain.Order)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // GetOpenOrders indicates an expected call of GetOpenOrders.
-func (mr *MockExchangeMockRecorder) GetOpenOrders() *gomock.Call {
+func (mr *MockExchangeMockRecorder) GetOpenOrders(filter interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetOpenOrders", reflect.TypeOf((*MockExchange)(nil).GetOpenOrders))
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetOpenOrders", reflect.TypeOf((*MockExchange)(nil).GetOpenOrders), filter)
+}
+
+// GetOrderByClientId mocks base method.
+func (m *MockExchange) GetOrderByClientId(pair domain.Pair, clientOrderId string) (*domain.Order, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetOrderByClientId", pair, clientOrderId)
+	ret0, _ := ret[0].(*domain.Order)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetOrderByClientId indicates an expected call of GetOrderByClientId.
+func (mr *MockExchangeMockRecorder) GetOrderByClientId(pair, clientOrderId interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetOrderByClientId", reflect.TypeOf((*MockExchange)(nil).GetOrderByClientId), pair, clientOrderId)
 }
 
 // GetOrderFee mocks base method.
-func (m *MockExchange) GetOrderFee(pair domain.Pair, amount decimal.Decimal) (*decimal.Decimal, error) {
+func (m *MockExchange) GetOrderFee(pair domain.Pair, amount, price decimal.Decimal) (domain.Balance, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetOrderFee", pair, amount)
-	ret0, _ := ret[0].(*decimal.Decimal)
+	ret := m.ctrl.Call(m, "GetOrderFee", pair, amount, price)
+	ret0, _ := ret[0].(domain.Balance)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // GetOrderFee indicates an expected call of GetOrderFee.
-func (mr *MockExchangeMockRecorder) GetOrderFee(pair, amount interface{}) *gomock.Call {
+func (mr *MockExchangeMockRecorder) GetOrderFee(pair, amount, price interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetOrderFee", reflect.TypeOf((*MockExchange)(nil).GetOrderFee), pair, amount)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetOrderFee", reflect.TypeOf((*MockExchange)(nil).GetOrderFee), pair, amount, price)
+}
+
+// GetPairFee mocks base method.
+func (m *MockExchange) GetPairFee(pair domain.Pair) (domain.Balance, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetPairFee", pair)
+	ret0, _ := ret[0].(domain.Balance)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetPairFee indicates an expected call of GetPairFee.
+func (mr *MockExchangeMockRecorder) GetPairFee(pair interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetPairFee", reflect.TypeOf((*MockExchange)(nil).GetPairFee), pair)
 }
 
 // LastPrice mocks base method.
@@ -125,6 +186,35 @@ func (mr *MockExchangeMockRecorder) LastPrice(pair interface{}) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LastPrice", reflect.TypeOf((*MockExchange)(nil).LastPrice), pair)
 }
 
+// Name mocks base method.
+func (m *MockExchange) Name() string {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Name")
+	ret0, _ := ret[0].(string)
+	return ret0
+}
+
+// Name indicates an expected call of Name.
+func (mr *MockExchangeMockRecorder) Name() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Name", reflect.TypeOf((*MockExchange)(nil).Name))
+}
+
+// PlaceOrder mocks base method.
+func (m *MockExchange) PlaceOrder(request domain.OrderRequest) (*domain.Order, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PlaceOrder", request)
+	ret0, _ := ret[0].(*domain.Order)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// PlaceOrder indicates an expected call of PlaceOrder.
+func (mr *MockExchangeMockRecorder) PlaceOrder(request interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PlaceOrder", reflect.TypeOf((*MockExchange)(nil).PlaceOrder), request)
+}
+
 // Sell mocks base method.
 func (m *MockExchange) Sell(pair domain.Pair, amount, price decimal.Decimal) (*domain.Order, error) {
 	m.ctrl.T.Helper()
@@ -140,6 +230,44 @@ func (mr *MockExchangeMockRecorder) Sell(pair, amount, price interface{}) *gomoc
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Sell", reflect.TypeOf((*MockExchange)(nil).Sell), pair, amount, price)
 }
 
+// MockStreamExchange is a mock of StreamExchange interface.
+type MockStreamExchange struct {
+	ctrl     *gomock.Controller
+	recorder *MockStreamExchangeMockRecorder
+}
+
+// MockStreamExchangeMockRecorder is the mock recorder for MockStreamExchange.
+type MockStreamExchangeMockRecorder struct {
+	mock *MockStreamExchange
+}
+
+// NewMockStreamExchange creates a new mock instance.
+func NewMockStreamExchange(ctrl *gomock.Controller) *MockStreamExchange {
+	mock := &MockStreamExchange{ctrl: ctrl}
+	mock.recorder = &MockStreamExchangeMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockStreamExchange) EXPECT() *MockStreamExchangeMockRecorder {
+	return m.recorder
+}
+
+// Stream mocks base method.
+func (m *MockStreamExchange) Stream(ctx context.Context, pairs []domain.Pair) (<-chan domain.StreamEvent, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Stream", ctx, pairs)
+	ret0, _ := ret[0].(<-chan domain.StreamEvent)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Stream indicates an expected call of Stream.
+func (mr *MockStreamExchangeMockRecorder) Stream(ctx, pairs interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Stream", reflect.TypeOf((*MockStreamExchange)(nil).Stream), ctx, pairs)
+}
+
 // MockStorage is a mock of Storage interface.
 type MockStorage struct {
 	ctrl     *gomock.Controller
@@ -234,74 +362,6 @@ func (mr *MockLoggerMockRecorder) Warn(message interface{}) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Warn", reflect.TypeOf((*MockLogger)(nil).Warn), message)
 }
 
-// MockUserRepo is a mock of UserRepo interface.
-type MockUserRepo struct {
-	ctrl     *gomock.Controller
-	recorder *MockUserRepoMockRecorder
-}
-
-// MockUserRepoMockRecorder is the mock recorder for MockUserRepo.
-type MockUserRepoMockRecorder struct {
-	mock *MockUserRepo
-}
-
-// NewMockUserRepo creates a new mock instance.
-func NewMockUserRepo(ctrl *gomock.Controller) *MockUserRepo {
-	mock := &MockUserRepo{ctrl: ctrl}
-	mock.recorder = &MockUserRepoMockRecorder{mock}
-	return mock
-}
-
-// EXPECT returns an object that allows the caller to indicate expected use.
-func (m *MockUserRepo) EXPECT() *MockUserRepoMockRecorder {
-	return m.recorder
-}
-
-// Create mocks base method.
-func (m *MockUserRepo) Create(linkName string, linkValue interface{}) (*domain.User, error) {
-	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "Create", linkName, linkValue)
-	ret0, _ := ret[0].(*domain.User)
-	ret1, _ := ret[1].(error)
-	return ret0, ret1
-}
-
-// Create indicates an expected call of Create.
-func (mr *MockUserRepoMockRecorder) Create(linkName, linkValue interface{}) *gomock.Call {
-	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Create", reflect.TypeOf((*MockUserRepo)(nil).Create), linkName, linkValue)
-}
-
-// Find mocks base method.
-func (m *MockUserRepo) Find(filter domain.UserFindFilter) ([]domain.User, error) {
-	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "Find", filter)
-	ret0, _ := ret[0].([]domain.User)
-	ret1, _ := ret[1].(error)
-	return ret0, ret1
-}
-
-// Find indicates an expected call of Find.
-func (mr *MockUserRepoMockRecorder) Find(filter interface{}) *gomock.Call {
-	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Find", reflect.TypeOf((*MockUserRepo)(nil).Find), filter)
-}
-
-// Update mocks base method.
-func (m *MockUserRepo) Update(userId, newName string) (*domain.User, error) {
-	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "Update", userId, newName)
-	ret0, _ := ret[0].(*domain.User)
-	ret1, _ := ret[1].(error)
-	return ret0, ret1
-}
-
-// Update indicates an expected call of Update.
-func (mr *MockUserRepoMockRecorder) Update(userId, newName interface{}) *gomock.Call {
-	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Update", reflect.TypeOf((*MockUserRepo)(nil).Update), userId, newName)
-}
-
 // MockAgentRepo is a mock of AgentRepo interface.
 type MockAgentRepo struct {
 	ctrl     *gomock.Controller
@@ -340,6 +400,35 @@ func (mr *MockAgentRepoMockRecorder) FindAgents(active interface{}) *gomock.Call
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindAgents", reflect.TypeOf((*MockAgentRepo)(nil).FindAgents), active)
 }
 
+// GetAgentStatus mocks base method.
+func (m *MockAgentRepo) GetAgentStatus(agentId int64) (domain.AgentStatus, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetAgentStatus", agentId)
+	ret0, _ := ret[0].(domain.AgentStatus)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetAgentStatus indicates an expected call of GetAgentStatus.
+func (mr *MockAgentRepoMockRecorder) GetAgentStatus(agentId interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAgentStatus", reflect.TypeOf((*MockAgentRepo)(nil).GetAgentStatus), agentId)
+}
+
+// SetAgentStatus mocks base method.
+func (m *MockAgentRepo) SetAgentStatus(agent domain.Agent, status domain.AgentStatus) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetAgentStatus", agent, status)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetAgentStatus indicates an expected call of SetAgentStatus.
+func (mr *MockAgentRepoMockRecorder) SetAgentStatus(agent, status interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetAgentStatus", reflect.TypeOf((*MockAgentRepo)(nil).SetAgentStatus), agent, status)
+}
+
 // MockStrategyRepo is a mock of StrategyRepo interface.
 type MockStrategyRepo struct {
 	ctrl     *gomock.Controller
@@ -437,6 +526,21 @@ func (m *MockExchangeRepo) EXPECT() *MockExchangeRepoMockRecorder {
 	return m.recorder
 }
 
+// AgentExchangesVersion mocks base method.
+func (m *MockExchangeRepo) AgentExchangesVersion(agentId int64) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AgentExchangesVersion", agentId)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AgentExchangesVersion indicates an expected call of AgentExchangesVersion.
+func (mr *MockExchangeRepoMockRecorder) AgentExchangesVersion(agentId interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AgentExchangesVersion", reflect.TypeOf((*MockExchangeRepo)(nil).AgentExchangesVersion), agentId)
+}
+
 // GetAgentExchanges mocks base method.
 func (m *MockExchangeRepo) GetAgentExchanges(agentId int64) ([]domain.Exchange, error) {
 	m.ctrl.T.Helper()
@@ -476,15 +580,450 @@ func (m *MockLoggerRepo) EXPECT() *MockLoggerRepoMockRecorder {
 }
 
 // New mocks base method.
-func (m *MockLoggerRepo) New(agentId int64) domain.Logger {
+func (m *MockLoggerRepo) New(labels domain.LoggerLabels) domain.Logger {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "New", agentId)
+	ret := m.ctrl.Call(m, "New", labels)
 	ret0, _ := ret[0].(domain.Logger)
 	return ret0
 }
 
 // New indicates an expected call of New.
-func (mr *MockLoggerRepoMockRecorder) New(agentId interface{}) *gomock.Call {
+func (mr *MockLoggerRepoMockRecorder) New(labels interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "New", reflect.TypeOf((*MockLoggerRepo)(nil).New), labels)
+}
+
+// MockMetrics is a mock of Metrics interface.
+type MockMetrics struct {
+	ctrl     *gomock.Controller
+	recorder *MockMetricsMockRecorder
+}
+
+// MockMetricsMockRecorder is the mock recorder for MockMetrics.
+type MockMetricsMockRecorder struct {
+	mock *MockMetrics
+}
+
+// NewMockMetrics creates a new mock instance.
+func NewMockMetrics(ctrl *gomock.Controller) *MockMetrics {
+	mock := &MockMetrics{ctrl: ctrl}
+	mock.recorder = &MockMetricsMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockMetrics) EXPECT() *MockMetricsMockRecorder {
+	return m.recorder
+}
+
+// Balance mocks base method.
+func (m *MockMetrics) Balance(asset string, amount decimal.Decimal) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "Balance", asset, amount)
+}
+
+// Balance indicates an expected call of Balance.
+func (mr *MockMetricsMockRecorder) Balance(asset, amount interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Balance", reflect.TypeOf((*MockMetrics)(nil).Balance), asset, amount)
+}
+
+// CycleFinished mocks base method.
+func (m *MockMetrics) CycleFinished(duration time.Duration, err error) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "CycleFinished", duration, err)
+}
+
+// CycleFinished indicates an expected call of CycleFinished.
+func (mr *MockMetricsMockRecorder) CycleFinished(duration, err interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CycleFinished", reflect.TypeOf((*MockMetrics)(nil).CycleFinished), duration, err)
+}
+
+// ExchangeCall mocks base method.
+func (m *MockMetrics) ExchangeCall(exchange, method string, duration time.Duration, err error) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "ExchangeCall", exchange, method, duration, err)
+}
+
+// ExchangeCall indicates an expected call of ExchangeCall.
+func (mr *MockMetricsMockRecorder) ExchangeCall(exchange, method, duration, err interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ExchangeCall", reflect.TypeOf((*MockMetrics)(nil).ExchangeCall), exchange, method, duration, err)
+}
+
+// Trades mocks base method.
+func (m *MockMetrics) Trades(status string, count int) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "Trades", status, count)
+}
+
+// Trades indicates an expected call of Trades.
+func (mr *MockMetricsMockRecorder) Trades(status, count interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Trades", reflect.TypeOf((*MockMetrics)(nil).Trades), status, count)
+}
+
+// MockMetricsRepo is a mock of MetricsRepo interface.
+type MockMetricsRepo struct {
+	ctrl     *gomock.Controller
+	recorder *MockMetricsRepoMockRecorder
+}
+
+// MockMetricsRepoMockRecorder is the mock recorder for MockMetricsRepo.
+type MockMetricsRepoMockRecorder struct {
+	mock *MockMetricsRepo
+}
+
+// NewMockMetricsRepo creates a new mock instance.
+func NewMockMetricsRepo(ctrl *gomock.Controller) *MockMetricsRepo {
+	mock := &MockMetricsRepo{ctrl: ctrl}
+	mock.recorder = &MockMetricsRepoMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockMetricsRepo) EXPECT() *MockMetricsRepoMockRecorder {
+	return m.recorder
+}
+
+// New mocks base method.
+func (m *MockMetricsRepo) New(agentId int64) domain.Metrics {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "New", agentId)
+	ret0, _ := ret[0].(domain.Metrics)
+	return ret0
+}
+
+// New indicates an expected call of New.
+func (mr *MockMetricsRepoMockRecorder) New(agentId interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "New", reflect.TypeOf((*MockMetricsRepo)(nil).New), agentId)
+}
+
+// MockTradeCounter is a mock of TradeCounter interface.
+type MockTradeCounter struct {
+	ctrl     *gomock.Controller
+	recorder *MockTradeCounterMockRecorder
+}
+
+// MockTradeCounterMockRecorder is the mock recorder for MockTradeCounter.
+type MockTradeCounterMockRecorder struct {
+	mock *MockTradeCounter
+}
+
+// NewMockTradeCounter creates a new mock instance.
+func NewMockTradeCounter(ctrl *gomock.Controller) *MockTradeCounter {
+	mock := &MockTradeCounter{ctrl: ctrl}
+	mock.recorder = &MockTradeCounterMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockTradeCounter) EXPECT() *MockTradeCounterMockRecorder {
+	return m.recorder
+}
+
+// CountTrades mocks base method.
+func (m *MockTradeCounter) CountTrades() (map[string]int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CountTrades")
+	ret0, _ := ret[0].(map[string]int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CountTrades indicates an expected call of CountTrades.
+func (mr *MockTradeCounterMockRecorder) CountTrades() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CountTrades", reflect.TypeOf((*MockTradeCounter)(nil).CountTrades))
+}
+
+// MockRiskRepo is a mock of RiskRepo interface.
+type MockRiskRepo struct {
+	ctrl     *gomock.Controller
+	recorder *MockRiskRepoMockRecorder
+}
+
+// MockRiskRepoMockRecorder is the mock recorder for MockRiskRepo.
+type MockRiskRepoMockRecorder struct {
+	mock *MockRiskRepo
+}
+
+// NewMockRiskRepo creates a new mock instance.
+func NewMockRiskRepo(ctrl *gomock.Controller) *MockRiskRepo {
+	mock := &MockRiskRepo{ctrl: ctrl}
+	mock.recorder = &MockRiskRepoMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockRiskRepo) EXPECT() *MockRiskRepoMockRecorder {
+	return m.recorder
+}
+
+// AddRiskOrder mocks base method.
+func (m *MockRiskRepo) AddRiskOrder(order domain.RiskOrder) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AddRiskOrder", order)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// AddRiskOrder indicates an expected call of AddRiskOrder.
+func (mr *MockRiskRepoMockRecorder) AddRiskOrder(order interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddRiskOrder", reflect.TypeOf((*MockRiskRepo)(nil).AddRiskOrder), order)
+}
+
+// FindRiskOrders mocks base method.
+func (m *MockRiskRepo) FindRiskOrders(filter domain.RiskOrderFilter) ([]domain.RiskOrder, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FindRiskOrders", filter)
+	ret0, _ := ret[0].([]domain.RiskOrder)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FindRiskOrders indicates an expected call of FindRiskOrders.
+func (mr *MockRiskRepoMockRecorder) FindRiskOrders(filter interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindRiskOrders", reflect.TypeOf((*MockRiskRepo)(nil).FindRiskOrders), filter)
+}
+
+// GetRiskLimits mocks base method.
+func (m *MockRiskRepo) GetRiskLimits(agent domain.Agent) (domain.RiskLimits, domain.RiskLimits, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetRiskLimits", agent)
+	ret0, _ := ret[0].(domain.RiskLimits)
+	ret1, _ := ret[1].(domain.RiskLimits)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// GetRiskLimits indicates an expected call of GetRiskLimits.
+func (mr *MockRiskRepoMockRecorder) GetRiskLimits(agent interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetRiskLimits", reflect.TypeOf((*MockRiskRepo)(nil).GetRiskLimits), agent)
+}
+
+// RemoveRiskOrder mocks base method.
+func (m *MockRiskRepo) RemoveRiskOrder(agentId int64, orderId string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RemoveRiskOrder", agentId, orderId)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RemoveRiskOrder indicates an expected call of RemoveRiskOrder.
+func (mr *MockRiskRepoMockRecorder) RemoveRiskOrder(agentId, orderId interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RemoveRiskOrder", reflect.TypeOf((*MockRiskRepo)(nil).RemoveRiskOrder), agentId, orderId)
+}
+
+// MockPriceFeed is a mock of PriceFeed interface.
+type MockPriceFeed struct {
+	ctrl     *gomock.Controller
+	recorder *MockPriceFeedMockRecorder
+}
+
+// MockPriceFeedMockRecorder is the mock recorder for MockPriceFeed.
+type MockPriceFeedMockRecorder struct {
+	mock *MockPriceFeed
+}
+
+// NewMockPriceFeed creates a new mock instance.
+func NewMockPriceFeed(ctrl *gomock.Controller) *MockPriceFeed {
+	mock := &MockPriceFeed{ctrl: ctrl}
+	mock.recorder = &MockPriceFeedMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockPriceFeed) EXPECT() *MockPriceFeedMockRecorder {
+	return m.recorder
+}
+
+// LastPrice mocks base method.
+func (m *MockPriceFeed) LastPrice(exchange domain.Exchange, pair domain.Pair) (decimal.Decimal, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "LastPrice", exchange, pair)
+	ret0, _ := ret[0].(decimal.Decimal)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// LastPrice indicates an expected call of LastPrice.
+func (mr *MockPriceFeedMockRecorder) LastPrice(exchange, pair interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LastPrice", reflect.TypeOf((*MockPriceFeed)(nil).LastPrice), exchange, pair)
+}
+
+// Update mocks base method.
+func (m *MockPriceFeed) Update(exchange string, pair domain.Pair, price decimal.Decimal) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "Update", exchange, pair, price)
+}
+
+// Update indicates an expected call of Update.
+func (mr *MockPriceFeedMockRecorder) Update(exchange, pair, price interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Update", reflect.TypeOf((*MockPriceFeed)(nil).Update), exchange, pair, price)
+}
+
+// MockCandleRepo is a mock of CandleRepo interface.
+type MockCandleRepo struct {
+	ctrl     *gomock.Controller
+	recorder *MockCandleRepoMockRecorder
+}
+
+// MockCandleRepoMockRecorder is the mock recorder for MockCandleRepo.
+type MockCandleRepoMockRecorder struct {
+	mock *MockCandleRepo
+}
+
+// NewMockCandleRepo creates a new mock instance.
+func NewMockCandleRepo(ctrl *gomock.Controller) *MockCandleRepo {
+	mock := &MockCandleRepo{ctrl: ctrl}
+	mock.recorder = &MockCandleRepoMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockCandleRepo) EXPECT() *MockCandleRepoMockRecorder {
+	return m.recorder
+}
+
+// CandleRange mocks base method.
+func (m *MockCandleRepo) CandleRange(exchange string, pair domain.Pair, interval domain.CandleInterval) (time.Time, time.Time, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CandleRange", exchange, pair, interval)
+	ret0, _ := ret[0].(time.Time)
+	ret1, _ := ret[1].(time.Time)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// CandleRange indicates an expected call of CandleRange.
+func (mr *MockCandleRepoMockRecorder) CandleRange(exchange, pair, interval interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CandleRange", reflect.TypeOf((*MockCandleRepo)(nil).CandleRange), exchange, pair, interval)
+}
+
+// FindCandles mocks base method.
+func (m *MockCandleRepo) FindCandles(exchange string, pair domain.Pair, interval domain.CandleInterval, since time.Time) ([]domain.Candle, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FindCandles", exchange, pair, interval, since)
+	ret0, _ := ret[0].([]domain.Candle)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FindCandles indicates an expected call of FindCandles.
+func (mr *MockCandleRepoMockRecorder) FindCandles(exchange, pair, interval, since interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindCandles", reflect.TypeOf((*MockCandleRepo)(nil).FindCandles), exchange, pair, interval, since)
+}
+
+// SaveCandles mocks base method.
+func (m *MockCandleRepo) SaveCandles(exchange string, pair domain.Pair, interval domain.CandleInterval, candles []domain.Candle) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SaveCandles", exchange, pair, interval, candles)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SaveCandles indicates an expected call of SaveCandles.
+func (mr *MockCandleRepoMockRecorder) SaveCandles(exchange, pair, interval, candles interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveCandles", reflect.TypeOf((*MockCandleRepo)(nil).SaveCandles), exchange, pair, interval, candles)
+}
+
+// MockPaperRepo is a mock of PaperRepo interface.
+type MockPaperRepo struct {
+	ctrl     *gomock.Controller
+	recorder *MockPaperRepoMockRecorder
+}
+
+// MockPaperRepoMockRecorder is the mock recorder for MockPaperRepo.
+type MockPaperRepoMockRecorder struct {
+	mock *MockPaperRepo
+}
+
+// NewMockPaperRepo creates a new mock instance.
+func NewMockPaperRepo(ctrl *gomock.Controller) *MockPaperRepo {
+	mock := &MockPaperRepo{ctrl: ctrl}
+	mock.recorder = &MockPaperRepoMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockPaperRepo) EXPECT() *MockPaperRepoMockRecorder {
+	return m.recorder
+}
+
+// FindPaperOrders mocks base method.
+func (m *MockPaperRepo) FindPaperOrders(agentId int64, exchange string, statuses []domain.OrderStatus) ([]domain.Order, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FindPaperOrders", agentId, exchange, statuses)
+	ret0, _ := ret[0].([]domain.Order)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FindPaperOrders indicates an expected call of FindPaperOrders.
+func (mr *MockPaperRepoMockRecorder) FindPaperOrders(agentId, exchange, statuses interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindPaperOrders", reflect.TypeOf((*MockPaperRepo)(nil).FindPaperOrders), agentId, exchange, statuses)
+}
+
+// SavePaperOrder mocks base method.
+func (m *MockPaperRepo) SavePaperOrder(agentId int64, exchange string, order domain.Order) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SavePaperOrder", agentId, exchange, order)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SavePaperOrder indicates an expected call of SavePaperOrder.
+func (mr *MockPaperRepoMockRecorder) SavePaperOrder(agentId, exchange, order interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SavePaperOrder", reflect.TypeOf((*MockPaperRepo)(nil).SavePaperOrder), agentId, exchange, order)
+}
+
+// MockNotifier is a mock of Notifier interface.
+type MockNotifier struct {
+	ctrl     *gomock.Controller
+	recorder *MockNotifierMockRecorder
+}
+
+// MockNotifierMockRecorder is the mock recorder for MockNotifier.
+type MockNotifierMockRecorder struct {
+	mock *MockNotifier
+}
+
+// NewMockNotifier creates a new mock instance.
+func NewMockNotifier(ctrl *gomock.Controller) *MockNotifier {
+	mock := &MockNotifier{ctrl: ctrl}
+	mock.recorder = &MockNotifierMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockNotifier) EXPECT() *MockNotifierMockRecorder {
+	return m.recorder
+}
+
+// Notify mocks base method.
+func (m *MockNotifier) Notify(userId int64, message string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Notify", userId, message)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Notify indicates an expected call of Notify.
+func (mr *MockNotifierMockRecorder) Notify(userId, message interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "New", reflect.TypeOf((*MockLoggerRepo)(nil).New), agentId)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Notify", reflect.TypeOf((*MockNotifier)(nil).Notify), userId, message)
 }
@@ -35,30 +35,30 @@ func (m *MockSimpleStorage) EXPECT() *MockSimpleStorageMockRecorder {
 }
 
 // GetTrades mocks base method.
-func (m *MockSimpleStorage) GetTrades(arg0 []domain.TradeStatus) ([]domain.Trade, error) {
+func (m *MockSimpleStorage) GetTrades(filter *domain.SimpleTradeFilter) ([]domain.SimpleTrade, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetTrades", arg0)
-	ret0, _ := ret[0].([]domain.Trade)
+	ret := m.ctrl.Call(m, "GetTrades", filter)
+	ret0, _ := ret[0].([]domain.SimpleTrade)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // GetTrades indicates an expected call of GetTrades.
-func (mr *MockSimpleStorageMockRecorder) GetTrades(arg0 interface{}) *gomock.Call {
+func (mr *MockSimpleStorageMockRecorder) GetTrades(filter interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetTrades", reflect.TypeOf((*MockSimpleStorage)(nil).GetTrades), arg0)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetTrades", reflect.TypeOf((*MockSimpleStorage)(nil).GetTrades), filter)
 }
 
-// SaveTrades mocks base method.
-func (m *MockSimpleStorage) SaveTrades(trades []domain.Trade) error {
+// SaveTrade mocks base method.
+func (m *MockSimpleStorage) SaveTrade(trade *domain.SimpleTrade) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "SaveTrades", trades)
+	ret := m.ctrl.Call(m, "SaveTrade", trade)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
-// SaveTrades indicates an expected call of SaveTrades.
-func (mr *MockSimpleStorageMockRecorder) SaveTrades(trades interface{}) *gomock.Call {
+// SaveTrade indicates an expected call of SaveTrade.
+func (mr *MockSimpleStorageMockRecorder) SaveTrade(trade interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveTrades", reflect.TypeOf((*MockSimpleStorage)(nil).SaveTrades), trades)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveTrade", reflect.TypeOf((*MockSimpleStorage)(nil).SaveTrade), trade)
 }
@@ -0,0 +1,78 @@
+package test_domain
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/scientistnik/invest-agents/internal/app/domain"
+	mock_domain "github.com/scientistnik/invest-agents/internal/app/domain/tests/mocks"
+	"github.com/shopspring/decimal"
+)
+
+func TestDryRunSellsOnPaper(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	pair := domain.Pair{BaseAsset: "BTC", QuoteAsset: "USD"}
+	agent := simpleAgent(1)
+	agent.DryRun = true
+
+	mRisk := mock_domain.NewMockRiskRepo(ctrl)
+	mPaper := mock_domain.NewMockPaperRepo(ctrl)
+	mExchange := mock_domain.NewMockExchange(ctrl)
+	run := newAgentRun(ctrl, agent, mExchange)
+	mStorage := run.Storage
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// reads go to the real exchange, orders never do
+	mExchange.EXPECT().Name().Return("test").AnyTimes()
+	mExchange.EXPECT().Balances(gomock.Any()).Return([]domain.Balance{{Asset: "USD", Amount: decimal.NewFromInt(1000)}}, nil)
+	mExchange.EXPECT().LastPrice(pair).Return(decimal.NewFromInt(100), nil).AnyTimes()
+	mExchange.EXPECT().GetOrderFee(gomock.Any(), gomock.Any(), gomock.Any()).Return(domain.Balance{}, nil).AnyTimes()
+	mExchange.EXPECT().GetPairFee(gomock.Any()).Return(domain.Balance{}, nil).AnyTimes()
+
+	mPaper.EXPECT().FindPaperOrders(agent.Id, "test", gomock.Any()).Return([]domain.Order{}, nil).AnyTimes()
+	mStorage.EXPECT().GetTrades(gomock.Any()).Return([]domain.SimpleTrade{{
+		Id:     1,
+		Pair:   pair,
+		Status: domain.SimpleTradeStatusSell,
+		Amount: decimal.NewFromInt(1),
+		Buy:    domain.SimpleTradeOrder{Price: decimal.NewFromInt(90), Commission: domain.Balance{Asset: "USD"}},
+	}}, nil)
+
+	var sellOrder domain.Order
+	mPaper.EXPECT().SavePaperOrder(agent.Id, "test", gomock.Any()).DoAndReturn(func(agentId int64, exchange string, order domain.Order) error {
+		sellOrder = order
+		return nil
+	})
+	// the sell is saved with its client id before it is placed
+	saves := 0
+	mStorage.EXPECT().SaveTrade(gomock.Any()).DoAndReturn(func(trade *domain.SimpleTrade) error {
+		saves++
+		if trade.Sell.ClientOrderId == "" {
+			t.Errorf("trade sell without client order id %+v", trade.Sell)
+		}
+		if saves == 2 {
+			if trade.Sell.OrderId != sellOrder.Id {
+				t.Errorf("trade sell order %q, want %q", trade.Sell.OrderId, sellOrder.Id)
+			}
+			cancel()
+		}
+		return nil
+	}).Times(2)
+
+	run.Repos.Risk = mRisk
+	run.Repos.Paper = mPaper
+	err := domain.StartAgents(ctx, run.Repos, domain.AgentsSettings{Interval: time.Hour, ShutdownTimeout: time.Second})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if sellOrder.Status != domain.PendingOrderStatus || !sellOrder.Amount.Equal(decimal.NewFromInt(1)) {
+		t.Fatalf("unexpected paper order %+v", sellOrder)
+	}
+}
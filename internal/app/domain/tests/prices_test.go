@@ -0,0 +1,60 @@
+package test_domain
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/scientistnik/invest-agents/internal/app/domain"
+	mock_domain "github.com/scientistnik/invest-agents/internal/app/domain/tests/mocks"
+	"github.com/shopspring/decimal"
+)
+
+func TestPriceCacheSharesPrices(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	btc := domain.Pair{BaseAsset: "BTC", QuoteAsset: "USD"}
+	eth := domain.Pair{BaseAsset: "ETH", QuoteAsset: "USD"}
+
+	first := mock_domain.NewMockExchange(ctrl)
+	second := mock_domain.NewMockExchange(ctrl)
+	first.EXPECT().Name().Return("test").AnyTimes()
+	second.EXPECT().Name().Return("test").AnyTimes()
+
+	var calls int32
+	lastPrice := func(pair domain.Pair) (decimal.Decimal, error) {
+		atomic.AddInt32(&calls, 1)
+		return decimal.NewFromInt(100), nil
+	}
+	first.EXPECT().LastPrice(btc).DoAndReturn(lastPrice).AnyTimes()
+	second.EXPECT().LastPrice(btc).DoAndReturn(lastPrice).AnyTimes()
+	second.EXPECT().LastPrice(eth).Return(decimal.NewFromInt(10), nil)
+
+	cache := domain.NewPriceCache(time.Hour)
+
+	var wg sync.WaitGroup
+	for _, exchange := range []domain.Exchange{first, second, first, second} {
+		wg.Add(1)
+		go func(exchange domain.Exchange) {
+			defer wg.Done()
+
+			price, err := cache.LastPrice(exchange, btc)
+			if err != nil || !price.Equal(decimal.NewFromInt(100)) {
+				t.Errorf("btc price %s, %v", price, err)
+			}
+		}(exchange)
+	}
+	wg.Wait()
+
+	if calls != 1 {
+		t.Fatalf("btc price is requested %d times", calls)
+	}
+
+	price, err := cache.LastPrice(second, eth)
+	if err != nil || !price.Equal(decimal.NewFromInt(10)) {
+		t.Fatalf("eth price %s, %v", price, err)
+	}
+}
@@ -0,0 +1,286 @@
+package test_domain
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/scientistnik/invest-agents/internal/app/domain"
+	mock_domain "github.com/scientistnik/invest-agents/internal/app/domain/tests/mocks"
+	"github.com/shopspring/decimal"
+)
+
+func TestRiskLimitPausesAgent(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	pair := domain.Pair{BaseAsset: "BTC", QuoteAsset: "USD"}
+	agent := simpleAgent(2)
+	mRisk := mock_domain.NewMockRiskRepo(ctrl)
+	mNotifier := mock_domain.NewMockNotifier(ctrl)
+	mExchange := mock_domain.NewMockExchange(ctrl)
+	run := newAgentRun(ctrl, agent, mExchange)
+	mStorage := run.Storage
+
+	mExchange.EXPECT().Balances(gomock.Any()).Return([]domain.Balance{{Asset: "USD", Amount: decimal.NewFromInt(1000)}}, nil)
+	mExchange.EXPECT().GetOpenOrders(gomock.Any()).Return([]domain.Order{}, nil)
+	mStorage.EXPECT().GetTrades(gomock.Any()).Return([]domain.SimpleTrade{{
+		Id:     1,
+		Pair:   pair,
+		Status: domain.SimpleTradeStatusSell,
+		Amount: decimal.NewFromInt(1),
+		Buy:    domain.SimpleTradeOrder{Price: decimal.NewFromInt(50), Commission: domain.Balance{Asset: "USD"}},
+	}}, nil)
+	mExchange.EXPECT().LastPrice(pair).Return(decimal.NewFromInt(100), nil)
+	mExchange.EXPECT().GetOrderFee(gomock.Any(), gomock.Any(), gomock.Any()).Return(domain.Balance{}, nil).AnyTimes()
+
+	mRisk.EXPECT().GetRiskLimits(agent).Return(domain.RiskLimits{MaxOrdersPerHour: 1}, domain.RiskLimits{}, nil).AnyTimes()
+	mRisk.EXPECT().FindRiskOrders(gomock.Any()).Return([]domain.RiskOrder{{AgentId: agent.Id, Datetime: time.Now()}}, nil).AnyTimes()
+
+	// the trade is saved before the buy and canceled after the refusal
+	var status domain.SimpleTradeStatus
+	mStorage.EXPECT().SaveTrade(gomock.Any()).DoAndReturn(func(trade *domain.SimpleTrade) error {
+		status = trade.Status
+		return nil
+	}).Times(2)
+
+	run.Agents.EXPECT().SetAgentStatus(agent, domain.AgentStatus(domain.PausedAgentStatus)).Return(nil)
+	mNotifier.EXPECT().Notify(agent.UserId, gomock.Any()).Return(nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	run.Repos.Risk = mRisk
+	run.Repos.Notifier = mNotifier
+	err := domain.StartAgents(ctx, run.Repos, domain.AgentsSettings{Interval: time.Hour, ShutdownTimeout: time.Second})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if status != domain.SimpleTradeStatusCanceled {
+		t.Fatalf("refused buy left trade status %d", status)
+	}
+}
+
+func TestAuthErrorFailsAgent(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	agent := simpleAgent(2)
+	mNotifier := mock_domain.NewMockNotifier(ctrl)
+	mExchange := mock_domain.NewMockExchange(ctrl)
+	run := newAgentRun(ctrl, agent, mExchange)
+
+	mExchange.EXPECT().Balances(gomock.Any()).Return(nil, domain.NewExchangeError(domain.ErrAuth, errors.New("invalid api key")))
+
+	// the agent is not run again, so only one cycle happens
+	run.Agents.EXPECT().SetAgentStatus(agent, domain.AgentStatus(domain.ErrorAgentStatus)).Return(nil)
+	mNotifier.EXPECT().Notify(agent.UserId, gomock.Any()).Return(nil)
+
+	run.Repos.Notifier = mNotifier
+	err := domain.StartAgents(context.Background(), run.Repos, domain.AgentsSettings{Interval: time.Hour, ShutdownTimeout: time.Second})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRiskLimitsOfBuy(t *testing.T) {
+	pair := domain.Pair{BaseAsset: "BTC", QuoteAsset: "USD"}
+	today := time.Now()
+
+	cases := []struct {
+		name    string
+		limits  domain.RiskLimits
+		orders  []domain.RiskOrder
+		refused string
+	}{
+		{
+			name:    "allocation reached",
+			limits:  domain.RiskLimits{MaxQuoteAllocation: decimal.NewFromInt(150)},
+			orders:  []domain.RiskOrder{{Side: domain.BuyRiskOrderSide, Pair: pair, Amount: decimal.NewFromInt(1), Price: decimal.NewFromInt(100), Datetime: today}},
+			refused: "max_quote_allocation",
+		},
+		{
+			name:   "allocation left",
+			limits: domain.RiskLimits{MaxQuoteAllocation: decimal.NewFromInt(250)},
+			orders: []domain.RiskOrder{{Side: domain.BuyRiskOrderSide, Pair: pair, Amount: decimal.NewFromInt(1), Price: decimal.NewFromInt(100), Datetime: today}},
+		},
+		{
+			name:   "sold allocation is free again",
+			limits: domain.RiskLimits{MaxQuoteAllocation: decimal.NewFromInt(150)},
+			orders: []domain.RiskOrder{
+				{Side: domain.BuyRiskOrderSide, Pair: pair, Amount: decimal.NewFromInt(1), Price: decimal.NewFromInt(100), Datetime: today},
+				{Side: domain.SellRiskOrderSide, Pair: pair, Amount: decimal.NewFromInt(1), Price: decimal.NewFromInt(100), Datetime: today},
+			},
+		},
+		{
+			name:    "exposure reached",
+			limits:  domain.RiskLimits{MaxExposure: map[string]decimal.Decimal{"BTC": decimal.NewFromFloat(1.5)}},
+			orders:  []domain.RiskOrder{{Side: domain.BuyRiskOrderSide, Pair: pair, Amount: decimal.NewFromInt(1), Price: decimal.NewFromInt(100), Datetime: today}},
+			refused: "max_exposure",
+		},
+		{
+			name:   "exposure of another asset",
+			limits: domain.RiskLimits{MaxExposure: map[string]decimal.Decimal{"ETH": decimal.NewFromFloat(0.5)}},
+			orders: []domain.RiskOrder{{Side: domain.BuyRiskOrderSide, Pair: pair, Amount: decimal.NewFromInt(1), Price: decimal.NewFromInt(100), Datetime: today}},
+		},
+		{
+			name:    "daily loss reached",
+			limits:  domain.RiskLimits{MaxDailyLoss: decimal.NewFromInt(20)},
+			orders:  []domain.RiskOrder{{Side: domain.BuyRiskOrderSide, Pair: pair, Amount: decimal.NewFromInt(1), Price: decimal.NewFromInt(130), Datetime: today}},
+			refused: "max_daily_loss",
+		},
+		{
+			name:   "daily loss below the limit",
+			limits: domain.RiskLimits{MaxDailyLoss: decimal.NewFromInt(20)},
+			orders: []domain.RiskOrder{{Side: domain.BuyRiskOrderSide, Pair: pair, Amount: decimal.NewFromInt(1), Price: decimal.NewFromInt(110), Datetime: today}},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			agent := simpleAgent(2)
+			mRisk := mock_domain.NewMockRiskRepo(ctrl)
+			mNotifier := mock_domain.NewMockNotifier(ctrl)
+			mExchange := mock_domain.NewMockExchange(ctrl)
+			run := newAgentRun(ctrl, agent, mExchange)
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			mExchange.EXPECT().Balances(gomock.Any()).Return([]domain.Balance{{Asset: "USD", Amount: decimal.NewFromInt(1000)}}, nil)
+			mExchange.EXPECT().GetOpenOrders(gomock.Any()).Return([]domain.Order{}, nil)
+			mExchange.EXPECT().LastPrice(pair).Return(decimal.NewFromInt(100), nil).AnyTimes()
+			mExchange.EXPECT().GetOrderFee(gomock.Any(), gomock.Any(), gomock.Any()).Return(domain.Balance{}, nil).AnyTimes()
+			// an open buy far below the price lets the agent buy again
+			mExchange.EXPECT().GetHistoryOrders(gomock.Any()).Return([]domain.Order{}, nil)
+			run.Storage.EXPECT().GetTrades(gomock.Any()).Return([]domain.SimpleTrade{{
+				Id:     1,
+				Pair:   pair,
+				Status: domain.SimpleTradeStatusBuy,
+				Amount: decimal.NewFromInt(1),
+				Buy:    domain.SimpleTradeOrder{OrderId: "1", Price: decimal.NewFromInt(50)},
+			}}, nil)
+
+			mRisk.EXPECT().GetRiskLimits(agent).Return(domain.RiskLimits{}, c.limits, nil)
+			mRisk.EXPECT().FindRiskOrders(gomock.Any()).Return(c.orders, nil).AnyTimes()
+
+			var saved domain.SimpleTrade
+			run.Storage.EXPECT().SaveTrade(gomock.Any()).DoAndReturn(func(trade *domain.SimpleTrade) error {
+				trade.Id = 2
+				saved = *trade
+				if trade.Buy.OrderId != "" {
+					cancel()
+				}
+				return nil
+			}).Times(2)
+
+			var recorded domain.RiskOrder
+			if c.refused != "" {
+				run.Agents.EXPECT().SetAgentStatus(agent, domain.AgentStatus(domain.PausedAgentStatus)).Return(nil)
+				mNotifier.EXPECT().Notify(agent.UserId, gomock.Any()).Return(nil)
+			} else {
+				// the exchange tells only the executed part of an open order
+				mExchange.EXPECT().PlaceOrder(gomock.Any()).Return(&domain.Order{Id: "7", Status: domain.PendingOrderStatus, Pair: pair}, nil)
+				mRisk.EXPECT().AddRiskOrder(gomock.Any()).DoAndReturn(func(order domain.RiskOrder) error {
+					recorded = order
+					return nil
+				})
+			}
+
+			run.Repos.Risk = mRisk
+			run.Repos.Notifier = mNotifier
+			err := domain.StartAgents(ctx, run.Repos, domain.AgentsSettings{Interval: time.Hour, ShutdownTimeout: time.Second})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if c.refused != "" {
+				if saved.Status != domain.SimpleTradeStatusCanceled {
+					t.Fatalf("refused buy left trade status %d", saved.Status)
+				}
+				return
+			}
+
+			if recorded.OrderId != "7" || !recorded.Amount.Equal(decimal.NewFromInt(1)) || !recorded.Price.Equal(decimal.NewFromInt(100)) {
+				t.Fatalf("unexpected recorded order %+v", recorded)
+			}
+		})
+	}
+}
+
+func TestRiskKeepsFilledPartOfCanceledOrder(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	pair := domain.Pair{BaseAsset: "BTC", QuoteAsset: "USD"}
+	agent := simpleAgent(1)
+	agent.StrategyData = []byte(`{"pairs":[{"pair":{"base_asset":"BTC","quote_asset":"USD"}}],"base_quantity":"1","max_trades":1,"profit_percent":"0.01","far_price_percent":"0.01","entry_order_type":"limit","entry_limit_offset":"0.01","entry_limit_timeout":10}`)
+
+	mRisk := mock_domain.NewMockRiskRepo(ctrl)
+	mExchange := mock_domain.NewMockExchange(ctrl)
+	run := newAgentRun(ctrl, agent, mExchange)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	mExchange.EXPECT().Balances(gomock.Any()).Return([]domain.Balance{{Asset: "USD", Amount: decimal.NewFromInt(1000)}}, nil)
+	mExchange.EXPECT().GetOpenOrders(gomock.Any()).Return([]domain.Order{{Id: "b1", Status: domain.PendingOrderStatus, Side: domain.BuyOrderSide, Pair: pair}}, nil)
+	mExchange.EXPECT().LastPrice(pair).Return(decimal.NewFromInt(100), nil).AnyTimes()
+	mExchange.EXPECT().GetOrderFee(gomock.Any(), gomock.Any(), gomock.Any()).Return(domain.Balance{}, nil).AnyTimes()
+	mExchange.EXPECT().PlaceOrder(gomock.Any()).Return(&domain.Order{Id: "b2", Status: domain.PendingOrderStatus, Pair: pair}, nil).AnyTimes()
+	run.Storage.EXPECT().GetTrades(gomock.Any()).Return([]domain.SimpleTrade{{
+		Id:     1,
+		Pair:   pair,
+		Status: domain.SimpleTradeStatusBuy,
+		Amount: decimal.NewFromInt(1),
+		Buy:    domain.SimpleTradeOrder{OrderId: "b1", Datetime: time.Now().Add(-time.Hour).Format(time.RFC3339), Price: decimal.NewFromInt(99)},
+	}}, nil)
+	run.Storage.EXPECT().SaveTrade(gomock.Any()).Return(nil).AnyTimes()
+
+	// the expired buy got 0.4 of 1 before it was canceled
+	gomock.InOrder(
+		mExchange.EXPECT().GetHistoryOrders(gomock.Any()).Return([]domain.Order{}, nil),
+		mExchange.EXPECT().CancelOrder("b1", pair).Return(nil),
+	)
+	mExchange.EXPECT().GetHistoryOrders([]domain.Pair{pair}).Return([]domain.Order{
+		{Id: "b1", Status: domain.FillOrderStatus, Side: domain.BuyOrderSide, Amount: decimal.NewFromFloat(0.3), Price: decimal.NewFromInt(99), Pair: pair},
+		{Id: "b1", Status: domain.FillOrderStatus, Side: domain.BuyOrderSide, Amount: decimal.NewFromFloat(0.1), Price: decimal.NewFromInt(99), Pair: pair},
+	}, nil).AnyTimes()
+
+	mRisk.EXPECT().GetRiskLimits(agent).Return(domain.RiskLimits{}, domain.RiskLimits{}, nil).AnyTimes()
+	mRisk.EXPECT().FindRiskOrders(domain.RiskOrderFilter{AgentId: agent.Id}).Return([]domain.RiskOrder{{
+		Id:      5,
+		AgentId: agent.Id,
+		OrderId: "b1",
+		Side:    domain.BuyRiskOrderSide,
+		Pair:    pair,
+		Amount:  decimal.NewFromInt(1),
+		Price:   decimal.NewFromInt(99),
+	}}, nil)
+	mRisk.EXPECT().RemoveRiskOrder(agent.Id, "b1").Return(nil)
+
+	var kept domain.RiskOrder
+	mRisk.EXPECT().AddRiskOrder(gomock.Any()).DoAndReturn(func(order domain.RiskOrder) error {
+		if order.OrderId == "b1" {
+			kept = order
+		}
+		cancel()
+		return nil
+	}).AnyTimes()
+
+	run.Repos.Risk = mRisk
+	err := domain.StartAgents(ctx, run.Repos, domain.AgentsSettings{Interval: time.Hour, ShutdownTimeout: time.Second})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if kept.Id != 0 || !kept.Amount.Equal(decimal.NewFromFloat(0.4)) || !kept.Price.Equal(decimal.NewFromInt(99)) {
+		t.Fatalf("unexpected kept order %+v", kept)
+	}
+}
@@ -1,11 +1,16 @@
 package test_domain
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"github.com/scientistnik/invest-agents/internal/app/domain"
 	mock_domain "github.com/scientistnik/invest-agents/internal/app/domain/tests/mocks"
 	"testing"
+	"time"
 
 	"github.com/golang/mock/gomock"
+	"github.com/shopspring/decimal"
 )
 
 func TestBalanceError(t *testing.T) {
@@ -17,11 +22,633 @@ func TestBalanceError(t *testing.T) {
 	mExchange := mock_domain.NewMockExchange(ctrl)
 
 	mLogger.EXPECT().Info(gomock.Any())
-	mLogger.EXPECT().Debug(gomock.Any()).Times(10)
 
-	empytSlice := make([]domain.Balance, 0)
-	mExchange.EXPECT().Balances(gomock.Any()).Return(empytSlice, nil)
+	mExchange.EXPECT().Balances(gomock.Any()).Return(nil, errors.New("balance error"))
 
 	simple := domain.SimpleStrategy{}
-	simple.Run(mStorage, []domain.Exchange{mExchange}, mLogger)
+	err := simple.Run(context.Background(), mStorage, []domain.Exchange{mExchange}, mLogger)
+	if err == nil {
+		t.Fatal("expected balance error")
+	}
+}
+
+func TestSimpleStrategyData(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mExchange := mock_domain.NewMockExchange(ctrl)
+	mExchange.EXPECT().GetPairFee(gomock.Any()).Return(domain.Balance{Asset: "USD", Amount: decimal.NewFromFloat(0.002)}, nil).Times(2)
+
+	pair := `"pair":{"base_asset":"BTC","quote_asset":"USD"}`
+
+	_, _, err := domain.ValidateStrategyData(domain.SimpleStratedy, []byte(`{`+pair+`,"base_quantity":"0.001","profit_percent":"0.003"}`), []domain.Exchange{mExchange})
+	if err == nil {
+		t.Fatal("expected profit below round-trip fees error")
+	}
+
+	data, _, err := domain.ValidateStrategyData(domain.SimpleStratedy, []byte(`{`+pair+`,"base_quantity":"0.001","profit_percent":"0.01"}`), []domain.Exchange{mExchange})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	strategy, err := domain.NewSimpleStrategyFromJson(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strategy.MaxTrades != 1 {
+		t.Fatalf("expected default max_trades 1, got %d", strategy.MaxTrades)
+	}
+
+	_, _, err = domain.ValidateStrategyData(domain.SimpleStratedy, []byte(`{`+pair+`,"base_quantity":"0.001","profit_percent":"0.01","max_trades":0,"extra":1}`), nil)
+	problems, ok := err.(domain.ParametersError)
+	if !ok || len(problems) != 2 {
+		t.Fatalf("expected max_trades and unknown key errors, got %v", err)
+	}
+}
+
+func TestSimpleStrategyUpgrade(t *testing.T) {
+	data := []byte(`{"pair":{"base_asset":"BTC","quote_asset":"USD"},"base_quality":"0.5","max_trades":2}`)
+
+	strategy, err := domain.GetStrategyFromJson(domain.SimpleStratedy, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	simple := strategy.(*domain.SimpleStrategy)
+	if !simple.BaseQuantity.Equal(decimal.NewFromFloat(0.5)) {
+		t.Fatalf("expected base_quality to become base_quantity, got %s", simple.BaseQuantity)
+	}
+	if len(simple.Pairs) != 1 || simple.Pairs[0].Pair.String() != "BTC/USD" {
+		t.Fatalf("expected pair to become pairs, got %v", simple.Pairs)
+	}
+
+	_, err = domain.GetStrategyFromJson(domain.SimpleStratedy, []byte(`{"version":100}`))
+	if err == nil {
+		t.Fatal("expected error for unknown data version")
+	}
+}
+
+func TestMultiPairBatching(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mLogger := mock_domain.NewMockLogger(ctrl)
+	mStorage := mock_domain.NewMockSimpleStorage(ctrl)
+	mExchange := mock_domain.NewMockExchange(ctrl)
+
+	btc := domain.Pair{BaseAsset: "BTC", QuoteAsset: "USD"}
+	eth := domain.Pair{BaseAsset: "ETH", QuoteAsset: "USD"}
+
+	mLogger.EXPECT().Info(gomock.Any()).AnyTimes()
+	mLogger.EXPECT().Debug(gomock.Any()).AnyTimes()
+
+	mExchange.EXPECT().Balances([]string{"BTC", "USD", "ETH"}).Return([]domain.Balance{}, nil)
+	mExchange.EXPECT().GetOpenOrders(&domain.OrderFilter{Pairs: []domain.Pair{btc, eth}}).Return([]domain.Order{}, nil)
+	mStorage.EXPECT().GetTrades(gomock.Any()).Return([]domain.SimpleTrade{}, nil)
+	mExchange.EXPECT().LastPrice(btc).Return(decimal.NewFromInt(100), nil)
+	mExchange.EXPECT().LastPrice(eth).Return(decimal.NewFromInt(10), nil)
+	mExchange.EXPECT().GetOrderFee(gomock.Any(), gomock.Any(), gomock.Any()).Return(domain.Balance{}, nil).AnyTimes()
+
+	simple := domain.SimpleStrategy{
+		Pairs:        []domain.SimplePair{{Pair: btc}, {Pair: eth}},
+		BaseQuantity: decimal.NewFromInt(1),
+		MaxTrades:    1,
+	}
+	err := simple.Run(context.Background(), mStorage, []domain.Exchange{mExchange}, mLogger)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestEntryConditions(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mLogger := mock_domain.NewMockLogger(ctrl)
+	mStorage := mock_domain.NewMockSimpleStorage(ctrl)
+	mExchange := mock_domain.NewMockExchange(ctrl)
+
+	pair := domain.Pair{BaseAsset: "BTC", QuoteAsset: "USD"}
+	candles := func(step int64) []domain.Candle {
+		candles := []domain.Candle{}
+		for i := int64(0); i < 20; i++ {
+			price := decimal.NewFromInt(100 + step*(i-19))
+			candles = append(candles, domain.Candle{Open: price, High: price, Low: price, Close: price})
+		}
+		return candles
+	}
+
+	mLogger.EXPECT().Info(gomock.Any()).AnyTimes()
+	mLogger.EXPECT().Debug(gomock.Any()).AnyTimes()
+
+	// an open sell far above the price lets the strategy look for a new buy
+	mExchange.EXPECT().Balances(gomock.Any()).Return([]domain.Balance{{Asset: "USD", Amount: decimal.NewFromInt(1000)}}, nil).Times(2)
+	mExchange.EXPECT().GetOpenOrders(gomock.Any()).Return([]domain.Order{{Id: "s1", Status: domain.PendingOrderStatus, Pair: pair}}, nil).Times(2)
+	mExchange.EXPECT().GetHistoryOrders(gomock.Any()).Return([]domain.Order{}, nil).Times(2)
+	mStorage.EXPECT().GetTrades(gomock.Any()).Return([]domain.SimpleTrade{{
+		Id:     1,
+		Pair:   pair,
+		Status: domain.SimpleTradeStatusSell,
+		Amount: decimal.NewFromInt(1),
+		Buy:    domain.SimpleTradeOrder{Price: decimal.NewFromInt(150), Commission: domain.Balance{Asset: "USD"}},
+		Sell:   domain.SimpleTradeOrder{OrderId: "s1", Price: decimal.NewFromInt(160)},
+	}}, nil).Times(2)
+	mExchange.EXPECT().LastPrice(pair).Return(decimal.NewFromInt(100), nil).Times(2)
+	mExchange.EXPECT().GetOrderFee(gomock.Any(), gomock.Any(), gomock.Any()).Return(domain.Balance{Asset: "USD"}, nil).AnyTimes()
+	mExchange.EXPECT().GetPairFee(pair).Return(domain.Balance{Asset: "USD"}, nil).AnyTimes()
+
+	simple := domain.SimpleStrategy{
+		Pairs:           []domain.SimplePair{{Pair: pair}},
+		BaseQuantity:    decimal.NewFromInt(1),
+		MaxTrades:       2,
+		ProfitPercent:   decimal.NewFromFloat(0.01),
+		FarPricePercent: decimal.NewFromFloat(0.01),
+		EntryInterval:   domain.HourCandleInterval,
+		EntryRsiPeriod:  5,
+		EntryRsiBelow:   30,
+	}
+
+	// rising prices are overbought, no buy
+	mExchange.EXPECT().GetCandles(pair, domain.HourCandleInterval, gomock.Any()).Return(candles(1), nil)
+	err := simple.Run(context.Background(), mStorage, []domain.Exchange{mExchange}, mLogger)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// falling prices are oversold, the buy goes on
+	mExchange.EXPECT().GetCandles(pair, domain.HourCandleInterval, gomock.Any()).Return(candles(-1), nil)
+	mExchange.EXPECT().PlaceOrder(gomock.Any()).Return(&domain.Order{Id: "b1", Status: domain.FillOrderStatus, Price: decimal.NewFromInt(100), Amount: decimal.NewFromInt(1), Pair: pair, Commission: domain.Balance{Asset: "USD"}}, nil)
+	mStorage.EXPECT().SaveTrade(gomock.Any()).Return(nil).AnyTimes()
+	mExchange.EXPECT().PlaceOrder(gomock.Any()).Return(&domain.Order{Id: "s2", Status: domain.PendingOrderStatus, Pair: pair}, nil).AnyTimes()
+	err = simple.Run(context.Background(), mStorage, []domain.Exchange{mExchange}, mLogger)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLimitEntryReprice(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mLogger := mock_domain.NewMockLogger(ctrl)
+	mStorage := mock_domain.NewMockSimpleStorage(ctrl)
+	mExchange := mock_domain.NewMockExchange(ctrl)
+
+	pair := domain.Pair{BaseAsset: "BTC", QuoteAsset: "USD"}
+
+	mLogger.EXPECT().Info(gomock.Any()).AnyTimes()
+	mLogger.EXPECT().Debug(gomock.Any()).AnyTimes()
+
+	mExchange.EXPECT().Balances(gomock.Any()).Return([]domain.Balance{{Asset: "USD", Amount: decimal.NewFromInt(1000)}}, nil)
+	mExchange.EXPECT().GetOpenOrders(gomock.Any()).Return([]domain.Order{{Id: "b1", Status: domain.PendingOrderStatus, Side: domain.BuyOrderSide, Pair: pair}}, nil)
+	mExchange.EXPECT().GetHistoryOrders(gomock.Any()).Return([]domain.Order{}, nil).Times(2)
+	mStorage.EXPECT().GetTrades(gomock.Any()).Return([]domain.SimpleTrade{{
+		Id:     1,
+		Pair:   pair,
+		Status: domain.SimpleTradeStatusBuy,
+		Amount: decimal.NewFromInt(1),
+		Buy:    domain.SimpleTradeOrder{OrderId: "b1", Datetime: time.Now().Add(-time.Hour).Format(time.RFC3339), Price: decimal.NewFromInt(95)},
+	}}, nil)
+	mExchange.EXPECT().LastPrice(pair).Return(decimal.NewFromInt(100), nil).AnyTimes()
+	mExchange.EXPECT().GetOrderFee(gomock.Any(), gomock.Any(), gomock.Any()).Return(domain.Balance{Asset: "USD"}, nil).AnyTimes()
+
+	// the old buy is canceled before a new one is placed one percent below the price
+	gomock.InOrder(
+		mExchange.EXPECT().CancelOrder("b1", pair).Return(nil),
+		mExchange.EXPECT().PlaceOrder(gomock.Any()).DoAndReturn(func(request domain.OrderRequest) (*domain.Order, error) {
+			if request.Side != domain.BuyOrderSide || request.Type != domain.LimitOrderType || !request.Price.Equal(decimal.NewFromInt(99)) {
+				t.Errorf("unexpected entry request %+v", request)
+			}
+			return &domain.Order{Id: "b2", Status: domain.PendingOrderStatus, Side: domain.BuyOrderSide, Price: request.Price, Pair: pair}, nil
+		}),
+	)
+	var saved domain.SimpleTrade
+	mStorage.EXPECT().SaveTrade(gomock.Any()).DoAndReturn(func(trade *domain.SimpleTrade) error {
+		saved = *trade
+		return nil
+	}).AnyTimes()
+
+	simple := domain.SimpleStrategy{
+		Pairs:             []domain.SimplePair{{Pair: pair}},
+		BaseQuantity:      decimal.NewFromInt(1),
+		MaxTrades:         1,
+		ProfitPercent:     decimal.NewFromFloat(0.01),
+		FarPricePercent:   decimal.NewFromFloat(0.01),
+		EntryOrderType:    domain.LimitOrderType,
+		EntryLimitOffset:  decimal.NewFromFloat(0.01),
+		EntryLimitTimeout: 10,
+	}
+	err := simple.Run(context.Background(), mStorage, []domain.Exchange{mExchange}, mLogger)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if saved.Buy.OrderId != "b2" || !saved.Buy.Price.Equal(decimal.NewFromInt(99)) {
+		t.Fatalf("unexpected repriced buy %+v", saved.Buy)
+	}
+}
+
+func TestLimitEntryRepriceKeepsFilledPart(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mLogger := mock_domain.NewMockLogger(ctrl)
+	mStorage := mock_domain.NewMockSimpleStorage(ctrl)
+	mExchange := mock_domain.NewMockExchange(ctrl)
+
+	pair := domain.Pair{BaseAsset: "BTC", QuoteAsset: "USD"}
+
+	mLogger.EXPECT().Info(gomock.Any()).AnyTimes()
+	mLogger.EXPECT().Debug(gomock.Any()).AnyTimes()
+
+	mExchange.EXPECT().Balances(gomock.Any()).Return([]domain.Balance{{Asset: "USD", Amount: decimal.NewFromInt(1000)}}, nil)
+	mExchange.EXPECT().GetOpenOrders(gomock.Any()).Return([]domain.Order{{Id: "b1", Status: domain.PendingOrderStatus, Side: domain.BuyOrderSide, Pair: pair}}, nil)
+	mStorage.EXPECT().GetTrades(gomock.Any()).Return([]domain.SimpleTrade{{
+		Id:     1,
+		Pair:   pair,
+		Status: domain.SimpleTradeStatusBuy,
+		Amount: decimal.NewFromInt(1),
+		Buy:    domain.SimpleTradeOrder{OrderId: "b1", Datetime: time.Now().Add(-time.Hour).Format(time.RFC3339), Price: decimal.NewFromInt(95)},
+	}}, nil)
+	mExchange.EXPECT().LastPrice(pair).Return(decimal.NewFromInt(100), nil).AnyTimes()
+	mExchange.EXPECT().GetOrderFee(gomock.Any(), gomock.Any(), gomock.Any()).Return(domain.Balance{Asset: "USD"}, nil).AnyTimes()
+
+	// the old buy was filled for 0.4 before the cancel
+	fill := domain.Order{Id: "b1", Status: domain.FillOrderStatus, Side: domain.BuyOrderSide, Pair: pair, Amount: decimal.NewFromFloat(0.4), Price: decimal.NewFromInt(95), Commission: domain.Balance{Asset: "BTC", Amount: decimal.NewFromFloat(0.001)}}
+	gomock.InOrder(
+		mExchange.EXPECT().GetHistoryOrders(gomock.Any()).Return([]domain.Order{}, nil),
+		mExchange.EXPECT().CancelOrder("b1", pair).Return(nil),
+		mExchange.EXPECT().GetHistoryOrders(gomock.Any()).Return([]domain.Order{fill}, nil),
+		mExchange.EXPECT().PlaceOrder(gomock.Any()).DoAndReturn(func(request domain.OrderRequest) (*domain.Order, error) {
+			if !request.Amount.Equal(decimal.NewFromFloat(0.6)) {
+				t.Errorf("expected the unfilled 0.6 placed again, got %+v", request)
+			}
+			return &domain.Order{Id: "b2", Status: domain.PendingOrderStatus, Side: domain.BuyOrderSide, Price: request.Price, Pair: pair}, nil
+		}),
+	)
+	saved := map[int]domain.SimpleTrade{}
+	mStorage.EXPECT().SaveTrade(gomock.Any()).DoAndReturn(func(trade *domain.SimpleTrade) error {
+		if trade.Id == 0 {
+			trade.Id = 2
+		}
+		saved[trade.Id] = *trade
+		return nil
+	}).AnyTimes()
+
+	simple := domain.SimpleStrategy{
+		Pairs:             []domain.SimplePair{{Pair: pair}},
+		BaseQuantity:      decimal.NewFromInt(1),
+		MaxTrades:         1,
+		ProfitPercent:     decimal.NewFromFloat(0.01),
+		FarPricePercent:   decimal.NewFromFloat(0.01),
+		EntryOrderType:    domain.LimitOrderType,
+		EntryLimitOffset:  decimal.NewFromFloat(0.01),
+		EntryLimitTimeout: 10,
+	}
+	err := simple.Run(context.Background(), mStorage, []domain.Exchange{mExchange}, mLogger)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	part := saved[2]
+	if part.Status != domain.SimpleTradeStatusSell || !part.Amount.Equal(fill.Amount) || part.Buy.OrderId != "b1" || part.Buy.Commission.Asset != "BTC" {
+		t.Fatalf("unexpected filled part %+v", part)
+	}
+
+	rest := saved[1]
+	if rest.Buy.OrderId != "b2" || !rest.Amount.Equal(decimal.NewFromFloat(0.6)) {
+		t.Fatalf("unexpected repriced trade %+v", rest)
+	}
+}
+
+func TestResumeBuyByClientId(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mLogger := mock_domain.NewMockLogger(ctrl)
+	mStorage := mock_domain.NewMockSimpleStorage(ctrl)
+	mExchange := mock_domain.NewMockExchange(ctrl)
+
+	pair := domain.Pair{BaseAsset: "BTC", QuoteAsset: "USD"}
+
+	mLogger.EXPECT().Info(gomock.Any()).AnyTimes()
+	mLogger.EXPECT().Debug(gomock.Any()).AnyTimes()
+
+	// the process stopped after the trade was saved, its buy has no order id
+	intent := domain.SimpleTrade{
+		Id:     1,
+		Pair:   pair,
+		Status: domain.SimpleTradeStatusBuy,
+		Amount: decimal.NewFromInt(1),
+		Buy:    domain.SimpleTradeOrder{Price: decimal.NewFromInt(100), ClientOrderId: "ia1"},
+	}
+
+	mExchange.EXPECT().Balances(gomock.Any()).Return([]domain.Balance{{Asset: "USD", Amount: decimal.NewFromInt(1000)}}, nil).Times(2)
+	mExchange.EXPECT().GetOpenOrders(gomock.Any()).Return([]domain.Order{}, nil).Times(2)
+	mStorage.EXPECT().GetTrades(gomock.Any()).DoAndReturn(func(filter *domain.SimpleTradeFilter) ([]domain.SimpleTrade, error) {
+		return []domain.SimpleTrade{intent}, nil
+	}).Times(2)
+	mExchange.EXPECT().GetHistoryOrders(gomock.Any()).Return([]domain.Order{}, nil).Times(2)
+	mExchange.EXPECT().LastPrice(pair).Return(decimal.NewFromInt(100), nil).AnyTimes()
+	mExchange.EXPECT().GetOrderFee(gomock.Any(), gomock.Any(), gomock.Any()).Return(domain.Balance{Asset: "USD"}, nil).AnyTimes()
+
+	var saved domain.SimpleTrade
+	mStorage.EXPECT().SaveTrade(gomock.Any()).DoAndReturn(func(trade *domain.SimpleTrade) error {
+		saved = *trade
+		return nil
+	}).AnyTimes()
+
+	simple := domain.SimpleStrategy{
+		Pairs:           []domain.SimplePair{{Pair: pair}},
+		BaseQuantity:    decimal.NewFromInt(1),
+		MaxTrades:       1,
+		ProfitPercent:   decimal.NewFromFloat(0.01),
+		FarPricePercent: decimal.NewFromFloat(0.01),
+	}
+
+	// the placed order is found and kept, no second buy
+	mExchange.EXPECT().GetOrderByClientId(pair, "ia1").Return(&domain.Order{
+		Id:            "b1",
+		Status:        domain.PendingOrderStatus,
+		Side:          domain.BuyOrderSide,
+		Price:         decimal.NewFromInt(100),
+		Pair:          pair,
+		ClientOrderId: "ia1",
+	}, nil)
+	err := simple.Run(context.Background(), mStorage, []domain.Exchange{mExchange}, mLogger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if saved.Buy.OrderId != "b1" {
+		t.Fatalf("expected found order to be kept, got %+v", saved.Buy)
+	}
+
+	// an order that was never placed is placed with the same client id
+	mExchange.EXPECT().GetOrderByClientId(pair, "ia1").Return(nil, nil)
+	mExchange.EXPECT().PlaceOrder(gomock.Any()).DoAndReturn(func(request domain.OrderRequest) (*domain.Order, error) {
+		if request.ClientOrderId != "ia1" {
+			t.Errorf("expected client order id ia1, got %q", request.ClientOrderId)
+		}
+		return &domain.Order{Id: "b2", Status: domain.PendingOrderStatus, Side: domain.BuyOrderSide, Price: decimal.NewFromInt(100), Pair: pair}, nil
+	})
+	err = simple.Run(context.Background(), mStorage, []domain.Exchange{mExchange}, mLogger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if saved.Buy.OrderId != "b2" {
+		t.Fatalf("expected placed order, got %+v", saved.Buy)
+	}
+}
+
+func TestBuyPlacementErrors(t *testing.T) {
+	pair := domain.Pair{BaseAsset: "BTC", QuoteAsset: "USD"}
+
+	cases := []struct {
+		name string
+		err  error
+		// status is the trade status after the error, a kept buy is looked
+		// up by its client order id next cycle
+		status domain.SimpleTradeStatus
+	}{
+		{"retryable", domain.ExchangeError{Kind: domain.RetryableExchangeError, Err: errors.New("error in call, timeout")}, domain.SimpleTradeStatusBuy},
+		{"unknown", errors.New("bad gateway"), domain.SimpleTradeStatusBuy},
+		{"insufficient funds", domain.NewExchangeError(domain.ErrInsufficientFunds, errors.New("no money")), domain.SimpleTradeStatusCanceled},
+		{"invalid order", domain.ExchangeError{Kind: domain.InvalidOrderExchangeError, Err: errors.New("bad quantity")}, domain.SimpleTradeStatusCanceled},
+		{"risk limit", domain.RiskLimitError{Limit: "max_exposure", Message: "too much"}, domain.SimpleTradeStatusCanceled},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mLogger := mock_domain.NewMockLogger(ctrl)
+			mStorage := mock_domain.NewMockSimpleStorage(ctrl)
+			mExchange := mock_domain.NewMockExchange(ctrl)
+
+			mLogger.EXPECT().Info(gomock.Any()).AnyTimes()
+			mLogger.EXPECT().Debug(gomock.Any()).AnyTimes()
+			mLogger.EXPECT().Warn(gomock.Any()).AnyTimes()
+
+			// an open sell far above the price lets the strategy buy
+			mExchange.EXPECT().Balances(gomock.Any()).Return([]domain.Balance{{Asset: "USD", Amount: decimal.NewFromInt(1000)}}, nil)
+			mExchange.EXPECT().GetOpenOrders(gomock.Any()).Return([]domain.Order{{Id: "s1", Status: domain.PendingOrderStatus, Pair: pair}}, nil)
+			mExchange.EXPECT().GetHistoryOrders(gomock.Any()).Return([]domain.Order{}, nil)
+			mStorage.EXPECT().GetTrades(gomock.Any()).Return([]domain.SimpleTrade{{
+				Id:     1,
+				Pair:   pair,
+				Status: domain.SimpleTradeStatusSell,
+				Amount: decimal.NewFromInt(1),
+				Buy:    domain.SimpleTradeOrder{Price: decimal.NewFromInt(150), Commission: domain.Balance{Asset: "USD"}},
+				Sell:   domain.SimpleTradeOrder{OrderId: "s1", Price: decimal.NewFromInt(160)},
+			}}, nil)
+			mExchange.EXPECT().LastPrice(pair).Return(decimal.NewFromInt(100), nil)
+			mExchange.EXPECT().GetOrderFee(gomock.Any(), gomock.Any(), gomock.Any()).Return(domain.Balance{Asset: "USD"}, nil).AnyTimes()
+			mExchange.EXPECT().GetPairFee(pair).Return(domain.Balance{Asset: "USD"}, nil).AnyTimes()
+
+			var placed domain.OrderRequest
+			mExchange.EXPECT().PlaceOrder(gomock.Any()).DoAndReturn(func(request domain.OrderRequest) (*domain.Order, error) {
+				placed = request
+				return nil, c.err
+			})
+			var saved domain.SimpleTrade
+			mStorage.EXPECT().SaveTrade(gomock.Any()).DoAndReturn(func(trade *domain.SimpleTrade) error {
+				trade.Id = 2
+				saved = *trade
+				return nil
+			}).MinTimes(1)
+
+			simple := domain.SimpleStrategy{
+				Pairs:           []domain.SimplePair{{Pair: pair}},
+				BaseQuantity:    decimal.NewFromInt(1),
+				MaxTrades:       2,
+				ProfitPercent:   decimal.NewFromFloat(0.01),
+				FarPricePercent: decimal.NewFromFloat(0.01),
+			}
+			_ = simple.Run(context.Background(), mStorage, []domain.Exchange{mExchange}, mLogger)
+
+			if saved.Status != c.status || saved.Buy.OrderId != "" || saved.Buy.ClientOrderId != placed.ClientOrderId {
+				t.Fatalf("trade %+v after placement with client order id %q", saved, placed.ClientOrderId)
+			}
+		})
+	}
+}
+
+func TestResumeBuyWithoutClientIds(t *testing.T) {
+	pair := domain.Pair{BaseAsset: "BTC", QuoteAsset: "USD"}
+	intentTime := time.Now().Add(-10 * time.Minute)
+	order := func(id string, amount int64, placed time.Time) domain.Order {
+		return domain.Order{Id: id, Status: domain.PendingOrderStatus, Side: domain.BuyOrderSide, Pair: pair, Price: decimal.NewFromInt(100), Amount: decimal.NewFromInt(amount), Time: placed}
+	}
+	fill := func(id string, amount float64, placed time.Time) domain.Order {
+		return domain.Order{Id: id, Status: domain.FillOrderStatus, Side: domain.BuyOrderSide, Pair: pair, Price: decimal.NewFromInt(100), Amount: decimal.NewFromFloat(amount), Time: placed, Commission: domain.Balance{Asset: "USD"}}
+	}
+
+	cases := []struct {
+		name    string
+		open    []domain.Order
+		history []domain.Order
+		// orderId is the adopted order, empty when the trade is left as it is
+		orderId string
+		placed  bool
+	}{
+		{
+			name:    "open order of the intent",
+			open:    []domain.Order{order("b0", 1, intentTime.Add(-time.Hour)), order("b9", 2, intentTime.Add(time.Second)), order("b1", 1, intentTime.Add(time.Second))},
+			orderId: "b1",
+		},
+		{
+			name:    "filled in parts",
+			history: []domain.Order{fill("b1", 0.4, intentTime.Add(time.Second)), fill("b1", 0.6, intentTime.Add(2*time.Second)), fill("b5", 1, intentTime.Add(-time.Hour))},
+			orderId: "b1",
+		},
+		{
+			name:    "ambiguous orders",
+			open:    []domain.Order{order("b1", 1, intentTime.Add(time.Second)), order("b2", 1, intentTime.Add(time.Minute))},
+			orderId: "",
+		},
+		{
+			name:    "never placed",
+			history: []domain.Order{fill("b5", 1, intentTime.Add(-time.Hour))},
+			orderId: "b3",
+			placed:  true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mLogger := mock_domain.NewMockLogger(ctrl)
+			mStorage := mock_domain.NewMockSimpleStorage(ctrl)
+			mExchange := mock_domain.NewMockExchange(ctrl)
+
+			mLogger.EXPECT().Info(gomock.Any()).AnyTimes()
+			mLogger.EXPECT().Debug(gomock.Any()).AnyTimes()
+			mLogger.EXPECT().Error(gomock.Any()).AnyTimes()
+
+			intent := domain.SimpleTrade{
+				Id:     1,
+				Pair:   pair,
+				Status: domain.SimpleTradeStatusBuy,
+				Amount: decimal.NewFromInt(1),
+				Buy:    domain.SimpleTradeOrder{Price: decimal.NewFromInt(100), ClientOrderId: "ia1", Datetime: intentTime.Format(time.RFC3339)},
+			}
+
+			mExchange.EXPECT().Balances(gomock.Any()).Return([]domain.Balance{{Asset: "USD", Amount: decimal.NewFromInt(1000)}}, nil)
+			mExchange.EXPECT().GetOpenOrders(gomock.Any()).Return(c.open, nil).AnyTimes()
+			mExchange.EXPECT().GetHistoryOrders(gomock.Any()).Return(c.history, nil).AnyTimes()
+			mStorage.EXPECT().GetTrades(gomock.Any()).Return([]domain.SimpleTrade{intent}, nil)
+			mExchange.EXPECT().GetOrderByClientId(pair, "ia1").Return(nil, fmt.Errorf("currency.com: client id(ia1): %w", domain.ErrClientOrderIdUnsupported))
+			mExchange.EXPECT().LastPrice(pair).Return(decimal.NewFromInt(100), nil).AnyTimes()
+			mExchange.EXPECT().GetOrderFee(gomock.Any(), gomock.Any(), gomock.Any()).Return(domain.Balance{Asset: "USD"}, nil).AnyTimes()
+			mExchange.EXPECT().GetPairFee(pair).Return(domain.Balance{Asset: "USD"}, nil).AnyTimes()
+			placed := false
+			mExchange.EXPECT().PlaceOrder(gomock.Any()).DoAndReturn(func(request domain.OrderRequest) (*domain.Order, error) {
+				if request.Side == domain.SellOrderSide {
+					return &domain.Order{Id: "s1", Status: domain.PendingOrderStatus, Side: request.Side, Price: request.Price, Pair: pair}, nil
+				}
+
+				placed = true
+				return &domain.Order{Id: "b3", Status: domain.PendingOrderStatus, Side: request.Side, Price: decimal.NewFromInt(100), Pair: pair}, nil
+			}).AnyTimes()
+
+			saved := intent
+			mStorage.EXPECT().SaveTrade(gomock.Any()).DoAndReturn(func(trade *domain.SimpleTrade) error {
+				saved = *trade
+				return nil
+			}).AnyTimes()
+
+			simple := domain.SimpleStrategy{
+				Pairs:           []domain.SimplePair{{Pair: pair}},
+				BaseQuantity:    decimal.NewFromInt(1),
+				MaxTrades:       1,
+				ProfitPercent:   decimal.NewFromFloat(0.01),
+				FarPricePercent: decimal.NewFromFloat(0.01),
+			}
+			err := simple.Run(context.Background(), mStorage, []domain.Exchange{mExchange}, mLogger)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if saved.Buy.OrderId != c.orderId || placed != c.placed {
+				t.Fatalf("buy order %q (placed %t), want %q", saved.Buy.OrderId, placed, c.orderId)
+			}
+		})
+	}
+}
+
+func TestSellRepriceOfOrderNotOpen(t *testing.T) {
+	pair := domain.Pair{BaseAsset: "BTC", QuoteAsset: "USD"}
+
+	cases := []struct {
+		name  string
+		fills []domain.Order
+		// orderId is the sell order the trade keeps
+		orderId string
+	}{
+		{name: "filled meanwhile", fills: []domain.Order{{Id: "s1", Status: domain.FillOrderStatus, Side: domain.SellOrderSide, Pair: pair, Amount: decimal.NewFromFloat(0.3)}}, orderId: "s1"},
+		{name: "gone without fills", fills: []domain.Order{}, orderId: ""},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mLogger := mock_domain.NewMockLogger(ctrl)
+			mStorage := mock_domain.NewMockSimpleStorage(ctrl)
+			mExchange := mock_domain.NewMockExchange(ctrl)
+
+			mLogger.EXPECT().Info(gomock.Any()).AnyTimes()
+			mLogger.EXPECT().Debug(gomock.Any()).AnyTimes()
+			mLogger.EXPECT().Warn(gomock.Any()).AnyTimes()
+
+			trade := domain.SimpleTrade{
+				Id:     1,
+				Pair:   pair,
+				Status: domain.SimpleTradeStatusSell,
+				Amount: decimal.NewFromInt(1),
+				Buy:    domain.SimpleTradeOrder{OrderId: "b1", Price: decimal.NewFromInt(100), Commission: domain.Balance{Asset: "USD"}},
+				Sell:   domain.SimpleTradeOrder{OrderId: "s1", Price: decimal.NewFromInt(50)},
+			}
+
+			mExchange.EXPECT().Balances(gomock.Any()).Return([]domain.Balance{{Asset: "USD", Amount: decimal.NewFromInt(1000)}}, nil)
+			mExchange.EXPECT().GetOpenOrders(gomock.Any()).Return([]domain.Order{}, nil)
+			mStorage.EXPECT().GetTrades(gomock.Any()).Return([]domain.SimpleTrade{trade}, nil)
+			mExchange.EXPECT().LastPrice(pair).Return(decimal.NewFromInt(100), nil)
+			mExchange.EXPECT().GetOrderFee(gomock.Any(), gomock.Any(), gomock.Any()).Return(domain.Balance{Asset: "USD"}, nil).AnyTimes()
+			mExchange.EXPECT().GetPairFee(pair).Return(domain.Balance{Asset: "USD"}, nil).AnyTimes()
+
+			// the sell fills between the history check and the cancel
+			gomock.InOrder(
+				mExchange.EXPECT().GetHistoryOrders(gomock.Any()).Return([]domain.Order{}, nil),
+				mExchange.EXPECT().CancelOrder("s1", pair).Return(domain.NewExchangeError(domain.ErrOrderNotFound, errors.New("unknown order"))),
+				mExchange.EXPECT().GetHistoryOrders(gomock.Any()).Return(c.fills, nil),
+			)
+
+			saved := trade
+			mStorage.EXPECT().SaveTrade(gomock.Any()).DoAndReturn(func(trade *domain.SimpleTrade) error {
+				saved = *trade
+				return nil
+			}).AnyTimes()
+
+			simple := domain.SimpleStrategy{
+				Pairs:           []domain.SimplePair{{Pair: pair}},
+				BaseQuantity:    decimal.NewFromInt(1),
+				MaxTrades:       1,
+				ProfitPercent:   decimal.NewFromFloat(0.01),
+				FarPricePercent: decimal.NewFromFloat(0.01),
+			}
+			err := simple.Run(context.Background(), mStorage, []domain.Exchange{mExchange}, mLogger)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if saved.Sell.OrderId != c.orderId {
+				t.Fatalf("sell order %q, want %q", saved.Sell.OrderId, c.orderId)
+			}
+		})
+	}
 }
@@ -0,0 +1,55 @@
+package test_domain
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/scientistnik/invest-agents/internal/app/domain"
+	mock_domain "github.com/scientistnik/invest-agents/internal/app/domain/tests/mocks"
+	"github.com/shopspring/decimal"
+)
+
+type streamingExchange struct {
+	*mock_domain.MockExchange
+	*mock_domain.MockStreamExchange
+}
+
+func TestStreamFillWakesAgent(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	pair := domain.Pair{BaseAsset: "BTC", QuoteAsset: "USD"}
+	agent := simpleAgent(1)
+	exchange := streamingExchange{mock_domain.NewMockExchange(ctrl), mock_domain.NewMockStreamExchange(ctrl)}
+	run := newAgentRun(ctrl, agent, exchange)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	events := make(chan domain.StreamEvent, 1)
+	events <- domain.StreamEvent{Type: domain.OrderStreamEvent, Pair: pair, Order: domain.Order{Id: "1", Status: domain.FillOrderStatus, Pair: pair}}
+
+	exchange.MockExchange.EXPECT().Name().Return("test").AnyTimes()
+	exchange.MockStreamExchange.EXPECT().Stream(gomock.Any(), []domain.Pair{pair}).Return((<-chan domain.StreamEvent)(events), nil)
+
+	// the second cycle starts long before the interval because of the fill
+	cycles := 0
+	exchange.MockExchange.EXPECT().Balances(gomock.Any()).DoAndReturn(func(assets []string) ([]domain.Balance, error) {
+		cycles++
+		if cycles == 2 {
+			cancel()
+		}
+		return []domain.Balance{{Asset: "USD", Amount: decimal.NewFromInt(1000)}}, nil
+	}).Times(2)
+	exchange.MockExchange.EXPECT().GetOpenOrders(gomock.Any()).Return([]domain.Order{}, nil).AnyTimes()
+	exchange.MockExchange.EXPECT().LastPrice(pair).Return(decimal.NewFromInt(100), nil).AnyTimes()
+	exchange.MockExchange.EXPECT().GetOrderFee(gomock.Any(), gomock.Any(), gomock.Any()).Return(domain.Balance{}, nil).AnyTimes()
+	run.Storage.EXPECT().GetTrades(gomock.Any()).Return([]domain.SimpleTrade{}, nil).AnyTimes()
+
+	err := domain.StartAgents(ctx, run.Repos, domain.AgentsSettings{Interval: time.Hour, ShutdownTimeout: time.Second})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,80 @@
+package test_domain
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/scientistnik/invest-agents/internal/app/domain"
+	mock_domain "github.com/scientistnik/invest-agents/internal/app/domain/tests/mocks"
+	"github.com/shopspring/decimal"
+)
+
+func TestExchangeLimiterRetries(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mExchange := mock_domain.NewMockExchange(ctrl)
+	pair := domain.Pair{BaseAsset: "BTC", QuoteAsset: "USD"}
+	retryable := domain.ExchangeError{Kind: domain.RetryableExchangeError, Err: errors.New("503")}
+
+	limiter := domain.NewExchangeLimiter(domain.ExchangeLimits{Retries: 2, RetryDelay: time.Millisecond})
+	exchange := limiter.Wrap(mExchange, "account")
+
+	// reads are retried
+	gomock.InOrder(
+		mExchange.EXPECT().Balances(nil).Return(nil, retryable).Times(2),
+		mExchange.EXPECT().Balances(nil).Return([]domain.Balance{{Asset: "USD", Amount: decimal.NewFromInt(1)}}, nil),
+	)
+	balances, err := exchange.Balances(nil)
+	if err != nil || len(balances) != 1 {
+		t.Fatalf("expected balances after retries, got %v, %v", balances, err)
+	}
+
+	// up to the limit of retries
+	mExchange.EXPECT().LastPrice(pair).Return(decimal.Decimal{}, retryable).Times(3)
+	_, err = exchange.LastPrice(pair)
+	if domain.ExchangeErrorKindOf(err) != domain.RetryableExchangeError {
+		t.Fatalf("expected retryable error, got %v", err)
+	}
+
+	// orders and other kinds of errors are not
+	mExchange.EXPECT().PlaceOrder(gomock.Any()).Return(nil, retryable)
+	_, err = exchange.PlaceOrder(domain.OrderRequest{Pair: pair, Side: domain.BuyOrderSide, Type: domain.MarketOrderType})
+	if err == nil {
+		t.Fatal("expected order error")
+	}
+
+	mExchange.EXPECT().GetOpenOrders(nil).Return(nil, domain.ExchangeError{Kind: domain.AuthExchangeError, Err: errors.New("401")})
+	_, err = exchange.GetOpenOrders(nil)
+	if domain.ExchangeErrorKindOf(err) != domain.AuthExchangeError {
+		t.Fatalf("expected auth error, got %v", err)
+	}
+}
+
+func TestExchangeLimiterSharesAccounts(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mExchange := mock_domain.NewMockExchange(ctrl)
+	pair := domain.Pair{BaseAsset: "BTC", QuoteAsset: "USD"}
+	mExchange.EXPECT().LastPrice(pair).Return(decimal.NewFromInt(1), nil).Times(3)
+
+	limiter := domain.NewExchangeLimiter(domain.ExchangeLimits{Rate: 20, Burst: 1})
+	first := limiter.Wrap(mExchange, "account")
+	second := limiter.Wrap(mExchange, "account")
+
+	started := time.Now()
+	for _, exchange := range []domain.Exchange{first, second, first} {
+		_, err := exchange.LastPrice(pair)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// one token at once and 20 per second, two calls wait 50ms each
+	if elapsed := time.Since(started); elapsed < 90*time.Millisecond {
+		t.Fatalf("calls of one account were not limited together, took %s", elapsed)
+	}
+}
@@ -0,0 +1,221 @@
+package domain
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// ExchangeLimits configure ExchangeLimiter. Rate is requests per second of
+// one exchange account, 0 turns rate limiting off. Retried calls wait
+// RetryDelay before the first retry and twice as long before each next one.
+type ExchangeLimits struct {
+	Rate       float64
+	Burst      int
+	Retries    int
+	RetryDelay time.Duration
+}
+
+var DefaultExchangeLimits = ExchangeLimits{Rate: 5, Burst: 10, Retries: 3, RetryDelay: 500 * time.Millisecond}
+
+// ExchangeLimiter shares rate limits of exchange accounts between all
+// exchanges it wraps, so agents with the same credentials don't exceed them
+// together.
+type ExchangeLimiter struct {
+	limits ExchangeLimits
+	sleep  func(time.Duration)
+
+	mu      sync.Mutex
+	buckets map[string]*tokenBucket
+}
+
+func NewExchangeLimiter(limits ExchangeLimits) *ExchangeLimiter {
+	return &ExchangeLimiter{limits: limits, sleep: time.Sleep, buckets: map[string]*tokenBucket{}}
+}
+
+// Wrap limits the calls of the exchange by the bucket of account and retries
+// read calls failed with a RetryableExchangeError. Orders are never retried,
+// a lost answer doesn't tell whether the order was placed.
+func (l *ExchangeLimiter) Wrap(exchange Exchange, account string) Exchange {
+	l.mu.Lock()
+	bucket, ok := l.buckets[account]
+	if !ok {
+		bucket = &tokenBucket{rate: l.limits.Rate, burst: float64(l.limits.Burst), tokens: float64(l.limits.Burst), now: time.Now}
+		l.buckets[account] = bucket
+	}
+	l.mu.Unlock()
+
+	limited := &limitedExchange{exchange: exchange, limiter: l, bucket: bucket}
+	if stream, ok := exchange.(StreamExchange); ok {
+		return &limitedStreamExchange{limitedExchange: limited, stream: stream}
+	}
+
+	return limited
+}
+
+type tokenBucket struct {
+	rate  float64
+	burst float64
+	now   func() time.Time
+
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+// reserve takes a token and returns how long to wait for it. Tokens may go
+// below zero, so waiting callers are served in order.
+func (b *tokenBucket) reserve() time.Duration {
+	if b.rate <= 0 {
+		return 0
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := b.now()
+	if !b.last.IsZero() {
+		b.tokens += now.Sub(b.last).Seconds() * b.rate
+		if b.tokens > b.burst {
+			b.tokens = b.burst
+		}
+	}
+	b.last = now
+
+	b.tokens--
+	if b.tokens >= 0 {
+		return 0
+	}
+
+	return time.Duration(-b.tokens / b.rate * float64(time.Second))
+}
+
+type limitedExchange struct {
+	exchange Exchange
+	limiter  *ExchangeLimiter
+	bucket   *tokenBucket
+}
+
+var _ Exchange = (*limitedExchange)(nil)
+
+// call runs fn after a token is taken, retry is set for idempotent calls.
+func (e *limitedExchange) call(retry bool, fn func() error) error {
+	delay := e.limiter.limits.RetryDelay
+	for attempt := 0; ; attempt++ {
+		if wait := e.bucket.reserve(); wait > 0 {
+			e.limiter.sleep(wait)
+		}
+
+		err := fn()
+		if err == nil || !retry || attempt >= e.limiter.limits.Retries || ExchangeErrorKindOf(err) != RetryableExchangeError {
+			return err
+		}
+
+		e.limiter.sleep(delay)
+		delay *= 2
+	}
+}
+
+func (e *limitedExchange) Name() string {
+	return e.exchange.Name()
+}
+
+func (e *limitedExchange) Balances(assets []string) (balances []Balance, err error) {
+	err = e.call(true, func() error {
+		balances, err = e.exchange.Balances(assets)
+		return err
+	})
+	return balances, err
+}
+
+func (e *limitedExchange) GetOpenOrders(filter *OrderFilter) (orders []Order, err error) {
+	err = e.call(true, func() error {
+		orders, err = e.exchange.GetOpenOrders(filter)
+		return err
+	})
+	return orders, err
+}
+
+func (e *limitedExchange) GetHistoryOrders(pairs []Pair) (orders []Order, err error) {
+	err = e.call(true, func() error {
+		orders, err = e.exchange.GetHistoryOrders(pairs)
+		return err
+	})
+	return orders, err
+}
+
+func (e *limitedExchange) LastPrice(pair Pair) (price decimal.Decimal, err error) {
+	err = e.call(true, func() error {
+		price, err = e.exchange.LastPrice(pair)
+		return err
+	})
+	return price, err
+}
+
+func (e *limitedExchange) Buy(pair Pair, amount decimal.Decimal) (order *Order, err error) {
+	err = e.call(false, func() error {
+		order, err = e.exchange.Buy(pair, amount)
+		return err
+	})
+	return order, err
+}
+
+func (e *limitedExchange) Sell(pair Pair, amount decimal.Decimal, price decimal.Decimal) (order *Order, err error) {
+	err = e.call(false, func() error {
+		order, err = e.exchange.Sell(pair, amount, price)
+		return err
+	})
+	return order, err
+}
+
+func (e *limitedExchange) PlaceOrder(request OrderRequest) (order *Order, err error) {
+	err = e.call(false, func() error {
+		order, err = e.exchange.PlaceOrder(request)
+		return err
+	})
+	return order, err
+}
+
+func (e *limitedExchange) GetOrderByClientId(pair Pair, clientOrderId string) (order *Order, err error) {
+	err = e.call(true, func() error {
+		order, err = e.exchange.GetOrderByClientId(pair, clientOrderId)
+		return err
+	})
+	return order, err
+}
+
+func (e *limitedExchange) CancelOrder(orderId string, pair Pair) error {
+	return e.call(false, func() error {
+		return e.exchange.CancelOrder(orderId, pair)
+	})
+}
+
+// fees are calculated by adapters without requests
+func (e *limitedExchange) GetOrderFee(pair Pair, amount decimal.Decimal, price decimal.Decimal) (Balance, error) {
+	return e.exchange.GetOrderFee(pair, amount, price)
+}
+
+func (e *limitedExchange) GetPairFee(pair Pair) (Balance, error) {
+	return e.exchange.GetPairFee(pair)
+}
+
+func (e *limitedExchange) GetCandles(pair Pair, interval CandleInterval, since time.Time) (candles []Candle, err error) {
+	err = e.call(true, func() error {
+		candles, err = e.exchange.GetCandles(pair, interval, since)
+		return err
+	})
+	return candles, err
+}
+
+// limitedStreamExchange keeps streams of wrapped exchanges visible, a stream
+// is one connection and isn't limited.
+type limitedStreamExchange struct {
+	*limitedExchange
+	stream StreamExchange
+}
+
+func (e *limitedStreamExchange) Stream(ctx context.Context, pairs []Pair) (<-chan StreamEvent, error) {
+	return e.stream.Stream(ctx, pairs)
+}
@@ -1,6 +1,12 @@
 package domain
 
-import "github.com/shopspring/decimal"
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
 
 type Balance struct {
 	Asset  string          `json:"asset"`
@@ -11,3 +17,81 @@ type Pair struct {
 	BaseAsset  string `json:"base_asset"`
 	QuoteAsset string `json:"quote_asset"`
 }
+
+func (p Pair) String() string {
+	return p.BaseAsset + "/" + p.QuoteAsset
+}
+
+type LogLevel int
+
+const (
+	_                      = iota
+	DebugLogLevel LogLevel = iota
+	InfoLogLevel  LogLevel = iota
+	WarnLogLevel  LogLevel = iota
+	ErrorLogLevel LogLevel = iota
+)
+
+func (l LogLevel) String() string {
+	switch l {
+	case DebugLogLevel:
+		return "debug"
+	case InfoLogLevel:
+		return "info"
+	case WarnLogLevel:
+		return "warn"
+	case ErrorLogLevel:
+		return "error"
+	}
+
+	return "unknown"
+}
+
+func ParseLogLevel(level string) (LogLevel, error) {
+	switch strings.ToLower(level) {
+	case "debug", "deb":
+		return DebugLogLevel, nil
+	case "info", "inf":
+		return InfoLogLevel, nil
+	case "warn", "warning", "wrn":
+		return WarnLogLevel, nil
+	case "error", "err":
+		return ErrorLogLevel, nil
+	}
+
+	return 0, fmt.Errorf("unknown log level %q", level)
+}
+
+type CandleInterval string
+
+const (
+	MinuteCandleInterval         CandleInterval = "1m"
+	FiveMinutesCandleInterval    CandleInterval = "5m"
+	FifteenMinutesCandleInterval CandleInterval = "15m"
+	HalfHourCandleInterval       CandleInterval = "30m"
+	HourCandleInterval           CandleInterval = "1h"
+	FourHoursCandleInterval      CandleInterval = "4h"
+	DayCandleInterval            CandleInterval = "1d"
+	WeekCandleInterval           CandleInterval = "1w"
+)
+
+var CandleIntervals = map[CandleInterval]time.Duration{
+	MinuteCandleInterval:         time.Minute,
+	FiveMinutesCandleInterval:    5 * time.Minute,
+	FifteenMinutesCandleInterval: 15 * time.Minute,
+	HalfHourCandleInterval:       30 * time.Minute,
+	HourCandleInterval:           time.Hour,
+	FourHoursCandleInterval:      4 * time.Hour,
+	DayCandleInterval:            24 * time.Hour,
+	WeekCandleInterval:           7 * 24 * time.Hour,
+}
+
+// Candle is one OHLCV bar, the last candle of a series may be still open.
+type Candle struct {
+	OpenTime time.Time
+	Open     decimal.Decimal
+	High     decimal.Decimal
+	Low      decimal.Decimal
+	Close    decimal.Decimal
+	Volume   decimal.Decimal
+}
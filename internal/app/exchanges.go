@@ -0,0 +1,266 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/scientistnik/invest-agents/internal/app/domain"
+)
+
+// exchangeNameLength is the size of the exchanges.name column.
+const exchangeNameLength = 16
+
+type ExchangeFilter struct {
+	UserId         int64
+	ExchangeNumber int
+}
+
+// ExchangeAccount describes an exchange account without its secrets.
+type ExchangeAccount struct {
+	Id     int
+	UserId int64
+	Name   string
+	Number int
+	// Exchange is the name of the exchange, empty for an unknown number.
+	Exchange string
+	// MaskedData is the account data with the keys hidden, nil when the data
+	// can't be read.
+	MaskedData []byte
+	// AgentIds are agents trading on the account.
+	AgentIds []int64
+}
+
+// ListExchanges returns exchange accounts of the user, admins get accounts of
+// everyone.
+func (a Actions) ListExchanges(user domain.User) ([]ExchangeAccount, error) {
+	filter := ExchangeFilter{UserId: user.Id}
+	if user.Role == domain.AdminUserRole {
+		filter = ExchangeFilter{}
+	}
+
+	exchanges, err := a.storage.FindExchanges(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	result := []ExchangeAccount{}
+	for _, exch := range exchanges {
+		account, err := a.exchangeAccount(exch)
+		if err != nil {
+			return nil, err
+		}
+
+		result = append(result, *account)
+	}
+
+	return result, nil
+}
+
+func (a Actions) exchangeAccount(exch ExchangeData) (*ExchangeAccount, error) {
+	agents, err := a.storage.FindAgents(AgentFilter{ExchangeId: exch.Id})
+	if err != nil {
+		return nil, err
+	}
+
+	agentIds := []int64{}
+	for _, agent := range agents {
+		agentIds = append(agentIds, agent.Id)
+	}
+
+	return &ExchangeAccount{
+		Id:         exch.Id,
+		UserId:     exch.UserId,
+		Name:       exch.Name,
+		Number:     exch.Number,
+		Exchange:   a.exchange.ExchangeName(exch.Number),
+		MaskedData: a.exchange.MaskExchangeJson(exch.Number, exch.Data),
+		AgentIds:   agentIds,
+	}, nil
+}
+
+// AddExchange adds an exchange account after the exchange accepted its keys.
+// Without a name the account is named after the exchange.
+func (a Actions) AddExchange(user domain.User, name string, exchangeNumber int, data []byte) (*ExchangeData, error) {
+	if user.Role < domain.TraderUserRole {
+		return nil, ErrForbidden
+	}
+
+	exchange := a.exchange.GetExchangeByJson(exchangeNumber, data)
+	if exchange == nil {
+		return nil, ValidationError{Message: fmt.Sprintf("bad data for exchange number %d", exchangeNumber)}
+	}
+
+	name = strings.TrimSpace(name)
+	if name == "" {
+		name = exchange.Name()
+	}
+
+	err := a.checkExchangeName(user.Id, 0, name)
+	if err != nil {
+		return nil, err
+	}
+
+	err = checkExchangeKeys(exchange)
+	if err != nil {
+		return nil, err
+	}
+
+	return a.storage.AddExchange(user.Id, name, exchangeNumber, data)
+}
+
+// checkExchangeName returns a ValidationError when the name is too long or
+// another account of the user has it.
+func (a Actions) checkExchangeName(userId int64, exchangeId int, name string) error {
+	if name == "" || utf8.RuneCountInString(name) > exchangeNameLength {
+		return ValidationError{Message: fmt.Sprintf("exchange account name must have 1 to %d characters", exchangeNameLength)}
+	}
+
+	exchanges, err := a.storage.FindExchanges(ExchangeFilter{UserId: userId})
+	if err != nil {
+		return err
+	}
+
+	for _, exch := range exchanges {
+		if exch.Name == name && exch.Id != exchangeId {
+			return ValidationError{Message: fmt.Sprintf("exchange account %q already exists", name)}
+		}
+	}
+
+	return nil
+}
+
+// checkExchangeKeys asks the exchange for balances, so keys it refuses never
+// reach agents.
+func checkExchangeKeys(exchange domain.Exchange) error {
+	_, err := exchange.Balances(nil)
+	if errors.Is(err, domain.ErrAuth) {
+		return ValidationError{Message: "the exchange refused the keys: " + err.Error()}
+	}
+	if err != nil {
+		return fmt.Errorf("check keys: %w", err)
+	}
+
+	return nil
+}
+
+func (a Actions) GetUserExchanges(user domain.User, exchangeIds []int) ([]ExchangeData, error) {
+	exchanges, err := a.storage.FindExchanges(ExchangeFilter{UserId: user.Id})
+	if err != nil {
+		return nil, err
+	}
+
+	result := []ExchangeData{}
+	for _, id := range exchangeIds {
+		found := false
+		for _, exchange := range exchanges {
+			if exchange.Id == id {
+				result = append(result, exchange)
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return nil, fmt.Errorf("exchange(id=%d): %w", id, ErrExchangeNotFound)
+		}
+	}
+
+	return result, nil
+}
+
+// getOwnExchange returns the exchange account when the user owns it and may
+// trade.
+func (a Actions) getOwnExchange(user domain.User, exchangeId int) (*ExchangeData, error) {
+	if user.Role < domain.TraderUserRole {
+		return nil, ErrForbidden
+	}
+
+	exchanges, err := a.GetUserExchanges(user, []int{exchangeId})
+	if err != nil {
+		return nil, err
+	}
+
+	return &exchanges[0], nil
+}
+
+func (a Actions) RenameExchange(user domain.User, exchangeId int, name string) (*ExchangeAccount, error) {
+	exch, err := a.getOwnExchange(user, exchangeId)
+	if err != nil {
+		return nil, err
+	}
+
+	name = strings.TrimSpace(name)
+	err = a.checkExchangeName(user.Id, exch.Id, name)
+	if err != nil {
+		return nil, err
+	}
+
+	err = a.storage.ExchangeSetName(exch.Id, name)
+	if err != nil {
+		return nil, err
+	}
+
+	exch.Name = name
+	return a.exchangeAccount(*exch)
+}
+
+// RotateExchangeKeys replaces the keys of the account after the exchange
+// accepted them. Agents read the keys from the account, so all of them switch
+// at once, running agents reload the account on their next cycle.
+func (a Actions) RotateExchangeKeys(user domain.User, exchangeId int, data []byte) (*ExchangeAccount, error) {
+	exch, err := a.getOwnExchange(user, exchangeId)
+	if err != nil {
+		return nil, err
+	}
+
+	exchange := a.exchange.GetExchangeByJson(exch.Number, data)
+	if exchange == nil {
+		return nil, ValidationError{Message: fmt.Sprintf("bad data for exchange number %d", exch.Number)}
+	}
+
+	err = checkExchangeKeys(exchange)
+	if err != nil {
+		return nil, err
+	}
+
+	err = a.storage.ExchangeSetData(exch.Id, data)
+	if err != nil {
+		return nil, err
+	}
+
+	exch.Data = data
+	return a.exchangeAccount(*exch)
+}
+
+// RemoveExchange removes the account unless active or paused agents trade on
+// it. Disabled agents lose the account and can't be activated again.
+func (a Actions) RemoveExchange(user domain.User, exchangeId int) error {
+	exch, err := a.getOwnExchange(user, exchangeId)
+	if err != nil {
+		return err
+	}
+
+	agents, err := a.storage.FindAgents(AgentFilter{ExchangeId: exch.Id})
+	if err != nil {
+		return err
+	}
+
+	running := []string{}
+	for _, agent := range agents {
+		if agent.Status == domain.ActiveAgentStatus || agent.Status == domain.PausedAgentStatus {
+			running = append(running, fmt.Sprint(agent.Id))
+		}
+	}
+
+	if len(running) > 0 {
+		return ValidationError{Message: fmt.Sprintf(
+			"exchange(id=%d) is used by active agents %s, disable them first",
+			exch.Id,
+			strings.Join(running, ", "),
+		)}
+	}
+
+	return a.storage.RemoveExchange(exch.Id)
+}
@@ -0,0 +1,369 @@
+package app
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/scientistnik/invest-agents/internal/app/domain"
+	"github.com/shopspring/decimal"
+)
+
+// panicSellPrice is the share of the last price used for panic sells, the
+// exchange port has only limit sells and this one should fill at once.
+var panicSellPrice = decimal.NewFromFloat(0.99)
+
+type PanicOptions struct {
+	// Global stops agents of every user, admins only.
+	Global        bool
+	SellPositions bool
+}
+
+type PanicReport struct {
+	HaltedAgents   []int64          `json:"halted_agents"`
+	StillRunning   []int64          `json:"still_running"`
+	CanceledOrders int              `json:"canceled_orders"`
+	Sold           []domain.Balance `json:"sold"`
+	// SoldValue is the sold positions valued in the quote asset of the user.
+	SoldValue *domain.Balance `json:"sold_value,omitempty"`
+	Errors    []string        `json:"errors"`
+}
+
+type PanicEvent struct {
+	Id       int64
+	UserId   int64
+	Datetime time.Time
+	Global   bool
+	Report   PanicReport
+}
+
+func (r *PanicReport) fail(format string, args ...interface{}) {
+	r.Errors = append(r.Errors, fmt.Sprintf(format, args...))
+}
+
+func (r PanicReport) Summary() string {
+	summary := fmt.Sprintf(
+		"halted agents: %d, still running: %d, canceled orders: %d, sold positions: %d, errors: %d",
+		len(r.HaltedAgents),
+		len(r.StillRunning),
+		r.CanceledOrders,
+		len(r.Sold),
+		len(r.Errors),
+	)
+
+	if r.SoldValue != nil {
+		summary += fmt.Sprintf(", sold for about %s %s", r.SoldValue.Amount.StringFixed(2), r.SoldValue.Asset)
+	}
+
+	return summary
+}
+
+// Panic disables agents of the user, stops the running ones, cancels open
+// orders on the exchanges of the user, releases open trades of the agents from
+// the canceled orders and optionally sells the positions of the trades.
+// Failures of single steps are collected in the report and don't
+// stop the rest. The event is recorded even when it partly failed.
+func (a Actions) Panic(user domain.User, options PanicOptions) (*PanicReport, error) {
+	if user.Role < domain.TraderUserRole || options.Global && user.Role != domain.AdminUserRole {
+		return nil, ErrForbidden
+	}
+
+	agentFilter := AgentFilter{UserId: user.Id}
+	exchangeFilter := ExchangeFilter{UserId: user.Id}
+	if options.Global {
+		agentFilter = AgentFilter{}
+		exchangeFilter = ExchangeFilter{}
+	}
+
+	agents, err := a.storage.FindAgents(agentFilter)
+	if err != nil {
+		return nil, err
+	}
+
+	report := PanicReport{HaltedAgents: []int64{}, Sold: []domain.Balance{}, Errors: []string{}}
+	for i := range agents {
+		if agents[i].Status != domain.ActiveAgentStatus && agents[i].Status != domain.PausedAgentStatus {
+			continue
+		}
+
+		err = a.storage.AgentSetStatus(&agents[i], domain.DisableAgentStatus)
+		if err != nil {
+			report.fail("agent(id=%d): %s", agents[i].Id, err)
+			continue
+		}
+
+		report.HaltedAgents = append(report.HaltedAgents, agents[i].Id)
+	}
+
+	if a.repos.Control != nil {
+		report.StillRunning = a.repos.Control.Halt(report.HaltedAgents, a.settings.ShutdownTimeout)
+	}
+
+	exchanges, err := a.storage.FindExchanges(exchangeFilter)
+	if err != nil {
+		report.fail("find exchanges: %s", err)
+	}
+
+	canceled := map[string]bool{}
+	for _, exch := range exchanges {
+		exchange := a.exchange.GetExchangeByJson(exch.Number, exch.Data)
+		if exchange == nil {
+			report.fail("exchange(id=%d): bad data", exch.Id)
+			continue
+		}
+
+		orders, err := exchange.GetOpenOrders(nil)
+		if err != nil {
+			report.fail("exchange(id=%d): open orders: %s", exch.Id, err)
+			continue
+		}
+
+		for _, order := range orders {
+			err = exchange.CancelOrder(order.Id, order.Pair)
+			if err != nil {
+				report.fail("exchange(id=%d): cancel order(id=%s): %s", exch.Id, order.Id, err)
+				continue
+			}
+
+			canceled[order.Id] = true
+			report.CanceledOrders++
+		}
+	}
+
+	trades := a.releaseTrades(agents, canceled, &report)
+	if options.SellPositions {
+		a.panicSell(user, trades, &report)
+	}
+
+	err = a.storage.AddPanicEvent(PanicEvent{UserId: user.Id, Datetime: time.Now(), Global: options.Global, Report: report})
+	if err != nil {
+		report.fail("record event: %s", err)
+	}
+
+	a.logger.New(domain.LoggerLabels{}).Warn(fmt.Sprintf("panic by user(id=%d): %s", user.Id, report.Summary()))
+
+	return &report, nil
+}
+
+// panicTrade is an open trade of an agent with the storage it is saved to.
+type panicTrade struct {
+	agent   domain.Agent
+	storage domain.SimpleStorage
+	trade   domain.SimpleTrade
+}
+
+// releaseTrades clears the canceled orders from open trades of the agents, so
+// activated again they place the orders anew instead of waiting for them, and
+// returns the open trades. Agents of strategies without trades are skipped,
+// so are dry-run agents, their trades hold no real funds.
+func (a Actions) releaseTrades(agents []domain.Agent, canceled map[string]bool, report *PanicReport) []panicTrade {
+	result := []panicTrade{}
+	for _, agent := range agents {
+		if agent.DryRun {
+			continue
+		}
+
+		storage, ok := a.storage.GetAgentStorage(agent).(domain.SimpleStorage)
+		if !ok {
+			continue
+		}
+
+		trades, err := storage.GetTrades(&domain.SimpleTradeFilter{Statuses: []domain.SimpleTradeStatus{domain.SimpleTradeStatusBuy, domain.SimpleTradeStatusSell}})
+		if err != nil {
+			report.fail("agent(id=%d): trades: %s", agent.Id, err)
+			continue
+		}
+
+		for _, trade := range trades {
+			released := false
+			if trade.Status == domain.SimpleTradeStatusBuy && canceled[trade.Buy.OrderId] {
+				trade.Buy.OrderId = ""
+				trade.Buy.ClientOrderId = ""
+				released = true
+			}
+			if trade.Status == domain.SimpleTradeStatusSell && canceled[trade.Sell.OrderId] {
+				trade.Sell.OrderId = ""
+				released = true
+			}
+
+			if released {
+				err = storage.SaveTrade(&trade)
+				if err != nil {
+					report.fail("agent(id=%d): trade(id=%d): %s", agent.Id, trade.Id, err)
+					continue
+				}
+			}
+
+			result = append(result, panicTrade{agent: agent, storage: storage, trade: trade})
+		}
+	}
+
+	return result
+}
+
+// panicPosition is the base asset bought by open trades of one pair on one
+// exchange account.
+type panicPosition struct {
+	exchange ExchangeData
+	pair     domain.Pair
+	amount   decimal.Decimal
+	trades   []panicTrade
+}
+
+// panicSell sells what open trades of the agents bought, never more than the
+// free balance, and finishes the trades. Sells are valued in the quote asset
+// of the user when it has one.
+func (a Actions) panicSell(user domain.User, trades []panicTrade, report *PanicReport) {
+	if user.QuoteAsset != "" {
+		report.SoldValue = &domain.Balance{Asset: user.QuoteAsset, Amount: decimal.Zero}
+	}
+
+	positions := []*panicPosition{}
+	agentExchanges := map[int64][]ExchangeData{}
+	for _, item := range trades {
+		// trades with an open sell order keep their base asset in the order,
+		// paper trades of dry-run agents have nothing to sell
+		if item.agent.DryRun || item.trade.Status != domain.SimpleTradeStatusSell || item.trade.Sell.OrderId != "" {
+			continue
+		}
+
+		exchanges, ok := agentExchanges[item.agent.Id]
+		if !ok {
+			var err error
+			exchanges, err = a.storage.GetAgentExchanges(item.agent.Id)
+			if err != nil {
+				report.fail("agent(id=%d): exchanges: %s", item.agent.Id, err)
+			}
+			agentExchanges[item.agent.Id] = exchanges
+		}
+
+		if len(exchanges) != 1 {
+			continue
+		}
+
+		pair := item.trade.Pair
+		if pair == (domain.Pair{}) {
+			pair = firstPair(item.agent)
+		}
+		if pair == (domain.Pair{}) {
+			report.fail("agent(id=%d): trade(id=%d) has no pair", item.agent.Id, item.trade.Id)
+			continue
+		}
+
+		var position *panicPosition
+		for _, p := range positions {
+			if p.exchange.Id == exchanges[0].Id && p.pair == pair {
+				position = p
+				break
+			}
+		}
+		if position == nil {
+			position = &panicPosition{exchange: exchanges[0], pair: pair}
+			positions = append(positions, position)
+		}
+
+		position.amount = position.amount.Add(item.trade.Amount)
+		position.trades = append(position.trades, item)
+	}
+
+	for _, position := range positions {
+		exch, pair := position.exchange, position.pair
+		exchange := a.exchange.GetExchangeByJson(exch.Number, exch.Data)
+		if exchange == nil {
+			report.fail("exchange(id=%d): bad data", exch.Id)
+			continue
+		}
+
+		balances, err := exchange.Balances([]string{pair.BaseAsset})
+		if err != nil {
+			report.fail("exchange(id=%d): balance %s: %s", exch.Id, pair.BaseAsset, err)
+			continue
+		}
+
+		amount := position.amount
+		if len(balances) == 0 || balances[0].Amount.LessThan(amount) {
+			amount = decimal.Zero
+			if len(balances) > 0 {
+				amount = balances[0].Amount
+			}
+		}
+		if !amount.IsPositive() {
+			report.fail("exchange(id=%d): no free %s to sell", exch.Id, pair.BaseAsset)
+			continue
+		}
+
+		price, err := exchange.LastPrice(pair)
+		if err != nil {
+			report.fail("exchange(id=%d): last price %s: %s", exch.Id, pair, err)
+			continue
+		}
+
+		sellPrice := price.Mul(panicSellPrice)
+		order, err := exchange.Sell(pair, amount, sellPrice)
+		if err != nil {
+			report.fail("exchange(id=%d): sell %s: %s", exch.Id, pair, err)
+			continue
+		}
+
+		sold := domain.Balance{Asset: pair.BaseAsset, Amount: amount}
+		report.Sold = append(report.Sold, sold)
+
+		for _, item := range position.trades {
+			item.trade.Status = domain.SimpleTradeStatusFinish
+			item.trade.Sell = domain.SimpleTradeOrder{
+				OrderId:  order.Id,
+				Price:    sellPrice,
+				Datetime: time.Now().Format(time.RFC3339),
+			}
+
+			err = item.storage.SaveTrade(&item.trade)
+			if err != nil {
+				report.fail("agent(id=%d): trade(id=%d): %s", item.agent.Id, item.trade.Id, err)
+			}
+		}
+
+		if report.SoldValue != nil {
+			value, err := quoteValue(exchange, sold, pair, sellPrice, report.SoldValue.Asset)
+			if err != nil {
+				report.fail("exchange(id=%d): value of %s in %s: %s", exch.Id, pair.BaseAsset, report.SoldValue.Asset, err)
+				continue
+			}
+
+			report.SoldValue.Amount = report.SoldValue.Amount.Add(value)
+		}
+	}
+}
+
+// firstPair is the pair trades of the agent saved before pairs became a list
+// belong to.
+func firstPair(agent domain.Agent) domain.Pair {
+	strategy, err := domain.GetStrategyFromJson(agent.StrategyId, agent.StrategyData)
+	if err != nil {
+		return domain.Pair{}
+	}
+
+	for _, param := range strategy.Parameters() {
+		if pair, ok := param.Value.(domain.Pair); ok && param.Type == domain.PairParameterType {
+			return pair
+		}
+	}
+
+	return domain.Pair{}
+}
+
+// quoteValue returns the balance sold on the pair at the price valued in the
+// quote asset, other quote assets are converted with the last price.
+func quoteValue(exchange domain.Exchange, balance domain.Balance, pair domain.Pair, price decimal.Decimal, quoteAsset string) (decimal.Decimal, error) {
+	switch quoteAsset {
+	case pair.QuoteAsset:
+		return balance.Amount.Mul(price), nil
+	case balance.Asset:
+		return balance.Amount, nil
+	}
+
+	price, err := exchange.LastPrice(domain.Pair{BaseAsset: balance.Asset, QuoteAsset: quoteAsset})
+	if err != nil {
+		return decimal.Zero, err
+	}
+
+	return balance.Amount.Mul(price), nil
+}
@@ -1,29 +1,117 @@
 package app
 
-import "github.com/scientistnik/invest-agents/internal/app/domain"
+import (
+	"time"
+
+	"github.com/scientistnik/invest-agents/internal/app/domain"
+)
 
 type AgentFilter struct {
 	Id     int64
 	Status domain.AgentStatus
 	UserId int64
+	// VisibleTo finds agents of the user and agents shared with it.
+	VisibleTo  int64
+	ExchangeId int
+}
+
+type AgentLog struct {
+	Id       int64
+	AgentId  int64
+	Level    domain.LogLevel
+	Datetime time.Time
+	Message  string
+}
+
+// AgentDataChange is one version of the agent strategy data, UserId is the
+// user who saved it.
+type AgentDataChange struct {
+	Id       int64
+	AgentId  int64
+	UserId   int64
+	Datetime time.Time
+	Data     []byte
+}
+
+type AgentLogFilter struct {
+	AgentId  int64
+	MinLevel domain.LogLevel
+	Limit    int
 }
 
 type AppStorage interface {
-	// User
-	UserGetOrCreate(links UserLinks) (*domain.User, error)
+	// User, UserFind returns nil when no user has the links
+	UserFind(links UserLinks) (*domain.User, error)
+	UserCreate(links UserLinks, role domain.UserRole) (*domain.User, error)
+	FindUsers() ([]domain.User, error)
+	UserSetRole(userId int64, role domain.UserRole) error
+	UserGet(userId int64) (*domain.User, error)
+	UserFindByApiKey(apiKeyHash string) (*domain.User, error)
+	UserGetLinks(userId int64) (*UserLinks, error)
+	UserSetApiKey(user domain.User, apiKeyHash string) error
+	UserUpdate(user domain.User) error
+	// UserAddLinks adds identities to the user, a new email replaces the
+	// previous one
+	UserAddLinks(userId int64, links UserLinks) error
+	// UserMerge moves agents, exchanges, identities and limits of the source
+	// user to the target and deletes the source
+	UserMerge(sourceId int64, target domain.User) error
+	AddLinkCode(code LinkCode) error
+	UseLinkCode(code string, now time.Time) (*LinkCode, error)
 	// Agent
 	FindAgents(filter AgentFilter) ([]domain.Agent, error)
 	AgentSave(agent domain.Agent) (*domain.Agent, error)
 	AgentSetStatus(agent *domain.Agent, status domain.AgentStatus) error
 	AgentUpdateData(agent *domain.Agent, data []byte) error
+	AgentSetDryRun(agent *domain.Agent, dryRun bool) error
 	//GetStrategyData(agentId string) []byte
 	GetAgentStorage(strategyId domain.Agent) interface{}
 	GetAgentExchanges(agentId int64) ([]ExchangeData, error)
 	FindExchanges(filter ExchangeFilter) ([]ExchangeData, error)
-	AddExchange(userId int64, exchangeNumber int, data []byte) error
+	AddExchange(userId int64, name string, exchangeNumber int, data []byte) (*ExchangeData, error)
+	ExchangeSetData(exchangeId int, data []byte) error
+	ExchangeSetName(exchangeId int, name string) error
+	RemoveExchange(exchangeId int) error
 	AgentAddExchange(agent *domain.Agent, exchanges []ExchangeData) error
+	// Access, UseInvite returns nil when the code is unknown, used or expired
+	FindAgentShares(filter AgentShareFilter) ([]AgentShare, error)
+	SetAgentShare(share AgentShare) error
+	RemoveAgentShare(agentId int64, userId int64) error
+	AddInvite(invite Invite) error
+	UseInvite(code string, now time.Time) (*Invite, error)
+	// Agent logs
+	AddAgentLogs(records []AgentLog) error
+	FindAgentLogs(filter AgentLogFilter) ([]AgentLog, error)
+	TrimAgentLogs(agentId int64, maxCount int, before time.Time) error
+	// Risk limits, agentId 0 keeps the limits of the user
+	GetRiskLimits(userId int64, agentId int64) (*domain.RiskLimits, error)
+	SetRiskLimits(userId int64, agentId int64, limits domain.RiskLimits) error
+	AddRiskOrder(order domain.RiskOrder) error
+	RemoveRiskOrder(agentId int64, orderId string) error
+	FindRiskOrders(filter domain.RiskOrderFilter) ([]domain.RiskOrder, error)
+	AddPanicEvent(event PanicEvent) error
+	// Candles of exchanges
+	FindCandles(exchange string, pair domain.Pair, interval domain.CandleInterval, since time.Time) ([]domain.Candle, error)
+	CandleRange(exchange string, pair domain.Pair, interval domain.CandleInterval) (time.Time, time.Time, error)
+	SaveCandles(exchange string, pair domain.Pair, interval domain.CandleInterval, candles []domain.Candle) error
+	// Simulated orders of dry-run agents
+	FindPaperOrders(agentId int64, exchange string, statuses []domain.OrderStatus) ([]domain.Order, error)
+	SavePaperOrder(agentId int64, exchange string, order domain.Order) error
+	// Agent data history
+	AddAgentDataChange(change AgentDataChange) error
+	FindAgentDataChanges(agentId int64) ([]AgentDataChange, error)
 }
 
 type AppExchange interface {
 	GetExchangeByJson(exchangeId int, data []byte) domain.Exchange
+	// ExchangeName returns an empty name for unknown exchanges.
+	ExchangeName(exchangeId int) string
+	// MaskExchangeJson returns the data with keys hidden, nil for bad data.
+	MaskExchangeJson(exchangeId int, data []byte) []byte
+}
+
+// UserNotifier sends messages to users over one of their links, users
+// without a suitable link are skipped.
+type UserNotifier interface {
+	Notify(links UserLinks, message string) error
 }
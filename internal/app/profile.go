@@ -0,0 +1,225 @@
+package app
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/mail"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	// timezones of users are checked on hosts without zoneinfo too
+	_ "time/tzdata"
+
+	"github.com/scientistnik/invest-agents/internal/app/domain"
+)
+
+var ErrBadLinkCode = errors.New("link code is unknown, used or expired")
+
+// linkCodeTtl is short, a link code gives the whole account away.
+const linkCodeTtl = 15 * time.Minute
+
+const userNameLength = 64
+
+var quoteAssetPattern = regexp.MustCompile(`^[A-Z0-9]{2,10}$`)
+
+// LinkCode lets a second identity of the same person join the account of
+// UserId.
+type LinkCode struct {
+	Code    string
+	UserId  int64
+	Expires time.Time
+}
+
+type UserProfile struct {
+	User  domain.User
+	Links UserLinks
+}
+
+// UserLocation returns the timezone of the user, UTC when it has none.
+func UserLocation(user domain.User) *time.Location {
+	location, err := time.LoadLocation(user.Timezone)
+	if err != nil {
+		return time.UTC
+	}
+
+	return location
+}
+
+func (a Actions) GetUserProfile(user domain.User) (*UserProfile, error) {
+	stored, err := a.storage.UserGet(user.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	if stored == nil {
+		return nil, fmt.Errorf("user(id=%d): %w", user.Id, ErrUserNotFound)
+	}
+
+	links, err := a.storage.UserGetLinks(user.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	return &UserProfile{User: *stored, Links: *links}, nil
+}
+
+// UserUpdateProfile replaces the name, the timezone and the quote asset of the
+// user, empty values clear them.
+func (a Actions) UserUpdateProfile(user domain.User, name string, timezone string, quoteAsset string) (*domain.User, error) {
+	name = strings.TrimSpace(name)
+	if utf8.RuneCountInString(name) > userNameLength {
+		return nil, ValidationError{Message: fmt.Sprintf("name is longer than %d characters", userNameLength)}
+	}
+
+	if timezone != "" {
+		_, err := time.LoadLocation(timezone)
+		if err != nil {
+			return nil, ValidationError{Message: fmt.Sprintf("unknown timezone %q", timezone)}
+		}
+	}
+
+	quoteAsset = strings.ToUpper(quoteAsset)
+	if quoteAsset != "" && !quoteAssetPattern.MatchString(quoteAsset) {
+		return nil, ValidationError{Message: fmt.Sprintf("bad quote asset %q", quoteAsset)}
+	}
+
+	profile, err := a.GetUserProfile(user)
+	if err != nil {
+		return nil, err
+	}
+
+	updated := profile.User
+	updated.Name = name
+	updated.Timezone = timezone
+	updated.QuoteAsset = quoteAsset
+
+	err = a.storage.UserUpdate(updated)
+	if err != nil {
+		return nil, err
+	}
+
+	return &updated, nil
+}
+
+// UserLinkEmail sets the email of the user. An email of another user is
+// refused, the accounts are merged with a link code instead.
+func (a Actions) UserLinkEmail(user domain.User, email string) error {
+	address, err := mail.ParseAddress(strings.TrimSpace(email))
+	if err != nil || address.Name != "" {
+		return ValidationError{Message: fmt.Sprintf("bad email %q", email)}
+	}
+
+	links := UserLinks{Email: strings.ToLower(address.Address)}
+
+	owner, err := a.storage.UserFind(links)
+	if err != nil {
+		return err
+	}
+
+	if owner != nil && owner.Id != user.Id {
+		return ValidationError{Message: "the email is linked to another user, link the accounts with a link code"}
+	}
+
+	return a.storage.UserAddLinks(user.Id, links)
+}
+
+// UserCreateLinkCode returns a one-time code, redeemed from another identity
+// with UserLinkByCode or from a new Telegram chat with UserRegister.
+func (a Actions) UserCreateLinkCode(user domain.User) (*LinkCode, error) {
+	code := make([]byte, 12)
+	_, err := rand.Read(code)
+	if err != nil {
+		return nil, err
+	}
+
+	linkCode := LinkCode{Code: hex.EncodeToString(code), UserId: user.Id, Expires: time.Now().Add(linkCodeTtl)}
+	err = a.storage.AddLinkCode(linkCode)
+	if err != nil {
+		return nil, err
+	}
+
+	return &linkCode, nil
+}
+
+// UserLinkByCode merges the account of the user with the account that created
+// the code and returns the merged user.
+func (a Actions) UserLinkByCode(user domain.User, code string) (*domain.User, error) {
+	linkCode, err := a.storage.UseLinkCode(code, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	if linkCode == nil {
+		return nil, ErrBadLinkCode
+	}
+
+	if linkCode.UserId == user.Id {
+		return nil, ValidationError{Message: "the link code is of this account, redeem it from the other one"}
+	}
+
+	return a.mergeUsers(user.Id, linkCode.UserId)
+}
+
+// MergeUsers merges two accounts of the same person, admins only.
+func (a Actions) MergeUsers(caller domain.User, userId int64, otherId int64) (*domain.User, error) {
+	if caller.Role != domain.AdminUserRole {
+		return nil, ErrForbidden
+	}
+
+	if userId == otherId {
+		return nil, ValidationError{Message: "a user can't be merged with itself"}
+	}
+
+	return a.mergeUsers(userId, otherId)
+}
+
+// mergeUsers keeps the older account. It gets the higher role of the two and
+// profile fields it doesn't have yet. Running agents of the other account
+// keep its user id for risk limits and notifications until they restart.
+func (a Actions) mergeUsers(userId int64, otherId int64) (*domain.User, error) {
+	users := []*domain.User{}
+	for _, id := range []int64{userId, otherId} {
+		user, err := a.storage.UserGet(id)
+		if err != nil {
+			return nil, err
+		}
+
+		if user == nil {
+			return nil, fmt.Errorf("user(id=%d): %w", id, ErrUserNotFound)
+		}
+
+		users = append(users, user)
+	}
+
+	target, source := *users[0], *users[1]
+	if source.Id < target.Id {
+		target, source = source, target
+	}
+
+	if source.Role > target.Role {
+		target.Role = source.Role
+	}
+
+	if target.Name == "" {
+		target.Name = source.Name
+	}
+
+	if target.Timezone == "" {
+		target.Timezone = source.Timezone
+	}
+
+	if target.QuoteAsset == "" {
+		target.QuoteAsset = source.QuoteAsset
+	}
+
+	err := a.storage.UserMerge(source.Id, target)
+	if err != nil {
+		return nil, err
+	}
+
+	return &target, nil
+}
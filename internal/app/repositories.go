@@ -1,6 +1,13 @@
 package app
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
 	"github.com/scientistnik/invest-agents/internal/app/domain"
 )
 
@@ -14,6 +21,23 @@ func (a AgentRepo) FindAgents(active bool) ([]domain.Agent, error) {
 	return (*a.storage).FindAgents(AgentFilter{Status: domain.ActiveAgentStatus})
 }
 
+func (a AgentRepo) GetAgentStatus(agentId int64) (domain.AgentStatus, error) {
+	agents, err := (*a.storage).FindAgents(AgentFilter{Id: agentId})
+	if err != nil {
+		return 0, err
+	}
+
+	if len(agents) == 0 {
+		return 0, ErrAgentNotFound
+	}
+
+	return agents[0].Status, nil
+}
+
+func (a AgentRepo) SetAgentStatus(agent domain.Agent, status domain.AgentStatus) error {
+	return (*a.storage).AgentSetStatus(&agent, status)
+}
+
 // type StrategyRepo struct {
 // 	storage *AppStorage
 // }
@@ -47,8 +71,327 @@ func (e ExchangeRepo) GetAgentExchanges(agentId int64) ([]domain.Exchange, error
 
 	exchanges := []domain.Exchange{}
 	for _, exch := range exchs {
-		exchanges = append(exchanges, (*e.exchange).GetExchangeByJson(exch.Id, exch.Data))
+		exchange := (*e.exchange).GetExchangeByJson(exch.Number, exch.Data)
+		if exchange == nil {
+			return nil, fmt.Errorf("bad data for exchange(id=%d)", exch.Id)
+		}
+
+		exchanges = append(exchanges, exchange)
 	}
 
 	return exchanges, nil
 }
+
+// AgentExchangesVersion is a hash of the accounts of the agent, rotated keys
+// change it.
+func (e ExchangeRepo) AgentExchangesVersion(agentId int64) (string, error) {
+	exchs, err := (*e.storage).GetAgentExchanges(agentId)
+	if err != nil {
+		return "", err
+	}
+
+	hash := sha256.New()
+	for _, exch := range exchs {
+		fmt.Fprintf(hash, "%d:%d:%d:", exch.Id, exch.Number, len(exch.Data))
+		hash.Write(exch.Data)
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+type AgentLogLimits struct {
+	MaxCount int
+	MaxAge   time.Duration
+	// MinLevel is the lowest level kept in the storage, lower records are
+	// only written to the logger.
+	MinLevel domain.LogLevel
+}
+
+var DefaultAgentLogLimits = AgentLogLimits{MaxCount: 1000, MaxAge: 7 * 24 * time.Hour, MinLevel: domain.InfoLogLevel}
+
+// trim the agent_logs ring buffer once per this number of writes
+const agentLogTrimEvery = 100
+
+// records wait for the storage in a buffer of this size and are saved in
+// batches of at most agentLogBatch
+const (
+	agentLogBuffer = 1000
+	agentLogBatch  = 100
+)
+
+type LoggerRepo struct {
+	storage *AppStorage
+	logger  domain.LoggerRepo
+	limits  AgentLogLimits
+	writer  *agentLogWriter
+}
+
+var _ domain.LoggerRepo = (*LoggerRepo)(nil)
+
+func newLoggerRepo(storage *AppStorage, logger domain.LoggerRepo, limits AgentLogLimits) LoggerRepo {
+	return LoggerRepo{
+		storage: storage,
+		logger:  logger,
+		limits:  limits,
+		writer:  newAgentLogWriter(storage, logger.New(domain.LoggerLabels{}), limits),
+	}
+}
+
+// New trims the stored logs of the agent, an agent started rarely writes
+// enough records to trim them by count.
+func (l LoggerRepo) New(labels domain.LoggerLabels) domain.Logger {
+	logger := &StorageLogger{
+		agentId: labels.AgentId,
+		writer:  l.writer,
+		logger:  l.logger.New(labels),
+		limits:  l.limits,
+	}
+
+	if labels.AgentId != 0 {
+		l.writer.trim(labels.AgentId)
+	}
+
+	return logger
+}
+
+// flush waits up to timeout for the buffered records to be saved.
+func (l LoggerRepo) flush(timeout time.Duration) {
+	l.writer.flush(timeout)
+}
+
+// StorageLogger is used by the agent cycle and its stream watchers at once.
+type StorageLogger struct {
+	agentId int64
+	writer  *agentLogWriter
+	logger  domain.Logger
+	limits  AgentLogLimits
+}
+
+func (l *StorageLogger) save(level domain.LogLevel, message string) {
+	if level < l.limits.MinLevel {
+		return
+	}
+
+	l.writer.add(AgentLog{
+		AgentId:  l.agentId,
+		Level:    level,
+		Datetime: time.Now(),
+		Message:  message,
+	})
+}
+
+func (l *StorageLogger) Info(message string) {
+	l.logger.Info(message)
+	l.save(domain.InfoLogLevel, message)
+}
+
+func (l *StorageLogger) Warn(message string) {
+	l.logger.Warn(message)
+	l.save(domain.WarnLogLevel, message)
+}
+
+func (l *StorageLogger) Error(message string) {
+	l.logger.Error(message)
+	l.save(domain.ErrorLogLevel, message)
+}
+
+func (l *StorageLogger) Debug(message string) {
+	l.logger.Debug(message)
+	l.save(domain.DebugLogLevel, message)
+}
+
+// agentLogWriter saves records of all agents out of their cycles, a slow
+// storage must not stall trading. Records that don't fit the buffer are
+// dropped and counted.
+type agentLogWriter struct {
+	storage *AppStorage
+	logger  domain.Logger
+	limits  AgentLogLimits
+
+	start   sync.Once
+	records chan AgentLog
+	flushes chan chan struct{}
+	dropped int64
+	// writes are records saved per agent since its last trim, used by the
+	// writer goroutine only.
+	writes map[int64]int
+}
+
+func newAgentLogWriter(storage *AppStorage, logger domain.Logger, limits AgentLogLimits) *agentLogWriter {
+	return &agentLogWriter{
+		storage: storage,
+		logger:  logger,
+		limits:  limits,
+		records: make(chan AgentLog, agentLogBuffer),
+		flushes: make(chan chan struct{}),
+		writes:  map[int64]int{},
+	}
+}
+
+// add never blocks, the writer goroutine is started by the first record.
+func (w *agentLogWriter) add(record AgentLog) {
+	w.start.Do(func() { go w.run() })
+
+	select {
+	case w.records <- record:
+	default:
+		atomic.AddInt64(&w.dropped, 1)
+	}
+}
+
+func (w *agentLogWriter) run() {
+	for {
+		select {
+		case record := <-w.records:
+			w.save(record)
+		case done := <-w.flushes:
+			for len(w.records) > 0 {
+				w.save(<-w.records)
+			}
+			close(done)
+		}
+	}
+}
+
+// save saves the record with the records buffered after it.
+func (w *agentLogWriter) save(record AgentLog) {
+	batch := []AgentLog{record}
+	for len(batch) < agentLogBatch && len(w.records) > 0 {
+		batch = append(batch, <-w.records)
+	}
+
+	err := (*w.storage).AddAgentLogs(batch)
+	if err != nil {
+		w.logger.Error(fmt.Sprintf("agent logs: %d records are not saved: %s", len(batch), err))
+	}
+
+	if dropped := atomic.SwapInt64(&w.dropped, 0); dropped > 0 {
+		w.logger.Warn(fmt.Sprintf("agent logs: %d records are dropped, the storage is too slow", dropped))
+	}
+
+	if err != nil {
+		return
+	}
+
+	for _, record := range batch {
+		w.writes[record.AgentId]++
+		if w.writes[record.AgentId] >= agentLogTrimEvery {
+			w.writes[record.AgentId] = 0
+			w.trim(record.AgentId)
+		}
+	}
+}
+
+func (w *agentLogWriter) trim(agentId int64) {
+	var before time.Time
+	if w.limits.MaxAge > 0 {
+		before = time.Now().Add(-w.limits.MaxAge)
+	}
+
+	err := (*w.storage).TrimAgentLogs(agentId, w.limits.MaxCount, before)
+	if err != nil {
+		w.logger.Error(fmt.Sprintf("agent logs: trim agent(id=%d): %s", agentId, err))
+	}
+}
+
+// flush returns when the records buffered before the call are saved or
+// timeout passes.
+func (w *agentLogWriter) flush(timeout time.Duration) {
+	w.start.Do(func() { go w.run() })
+
+	done := make(chan struct{})
+	select {
+	case w.flushes <- done:
+	case <-time.After(timeout):
+		return
+	}
+
+	select {
+	case <-done:
+	case <-time.After(timeout):
+	}
+}
+
+type RiskRepo struct {
+	storage *AppStorage
+}
+
+var _ domain.RiskRepo = (*RiskRepo)(nil)
+
+func (r RiskRepo) GetRiskLimits(agent domain.Agent) (domain.RiskLimits, domain.RiskLimits, error) {
+	agentLimits, err := (*r.storage).GetRiskLimits(agent.UserId, agent.Id)
+	if err != nil {
+		return domain.RiskLimits{}, domain.RiskLimits{}, err
+	}
+
+	userLimits, err := (*r.storage).GetRiskLimits(agent.UserId, 0)
+	if err != nil {
+		return domain.RiskLimits{}, domain.RiskLimits{}, err
+	}
+
+	return *agentLimits, *userLimits, nil
+}
+
+func (r RiskRepo) AddRiskOrder(order domain.RiskOrder) error {
+	return (*r.storage).AddRiskOrder(order)
+}
+
+func (r RiskRepo) RemoveRiskOrder(agentId int64, orderId string) error {
+	return (*r.storage).RemoveRiskOrder(agentId, orderId)
+}
+
+func (r RiskRepo) FindRiskOrders(filter domain.RiskOrderFilter) ([]domain.RiskOrder, error) {
+	return (*r.storage).FindRiskOrders(filter)
+}
+
+type PaperRepo struct {
+	storage *AppStorage
+}
+
+var _ domain.PaperRepo = (*PaperRepo)(nil)
+
+func (p PaperRepo) FindPaperOrders(agentId int64, exchange string, statuses []domain.OrderStatus) ([]domain.Order, error) {
+	return (*p.storage).FindPaperOrders(agentId, exchange, statuses)
+}
+
+func (p PaperRepo) SavePaperOrder(agentId int64, exchange string, order domain.Order) error {
+	return (*p.storage).SavePaperOrder(agentId, exchange, order)
+}
+
+type CandleRepo struct {
+	storage *AppStorage
+}
+
+var _ domain.CandleRepo = (*CandleRepo)(nil)
+
+func (c CandleRepo) FindCandles(exchange string, pair domain.Pair, interval domain.CandleInterval, since time.Time) ([]domain.Candle, error) {
+	return (*c.storage).FindCandles(exchange, pair, interval, since)
+}
+
+func (c CandleRepo) CandleRange(exchange string, pair domain.Pair, interval domain.CandleInterval) (time.Time, time.Time, error) {
+	return (*c.storage).CandleRange(exchange, pair, interval)
+}
+
+func (c CandleRepo) SaveCandles(exchange string, pair domain.Pair, interval domain.CandleInterval, candles []domain.Candle) error {
+	return (*c.storage).SaveCandles(exchange, pair, interval, candles)
+}
+
+type NotifyRepo struct {
+	storage  *AppStorage
+	notifier UserNotifier
+}
+
+var _ domain.Notifier = (*NotifyRepo)(nil)
+
+func (n NotifyRepo) Notify(userId int64, message string) error {
+	links, err := (*n.storage).UserGetLinks(userId)
+	if err != nil {
+		return err
+	}
+
+	if links == nil {
+		return fmt.Errorf("user(id=%d) not found", userId)
+	}
+
+	return n.notifier.Notify(*links, message)
+}
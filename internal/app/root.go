@@ -2,20 +2,43 @@ package app
 
 import (
 	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"github.com/scientistnik/invest-agents/internal/app/domain"
+	"time"
 )
 
 type ExchangeData struct {
-	Id   int
-	Data []byte
+	Id     int
+	UserId int64
+	Name   string
+	Number int
+	Data   []byte
 }
 
+type ValidationError struct {
+	Message string
+}
+
+func (e ValidationError) Error() string {
+	return e.Message
+}
+
+var ErrAgentNotFound = errors.New("agent not found")
+var ErrExchangeNotFound = errors.New("exchange not found")
+var ErrAgentDataChangeNotFound = errors.New("agent data change not found")
+var ErrTradeNotFound = errors.New("trade not found")
+
 type Actions struct {
 	storage  AppStorage
 	exchange AppExchange
 	logger   domain.LoggerRepo
 	repos    domain.Repos
+	settings domain.AgentsSettings
+	access   AccessSettings
 }
 
 func GetAppActions(storage AppStorage, exchange AppExchange, appLogger domain.LoggerRepo) *Actions {
@@ -23,50 +46,146 @@ func GetAppActions(storage AppStorage, exchange AppExchange, appLogger domain.Lo
 		storage:  storage,
 		exchange: exchange,
 		logger:   appLogger,
+		settings: domain.DefaultAgentsSettings,
+		access:   DefaultAccessSettings,
 		repos: domain.Repos{
 			Agent:    AgentRepo{storage: &storage},
 			Storage:  StorageRepo{storage: &storage},
 			Exchange: ExchangeRepo{storage: &storage, exchange: &exchange},
-			Logger:   appLogger,
+			Logger:   newLoggerRepo(&storage, appLogger, DefaultAgentLogLimits),
+			Risk:     RiskRepo{storage: &storage},
+			Paper:    PaperRepo{storage: &storage},
+			Candles:  CandleRepo{storage: &storage},
+			Control:  domain.NewAgentControl(),
 		},
 	}
 }
 
+func (a *Actions) SetMetrics(metrics domain.MetricsRepo) {
+	a.repos.Metrics = metrics
+}
+
+func (a *Actions) SetNotifier(notifier UserNotifier) {
+	a.repos.Notifier = NotifyRepo{storage: &a.storage, notifier: notifier}
+}
+
+func (a *Actions) SetAgentsSettings(settings domain.AgentsSettings) {
+	a.settings = settings
+}
+
+func (a *Actions) SetAgentLogLimits(limits AgentLogLimits) {
+	a.repos.Logger = newLoggerRepo(&a.storage, a.logger, limits)
+}
+
+func (a *Actions) DisableAgentLogs() {
+	a.repos.Logger = a.logger
+}
+
+// UserLinks are identities of a user, the first one of each kind when the
+// user has several.
 type UserLinks struct {
-	Telegram int64 `json:"telegram"`
+	Telegram int64  `json:"telegram"`
+	Email    string `json:"email,omitempty"`
 }
 
-func (a Actions) UserGetOrCreate(name string, links UserLinks) (*domain.User, error) {
-	return a.storage.UserGetOrCreate(links)
+func (a Actions) GetUser(userId int64) (*domain.User, error) {
+	return a.storage.UserGet(userId)
 }
 
-type ExchangeFilter struct {
-	UserId         int64
-	ExchangeNumber int
+func hashApiKey(apiKey string) string {
+	hash := sha256.Sum256([]byte(apiKey))
+	return hex.EncodeToString(hash[:])
 }
 
-func (a Actions) FindExchanges(filter ExchangeFilter) ([]ExchangeData, error) {
-	return a.storage.FindExchanges(filter)
+// UserCreateApiKey generates a new HTTP API key for the user. Only a hash is
+// stored, so the key is shown once and replaces the previous one.
+func (a Actions) UserCreateApiKey(user domain.User) (string, error) {
+	key := make([]byte, 32)
+	_, err := rand.Read(key)
+	if err != nil {
+		return "", err
+	}
+
+	apiKey := hex.EncodeToString(key)
+	err = a.storage.UserSetApiKey(user, hashApiKey(apiKey))
+	if err != nil {
+		return "", err
+	}
+
+	return apiKey, nil
 }
 
-func (a Actions) AddExchange(user domain.User, exchangeNumber int, data []byte) error {
-	return a.storage.AddExchange(user.Id, exchangeNumber, data)
+func (a Actions) UserFindByApiKey(apiKey string) (*domain.User, error) {
+	if apiKey == "" {
+		return nil, nil
+	}
+
+	return a.storage.UserFindByApiKey(hashApiKey(apiKey))
 }
 
+// GetUserAgents returns agents of the user and agents shared with it, admins
+// get agents of everyone.
 func (a Actions) GetUserAgents(user domain.User) ([]domain.Agent, error) {
-	return a.storage.FindAgents(AgentFilter{UserId: user.Id})
+	if user.Role == domain.AdminUserRole {
+		return a.storage.FindAgents(AgentFilter{})
+	}
+
+	return a.storage.FindAgents(AgentFilter{VisibleTo: user.Id})
 }
 
-func (a Actions) FindAgents(filter AgentFilter) ([]domain.Agent, error) {
-	return a.storage.FindAgents(filter)
+// validateStrategyData returns the normalized data to store.
+func validateStrategyData(strategyId domain.StrategyId, data []byte, exchanges []domain.Exchange) ([]byte, error) {
+	data, _, err := domain.ValidateStrategyData(strategyId, data, exchanges)
+	if err != nil {
+		return nil, ValidationError{Message: err.Error()}
+	}
+
+	return data, nil
+}
+
+func (a Actions) exchangesFromData(exchanges []ExchangeData) ([]domain.Exchange, error) {
+	result := []domain.Exchange{}
+	for _, exch := range exchanges {
+		exchange := a.exchange.GetExchangeByJson(exch.Number, exch.Data)
+		if exchange == nil {
+			return nil, fmt.Errorf("bad data for exchange(id=%d)", exch.Id)
+		}
+
+		result = append(result, exchange)
+	}
+
+	return result, nil
 }
 
 func (a Actions) AgentCreate(user domain.User, strategyId domain.StrategyId, data []byte, exchanges []ExchangeData) (*domain.Agent, error) {
+	if user.Role < domain.TraderUserRole {
+		return nil, ErrForbidden
+	}
+
+	if len(exchanges) == 0 {
+		return nil, ValidationError{Message: "agent needs at least one exchange"}
+	}
+
+	agentExchanges, err := a.exchangesFromData(exchanges)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err = validateStrategyData(strategyId, data, agentExchanges)
+	if err != nil {
+		return nil, err
+	}
+
 	agent, err := a.storage.AgentSave(domain.Agent{UserId: user.Id, Status: domain.DisableAgentStatus, StrategyId: strategyId, StrategyData: data})
 	if err != nil {
 		return nil, err
 	}
 
+	err = a.storage.AddAgentDataChange(AgentDataChange{AgentId: agent.Id, UserId: user.Id, Datetime: time.Now(), Data: data})
+	if err != nil {
+		return nil, err
+	}
+
 	err = a.storage.AgentAddExchange(agent, exchanges)
 	if err != nil {
 		return nil, err
@@ -75,16 +194,194 @@ func (a Actions) AgentCreate(user domain.User, strategyId domain.StrategyId, dat
 	return agent, err
 }
 
-func (a Actions) AgentSetStatus(agent *domain.Agent, status domain.AgentStatus) error {
+func (a Actions) AgentSetStatus(user domain.User, agent *domain.Agent, status domain.AgentStatus) error {
+	err := a.checkAgentRole(user, *agent, domain.TraderUserRole)
+	if err != nil {
+		return err
+	}
+
+	if status != domain.ActiveAgentStatus && status != domain.DisableAgentStatus {
+		return ValidationError{Message: fmt.Sprintf("bad agent status %d", status)}
+	}
+
+	// the exchange account of a disabled agent may be removed
+	if status == domain.ActiveAgentStatus {
+		exchanges, err := a.storage.GetAgentExchanges(agent.Id)
+		if err != nil {
+			return err
+		}
+
+		if len(exchanges) == 0 {
+			return ValidationError{Message: "the agent has no exchange account left"}
+		}
+	}
+
 	return a.storage.AgentSetStatus(agent, status)
 }
 
-func (a Actions) AgentUpdateData(agent *domain.Agent, data []byte) error {
-	return a.storage.AgentUpdateData(agent, data)
+// AgentSetDryRun switches the agent between the real and the simulated
+// exchange. Trades of both modes are kept apart, so only a disabled agent may
+// switch and it continues with the trades of the new mode.
+func (a Actions) AgentSetDryRun(user domain.User, agent *domain.Agent, dryRun bool) error {
+	err := a.checkAgentRole(user, *agent, domain.TraderUserRole)
+	if err != nil {
+		return err
+	}
+
+	if agent.Status == domain.ActiveAgentStatus {
+		return ValidationError{Message: "disable the agent before switching dry run"}
+	}
+
+	return a.storage.AgentSetDryRun(agent, dryRun)
+}
+
+// AgentUpdateData validates and stores new strategy data, the change is kept
+// in the agent data history on behalf of user.
+func (a Actions) AgentUpdateData(user domain.User, agent *domain.Agent, data []byte) error {
+	err := a.checkAgentRole(user, *agent, domain.TraderUserRole)
+	if err != nil {
+		return err
+	}
+
+	exchanges, err := a.repos.Exchange.GetAgentExchanges(agent.Id)
+	if err != nil {
+		return err
+	}
+
+	data, err = validateStrategyData(agent.StrategyId, data, exchanges)
+	if err != nil {
+		return err
+	}
+
+	err = a.storage.AgentUpdateData(agent, data)
+	if err != nil {
+		return err
+	}
+
+	return a.storage.AddAgentDataChange(AgentDataChange{AgentId: agent.Id, UserId: user.Id, Datetime: time.Now(), Data: data})
+}
+
+func (a Actions) GetAgentDataHistory(user domain.User, agentId int64) ([]AgentDataChange, error) {
+	agent, err := a.GetUserAgent(user, agentId)
+	if err != nil {
+		return nil, err
+	}
+
+	return a.storage.FindAgentDataChanges(agent.Id)
+}
+
+// AgentRollbackData restores the strategy data saved by the change. Old data
+// is upgraded and validated like any other update.
+func (a Actions) AgentRollbackData(user domain.User, agentId int64, changeId int64) (*domain.Agent, error) {
+	agent, err := a.getAgent(user, agentId, domain.TraderUserRole)
+	if err != nil {
+		return nil, err
+	}
+
+	changes, err := a.storage.FindAgentDataChanges(agent.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, change := range changes {
+		if change.Id == changeId {
+			err = a.AgentUpdateData(user, agent, change.Data)
+			if err != nil {
+				return nil, err
+			}
+
+			return agent, nil
+		}
+	}
+
+	return nil, ErrAgentDataChangeNotFound
 }
 
 func (a Actions) StartAgents(ctx context.Context) error {
-	return domain.StartAgents(ctx, a.repos)
+	err := domain.StartAgents(ctx, a.repos, a.settings)
+
+	// the last records of the stopped agents are still in the buffer
+	if logger, ok := a.repos.Logger.(LoggerRepo); ok {
+		logger.flush(a.settings.ShutdownTimeout)
+	}
+
+	return err
+}
+
+// GetUserAgent returns the agent when the user may see it.
+func (a Actions) GetUserAgent(user domain.User, agentId int64) (*domain.Agent, error) {
+	return a.getAgent(user, agentId, domain.ViewerUserRole)
+}
+
+func (a Actions) GetAgentLogs(user domain.User, agentId int64, minLevel domain.LogLevel, limit int) ([]AgentLog, error) {
+	agent, err := a.GetUserAgent(user, agentId)
+	if err != nil {
+		return nil, err
+	}
+
+	return a.storage.FindAgentLogs(AgentLogFilter{AgentId: agent.Id, MinLevel: minLevel, Limit: limit})
+}
+
+func (a Actions) GetAgentStorage(agent domain.Agent) interface{} {
+	return a.storage.GetAgentStorage(agent)
+}
+
+// ResolveTrade settles a trade side whose order the strategy can't find by
+// the client order id. orderId is the order placed for the side, an empty
+// one tells that no order was placed and the strategy places it again. The
+// agent has to be disabled, so the strategy doesn't save the trade meanwhile.
+func (a Actions) ResolveTrade(user domain.User, agentId int64, tradeId int, side domain.OrderSide, orderId string) (*domain.SimpleTrade, error) {
+	agent, err := a.getAgent(user, agentId, domain.TraderUserRole)
+	if err != nil {
+		return nil, err
+	}
+
+	if agent.Status == domain.ActiveAgentStatus {
+		return nil, ValidationError{Message: "disable the agent before resolving its trades"}
+	}
+
+	storage, ok := a.storage.GetAgentStorage(*agent).(domain.SimpleStorage)
+	if !ok {
+		return nil, ValidationError{Message: fmt.Sprintf("trades are not supported for agent(id=%d) strategy", agent.Id)}
+	}
+
+	trades, err := storage.GetTrades(&domain.SimpleTradeFilter{Statuses: []domain.SimpleTradeStatus{domain.SimpleTradeStatusBuy, domain.SimpleTradeStatusSell}})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, trade := range trades {
+		if trade.Id != tradeId {
+			continue
+		}
+
+		var order *domain.SimpleTradeOrder
+		switch {
+		case side == domain.BuyOrderSide && trade.Status == domain.SimpleTradeStatusBuy:
+			order = &trade.Buy
+		case side == domain.SellOrderSide && trade.Status == domain.SimpleTradeStatusSell:
+			order = &trade.Sell
+		}
+
+		if order == nil || order.OrderId != "" || order.ClientOrderId == "" {
+			return nil, ValidationError{Message: fmt.Sprintf("trade(id=%d) %s doesn't wait for its order", trade.Id, side)}
+		}
+
+		order.OrderId = orderId
+		order.ClientOrderId = ""
+		err = storage.SaveTrade(&trade)
+		if err != nil {
+			return nil, err
+		}
+
+		return &trade, nil
+	}
+
+	return nil, ErrTradeNotFound
+}
+
+func (a Actions) GetStrategies() []domain.StrategyInfo {
+	return domain.Strategies()
 }
 
 type AgentInfo struct {
@@ -95,7 +392,10 @@ type AgentInfo struct {
 }
 
 func (a Actions) GetAgentInfo(agent domain.Agent) *AgentInfo {
-	strategy := domain.GetStrategyFromJson(agent.StrategyId, agent.StrategyData)
+	strategy, err := domain.GetStrategyFromJson(agent.StrategyId, agent.StrategyData)
+	if err != nil {
+		return nil
+	}
 
 	exchanges, err := a.repos.Exchange.GetAgentExchanges(agent.Id)
 	if err != nil {
@@ -114,3 +414,53 @@ func (a Actions) GetAgentInfo(agent domain.Agent) *AgentInfo {
 		Parameters:   strategy.Parameters(),
 	}
 }
+
+// GetRiskLimits returns limits of the agent, agentId 0 returns limits of the
+// user. Limits of an agent are kept for its owner.
+func (a Actions) GetRiskLimits(user domain.User, agentId int64) (*domain.RiskLimits, error) {
+	if agentId != 0 {
+		agent, err := a.GetUserAgent(user, agentId)
+		if err != nil {
+			return nil, err
+		}
+
+		return a.storage.GetRiskLimits(agent.UserId, agent.Id)
+	}
+
+	return a.storage.GetRiskLimits(user.Id, agentId)
+}
+
+// SetRiskLimits sets limits of the agent, agentId 0 sets limits of the user.
+// The limits of an agent protect the funds of its owner, so users the agent
+// is shared with can't change them.
+func (a Actions) SetRiskLimits(user domain.User, agentId int64, limits domain.RiskLimits) error {
+	if user.Role < domain.TraderUserRole {
+		return ErrForbidden
+	}
+
+	ownerId := user.Id
+	if agentId != 0 {
+		agent, err := a.getOwnAgent(user, agentId)
+		if err != nil {
+			return err
+		}
+
+		if len(limits.MaxExposure) > 0 {
+			return ValidationError{Message: "max_exposure is a user limit"}
+		}
+
+		ownerId = agent.UserId
+	}
+
+	if limits.MaxQuoteAllocation.IsNegative() || limits.MaxOrdersPerHour < 0 || limits.MaxDailyLoss.IsNegative() {
+		return ValidationError{Message: "risk limits can't be negative"}
+	}
+
+	for asset, amount := range limits.MaxExposure {
+		if asset == "" || !amount.IsPositive() {
+			return ValidationError{Message: fmt.Sprintf("bad max_exposure for %q", asset)}
+		}
+	}
+
+	return a.storage.SetRiskLimits(ownerId, agentId, limits)
+}
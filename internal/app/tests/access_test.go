@@ -0,0 +1,270 @@
+package test_app
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/scientistnik/invest-agents/internal/app"
+	"github.com/scientistnik/invest-agents/internal/app/domain"
+	mock_domain "github.com/scientistnik/invest-agents/internal/app/domain/tests/mocks"
+	"github.com/scientistnik/invest-agents/internal/app/tests/fakes"
+	"github.com/scientistnik/invest-agents/internal/loggers"
+	"github.com/shopspring/decimal"
+)
+
+const agentData = `{"pairs":[{"pair":{"base_asset":"BTC","quote_asset":"USD"}}],"base_quantity":"1","max_trades":1,"profit_percent":"0.01","far_price_percent":"0.01"}`
+
+// accessUsers are the users of newAccessStorage by name: the owner of agent
+// 10, users it is shared with and users without a share.
+var accessUsers = map[string]domain.User{
+	"owner":    {Id: 1, Role: domain.TraderUserRole},
+	"viewer":   {Id: 2, Role: domain.ViewerUserRole},
+	"trader":   {Id: 3, Role: domain.TraderUserRole},
+	"capped":   {Id: 4, Role: domain.ViewerUserRole},
+	"stranger": {Id: 5, Role: domain.TraderUserRole},
+	"admin":    {Id: 6, Role: domain.AdminUserRole},
+}
+
+func newAccessStorage() *fakes.Storage {
+	storage := fakes.NewStorage()
+	for _, user := range accessUsers {
+		storage.AddUser(user)
+	}
+
+	storage.Exchanges[1] = &app.ExchangeData{Id: 1, UserId: 1, Name: "main", Number: fakes.ExchangeNumber, Data: []byte(`{"key":"k"}`)}
+	storage.AddAgent(domain.Agent{Id: 10, UserId: 1, Status: domain.ActiveAgentStatus, StrategyId: domain.SimpleStratedy, StrategyData: []byte(agentData)}, 1)
+	storage.Shares = append(storage.Shares,
+		app.AgentShare{AgentId: 10, UserId: 2, Role: domain.ViewerUserRole},
+		app.AgentShare{AgentId: 10, UserId: 3, Role: domain.TraderUserRole},
+		// a share gives no more than the role of the user
+		app.AgentShare{AgentId: 10, UserId: 4, Role: domain.TraderUserRole},
+	)
+
+	return storage
+}
+
+func TestAgentActionsByRole(t *testing.T) {
+	all := func(err error) map[string]error {
+		result := map[string]error{}
+		for name := range accessUsers {
+			result[name] = err
+		}
+		return result
+	}
+	with := func(errs map[string]error, name string, err error) map[string]error {
+		errs[name] = err
+		return errs
+	}
+
+	cases := []struct {
+		name string
+		call func(actions *app.Actions, user domain.User, agent domain.Agent) error
+		// errs are the errors by user name, nil when the call is allowed
+		errs map[string]error
+	}{
+		{
+			name: "get agent",
+			call: func(actions *app.Actions, user domain.User, agent domain.Agent) error {
+				_, err := actions.GetUserAgent(user, agent.Id)
+				return err
+			},
+			errs: with(all(nil), "stranger", app.ErrAgentNotFound),
+		},
+		{
+			name: "get agent to trade",
+			call: func(actions *app.Actions, user domain.User, agent domain.Agent) error {
+				_, err := actions.AgentRollbackData(user, agent.Id, 99)
+				if errors.Is(err, app.ErrAgentDataChangeNotFound) {
+					return nil
+				}
+				return err
+			},
+			errs: map[string]error{"owner": nil, "viewer": app.ErrForbidden, "trader": nil, "capped": app.ErrForbidden, "stranger": app.ErrAgentNotFound, "admin": nil},
+		},
+		{
+			name: "set status",
+			call: func(actions *app.Actions, user domain.User, agent domain.Agent) error {
+				return actions.AgentSetStatus(user, &agent, domain.DisableAgentStatus)
+			},
+			errs: map[string]error{"owner": nil, "viewer": app.ErrForbidden, "trader": nil, "capped": app.ErrForbidden, "stranger": app.ErrForbidden, "admin": nil},
+		},
+		{
+			name: "update data",
+			call: func(actions *app.Actions, user domain.User, agent domain.Agent) error {
+				return actions.AgentUpdateData(user, &agent, []byte(agentData))
+			},
+			errs: map[string]error{"owner": nil, "viewer": app.ErrForbidden, "trader": nil, "capped": app.ErrForbidden, "stranger": app.ErrForbidden, "admin": nil},
+		},
+		{
+			name: "set risk limits",
+			call: func(actions *app.Actions, user domain.User, agent domain.Agent) error {
+				return actions.SetRiskLimits(user, agent.Id, domain.RiskLimits{MaxOrdersPerHour: 100})
+			},
+			errs: map[string]error{"owner": nil, "viewer": app.ErrForbidden, "trader": app.ErrForbidden, "capped": app.ErrForbidden, "stranger": app.ErrAgentNotFound, "admin": nil},
+		},
+		{
+			name: "panic",
+			call: func(actions *app.Actions, user domain.User, agent domain.Agent) error {
+				_, err := actions.Panic(user, app.PanicOptions{})
+				return err
+			},
+			errs: map[string]error{"owner": nil, "viewer": app.ErrForbidden, "trader": nil, "capped": app.ErrForbidden, "stranger": nil, "admin": nil},
+		},
+		{
+			name: "global panic",
+			call: func(actions *app.Actions, user domain.User, agent domain.Agent) error {
+				_, err := actions.Panic(user, app.PanicOptions{Global: true})
+				return err
+			},
+			errs: with(all(app.ErrForbidden), "admin", nil),
+		},
+	}
+
+	for _, c := range cases {
+		for name, user := range accessUsers {
+			t.Run(c.name+"/"+name, func(t *testing.T) {
+				ctrl := gomock.NewController(t)
+				defer ctrl.Finish()
+
+				mExchange := mock_domain.NewMockExchange(ctrl)
+				mExchange.EXPECT().GetPairFee(gomock.Any()).Return(domain.Balance{Asset: "USD", Amount: decimal.NewFromFloat(0.002)}, nil).AnyTimes()
+				mExchange.EXPECT().GetOpenOrders(gomock.Any()).Return([]domain.Order{}, nil).AnyTimes()
+
+				storage := newAccessStorage()
+				actions := app.GetAppActions(storage, fakes.AppExchange{Exchange: mExchange}, loggers.ConstructorConsoleLogger{})
+
+				err := c.call(actions, user, *storage.Agents[10])
+				want := c.errs[name]
+				if want == nil && err != nil || want != nil && !errors.Is(err, want) {
+					t.Fatalf("got %v, want %v", err, want)
+				}
+			})
+		}
+	}
+}
+
+func TestAgentRoleOfShares(t *testing.T) {
+	storage := newAccessStorage()
+	actions := app.GetAppActions(storage, fakes.AppExchange{}, loggers.ConstructorConsoleLogger{})
+
+	cases := map[string]domain.UserRole{
+		"owner":    domain.TraderUserRole,
+		"viewer":   domain.ViewerUserRole,
+		"trader":   domain.TraderUserRole,
+		"capped":   domain.ViewerUserRole,
+		"stranger": 0,
+		"admin":    domain.AdminUserRole,
+	}
+
+	for name, want := range cases {
+		role, err := actions.AgentRole(accessUsers[name], *storage.Agents[10])
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if role != want {
+			t.Errorf("%s: role %d, want %d", name, role, want)
+		}
+	}
+}
+
+func TestInvites(t *testing.T) {
+	storage := newAccessStorage()
+	actions := app.GetAppActions(storage, fakes.AppExchange{}, loggers.ConstructorConsoleLogger{})
+
+	_, err := actions.CreateInvite(accessUsers["trader"], domain.TraderUserRole)
+	if !errors.Is(err, app.ErrForbidden) {
+		t.Fatalf("only admins invite, got %v", err)
+	}
+
+	invite, err := actions.CreateInvite(accessUsers["admin"], domain.ViewerUserRole)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	storage.AddInvite(app.Invite{Code: "old", Role: domain.TraderUserRole, Expires: time.Now().Add(-time.Minute)})
+
+	cases := []struct {
+		name     string
+		telegram int64
+		code     string
+		// role of the registered user, 0 when the code is refused
+		role domain.UserRole
+	}{
+		{name: "invite", telegram: 100, code: invite.Code, role: domain.ViewerUserRole},
+		{name: "used invite", telegram: 101, code: invite.Code},
+		{name: "expired invite", telegram: 102, code: "old"},
+		{name: "unknown code", telegram: 103, code: "nope"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			user, err := actions.UserRegister(app.UserLinks{Telegram: c.telegram}, c.code)
+			if c.role == 0 {
+				if !errors.Is(err, app.ErrBadInvite) {
+					t.Fatalf("expected bad invite, got %v %+v", err, user)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if user.Role != c.role {
+				t.Fatalf("role %d, want %d", user.Role, c.role)
+			}
+		})
+	}
+}
+
+func TestSetUserRole(t *testing.T) {
+	cases := []struct {
+		name   string
+		caller string
+		userId int64
+		role   domain.UserRole
+		// err is nil when the role is set
+		err error
+	}{
+		{name: "admin promotes", caller: "admin", userId: 2, role: domain.TraderUserRole},
+		{name: "admin keeps own role", caller: "admin", userId: 6, role: domain.AdminUserRole},
+		{name: "admin demotes itself", caller: "admin", userId: 6, role: domain.TraderUserRole, err: app.ValidationError{}},
+		{name: "trader promotes itself", caller: "trader", userId: 3, role: domain.AdminUserRole, err: app.ErrForbidden},
+		{name: "unknown user", caller: "admin", userId: 99, role: domain.TraderUserRole, err: app.ErrUserNotFound},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			storage := newAccessStorage()
+			actions := app.GetAppActions(storage, fakes.AppExchange{}, loggers.ConstructorConsoleLogger{})
+
+			var before domain.UserRole
+			if user, ok := storage.Users[c.userId]; ok {
+				before = user.Role
+			}
+
+			err := actions.SetUserRole(accessUsers[c.caller], c.userId, c.role)
+
+			var validation app.ValidationError
+			switch {
+			case c.err == nil && err != nil:
+				t.Fatal(err)
+			case errors.As(c.err, &validation) && !errors.As(err, &validation):
+				t.Fatalf("expected a validation error, got %v", err)
+			case c.err != nil && !errors.As(c.err, &validation) && !errors.Is(err, c.err):
+				t.Fatalf("expected %v, got %v", c.err, err)
+			}
+
+			want := c.role
+			if c.err != nil {
+				want = before
+			}
+			if user, ok := storage.Users[c.userId]; ok && user.Role != want {
+				t.Fatalf("role %d, want %d", user.Role, want)
+			}
+		})
+	}
+}
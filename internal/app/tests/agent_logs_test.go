@@ -0,0 +1,95 @@
+package test_app
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/scientistnik/invest-agents/internal/app"
+	"github.com/scientistnik/invest-agents/internal/app/domain"
+	mock_domain "github.com/scientistnik/invest-agents/internal/app/domain/tests/mocks"
+	"github.com/scientistnik/invest-agents/internal/app/tests/fakes"
+	"github.com/scientistnik/invest-agents/internal/loggers"
+)
+
+// slowLogStorage saves agent logs once released.
+type slowLogStorage struct {
+	*fakes.Storage
+	release chan struct{}
+
+	mu   sync.Mutex
+	logs []app.AgentLog
+}
+
+func (s *slowLogStorage) AddAgentLogs(records []app.AgentLog) error {
+	<-s.release
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.logs = append(s.logs, records...)
+	return nil
+}
+
+func (s *slowLogStorage) TrimAgentLogs(agentId int64, maxCount int, before time.Time) error {
+	return nil
+}
+
+func TestSlowAgentLogsDontStallCycles(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	fake, _ := newExchangeStorage(domain.ActiveAgentStatus)
+	fake.Agents[10].StrategyData = []byte(agentData)
+	fake.AgentStorages[10] = &fakes.Trades{}
+	storage := &slowLogStorage{Storage: fake, release: make(chan struct{})}
+
+	var release sync.Once
+	stalled := time.AfterFunc(5*time.Second, func() {
+		t.Error("agent cycles are stalled by the log storage")
+		release.Do(func() { close(storage.release) })
+	})
+	defer stalled.Stop()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// every cycle logs while the storage is blocked
+	cycles := 0
+	mExchange := mock_domain.NewMockExchange(ctrl)
+	mExchange.EXPECT().Balances(gomock.Any()).DoAndReturn(func(assets []string) ([]domain.Balance, error) {
+		cycles++
+		if cycles == 5 {
+			release.Do(func() { close(storage.release) })
+			cancel()
+		}
+		return nil, errors.New("no balance")
+	}).Times(5)
+
+	actions := app.GetAppActions(storage, fakes.AppExchange{Exchange: mExchange}, loggers.ConstructorConsoleLogger{})
+	actions.SetAgentLogLimits(app.DefaultAgentLogLimits)
+	actions.SetAgentsSettings(domain.AgentsSettings{Interval: time.Millisecond, ShutdownTimeout: time.Second})
+
+	err := actions.StartAgents(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	storage.mu.Lock()
+	defer storage.mu.Unlock()
+
+	// the records of the last cycle are saved before StartAgents returns
+	errorLogs := 0
+	for _, record := range storage.logs {
+		if record.AgentId == 10 && record.Level == domain.ErrorLogLevel && strings.Contains(record.Message, "no balance") {
+			errorLogs++
+		}
+	}
+	if errorLogs != 5 || !strings.Contains(storage.logs[len(storage.logs)-1].Message, "agent stopped") {
+		t.Fatalf("%d errors of 5 are saved, the last record is %+v", errorLogs, storage.logs[len(storage.logs)-1])
+	}
+}
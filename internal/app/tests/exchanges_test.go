@@ -0,0 +1,235 @@
+package test_app
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/scientistnik/invest-agents/internal/app"
+	"github.com/scientistnik/invest-agents/internal/app/domain"
+	mock_domain "github.com/scientistnik/invest-agents/internal/app/domain/tests/mocks"
+	"github.com/scientistnik/invest-agents/internal/app/tests/fakes"
+	"github.com/scientistnik/invest-agents/internal/loggers"
+)
+
+// newExchangeStorage stores the account 1 of the owner with the agent 10 of
+// the status trading on it.
+func newExchangeStorage(status domain.AgentStatus) (*fakes.Storage, domain.User) {
+	storage := fakes.NewStorage()
+	owner := storage.AddUser(domain.User{Id: 1, Role: domain.TraderUserRole})
+	storage.AddUser(domain.User{Id: 2, Role: domain.TraderUserRole})
+	storage.Exchanges[1] = &app.ExchangeData{Id: 1, UserId: owner.Id, Name: "main", Number: fakes.ExchangeNumber, Data: []byte(`{"key":"old-secret"}`)}
+	storage.AddAgent(domain.Agent{Id: 10, UserId: owner.Id, Status: status, StrategyId: domain.SimpleStratedy}, 1)
+
+	return storage, owner
+}
+
+func TestRotateExchangeKeys(t *testing.T) {
+	cases := []struct {
+		name   string
+		userId int64
+		data   string
+		// balances is the answer of the exchange to the new keys
+		balances error
+		// err is nil when the keys are rotated
+		err error
+	}{
+		{name: "rotate", userId: 1, data: `{"key":"new-secret"}`},
+		{name: "bad data", userId: 1, data: `{"nokey":1}`, err: app.ValidationError{}},
+		{name: "refused keys", userId: 1, data: `{"key":"new-secret"}`, balances: domain.NewExchangeError(domain.ErrAuth, errors.New("bad signature")), err: app.ValidationError{}},
+		{name: "exchange is down", userId: 1, data: `{"key":"new-secret"}`, balances: errors.New("timeout"), err: errors.New("check keys")},
+		{name: "not owner", userId: 2, data: `{"key":"new-secret"}`, err: app.ErrExchangeNotFound},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mExchange := mock_domain.NewMockExchange(ctrl)
+			mExchange.EXPECT().Balances(gomock.Any()).Return([]domain.Balance{}, c.balances).AnyTimes()
+
+			storage, _ := newExchangeStorage(domain.ActiveAgentStatus)
+			actions := app.GetAppActions(storage, fakes.AppExchange{Exchange: mExchange}, loggers.ConstructorConsoleLogger{})
+
+			account, err := actions.RotateExchangeKeys(*storage.Users[c.userId], 1, []byte(c.data))
+
+			var validation app.ValidationError
+			switch {
+			case c.err == nil && err != nil:
+				t.Fatal(err)
+			case errors.As(c.err, &validation) && !errors.As(err, &validation):
+				t.Fatalf("expected a validation error, got %v", err)
+			case c.err != nil && !errors.As(c.err, &validation) && (err == nil || !errors.Is(err, c.err) && !strings.Contains(err.Error(), c.err.Error())):
+				t.Fatalf("expected %v, got %v", c.err, err)
+			}
+
+			want := `{"key":"old-secret"}`
+			if c.err == nil {
+				want = c.data
+				if string(account.MaskedData) != `{"key":"***"}` || strings.Contains(string(account.MaskedData), "secret") {
+					t.Fatalf("keys are not masked: %s", account.MaskedData)
+				}
+			}
+
+			if string(storage.Exchanges[1].Data) != want {
+				t.Fatalf("account data %s, want %s", storage.Exchanges[1].Data, want)
+			}
+		})
+	}
+}
+
+func TestRemoveExchangeOfRunningAgents(t *testing.T) {
+	cases := []struct {
+		name   string
+		status domain.AgentStatus
+		// removed tells that the account is gone
+		removed bool
+	}{
+		{name: "active agent", status: domain.ActiveAgentStatus},
+		{name: "paused agent", status: domain.PausedAgentStatus},
+		{name: "disabled agent", status: domain.DisableAgentStatus, removed: true},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			storage, owner := newExchangeStorage(c.status)
+			actions := app.GetAppActions(storage, fakes.AppExchange{}, loggers.ConstructorConsoleLogger{})
+
+			err := actions.RemoveExchange(owner, 1)
+
+			var validation app.ValidationError
+			if c.removed && err != nil || !c.removed && !errors.As(err, &validation) {
+				t.Fatalf("unexpected error %v", err)
+			}
+
+			_, kept := storage.Exchanges[1]
+			if kept == c.removed {
+				t.Fatalf("account kept %t", kept)
+			}
+			if c.removed && len(storage.AgentLinks[10]) != 0 {
+				t.Fatalf("agent keeps removed account %v", storage.AgentLinks[10])
+			}
+		})
+	}
+}
+
+func TestListExchangesMasksKeys(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	storage, owner := newExchangeStorage(domain.ActiveAgentStatus)
+	storage.Exchanges[2] = &app.ExchangeData{Id: 2, UserId: 2, Name: "other", Number: fakes.ExchangeNumber, Data: []byte(`{"key":"other-secret"}`)}
+	storage.Exchanges[3] = &app.ExchangeData{Id: 3, UserId: owner.Id, Name: "broken", Number: fakes.ExchangeNumber, Data: []byte(`{"secret":"raw-secret"}`)}
+	actions := app.GetAppActions(storage, fakes.AppExchange{Exchange: mock_domain.NewMockExchange(ctrl)}, loggers.ConstructorConsoleLogger{})
+
+	accounts, err := actions.ListExchanges(owner)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(accounts) != 2 {
+		t.Fatalf("expected accounts of the owner only, got %+v", accounts)
+	}
+
+	for _, account := range accounts {
+		if strings.Contains(string(account.MaskedData), "secret") {
+			t.Fatalf("account(id=%d) shows keys: %s", account.Id, account.MaskedData)
+		}
+	}
+
+	if string(accounts[0].MaskedData) != `{"key":"***"}` || accounts[1].MaskedData != nil || len(accounts[0].AgentIds) != 1 {
+		t.Fatalf("unexpected accounts %+v", accounts)
+	}
+}
+
+type streamingExchange struct {
+	*mock_domain.MockExchange
+	*mock_domain.MockStreamExchange
+}
+
+// newStreamingExchange returns an exchange that streams until the stream
+// context is canceled, streams gets the context.
+func newStreamingExchange(ctrl *gomock.Controller, streams chan<- context.Context) streamingExchange {
+	exchange := streamingExchange{mock_domain.NewMockExchange(ctrl), mock_domain.NewMockStreamExchange(ctrl)}
+	exchange.MockExchange.EXPECT().Name().Return("fake").AnyTimes()
+	exchange.MockStreamExchange.EXPECT().Stream(gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, pairs []domain.Pair) (<-chan domain.StreamEvent, error) {
+		events := make(chan domain.StreamEvent)
+		go func() {
+			<-ctx.Done()
+			close(events)
+		}()
+		streams <- ctx
+		return events, nil
+	})
+
+	return exchange
+}
+
+func TestRotatedKeysRestartStreams(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	storage, owner := newExchangeStorage(domain.ActiveAgentStatus)
+	storage.Agents[10].StrategyData = []byte(agentData)
+	storage.AgentStorages[10] = &fakes.Trades{}
+
+	streams := make(chan context.Context, 2)
+	oldKeys := newStreamingExchange(ctrl, streams)
+	newKeys := newStreamingExchange(ctrl, streams)
+
+	var actions *app.Actions
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// the keys are rotated during the first cycle
+	oldKeys.MockExchange.EXPECT().Balances(gomock.Any()).DoAndReturn(func(assets []string) ([]domain.Balance, error) {
+		_, err := actions.RotateExchangeKeys(owner, 1, []byte(`{"key":"new-secret"}`))
+		if err != nil {
+			t.Error(err)
+		}
+		return nil, errors.New("old keys")
+	})
+
+	// the first call checks the new keys, the second one is the next cycle
+	checked := false
+	newKeys.MockExchange.EXPECT().Balances(gomock.Any()).DoAndReturn(func(assets []string) ([]domain.Balance, error) {
+		if !checked {
+			checked = true
+			return []domain.Balance{}, nil
+		}
+		defer cancel()
+
+		if len(streams) != 2 {
+			t.Errorf("%d streams are started, want the old and the new one", len(streams))
+			return nil, errors.New("stop")
+		}
+
+		oldStream, newStream := <-streams, <-streams
+		if oldStream.Err() == nil {
+			t.Error("the stream of the old keys is still running")
+		}
+		if newStream.Err() != nil {
+			t.Error("the stream of the new keys is stopped")
+		}
+		return nil, errors.New("stop")
+	}).Times(2)
+
+	exchange := fakes.AppExchange{New: func(data []byte) domain.Exchange {
+		if strings.Contains(string(data), "new-secret") {
+			return newKeys
+		}
+		return oldKeys
+	}}
+	actions = app.GetAppActions(storage, exchange, loggers.ConstructorConsoleLogger{})
+	actions.DisableAgentLogs()
+	actions.SetAgentsSettings(domain.AgentsSettings{Interval: time.Millisecond, ShutdownTimeout: time.Second})
+
+	err := actions.StartAgents(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,52 @@
+package fakes
+
+import (
+	"encoding/json"
+
+	"github.com/scientistnik/invest-agents/internal/app/domain"
+)
+
+// ExchangeNumber is the only exchange number AppExchange knows.
+const ExchangeNumber = 1
+
+// AppExchange builds exchanges of number ExchangeNumber from any JSON object
+// with a key.
+type AppExchange struct {
+	// Exchange is returned for every account, when New is nil.
+	Exchange domain.Exchange
+	// New returns the exchange of the account data.
+	New func(data []byte) domain.Exchange
+}
+
+type exchangeData struct {
+	Key string `json:"key"`
+}
+
+func (e AppExchange) GetExchangeByJson(exchangeId int, data []byte) domain.Exchange {
+	var account exchangeData
+	if exchangeId != ExchangeNumber || json.Unmarshal(data, &account) != nil || account.Key == "" {
+		return nil
+	}
+
+	if e.New != nil {
+		return e.New(data)
+	}
+
+	return e.Exchange
+}
+
+func (e AppExchange) ExchangeName(exchangeId int) string {
+	if exchangeId != ExchangeNumber {
+		return ""
+	}
+
+	return "fake"
+}
+
+func (e AppExchange) MaskExchangeJson(exchangeId int, data []byte) []byte {
+	if e.GetExchangeByJson(exchangeId, data) == nil {
+		return nil
+	}
+
+	return []byte(`{"key":"***"}`)
+}
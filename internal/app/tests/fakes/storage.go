@@ -0,0 +1,448 @@
+package fakes
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/scientistnik/invest-agents/internal/app"
+	"github.com/scientistnik/invest-agents/internal/app/domain"
+)
+
+// Storage keeps users, agents, exchanges and access data in memory. Methods
+// tests don't need panic through the embedded nil interface.
+type Storage struct {
+	app.AppStorage
+
+	mu          sync.Mutex
+	Users       map[int64]*domain.User
+	Links       map[int64]app.UserLinks
+	ApiKeys     map[string]int64
+	Agents      map[int64]*domain.Agent
+	Exchanges   map[int]*app.ExchangeData
+	AgentLinks  map[int64][]int
+	Shares      []app.AgentShare
+	Invites     map[string]*app.Invite
+	UsedInvites map[string]bool
+	LinkCodes   map[string]*app.LinkCode
+	Changes     []app.AgentDataChange
+	Limits      map[[2]int64]domain.RiskLimits
+	Panics      []app.PanicEvent
+	// AgentStorages are returned by GetAgentStorage by agent id.
+	AgentStorages map[int64]interface{}
+}
+
+func NewStorage() *Storage {
+	return &Storage{
+		Users:         map[int64]*domain.User{},
+		Links:         map[int64]app.UserLinks{},
+		ApiKeys:       map[string]int64{},
+		Agents:        map[int64]*domain.Agent{},
+		Exchanges:     map[int]*app.ExchangeData{},
+		AgentLinks:    map[int64][]int{},
+		Invites:       map[string]*app.Invite{},
+		UsedInvites:   map[string]bool{},
+		LinkCodes:     map[string]*app.LinkCode{},
+		Limits:        map[[2]int64]domain.RiskLimits{},
+		AgentStorages: map[int64]interface{}{},
+	}
+}
+
+// AddUser stores the user with a Telegram link equal to its id.
+func (s *Storage) AddUser(user domain.User) domain.User {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.Users[user.Id] = &user
+	s.Links[user.Id] = app.UserLinks{Telegram: user.Id}
+	return user
+}
+
+// AddAgent stores the agent linked to the exchanges.
+func (s *Storage) AddAgent(agent domain.Agent, exchangeIds ...int) domain.Agent {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.Agents[agent.Id] = &agent
+	s.AgentLinks[agent.Id] = exchangeIds
+	return agent
+}
+
+func (s *Storage) UserFind(links app.UserLinks) (*domain.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for userId, userLinks := range s.Links {
+		if links.Telegram != 0 && userLinks.Telegram == links.Telegram || links.Email != "" && userLinks.Email == links.Email {
+			user := *s.Users[userId]
+			return &user, nil
+		}
+	}
+
+	return nil, nil
+}
+
+func (s *Storage) UserCreate(links app.UserLinks, role domain.UserRole) (*domain.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user := domain.User{Id: int64(len(s.Users) + 1), Role: role}
+	for s.Users[user.Id] != nil {
+		user.Id++
+	}
+
+	s.Users[user.Id] = &user
+	s.Links[user.Id] = links
+	result := user
+	return &result, nil
+}
+
+func (s *Storage) FindUsers() ([]domain.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	users := []domain.User{}
+	for _, user := range s.Users {
+		users = append(users, *user)
+	}
+	sort.Slice(users, func(i, j int) bool { return users[i].Id < users[j].Id })
+
+	return users, nil
+}
+
+func (s *Storage) UserSetRole(userId int64, role domain.UserRole) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.Users[userId].Role = role
+	return nil
+}
+
+func (s *Storage) UserGet(userId int64) (*domain.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, ok := s.Users[userId]
+	if !ok {
+		return nil, nil
+	}
+
+	result := *user
+	return &result, nil
+}
+
+func (s *Storage) UserFindByApiKey(apiKeyHash string) (*domain.User, error) {
+	s.mu.Lock()
+	userId, ok := s.ApiKeys[apiKeyHash]
+	s.mu.Unlock()
+
+	if !ok {
+		return nil, nil
+	}
+
+	return s.UserGet(userId)
+}
+
+func (s *Storage) UserSetApiKey(user domain.User, apiKeyHash string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for hash, userId := range s.ApiKeys {
+		if userId == user.Id {
+			delete(s.ApiKeys, hash)
+		}
+	}
+
+	s.ApiKeys[apiKeyHash] = user.Id
+	return nil
+}
+
+func (s *Storage) UserGetLinks(userId int64) (*app.UserLinks, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	links := s.Links[userId]
+	return &links, nil
+}
+
+func (s *Storage) FindAgents(filter app.AgentFilter) ([]domain.Agent, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	agents := []domain.Agent{}
+	for _, agent := range s.Agents {
+		switch {
+		case filter.Id != 0 && agent.Id != filter.Id:
+		case filter.Status != domain.ErrorAgentStatus && agent.Status != filter.Status:
+		case filter.UserId != 0 && agent.UserId != filter.UserId:
+		case filter.VisibleTo != 0 && agent.UserId != filter.VisibleTo && !s.shared(agent.Id, filter.VisibleTo):
+		case filter.ExchangeId != 0 && !containsInt(s.AgentLinks[agent.Id], filter.ExchangeId):
+		default:
+			agents = append(agents, *agent)
+		}
+	}
+	sort.Slice(agents, func(i, j int) bool { return agents[i].Id < agents[j].Id })
+
+	return agents, nil
+}
+
+func (s *Storage) shared(agentId int64, userId int64) bool {
+	for _, share := range s.Shares {
+		if share.AgentId == agentId && share.UserId == userId {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (s *Storage) AgentSetStatus(agent *domain.Agent, status domain.AgentStatus) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.Agents[agent.Id].Status = status
+	agent.Status = status
+	return nil
+}
+
+func (s *Storage) AgentUpdateData(agent *domain.Agent, data []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.Agents[agent.Id].StrategyData = data
+	agent.StrategyData = data
+	return nil
+}
+
+func (s *Storage) AddAgentDataChange(change app.AgentDataChange) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	change.Id = int64(len(s.Changes) + 1)
+	s.Changes = append(s.Changes, change)
+	return nil
+}
+
+func (s *Storage) FindAgentDataChanges(agentId int64) ([]app.AgentDataChange, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	changes := []app.AgentDataChange{}
+	for _, change := range s.Changes {
+		if change.AgentId == agentId {
+			changes = append(changes, change)
+		}
+	}
+
+	return changes, nil
+}
+
+func (s *Storage) GetAgentStorage(agent domain.Agent) interface{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.AgentStorages[agent.Id]
+}
+
+func (s *Storage) GetAgentExchanges(agentId int64) ([]app.ExchangeData, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	exchanges := []app.ExchangeData{}
+	for _, exchangeId := range s.AgentLinks[agentId] {
+		if exch, ok := s.Exchanges[exchangeId]; ok {
+			exchanges = append(exchanges, *exch)
+		}
+	}
+
+	return exchanges, nil
+}
+
+func (s *Storage) FindExchanges(filter app.ExchangeFilter) ([]app.ExchangeData, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	exchanges := []app.ExchangeData{}
+	for _, exch := range s.Exchanges {
+		if filter.UserId != 0 && exch.UserId != filter.UserId || filter.ExchangeNumber != 0 && exch.Number != filter.ExchangeNumber {
+			continue
+		}
+
+		exchanges = append(exchanges, *exch)
+	}
+	sort.Slice(exchanges, func(i, j int) bool { return exchanges[i].Id < exchanges[j].Id })
+
+	return exchanges, nil
+}
+
+func (s *Storage) AddExchange(userId int64, name string, exchangeNumber int, data []byte) (*app.ExchangeData, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	exch := app.ExchangeData{Id: len(s.Exchanges) + 1, UserId: userId, Name: name, Number: exchangeNumber, Data: data}
+	for s.Exchanges[exch.Id] != nil {
+		exch.Id++
+	}
+
+	s.Exchanges[exch.Id] = &exch
+	result := exch
+	return &result, nil
+}
+
+func (s *Storage) ExchangeSetData(exchangeId int, data []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.Exchanges[exchangeId].Data = data
+	return nil
+}
+
+func (s *Storage) ExchangeSetName(exchangeId int, name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.Exchanges[exchangeId].Name = name
+	return nil
+}
+
+func (s *Storage) RemoveExchange(exchangeId int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.Exchanges, exchangeId)
+	for agentId, exchangeIds := range s.AgentLinks {
+		kept := []int{}
+		for _, id := range exchangeIds {
+			if id != exchangeId {
+				kept = append(kept, id)
+			}
+		}
+		s.AgentLinks[agentId] = kept
+	}
+
+	return nil
+}
+
+func (s *Storage) FindAgentShares(filter app.AgentShareFilter) ([]app.AgentShare, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	shares := []app.AgentShare{}
+	for _, share := range s.Shares {
+		if filter.AgentId != 0 && share.AgentId != filter.AgentId || filter.UserId != 0 && share.UserId != filter.UserId {
+			continue
+		}
+
+		shares = append(shares, share)
+	}
+
+	return shares, nil
+}
+
+func (s *Storage) SetAgentShare(share app.AgentShare) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for index := range s.Shares {
+		if s.Shares[index].AgentId == share.AgentId && s.Shares[index].UserId == share.UserId {
+			s.Shares[index].Role = share.Role
+			return nil
+		}
+	}
+
+	s.Shares = append(s.Shares, share)
+	return nil
+}
+
+func (s *Storage) RemoveAgentShare(agentId int64, userId int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	kept := []app.AgentShare{}
+	for _, share := range s.Shares {
+		if share.AgentId != agentId || share.UserId != userId {
+			kept = append(kept, share)
+		}
+	}
+	s.Shares = kept
+
+	return nil
+}
+
+func (s *Storage) AddInvite(invite app.Invite) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.Invites[invite.Code] = &invite
+	return nil
+}
+
+func (s *Storage) UseInvite(code string, now time.Time) (*app.Invite, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	invite, ok := s.Invites[code]
+	if !ok || s.UsedInvites[code] || !invite.Expires.After(now) {
+		return nil, nil
+	}
+
+	s.UsedInvites[code] = true
+	result := *invite
+	return &result, nil
+}
+
+func (s *Storage) AddLinkCode(code app.LinkCode) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.LinkCodes[code.Code] = &code
+	return nil
+}
+
+func (s *Storage) UseLinkCode(code string, now time.Time) (*app.LinkCode, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	linkCode, ok := s.LinkCodes[code]
+	if !ok || !linkCode.Expires.After(now) {
+		return nil, nil
+	}
+
+	delete(s.LinkCodes, code)
+	result := *linkCode
+	return &result, nil
+}
+
+func (s *Storage) GetRiskLimits(userId int64, agentId int64) (*domain.RiskLimits, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	limits := s.Limits[[2]int64{userId, agentId}]
+	return &limits, nil
+}
+
+func (s *Storage) SetRiskLimits(userId int64, agentId int64, limits domain.RiskLimits) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.Limits[[2]int64{userId, agentId}] = limits
+	return nil
+}
+
+func (s *Storage) AddPanicEvent(event app.PanicEvent) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.Panics = append(s.Panics, event)
+	return nil
+}
+
+func containsInt(items []int, item int) bool {
+	for _, value := range items {
+		if value == item {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,60 @@
+package fakes
+
+import (
+	"sync"
+
+	"github.com/scientistnik/invest-agents/internal/app/domain"
+)
+
+// Trades is the storage of a simple strategy agent.
+type Trades struct {
+	mu     sync.Mutex
+	Trades []domain.SimpleTrade
+}
+
+func (s *Trades) GetTrades(filter *domain.SimpleTradeFilter) ([]domain.SimpleTrade, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	trades := []domain.SimpleTrade{}
+	for _, trade := range s.Trades {
+		for _, status := range filter.Statuses {
+			if trade.Status == status {
+				trades = append(trades, trade)
+				break
+			}
+		}
+	}
+
+	return trades, nil
+}
+
+func (s *Trades) SaveTrade(trade *domain.SimpleTrade) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for index := range s.Trades {
+		if s.Trades[index].Id == trade.Id {
+			s.Trades[index] = *trade
+			return nil
+		}
+	}
+
+	trade.Id = len(s.Trades) + 1
+	s.Trades = append(s.Trades, *trade)
+	return nil
+}
+
+// Get returns the trade by its id.
+func (s *Trades) Get(id int) domain.SimpleTrade {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, trade := range s.Trades {
+		if trade.Id == id {
+			return trade
+		}
+	}
+
+	return domain.SimpleTrade{}
+}
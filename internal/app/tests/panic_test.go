@@ -0,0 +1,155 @@
+package test_app
+
+import (
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/scientistnik/invest-agents/internal/app"
+	"github.com/scientistnik/invest-agents/internal/app/domain"
+	mock_domain "github.com/scientistnik/invest-agents/internal/app/domain/tests/mocks"
+	"github.com/scientistnik/invest-agents/internal/app/tests/fakes"
+	"github.com/scientistnik/invest-agents/internal/loggers"
+	"github.com/shopspring/decimal"
+)
+
+func TestPanicReleasesAndSellsTrades(t *testing.T) {
+	pair := domain.Pair{BaseAsset: "BTC", QuoteAsset: "USD"}
+
+	cases := []struct {
+		name string
+		sell bool
+		free decimal.Decimal
+		// sold is the amount of the panic sell, zero without one
+		sold decimal.Decimal
+	}{
+		{name: "no sell", free: decimal.NewFromInt(5)},
+		{name: "sell open trades only", sell: true, free: decimal.NewFromInt(5), sold: decimal.NewFromFloat(1.5)},
+		{name: "sell no more than free", sell: true, free: decimal.NewFromFloat(1.2), sold: decimal.NewFromFloat(1.2)},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			storage := fakes.NewStorage()
+			user := storage.AddUser(domain.User{Id: 1, Role: domain.TraderUserRole, QuoteAsset: "USD"})
+			storage.Exchanges[1] = &app.ExchangeData{Id: 1, UserId: user.Id, Name: "main", Number: fakes.ExchangeNumber, Data: []byte(`{"key":"k"}`)}
+			agent := storage.AddAgent(domain.Agent{
+				Id:           10,
+				UserId:       user.Id,
+				Status:       domain.ActiveAgentStatus,
+				StrategyId:   domain.SimpleStratedy,
+				StrategyData: []byte(`{"pairs":[{"pair":{"base_asset":"BTC","quote_asset":"USD"}}],"base_quantity":"1","max_trades":3,"profit_percent":"0.01","far_price_percent":"0.01"}`),
+			}, 1)
+
+			trades := &fakes.Trades{Trades: []domain.SimpleTrade{
+				{Id: 1, Pair: pair, Status: domain.SimpleTradeStatusBuy, Amount: decimal.NewFromInt(1), Buy: domain.SimpleTradeOrder{OrderId: "b1", ClientOrderId: "ia1"}},
+				{Id: 2, Pair: pair, Status: domain.SimpleTradeStatusSell, Amount: decimal.NewFromInt(1), Sell: domain.SimpleTradeOrder{OrderId: "s1"}},
+				{Id: 3, Pair: pair, Status: domain.SimpleTradeStatusSell, Amount: decimal.NewFromFloat(0.5)},
+				{Id: 4, Pair: pair, Status: domain.SimpleTradeStatusFinish, Amount: decimal.NewFromInt(7), Sell: domain.SimpleTradeOrder{OrderId: "s0"}},
+			}}
+			storage.AgentStorages[agent.Id] = trades
+
+			mExchange := mock_domain.NewMockExchange(ctrl)
+			// the order of nobody's trade is canceled too
+			mExchange.EXPECT().GetOpenOrders(nil).Return([]domain.Order{{Id: "b1", Pair: pair}, {Id: "s1", Pair: pair}, {Id: "manual", Pair: pair}}, nil)
+			mExchange.EXPECT().CancelOrder(gomock.Any(), pair).Return(nil).Times(3)
+			if c.sell {
+				mExchange.EXPECT().Balances([]string{"BTC"}).Return([]domain.Balance{{Asset: "BTC", Amount: c.free}}, nil)
+				mExchange.EXPECT().LastPrice(pair).Return(decimal.NewFromInt(100), nil)
+				mExchange.EXPECT().Sell(pair, gomock.Any(), gomock.Any()).DoAndReturn(func(pair domain.Pair, amount decimal.Decimal, price decimal.Decimal) (*domain.Order, error) {
+					if !amount.Equal(c.sold) || !price.Equal(decimal.NewFromInt(99)) {
+						t.Errorf("sell %s at %s, want %s at 99", amount, price, c.sold)
+					}
+					return &domain.Order{Id: "p1", Pair: pair}, nil
+				})
+			}
+
+			actions := app.GetAppActions(storage, fakes.AppExchange{Exchange: mExchange}, loggers.ConstructorConsoleLogger{})
+			report, err := actions.Panic(user, app.PanicOptions{SellPositions: c.sell})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(report.Errors) != 0 || report.CanceledOrders != 3 || len(report.HaltedAgents) != 1 {
+				t.Fatalf("unexpected report %+v", report)
+			}
+
+			if storage.Agents[agent.Id].Status != domain.DisableAgentStatus {
+				t.Fatalf("agent status %d", storage.Agents[agent.Id].Status)
+			}
+
+			buy := trades.Get(1)
+			if buy.Status != domain.SimpleTradeStatusBuy || buy.Buy.OrderId != "" || buy.Buy.ClientOrderId != "" {
+				t.Fatalf("buy trade keeps the canceled order %+v", buy)
+			}
+
+			for _, id := range []int{2, 3} {
+				trade := trades.Get(id)
+				switch {
+				case !c.sell && (trade.Status != domain.SimpleTradeStatusSell || trade.Sell.OrderId != ""):
+					t.Fatalf("trade %d keeps the canceled order %+v", id, trade)
+				case c.sell && (trade.Status != domain.SimpleTradeStatusFinish || trade.Sell.OrderId != "p1"):
+					t.Fatalf("trade %d is not finished by the panic sell %+v", id, trade)
+				}
+			}
+
+			if finished := trades.Get(4); finished.Sell.OrderId != "s0" {
+				t.Fatalf("finished trade is changed %+v", finished)
+			}
+
+			if !c.sell {
+				return
+			}
+
+			if len(report.Sold) != 1 || !report.Sold[0].Amount.Equal(c.sold) {
+				t.Fatalf("unexpected sold %+v", report.Sold)
+			}
+			if want := c.sold.Mul(decimal.NewFromInt(99)); report.SoldValue == nil || !report.SoldValue.Amount.Equal(want) {
+				t.Fatalf("sold value %+v, want %s", report.SoldValue, want)
+			}
+		})
+	}
+}
+
+func TestPanicKeepsDryRunTrades(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	pair := domain.Pair{BaseAsset: "BTC", QuoteAsset: "USD"}
+
+	storage := fakes.NewStorage()
+	user := storage.AddUser(domain.User{Id: 1, Role: domain.TraderUserRole})
+	storage.Exchanges[1] = &app.ExchangeData{Id: 1, UserId: user.Id, Name: "main", Number: fakes.ExchangeNumber, Data: []byte(`{"key":"k"}`)}
+	agent := storage.AddAgent(domain.Agent{Id: 10, UserId: user.Id, Status: domain.ActiveAgentStatus, StrategyId: domain.SimpleStratedy, DryRun: true}, 1)
+
+	trades := &fakes.Trades{Trades: []domain.SimpleTrade{
+		{Id: 1, Pair: pair, Status: domain.SimpleTradeStatusSell, Amount: decimal.NewFromInt(1)},
+		{Id: 2, Pair: pair, Status: domain.SimpleTradeStatusSell, Amount: decimal.NewFromInt(1), Sell: domain.SimpleTradeOrder{OrderId: "paper-1"}},
+	}}
+	storage.AgentStorages[agent.Id] = trades
+
+	// the real exchange sees no balance checks and no sells
+	mExchange := mock_domain.NewMockExchange(ctrl)
+	mExchange.EXPECT().GetOpenOrders(nil).Return([]domain.Order{}, nil)
+
+	actions := app.GetAppActions(storage, fakes.AppExchange{Exchange: mExchange}, loggers.ConstructorConsoleLogger{})
+	report, err := actions.Panic(user, app.PanicOptions{SellPositions: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(report.Errors) != 0 || len(report.Sold) != 0 || len(report.HaltedAgents) != 1 {
+		t.Fatalf("unexpected report %+v", report)
+	}
+
+	for _, id := range []int{1, 2} {
+		if trade := trades.Get(id); trade.Status != domain.SimpleTradeStatusSell {
+			t.Fatalf("paper trade %d is changed %+v", id, trade)
+		}
+	}
+	if trade := trades.Get(2); trade.Sell.OrderId != "paper-1" {
+		t.Fatalf("paper order is released %+v", trade)
+	}
+}
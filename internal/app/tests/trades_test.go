@@ -0,0 +1,74 @@
+package test_app
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/scientistnik/invest-agents/internal/app"
+	"github.com/scientistnik/invest-agents/internal/app/domain"
+	"github.com/scientistnik/invest-agents/internal/app/tests/fakes"
+	"github.com/scientistnik/invest-agents/internal/loggers"
+	"github.com/shopspring/decimal"
+)
+
+func TestResolveTrade(t *testing.T) {
+	cases := []struct {
+		name    string
+		status  domain.AgentStatus
+		tradeId int
+		side    domain.OrderSide
+		orderId string
+		// err is nil when the trade is resolved
+		err error
+	}{
+		{name: "placed buy", status: domain.DisableAgentStatus, tradeId: 1, side: domain.BuyOrderSide, orderId: "b1"},
+		{name: "buy never placed", status: domain.DisableAgentStatus, tradeId: 1, side: domain.BuyOrderSide},
+		{name: "placed sell", status: domain.DisableAgentStatus, tradeId: 2, side: domain.SellOrderSide, orderId: "s1"},
+		{name: "active agent", status: domain.ActiveAgentStatus, tradeId: 1, side: domain.BuyOrderSide, err: app.ValidationError{}},
+		{name: "wrong side", status: domain.DisableAgentStatus, tradeId: 1, side: domain.SellOrderSide, err: app.ValidationError{}},
+		{name: "order is known", status: domain.DisableAgentStatus, tradeId: 3, side: domain.SellOrderSide, err: app.ValidationError{}},
+		{name: "unknown trade", status: domain.DisableAgentStatus, tradeId: 9, side: domain.BuyOrderSide, err: app.ErrTradeNotFound},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			storage := fakes.NewStorage()
+			user := storage.AddUser(domain.User{Id: 1, Role: domain.TraderUserRole})
+			agent := storage.AddAgent(domain.Agent{Id: 10, UserId: user.Id, Status: c.status, StrategyId: domain.SimpleStratedy})
+
+			trades := &fakes.Trades{Trades: []domain.SimpleTrade{
+				{Id: 1, Status: domain.SimpleTradeStatusBuy, Amount: decimal.NewFromInt(1), Buy: domain.SimpleTradeOrder{ClientOrderId: "ia1"}},
+				{Id: 2, Status: domain.SimpleTradeStatusSell, Amount: decimal.NewFromInt(1), Buy: domain.SimpleTradeOrder{OrderId: "b0"}, Sell: domain.SimpleTradeOrder{ClientOrderId: "ia2"}},
+				{Id: 3, Status: domain.SimpleTradeStatusSell, Amount: decimal.NewFromInt(1), Buy: domain.SimpleTradeOrder{OrderId: "b2"}, Sell: domain.SimpleTradeOrder{OrderId: "s2"}},
+			}}
+			storage.AgentStorages[agent.Id] = trades
+
+			actions := app.GetAppActions(storage, fakes.AppExchange{}, loggers.ConstructorConsoleLogger{})
+			_, err := actions.ResolveTrade(user, agent.Id, c.tradeId, c.side, c.orderId)
+
+			var validation app.ValidationError
+			switch {
+			case c.err == nil && err != nil:
+				t.Fatal(err)
+			case errors.As(c.err, &validation) && !errors.As(err, &validation):
+				t.Fatalf("expected a validation error, got %v", err)
+			case c.err != nil && !errors.As(c.err, &validation) && !errors.Is(err, c.err):
+				t.Fatalf("expected %v, got %v", c.err, err)
+			}
+
+			if c.err != nil {
+				return
+			}
+
+			trade := trades.Get(c.tradeId)
+			order := trade.Buy
+			if c.side == domain.SellOrderSide {
+				order = trade.Sell
+			}
+
+			if order.OrderId != c.orderId || order.ClientOrderId != "" {
+				t.Fatalf("unexpected resolved order %+v", order)
+			}
+		})
+	}
+}
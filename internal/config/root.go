@@ -0,0 +1,362 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/scientistnik/invest-agents/internal/app/domain"
+	"gopkg.in/yaml.v3"
+)
+
+const defaultConfigFile = "config.yaml"
+
+type StorageConfig struct {
+	Driver string `yaml:"driver"`
+	Dsn    string `yaml:"dsn"`
+}
+
+type LoggerConfig struct {
+	Format      string           `yaml:"format"`
+	Level       string           `yaml:"level"`
+	Color       bool             `yaml:"color"`
+	File        string           `yaml:"file"`
+	MaxSizeMb   int              `yaml:"max_size_mb"`
+	MaxBackups  int              `yaml:"max_backups"`
+	AgentLevels map[int64]string `yaml:"agent_levels"`
+}
+
+type TelegramConfig struct {
+	Enabled bool   `yaml:"enabled"`
+	Token   string `yaml:"token"`
+}
+
+type HttpConfig struct {
+	Enabled bool   `yaml:"enabled"`
+	Addr    string `yaml:"addr"`
+}
+
+type MetricsConfig struct {
+	Enabled bool   `yaml:"enabled"`
+	Addr    string `yaml:"addr"`
+}
+
+type AgentsConfig struct {
+	Interval        time.Duration `yaml:"interval"`
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout"`
+	PriceMaxAge     time.Duration `yaml:"price_max_age"`
+}
+
+// ExchangesConfig limits requests of one exchange account shared by agents.
+type ExchangesConfig struct {
+	RateLimit  float64       `yaml:"rate_limit"`
+	Burst      int           `yaml:"burst"`
+	Retries    int           `yaml:"retries"`
+	RetryDelay time.Duration `yaml:"retry_delay"`
+}
+
+// AccessConfig controls who may register over Telegram.
+type AccessConfig struct {
+	// Registration is open or invite.
+	Registration string `yaml:"registration"`
+	// Allowlist are Telegram chat ids registered without an invite.
+	Allowlist []int64 `yaml:"allowlist"`
+}
+
+type FeaturesConfig struct {
+	AgentLogs         bool          `yaml:"agent_logs"`
+	AgentLogsMaxCount int           `yaml:"agent_logs_max_count"`
+	AgentLogsMaxAge   time.Duration `yaml:"agent_logs_max_age"`
+	// AgentLogsLevel is the lowest level of stored agent logs.
+	AgentLogsLevel string `yaml:"agent_logs_level"`
+	// ExchangeStreams lets agents get prices and fills pushed by exchanges.
+	ExchangeStreams bool `yaml:"exchange_streams"`
+}
+
+type Config struct {
+	Storage   StorageConfig   `yaml:"storage"`
+	Logger    LoggerConfig    `yaml:"logger"`
+	Telegram  TelegramConfig  `yaml:"telegram"`
+	Http      HttpConfig      `yaml:"http"`
+	Metrics   MetricsConfig   `yaml:"metrics"`
+	Agents    AgentsConfig    `yaml:"agents"`
+	Exchanges ExchangesConfig `yaml:"exchanges"`
+	Features  FeaturesConfig  `yaml:"features"`
+	Access    AccessConfig    `yaml:"access"`
+	// Admins are user ids given the admin role on start. The role stays in
+	// the storage, removing an id doesn't take it back.
+	Admins []int64 `yaml:"admins"`
+}
+
+func Default() Config {
+	return Config{
+		Storage:  StorageConfig{Driver: "sqlite", Dsn: "database.db"},
+		Logger:   LoggerConfig{Format: "console", Level: "debug", Color: true},
+		Telegram: TelegramConfig{Enabled: true},
+		Http:     HttpConfig{Addr: ":8080"},
+		Metrics:  MetricsConfig{Addr: ":9090"},
+		Agents:   AgentsConfig{Interval: 60 * time.Second, ShutdownTimeout: 30 * time.Second, PriceMaxAge: 10 * time.Second},
+		Exchanges: ExchangesConfig{
+			RateLimit:  domain.DefaultExchangeLimits.Rate,
+			Burst:      domain.DefaultExchangeLimits.Burst,
+			Retries:    domain.DefaultExchangeLimits.Retries,
+			RetryDelay: domain.DefaultExchangeLimits.RetryDelay,
+		},
+		Features: FeaturesConfig{AgentLogs: true, AgentLogsMaxCount: 1000, AgentLogsMaxAge: 7 * 24 * time.Hour, AgentLogsLevel: "info"},
+		Access:   AccessConfig{Registration: "invite"},
+	}
+}
+
+// Load builds the config from defaults, the YAML file, environment variables
+// and command line flags, in that order of precedence, and validates it.
+func Load(args []string) (*Config, error) {
+	cfg := Default()
+
+	flags := flag.NewFlagSet("invest-agents", flag.ContinueOnError)
+	configFile := flags.String("config", os.Getenv("CONFIG_FILE"), "path to YAML config file")
+	dsn := flags.String("db", "", "storage DSN")
+	logLevel := flags.String("log-level", "", "minimum log level")
+	logFormat := flags.String("log-format", "", "log format: console or json")
+	httpAddr := flags.String("http-addr", "", "enable HTTP API on this address")
+	metricsAddr := flags.String("metrics-addr", "", "enable metrics endpoint on this address")
+	interval := flags.Duration("interval", 0, "default agent cycle interval")
+	err := flags.Parse(args)
+	if err != nil {
+		return nil, err
+	}
+
+	err = cfg.readFile(*configFile)
+	if err != nil {
+		return nil, err
+	}
+
+	err = cfg.applyEnv()
+	if err != nil {
+		return nil, err
+	}
+
+	flags.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "db":
+			cfg.Storage.Dsn = *dsn
+		case "log-level":
+			cfg.Logger.Level = *logLevel
+		case "log-format":
+			cfg.Logger.Format = *logFormat
+		case "http-addr":
+			cfg.Http.Enabled = true
+			cfg.Http.Addr = *httpAddr
+		case "metrics-addr":
+			cfg.Metrics.Enabled = true
+			cfg.Metrics.Addr = *metricsAddr
+		case "interval":
+			cfg.Agents.Interval = *interval
+		}
+	})
+
+	err = cfg.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	return &cfg, nil
+}
+
+// LoadStorage reads the storage part of the config from the YAML file and
+// environment variables, for tools that don't run agents.
+func LoadStorage(configFile string) (*StorageConfig, error) {
+	cfg := Default()
+
+	err := cfg.readFile(configFile)
+	if err != nil {
+		return nil, err
+	}
+
+	err = cfg.applyEnv()
+	if err != nil {
+		return nil, err
+	}
+
+	problems := cfg.Storage.problems()
+	if len(problems) > 0 {
+		return nil, errors.New("bad config:\n  " + strings.Join(problems, "\n  "))
+	}
+
+	return &cfg.Storage, nil
+}
+
+// readFile decodes the YAML file, config.yaml of the working directory is read
+// when it exists and the filename is empty.
+func (c *Config) readFile(filename string) error {
+	if filename == "" {
+		if _, err := os.Stat(defaultConfigFile); err == nil {
+			filename = defaultConfigFile
+		}
+	}
+
+	if filename == "" {
+		return nil
+	}
+
+	file, err := os.Open(filename)
+	if err != nil {
+		return fmt.Errorf("read config: %w", err)
+	}
+	defer file.Close()
+
+	decoder := yaml.NewDecoder(file)
+	decoder.KnownFields(true)
+	err = decoder.Decode(c)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("parse config %s: %w", filename, err)
+	}
+
+	return nil
+}
+
+func (c *Config) applyEnv() error {
+	setString := func(name string, target *string) {
+		if value, ok := os.LookupEnv(name); ok {
+			*target = value
+		}
+	}
+
+	setString("STORAGE_DRIVER", &c.Storage.Driver)
+	setString("STORAGE_DSN", &c.Storage.Dsn)
+	setString("LOG_FORMAT", &c.Logger.Format)
+	setString("LOG_LEVEL", &c.Logger.Level)
+	setString("LOG_FILE", &c.Logger.File)
+	setString("TELEGRAM_TOKEN", &c.Telegram.Token)
+	setString("REGISTRATION", &c.Access.Registration)
+	setString("FEATURE_AGENT_LOGS_LEVEL", &c.Features.AgentLogsLevel)
+
+	if value, ok := os.LookupEnv("HTTP_ADDR"); ok {
+		c.Http.Enabled = value != ""
+		c.Http.Addr = value
+	}
+
+	if value, ok := os.LookupEnv("METRICS_ADDR"); ok {
+		c.Metrics.Enabled = value != ""
+		c.Metrics.Addr = value
+	}
+
+	if value, ok := os.LookupEnv("AGENT_INTERVAL"); ok {
+		interval, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("AGENT_INTERVAL: %w", err)
+		}
+		c.Agents.Interval = interval
+	}
+
+	for name, target := range map[string]*int{
+		"LOG_FILE_MAX_SIZE_MB": &c.Logger.MaxSizeMb,
+		"LOG_FILE_MAX_BACKUPS": &c.Logger.MaxBackups,
+	} {
+		if value, ok := os.LookupEnv(name); ok {
+			number, err := strconv.Atoi(value)
+			if err != nil {
+				return fmt.Errorf("%s: %w", name, err)
+			}
+			*target = number
+		}
+	}
+
+	for name, target := range map[string]*bool{
+		"TELEGRAM_ENABLED":         &c.Telegram.Enabled,
+		"FEATURE_AGENT_LOGS":       &c.Features.AgentLogs,
+		"FEATURE_EXCHANGE_STREAMS": &c.Features.ExchangeStreams,
+	} {
+		if value, ok := os.LookupEnv(name); ok {
+			enabled, err := strconv.ParseBool(value)
+			if err != nil {
+				return fmt.Errorf("%s: %w", name, err)
+			}
+			*target = enabled
+		}
+	}
+
+	return nil
+}
+
+func (c StorageConfig) problems() []string {
+	problems := []string{}
+
+	if c.Driver != "sqlite" {
+		problems = append(problems, fmt.Sprintf("storage.driver: unsupported driver %q", c.Driver))
+	}
+	if c.Dsn == "" {
+		problems = append(problems, "storage.dsn: is required")
+	}
+
+	return problems
+}
+
+func (c Config) Validate() error {
+	problems := c.Storage.problems()
+
+	if c.Logger.Format != "console" && c.Logger.Format != "json" {
+		problems = append(problems, fmt.Sprintf("logger.format: must be console or json, got %q", c.Logger.Format))
+	}
+	if _, err := domain.ParseLogLevel(c.Logger.Level); err != nil {
+		problems = append(problems, "logger.level: "+err.Error())
+	}
+	for agentId, level := range c.Logger.AgentLevels {
+		if _, err := domain.ParseLogLevel(level); err != nil {
+			problems = append(problems, fmt.Sprintf("logger.agent_levels.%d: %s", agentId, err.Error()))
+		}
+	}
+	if c.Logger.MaxSizeMb < 0 || c.Logger.MaxBackups < 0 {
+		problems = append(problems, "logger.max_size_mb and logger.max_backups: must not be negative")
+	}
+
+	if c.Telegram.Enabled && c.Telegram.Token == "" {
+		problems = append(problems, "telegram.token: is required when telegram is enabled")
+	}
+	if c.Http.Enabled && c.Http.Addr == "" {
+		problems = append(problems, "http.addr: is required when http is enabled")
+	}
+	if c.Metrics.Enabled && c.Metrics.Addr == "" {
+		problems = append(problems, "metrics.addr: is required when metrics are enabled")
+	}
+
+	if c.Agents.Interval < time.Second {
+		problems = append(problems, fmt.Sprintf("agents.interval: must be at least 1s, got %s", c.Agents.Interval))
+	}
+
+	if c.Agents.ShutdownTimeout < 0 {
+		problems = append(problems, "agents.shutdown_timeout: must not be negative")
+	}
+
+	if c.Agents.PriceMaxAge < 0 {
+		problems = append(problems, "agents.price_max_age: must not be negative")
+	}
+
+	if c.Exchanges.RateLimit < 0 || c.Exchanges.Retries < 0 || c.Exchanges.RetryDelay < 0 {
+		problems = append(problems, "exchanges.rate_limit, exchanges.retries and exchanges.retry_delay: must not be negative")
+	}
+	if c.Exchanges.RateLimit > 0 && c.Exchanges.Burst < 1 {
+		problems = append(problems, "exchanges.burst: must be at least 1 with a rate limit")
+	}
+
+	if c.Access.Registration != "open" && c.Access.Registration != "invite" {
+		problems = append(problems, fmt.Sprintf("access.registration: must be open or invite, got %q", c.Access.Registration))
+	}
+
+	if c.Features.AgentLogsMaxCount < 0 || c.Features.AgentLogsMaxAge < 0 {
+		problems = append(problems, "features.agent_logs_max_count and features.agent_logs_max_age: must not be negative")
+	}
+	if _, err := domain.ParseLogLevel(c.Features.AgentLogsLevel); err != nil {
+		problems = append(problems, "features.agent_logs_level: "+err.Error())
+	}
+
+	if len(problems) > 0 {
+		return errors.New("bad config:\n  " + strings.Join(problems, "\n  "))
+	}
+
+	return nil
+}
@@ -2,7 +2,9 @@ package exchanges
 
 import (
 	"encoding/json"
+	"fmt"
 	"github.com/scientistnik/invest-agents/internal/app/domain"
+	"time"
 
 	currencycom "github.com/scientistnik/currency.com"
 	"github.com/shopspring/decimal"
@@ -10,7 +12,8 @@ import (
 )
 
 type Currency struct {
-	api currencycom.RestAPI
+	api  currencycom.RestAPI
+	data CurrencyData
 }
 
 var _ domain.Exchange = (*Currency)(nil)
@@ -28,7 +31,7 @@ func GetCurrencyFromJson(data []byte) (*Currency, error) {
 		return nil, err
 	}
 
-	return &Currency{api: *currencycom.NewRestAPI(c.ApiKey, c.Secret, currencycom.DEFAULT_ENDPOINT)}, nil
+	return &Currency{api: *currencycom.NewRestAPI(c.ApiKey, c.Secret, currencycom.DEFAULT_ENDPOINT), data: c}, nil
 }
 
 func GetCurrencyToJson(cd CurrencyData) ([]byte, error) {
@@ -47,9 +50,15 @@ func convertOrderStatusStringToInt(orderStatus string) domain.OrderStatus {
 	}
 }
 
-func convertPairStringToStruct(symbol string) domain.Pair {
-	assets := strings.Split(symbol, "/")
-	return domain.Pair{BaseAsset: assets[0], QuoteAsset: assets[1]}
+// convertPairStringToStruct returns false for symbols that aren't BASE/QUOTE,
+// like the ones of leverage markets agents don't trade.
+func convertPairStringToStruct(symbol string) (domain.Pair, bool) {
+	base, quote, ok := strings.Cut(symbol, "/")
+	if !ok || base == "" || quote == "" {
+		return domain.Pair{}, false
+	}
+
+	return domain.Pair{BaseAsset: base, QuoteAsset: quote}, true
 }
 
 func convertPairStructToString(pair domain.Pair) string {
@@ -63,7 +72,7 @@ func (c Currency) Name() string {
 func (c *Currency) Balances(assets []string) ([]domain.Balance, error) {
 	res, err := c.api.AccountInfo(&currencycom.AccountRequest{ShowZeroBalance: true})
 	if err != nil {
-		return nil, err
+		return nil, currencyError(err, false)
 	}
 
 	balances := []domain.Balance{}
@@ -93,13 +102,16 @@ func (c *Currency) GetOpenOrders(filter *domain.OrderFilter) ([]domain.Order, er
 
 	currencyOrders, err := c.api.ListOfOpenOrder(&orderFilter)
 	if err != nil {
-		return nil, err
+		return nil, currencyError(err, false)
 	}
 
 	var orders []domain.Order
 	for _, currOrder := range currencyOrders {
 		orderStatus := convertOrderStatusStringToInt(currOrder.Status)
-		orderPair := convertPairStringToStruct(currOrder.Symbol)
+		orderPair, ok := convertPairStringToStruct(currOrder.Symbol)
+		if !ok {
+			continue
+		}
 
 		if filter != nil {
 			excludeOrder := true
@@ -162,9 +174,11 @@ func (c *Currency) GetOpenOrders(filter *domain.OrderFilter) ([]domain.Order, er
 		orders = append(orders, domain.Order{
 			Id:     currOrder.OrderId,
 			Status: orderStatus,
+			Side:   strings.ToLower(currOrder.Side),
 			Price:  price,
 			Amount: amount,
 			Pair:   orderPair,
+			Time:   time.UnixMilli(currOrder.Time),
 			//Commission: 0,
 		})
 	}
@@ -180,7 +194,7 @@ func (c *Currency) GetHistoryOrders(pairs []domain.Pair) ([]domain.Order, error)
 
 	trades, err := c.api.ListOfTrades(&filter)
 	if err != nil {
-		return nil, err
+		return nil, currencyError(err, false)
 	}
 
 	var orders []domain.Order
@@ -201,7 +215,10 @@ func (c *Currency) GetHistoryOrders(pairs []domain.Pair) ([]domain.Order, error)
 			}
 		}
 
-		pair := convertPairStringToStruct(trade.Symbol)
+		pair, ok := convertPairStringToStruct(trade.Symbol)
+		if !ok {
+			continue
+		}
 
 		price, err := decimal.NewFromString(trade.Price)
 		if err != nil {
@@ -218,13 +235,20 @@ func (c *Currency) GetHistoryOrders(pairs []domain.Pair) ([]domain.Order, error)
 			return nil, err
 		}
 
+		side := domain.SellOrderSide
+		if trade.IsBuyer {
+			side = domain.BuyOrderSide
+		}
+
 		orders = append(orders, domain.Order{
 			Id:         trade.OrderId,
+			Side:       side,
 			Status:     domain.FillOrderStatus,
 			Price:      price,
 			Amount:     amount,
 			Pair:       pair,
 			Commission: domain.Balance{Asset: trade.CommissionAsset, Amount: comission},
+			Time:       time.UnixMilli(trade.Time),
 		})
 	}
 
@@ -234,73 +258,46 @@ func (c *Currency) GetHistoryOrders(pairs []domain.Pair) ([]domain.Order, error)
 func (c *Currency) LastPrice(pair domain.Pair) (decimal.Decimal, error) {
 	ticker, err := currencycom.PriceChange(&currencycom.BySymbolRequest{Symbol: convertPairStructToString(pair)})
 	if err != nil {
-		return decimal.New(0, 0), err
+		return decimal.New(0, 0), currencyError(err, false)
 	}
 
 	return decimal.NewFromString(ticker.LastPrice)
 }
 
 func (c *Currency) Buy(pair domain.Pair, amount decimal.Decimal) (*domain.Order, error) {
-	quantity, _ := amount.Float64()
-
-	result, err := c.api.CreateOrder(&currencycom.CreateOrderRequest{
-		Symbol:   convertPairStructToString(pair),
-		Quantity: quantity,
-		Type:     "MARKET",
-		Side:     "BUY",
-	})
-	if err != nil {
-		return nil, err
-	}
-
-	price, err := decimal.NewFromString(result.Price)
-	if err != nil {
-		return nil, err
-	}
+	return c.PlaceOrder(domain.OrderRequest{Pair: pair, Side: domain.BuyOrderSide, Type: domain.MarketOrderType, Amount: amount})
+}
 
-	executedQty, err := decimal.NewFromString(result.ExecutedQty)
-	if err != nil {
-		return nil, err
-	}
+func (c *Currency) Sell(pair domain.Pair, amount decimal.Decimal, price decimal.Decimal) (*domain.Order, error) {
+	return c.PlaceOrder(domain.OrderRequest{Pair: pair, Side: domain.SellOrderSide, Type: domain.LimitOrderType, Amount: amount, Price: price})
+}
 
-	commission, err := c.GetOrderFee(pair, amount, price)
-	if err != nil {
-		return nil, err
+// PlaceOrder supports only good-till-canceled orders, the client sends no
+// time in force and no client order id. The id is kept in the returned order
+// only, so GetOrderByClientId can't find it later.
+func (c *Currency) PlaceOrder(request domain.OrderRequest) (*domain.Order, error) {
+	if request.TimeInForce != "" && request.TimeInForce != domain.GoodTillCanceled {
+		return nil, fmt.Errorf("currency.com: time in force %s is not supported", request.TimeInForce)
 	}
 
-	status := domain.PendingOrderStatus
-	if executedQty.Equal(amount) {
-		status = domain.FillOrderStatus
+	quantity, _ := request.Amount.Float64()
+	params := &currencycom.CreateOrderRequest{
+		Symbol:   convertPairStructToString(request.Pair),
+		Quantity: quantity,
+		Type:     strings.ToUpper(request.Type),
+		Side:     strings.ToUpper(request.Side),
 	}
 
-	order := domain.Order{
-		Id:         result.OrderId,
-		Status:     status,
-		Price:      price,
-		Amount:     executedQty,
-		Pair:       convertPairStringToStruct(result.Symbol),
-		Commission: commission,
+	if request.Type == domain.LimitOrderType {
+		params.Price, _ = request.Price.Float64()
 	}
 
-	return &order, nil
-}
-
-func (c *Currency) Sell(pair domain.Pair, amount decimal.Decimal, price decimal.Decimal) (*domain.Order, error) {
-	quantity, _ := amount.Float64()
-	floatPrice, _ := price.Float64()
-
-	result, err := c.api.CreateOrder(&currencycom.CreateOrderRequest{
-		Symbol:   convertPairStructToString(pair),
-		Quantity: quantity,
-		Type:     "LIMIT",
-		Side:     "SELL",
-		Price:    floatPrice,
-	})
+	result, err := c.api.CreateOrder(params)
 	if err != nil {
-		return nil, err
+		return nil, currencyError(err, true)
 	}
 
-	resPrice, err := decimal.NewFromString(result.Price)
+	price, err := decimal.NewFromString(result.Price)
 	if err != nil {
 		return nil, err
 	}
@@ -310,31 +307,49 @@ func (c *Currency) Sell(pair domain.Pair, amount decimal.Decimal, price decimal.
 		return nil, err
 	}
 
-	commission, err := c.GetOrderFee(pair, amount, price)
+	feePrice := price
+	if request.Type == domain.LimitOrderType {
+		feePrice = request.Price
+	}
+
+	commission, err := c.GetOrderFee(request.Pair, request.Amount, feePrice)
 	if err != nil {
 		return nil, err
 	}
 
 	status := domain.PendingOrderStatus
-	if executedQty.Equal(amount) {
+	if executedQty.Equal(request.Amount) {
 		status = domain.FillOrderStatus
 	}
 
 	order := domain.Order{
-		Id:         result.OrderId,
-		Status:     status,
-		Price:      resPrice,
-		Amount:     executedQty,
-		Pair:       convertPairStringToStruct(result.Symbol),
-		Commission: commission,
+		Id:            result.OrderId,
+		Status:        status,
+		Side:          request.Side,
+		Price:         price,
+		Amount:        executedQty,
+		Pair:          request.Pair,
+		Commission:    commission,
+		ClientOrderId: request.ClientOrderId,
 	}
 
 	return &order, nil
 }
 
+// GetOrderByClientId always fails: orders of currency.com carry no client
+// ids, and a not found order would be placed again. Strategies look for the
+// order in the open and history orders instead.
+func (c *Currency) GetOrderByClientId(pair domain.Pair, clientOrderId string) (*domain.Order, error) {
+	return nil, fmt.Errorf("currency.com: client id(%s): %w", clientOrderId, domain.ErrClientOrderIdUnsupported)
+}
+
 func (c *Currency) CancelOrder(orderId string, pair domain.Pair) error {
 	_, err := c.api.CancelOrder(&currencycom.CancelOrderRequest{OrderId: orderId, Symbol: convertPairStructToString(pair)})
-	return err
+	if err != nil {
+		return currencyError(err, true)
+	}
+
+	return nil
 }
 
 func (c *Currency) GetOrderFee(pair domain.Pair, amount decimal.Decimal, price decimal.Decimal) (domain.Balance, error) {
@@ -345,3 +360,61 @@ func (c *Currency) GetOrderFee(pair domain.Pair, amount decimal.Decimal, price d
 func (c *Currency) GetPairFee(pair domain.Pair) (domain.Balance, error) {
 	return domain.Balance{Asset: pair.QuoteAsset, Amount: decimal.NewFromFloat(0.002)}, nil
 }
+
+// currencyKlinesLimit is the most candles currency.com returns at once.
+const currencyKlinesLimit = 1000
+
+func (c *Currency) GetCandles(pair domain.Pair, interval domain.CandleInterval, since time.Time) (candles []domain.Candle, err error) {
+	if _, ok := domain.CandleIntervals[interval]; !ok {
+		return nil, fmt.Errorf("unknown candle interval %q", interval)
+	}
+
+	// the client panics on unexpected kline values
+	defer func() {
+		if r := recover(); r != nil {
+			candles, err = nil, fmt.Errorf("bad klines response: %v", r)
+		}
+	}()
+
+	lines, err := currencycom.Klines(&currencycom.KLinesRequest{
+		Symbol:    convertPairStructToString(pair),
+		Interval:  string(interval),
+		StartTime: since.UnixMilli(),
+		Limit:     currencyKlinesLimit,
+	})
+	if err != nil {
+		return nil, currencyError(err, false)
+	}
+
+	candles = []domain.Candle{}
+	for _, line := range lines {
+		// the client leaves empty lines before the real ones
+		if line.OpenTime == 0 {
+			continue
+		}
+
+		candle := domain.Candle{
+			OpenTime: time.UnixMilli(int64(line.OpenTime)).UTC(),
+			Volume:   decimal.NewFromFloat(line.Volume),
+		}
+
+		for _, value := range []struct {
+			target *decimal.Decimal
+			source string
+		}{
+			{&candle.Open, line.Open},
+			{&candle.High, line.High},
+			{&candle.Low, line.Low},
+			{&candle.Close, line.Close},
+		} {
+			*value.target, err = decimal.NewFromString(value.source)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		candles = append(candles, candle)
+	}
+
+	return candles, nil
+}
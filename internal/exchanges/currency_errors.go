@@ -0,0 +1,60 @@
+package exchanges
+
+import (
+	"encoding/json"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/scientistnik/invest-agents/internal/app/domain"
+)
+
+// the client reports failed requests only as text: "error in call, ..." for
+// network errors and "bad response from server, 400 Bad Request: {body}"
+var currencyResponseError = regexp.MustCompile(`(?s)^bad response from server, (\d{3})[^:]*: (.*)$`)
+
+type currencyErrorBody struct {
+	Code int    `json:"code"`
+	Msg  string `json:"msg"`
+}
+
+// currencyError classifies an error of the client, order tells that the
+// request placed or canceled an order. Unknown errors are returned as they are.
+func currencyError(err error, order bool) error {
+	if strings.HasPrefix(err.Error(), "error in call") {
+		return domain.ExchangeError{Kind: domain.RetryableExchangeError, Err: err}
+	}
+
+	match := currencyResponseError.FindStringSubmatch(err.Error())
+	if match == nil {
+		return err
+	}
+
+	status, _ := strconv.Atoi(match[1])
+	var body currencyErrorBody
+	_ = json.Unmarshal([]byte(match[2]), &body)
+
+	message := strings.ToLower(body.Msg)
+	switch {
+	case status == 429 || status == 418 || body.Code == -1003:
+		return domain.NewExchangeError(domain.ErrRateLimited, err)
+	// bad signature and rejected api keys
+	case status == 401 || status == 403 || body.Code == -1022 || body.Code == -2014 || body.Code == -2015:
+		return domain.NewExchangeError(domain.ErrAuth, err)
+	case status >= 500 || body.Code == -1021: // -1021 is a timestamp out of the receive window
+		return domain.ExchangeError{Kind: domain.RetryableExchangeError, Err: err}
+	case strings.Contains(message, "insufficient"):
+		return domain.NewExchangeError(domain.ErrInsufficientFunds, err)
+	// -2011 is a cancel of an unknown order, -2013 is a query of one
+	case body.Code == -2011 || body.Code == -2013:
+		return domain.NewExchangeError(domain.ErrOrderNotFound, err)
+	case body.Code == -1013 || strings.Contains(message, "notional") || strings.Contains(message, "minimum"):
+		return domain.NewExchangeError(domain.ErrMinNotional, err)
+	case strings.Contains(message, "market is closed") || strings.Contains(message, "market closed"):
+		return domain.NewExchangeError(domain.ErrMarketClosed, err)
+	case order && status >= 400 && status < 500:
+		return domain.ExchangeError{Kind: domain.InvalidOrderExchangeError, Err: err}
+	}
+
+	return err
+}
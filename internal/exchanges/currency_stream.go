@@ -0,0 +1,250 @@
+package exchanges
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/scientistnik/invest-agents/internal/app/domain"
+	"github.com/scientistnik/invest-agents/internal/websocket"
+	"github.com/shopspring/decimal"
+)
+
+var _ domain.StreamExchange = (*Currency)(nil)
+
+const (
+	currencyStreamEndpoint = "wss://api-adapter.backend.currency.com/connect"
+
+	currencyPing            = "ping"
+	currencyQuoteSubscribe  = "marketData.subscribe"
+	currencyQuoteEvent      = "internal.quote"
+	currencyOrderSubscribe  = "executionReport.subscribe"
+	currencyOrderEvent      = "internal.executionReport"
+	currencyBalanceEvent    = "internal.balance"
+	currencyErrorStatus     = "ERROR"
+	currencyStreamPing      = 30 * time.Second
+	currencyStreamTimeout   = 90 * time.Second
+	currencyStreamMinDelay  = time.Second
+	currencyStreamMaxDelay  = time.Minute
+	currencyStreamEventsCap = 64
+)
+
+type currencyRequest struct {
+	Destination   string      `json:"destination"`
+	CorrelationId int64       `json:"correlationId"`
+	Payload       interface{} `json:"payload"`
+}
+
+type currencyMessage struct {
+	Status      string          `json:"status"`
+	Destination string          `json:"destination"`
+	Payload     json.RawMessage `json:"payload"`
+}
+
+type currencyQuote struct {
+	Symbol string          `json:"symbolName"`
+	Bid    decimal.Decimal `json:"bid"`
+	Ofr    decimal.Decimal `json:"ofr"`
+}
+
+type currencyExecutionReport struct {
+	OrderId     string `json:"orderId"`
+	Symbol      string `json:"symbol"`
+	Status      string `json:"status"`
+	Price       string `json:"price"`
+	OrigQty     string `json:"origQty"`
+	ExecutedQty string `json:"executedQty"`
+}
+
+type currencyBalance struct {
+	Asset string  `json:"asset"`
+	Free  float64 `json:"free"`
+}
+
+// Stream pushes quotes of pairs, order and balance updates of the account.
+// Lost connections are restored with a growing delay and subscriptions are
+// sent again.
+func (c *Currency) Stream(ctx context.Context, pairs []domain.Pair) (<-chan domain.StreamEvent, error) {
+	events := make(chan domain.StreamEvent, currencyStreamEventsCap)
+
+	go func() {
+		defer close(events)
+
+		delay := currencyStreamMinDelay
+		for {
+			connected := time.Now()
+			err := c.stream(ctx, pairs, events)
+			if ctx.Err() != nil {
+				return
+			}
+
+			if time.Since(connected) > currencyStreamMaxDelay {
+				delay = currencyStreamMinDelay
+			}
+
+			select {
+			case events <- domain.StreamEvent{Type: domain.ErrorStreamEvent, Err: fmt.Errorf("reconnect in %s: %w", delay, err)}:
+			case <-ctx.Done():
+				return
+			}
+
+			select {
+			case <-time.After(delay):
+			case <-ctx.Done():
+				return
+			}
+
+			delay *= 2
+			if delay > currencyStreamMaxDelay {
+				delay = currencyStreamMaxDelay
+			}
+		}
+	}()
+
+	return events, nil
+}
+
+func (c *Currency) stream(ctx context.Context, pairs []domain.Pair, events chan<- domain.StreamEvent) error {
+	conn, err := websocket.Dial(ctx, currencyStreamEndpoint)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	symbols := []string{}
+	for _, pair := range pairs {
+		symbols = append(symbols, convertPairStructToString(pair))
+	}
+
+	err = conn.WriteJSON(currencyRequest{Destination: currencyQuoteSubscribe, CorrelationId: 1, Payload: map[string]interface{}{"symbols": symbols}})
+	if err != nil {
+		return err
+	}
+
+	err = conn.WriteJSON(currencyRequest{Destination: currencyOrderSubscribe, CorrelationId: 2, Payload: c.signedPayload()})
+	if err != nil {
+		return err
+	}
+
+	// ping keeps the connection alive, closing it stops the read below
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		ticker := time.NewTicker(currencyStreamPing)
+		defer ticker.Stop()
+
+		for correlationId := int64(3); ; correlationId++ {
+			select {
+			case <-ctx.Done():
+				conn.Close()
+				return
+			case <-stop:
+				return
+			case <-ticker.C:
+				conn.WriteJSON(currencyRequest{Destination: currencyPing, CorrelationId: correlationId, Payload: map[string]interface{}{}})
+			}
+		}
+	}()
+
+	for {
+		data, err := conn.ReadMessage(currencyStreamTimeout)
+		if err != nil {
+			return err
+		}
+
+		var message currencyMessage
+		err = json.Unmarshal(data, &message)
+		if err != nil {
+			continue
+		}
+
+		if message.Status == currencyErrorStatus {
+			return fmt.Errorf("%s: %s", message.Destination, message.Payload)
+		}
+
+		event, ok := parseCurrencyEvent(message)
+		if !ok {
+			continue
+		}
+
+		select {
+		case events <- event:
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// signedPayload is signed the same way as REST requests.
+func (c *Currency) signedPayload() map[string]string {
+	query := url.Values{}
+	query.Add("apiKey", c.data.ApiKey)
+	query.Add("timestamp", strconv.FormatInt(time.Now().UnixMilli(), 10))
+
+	sig := hmac.New(sha256.New, []byte(c.data.Secret))
+	sig.Write([]byte(query.Encode()))
+
+	return map[string]string{
+		"apiKey":    query.Get("apiKey"),
+		"timestamp": query.Get("timestamp"),
+		"signature": hex.EncodeToString(sig.Sum(nil)),
+	}
+}
+
+func parseCurrencyEvent(message currencyMessage) (domain.StreamEvent, bool) {
+	switch message.Destination {
+	case currencyQuoteEvent:
+		var quote currencyQuote
+		if json.Unmarshal(message.Payload, &quote) != nil {
+			return domain.StreamEvent{}, false
+		}
+
+		pair, ok := convertPairStringToStruct(quote.Symbol)
+		if !ok {
+			return domain.StreamEvent{}, false
+		}
+
+		// the bid is what a sell gets, like the last price of REST
+		return domain.StreamEvent{Type: domain.PriceStreamEvent, Pair: pair, Price: quote.Bid}, true
+	case currencyOrderEvent:
+		var report currencyExecutionReport
+		if json.Unmarshal(message.Payload, &report) != nil || report.OrderId == "" {
+			return domain.StreamEvent{}, false
+		}
+
+		pair, ok := convertPairStringToStruct(report.Symbol)
+		if !ok {
+			return domain.StreamEvent{}, false
+		}
+
+		price, _ := decimal.NewFromString(report.Price)
+		amount, _ := decimal.NewFromString(report.OrigQty)
+
+		return domain.StreamEvent{
+			Type: domain.OrderStreamEvent,
+			Pair: pair,
+			Order: domain.Order{
+				Id:     report.OrderId,
+				Status: convertOrderStatusStringToInt(report.Status),
+				Price:  price,
+				Amount: amount,
+				Pair:   pair,
+			},
+		}, true
+	case currencyBalanceEvent:
+		var balance currencyBalance
+		if json.Unmarshal(message.Payload, &balance) != nil || balance.Asset == "" {
+			return domain.StreamEvent{}, false
+		}
+
+		return domain.StreamEvent{Type: domain.BalanceStreamEvent, Balance: domain.Balance{Asset: balance.Asset, Amount: decimal.NewFromFloat(balance.Free)}}, true
+	}
+
+	return domain.StreamEvent{}, false
+}
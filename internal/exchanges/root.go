@@ -1,6 +1,8 @@
 package exchanges
 
 import (
+	"encoding/json"
+
 	"github.com/scientistnik/invest-agents/internal/app"
 	"github.com/scientistnik/invest-agents/internal/app/domain"
 )
@@ -13,7 +15,13 @@ const (
 	MaxExchangeId ExchangeId = iota
 )
 
-type AppExchange struct{}
+// AppExchange builds exchanges of accounts, with Limiter set they share its
+// rate limits. Exchanges push updates over their streams only with Streams
+// set.
+type AppExchange struct {
+	Limiter *domain.ExchangeLimiter
+	Streams bool
+}
 
 var _ app.AppExchange = (*AppExchange)(nil)
 
@@ -24,7 +32,61 @@ func (ae AppExchange) GetExchangeByJson(exchangeId int, data []byte) domain.Exch
 		if err != nil {
 			return nil
 		}
-		return exch
+
+		var exchange domain.Exchange = exch
+		if !ae.Streams {
+			exchange = restExchange{exch}
+		}
+
+		if ae.Limiter != nil {
+			return ae.Limiter.Wrap(exchange, "currency.com:"+exch.data.ApiKey)
+		}
+		return exchange
+	}
+	return nil
+}
+
+func (ae AppExchange) ExchangeName(exchangeId int) string {
+	switch exchangeId {
+	case int(CurrencyId):
+		return Currency{}.Name()
+	}
+	return ""
+}
+
+func (ae AppExchange) MaskExchangeJson(exchangeId int, data []byte) []byte {
+	switch exchangeId {
+	case int(CurrencyId):
+		var c CurrencyData
+		err := json.Unmarshal(data, &c)
+		if err != nil {
+			return nil
+		}
+
+		masked, err := GetCurrencyToJson(CurrencyData{ApiKey: maskKey(c.ApiKey, 4), Secret: maskKey(c.Secret, 0)})
+		if err != nil {
+			return nil
+		}
+		return masked
 	}
 	return nil
 }
+
+// restExchange hides the stream of the exchange, agents poll it instead.
+type restExchange struct {
+	domain.Exchange
+}
+
+// maskKey keeps up to visible last characters of long keys to tell them
+// apart, short keys are hidden completely.
+func maskKey(key string, visible int) string {
+	if key == "" {
+		return ""
+	}
+
+	if len(key) < 3*visible {
+		visible = 0
+	}
+
+	return "****" + key[len(key)-visible:]
+}
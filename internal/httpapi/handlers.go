@@ -0,0 +1,568 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/scientistnik/invest-agents/internal/app"
+	"github.com/scientistnik/invest-agents/internal/app/domain"
+	"github.com/shopspring/decimal"
+)
+
+type userResponse struct {
+	Id         int64         `json:"id"`
+	Name       string        `json:"name"`
+	Role       string        `json:"role"`
+	Timezone   string        `json:"timezone"`
+	QuoteAsset string        `json:"quote_asset"`
+	Links      app.UserLinks `json:"links"`
+}
+
+type profileRequest struct {
+	Name       string `json:"name"`
+	Timezone   string `json:"timezone"`
+	QuoteAsset string `json:"quote_asset"`
+}
+
+type emailRequest struct {
+	Email string `json:"email"`
+}
+
+type linkCodeResponse struct {
+	Code    string    `json:"code"`
+	Expires time.Time `json:"expires"`
+}
+
+type linkRequest struct {
+	Code string `json:"code"`
+}
+
+type exchangeResponse struct {
+	Id       int             `json:"id"`
+	UserId   int64           `json:"user_id"`
+	Name     string          `json:"name"`
+	Number   int             `json:"exchange_number"`
+	Exchange string          `json:"exchange,omitempty"`
+	Data     json.RawMessage `json:"data,omitempty"`
+	AgentIds []int64         `json:"agent_ids"`
+}
+
+type addExchangeRequest struct {
+	Name   string          `json:"name"`
+	Number int             `json:"exchange_number"`
+	Data   json.RawMessage `json:"data"`
+}
+
+type exchangeNameRequest struct {
+	Name string `json:"name"`
+}
+
+type exchangeKeysRequest struct {
+	Data json.RawMessage `json:"data"`
+}
+
+type parameterResponse struct {
+	Name  string      `json:"name"`
+	Type  int         `json:"type"`
+	Value interface{} `json:"value"`
+}
+
+type agentResponse struct {
+	Id           int64               `json:"id"`
+	UserId       int64               `json:"user_id"`
+	Status       string              `json:"status"`
+	StrategyId   domain.StrategyId   `json:"strategy_id"`
+	StrategyName string              `json:"strategy_name,omitempty"`
+	StrategyData json.RawMessage     `json:"strategy_data"`
+	DryRun       bool                `json:"dry_run"`
+	Exchanges    []string            `json:"exchanges,omitempty"`
+	Parameters   []parameterResponse `json:"parameters,omitempty"`
+}
+
+type schemaResponse struct {
+	Key         string           `json:"key"`
+	Name        string           `json:"name"`
+	Type        string           `json:"type"`
+	Description string           `json:"description"`
+	Required    bool             `json:"required"`
+	Min         *decimal.Decimal `json:"min,omitempty"`
+	Max         *decimal.Decimal `json:"max,omitempty"`
+	Choices     []string         `json:"choices,omitempty"`
+	Default     interface{}      `json:"default,omitempty"`
+	Items       []schemaResponse `json:"items,omitempty"`
+}
+
+type strategyResponse struct {
+	Id         domain.StrategyId `json:"id"`
+	Name       string            `json:"name"`
+	Parameters []schemaResponse  `json:"parameters"`
+}
+
+type createAgentRequest struct {
+	StrategyId   domain.StrategyId `json:"strategy_id"`
+	StrategyData json.RawMessage   `json:"strategy_data"`
+	ExchangeIds  []int             `json:"exchange_ids"`
+}
+
+type agentDataChangeResponse struct {
+	Id       int64           `json:"id"`
+	UserId   int64           `json:"user_id"`
+	Datetime time.Time       `json:"datetime"`
+	Data     json.RawMessage `json:"data"`
+}
+
+type rollbackRequest struct {
+	ChangeId int64 `json:"change_id"`
+}
+
+type agentStatusRequest struct {
+	Status string `json:"status"`
+}
+
+type agentDryRunRequest struct {
+	DryRun bool `json:"dry_run"`
+}
+
+type agentDataRequest struct {
+	StrategyData json.RawMessage `json:"strategy_data"`
+}
+
+type agentShareResponse struct {
+	UserId int64  `json:"user_id"`
+	Role   string `json:"role"`
+}
+
+type agentShareRequest struct {
+	Role string `json:"role"`
+}
+
+func (s *Server) getProfile(user domain.User) (int, interface{}, error) {
+	profile, err := s.actions.GetUserProfile(user)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	return http.StatusOK, userResponse{
+		Id:         profile.User.Id,
+		Name:       profile.User.Name,
+		Role:       domain.UserRoleNames[profile.User.Role],
+		Timezone:   profile.User.Timezone,
+		QuoteAsset: profile.User.QuoteAsset,
+		Links:      profile.Links,
+	}, nil
+}
+
+func (s *Server) updateProfile(r *http.Request, user domain.User) (int, interface{}, error) {
+	var request profileRequest
+	err := decodeBody(r, &request)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	_, err = s.actions.UserUpdateProfile(user, request.Name, request.Timezone, request.QuoteAsset)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	return s.getProfile(user)
+}
+
+func (s *Server) linkEmail(r *http.Request, user domain.User) (int, interface{}, error) {
+	var request emailRequest
+	err := decodeBody(r, &request)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	err = s.actions.UserLinkEmail(user, request.Email)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	return s.getProfile(user)
+}
+
+func (s *Server) createLinkCode(user domain.User) (int, interface{}, error) {
+	linkCode, err := s.actions.UserCreateLinkCode(user)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	return http.StatusCreated, linkCodeResponse{Code: linkCode.Code, Expires: linkCode.Expires}, nil
+}
+
+// linkByCode merges the account of the api key with the account of the code,
+// the api key keeps working for the merged account.
+func (s *Server) linkByCode(r *http.Request, user domain.User) (int, interface{}, error) {
+	var request linkRequest
+	err := decodeBody(r, &request)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	merged, err := s.actions.UserLinkByCode(user, request.Code)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	return s.getProfile(*merged)
+}
+
+func parametersResponse(params []domain.StrategyParameter) []parameterResponse {
+	result := []parameterResponse{}
+	for _, param := range params {
+		result = append(result, parameterResponse{Name: param.Name, Type: param.Type, Value: param.Value})
+	}
+
+	return result
+}
+
+func (s *Server) listStrategies() (int, interface{}, error) {
+	result := []strategyResponse{}
+	for _, info := range s.actions.GetStrategies() {
+		result = append(result, strategyResponse{Id: info.Id, Name: info.Name, Parameters: schemaResponses(info.Schema)})
+	}
+
+	return http.StatusOK, result, nil
+}
+
+func schemaResponses(schemas []domain.ParameterSchema) []schemaResponse {
+	result := []schemaResponse{}
+	for _, schema := range schemas {
+		response := schemaResponse{
+			Key:         schema.Key,
+			Name:        schema.Name,
+			Type:        domain.StrategyParameterTypeNames[schema.Type],
+			Description: schema.Description,
+			Required:    schema.Required,
+			Min:         schema.Min,
+			Max:         schema.Max,
+			Choices:     schema.Choices,
+			Default:     schema.Default,
+		}
+		if len(schema.Items) > 0 {
+			response.Items = schemaResponses(schema.Items)
+		}
+
+		result = append(result, response)
+	}
+
+	return result
+}
+
+func exchangeAccountResponse(account app.ExchangeAccount) exchangeResponse {
+	return exchangeResponse{
+		Id:       account.Id,
+		UserId:   account.UserId,
+		Name:     account.Name,
+		Number:   account.Number,
+		Exchange: account.Exchange,
+		Data:     account.MaskedData,
+		AgentIds: account.AgentIds,
+	}
+}
+
+func (s *Server) listExchanges(user domain.User) (int, interface{}, error) {
+	accounts, err := s.actions.ListExchanges(user)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	result := []exchangeResponse{}
+	for _, account := range accounts {
+		result = append(result, exchangeAccountResponse(account))
+	}
+
+	return http.StatusOK, result, nil
+}
+
+func (s *Server) addExchange(r *http.Request, user domain.User) (int, interface{}, error) {
+	var request addExchangeRequest
+	err := decodeBody(r, &request)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	if request.Number == 0 || len(request.Data) == 0 {
+		return 0, nil, badRequest("exchange_number and data are required")
+	}
+
+	exchange, err := s.actions.AddExchange(user, request.Name, request.Number, request.Data)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	return http.StatusCreated, exchangeResponse{
+		Id:       exchange.Id,
+		UserId:   exchange.UserId,
+		Name:     exchange.Name,
+		Number:   exchange.Number,
+		AgentIds: []int64{},
+	}, nil
+}
+
+func (s *Server) renameExchange(r *http.Request, user domain.User, exchangeId int) (int, interface{}, error) {
+	var request exchangeNameRequest
+	err := decodeBody(r, &request)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	account, err := s.actions.RenameExchange(user, exchangeId, request.Name)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	return http.StatusOK, exchangeAccountResponse(*account), nil
+}
+
+func (s *Server) rotateExchangeKeys(r *http.Request, user domain.User, exchangeId int) (int, interface{}, error) {
+	var request exchangeKeysRequest
+	err := decodeBody(r, &request)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	if len(request.Data) == 0 {
+		return 0, nil, badRequest("data is required")
+	}
+
+	account, err := s.actions.RotateExchangeKeys(user, exchangeId, request.Data)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	return http.StatusOK, exchangeAccountResponse(*account), nil
+}
+
+func (s *Server) removeExchange(user domain.User, exchangeId int) (int, interface{}, error) {
+	err := s.actions.RemoveExchange(user, exchangeId)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	return http.StatusOK, map[string]int{"id": exchangeId}, nil
+}
+
+func (s *Server) agentResponse(agent domain.Agent) agentResponse {
+	response := agentResponse{
+		Id:           agent.Id,
+		UserId:       agent.UserId,
+		Status:       domain.AgentStatusNames[agent.Status],
+		StrategyId:   agent.StrategyId,
+		StrategyData: agent.StrategyData,
+		DryRun:       agent.DryRun,
+	}
+
+	info := s.actions.GetAgentInfo(agent)
+	if info != nil {
+		response.StrategyName = info.StrategyName
+		response.Exchanges = info.Exchanges
+		response.Parameters = parametersResponse(info.Parameters)
+	}
+
+	return response
+}
+
+func (s *Server) listAgents(user domain.User) (int, interface{}, error) {
+	agents, err := s.actions.GetUserAgents(user)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	result := []agentResponse{}
+	for _, agent := range agents {
+		result = append(result, s.agentResponse(agent))
+	}
+
+	return http.StatusOK, result, nil
+}
+
+func (s *Server) getAgent(agent domain.Agent) (int, interface{}, error) {
+	return http.StatusOK, s.agentResponse(agent), nil
+}
+
+func (s *Server) createAgent(r *http.Request, user domain.User) (int, interface{}, error) {
+	var request createAgentRequest
+	err := decodeBody(r, &request)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	if request.StrategyId == 0 || len(request.StrategyData) == 0 {
+		return 0, nil, badRequest("strategy_id and strategy_data are required")
+	}
+
+	exchanges, err := s.actions.GetUserExchanges(user, request.ExchangeIds)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	agent, err := s.actions.AgentCreate(user, request.StrategyId, request.StrategyData, exchanges)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	return http.StatusCreated, s.agentResponse(*agent), nil
+}
+
+func (s *Server) setAgentStatus(r *http.Request, user domain.User, agent *domain.Agent) (int, interface{}, error) {
+	var request agentStatusRequest
+	err := decodeBody(r, &request)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	var status domain.AgentStatus
+	switch request.Status {
+	case domain.AgentStatusNames[domain.ActiveAgentStatus]:
+		status = domain.ActiveAgentStatus
+	case domain.AgentStatusNames[domain.DisableAgentStatus]:
+		status = domain.DisableAgentStatus
+	default:
+		return 0, nil, badRequest("status must be %q or %q", "active", "disable")
+	}
+
+	err = s.actions.AgentSetStatus(user, agent, status)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	return http.StatusOK, s.agentResponse(*agent), nil
+}
+
+func (s *Server) setAgentDryRun(r *http.Request, user domain.User, agent *domain.Agent) (int, interface{}, error) {
+	var request agentDryRunRequest
+	err := decodeBody(r, &request)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	err = s.actions.AgentSetDryRun(user, agent, request.DryRun)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	return http.StatusOK, s.agentResponse(*agent), nil
+}
+
+func (s *Server) updateAgentData(r *http.Request, user domain.User, agent *domain.Agent) (int, interface{}, error) {
+	var request agentDataRequest
+	err := decodeBody(r, &request)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	if len(request.StrategyData) == 0 {
+		return 0, nil, badRequest("strategy_data is required")
+	}
+
+	err = s.actions.AgentUpdateData(user, agent, request.StrategyData)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	return http.StatusOK, s.agentResponse(*agent), nil
+}
+
+func (s *Server) agentHistory(user domain.User, agent domain.Agent) (int, interface{}, error) {
+	changes, err := s.actions.GetAgentDataHistory(user, agent.Id)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	result := []agentDataChangeResponse{}
+	for _, change := range changes {
+		result = append(result, agentDataChangeResponse{
+			Id:       change.Id,
+			UserId:   change.UserId,
+			Datetime: change.Datetime,
+			Data:     change.Data,
+		})
+	}
+
+	return http.StatusOK, result, nil
+}
+
+func (s *Server) rollbackAgentData(r *http.Request, user domain.User, agent domain.Agent) (int, interface{}, error) {
+	var request rollbackRequest
+	err := decodeBody(r, &request)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	updated, err := s.actions.AgentRollbackData(user, agent.Id, request.ChangeId)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	return http.StatusOK, s.agentResponse(*updated), nil
+}
+
+func (s *Server) getRiskLimits(user domain.User, agentId int64) (int, interface{}, error) {
+	limits, err := s.actions.GetRiskLimits(user, agentId)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	return http.StatusOK, limits, nil
+}
+
+func (s *Server) setRiskLimits(r *http.Request, user domain.User, agentId int64) (int, interface{}, error) {
+	var limits domain.RiskLimits
+	err := decodeBody(r, &limits)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	err = s.actions.SetRiskLimits(user, agentId, limits)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	return http.StatusOK, limits, nil
+}
+
+func (s *Server) listAgentShares(user domain.User, agent domain.Agent) (int, interface{}, error) {
+	shares, err := s.actions.GetAgentShares(user, agent.Id)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	result := []agentShareResponse{}
+	for _, share := range shares {
+		result = append(result, agentShareResponse{UserId: share.UserId, Role: domain.UserRoleNames[share.Role]})
+	}
+
+	return http.StatusOK, result, nil
+}
+
+func (s *Server) shareAgent(r *http.Request, user domain.User, agent domain.Agent, userId int64) (int, interface{}, error) {
+	var request agentShareRequest
+	err := decodeBody(r, &request)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	role, err := domain.ParseUserRole(request.Role)
+	if err != nil {
+		return 0, nil, badRequest("%s", err)
+	}
+
+	err = s.actions.ShareAgent(user, agent.Id, userId, role)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	return http.StatusOK, agentShareResponse{UserId: userId, Role: domain.UserRoleNames[role]}, nil
+}
+
+func (s *Server) unshareAgent(user domain.User, agent domain.Agent, userId int64) (int, interface{}, error) {
+	err := s.actions.UnshareAgent(user, agent.Id, userId)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	return http.StatusOK, map[string]int64{"user_id": userId}, nil
+}
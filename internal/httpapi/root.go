@@ -0,0 +1,274 @@
+package httpapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/scientistnik/invest-agents/internal/app"
+	"github.com/scientistnik/invest-agents/internal/app/domain"
+)
+
+const apiPrefix = "/api/v1/"
+
+type Server struct {
+	actions *app.Actions
+}
+
+func NewServer(actions *app.Actions) *Server {
+	return &Server{actions: actions}
+}
+
+func Start(ctx context.Context, addr string, actions *app.Actions) error {
+	server := &http.Server{
+		Addr:              addr,
+		Handler:           NewServer(actions),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	go func() {
+		<-ctx.Done()
+
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(shutdownCtx)
+	}()
+
+	err := server.ListenAndServe()
+	if err != nil && err != http.ErrServerClosed {
+		return fmt.Errorf("http api error, %w", err)
+	}
+
+	return nil
+}
+
+type httpError struct {
+	status  int
+	message string
+}
+
+func (e httpError) Error() string {
+	return e.message
+}
+
+func badRequest(format string, args ...interface{}) error {
+	return httpError{status: http.StatusBadRequest, message: fmt.Sprintf(format, args...)}
+}
+
+var errNotFound = httpError{status: http.StatusNotFound, message: "not found"}
+var errMethodNotAllowed = httpError{status: http.StatusMethodNotAllowed, message: "method not allowed"}
+
+func writeJson(w http.ResponseWriter, status int, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(value)
+}
+
+// writeError hides the details of internal errors, they can carry storage or
+// exchange messages, and prints them instead.
+func writeError(w http.ResponseWriter, r *http.Request, err error) {
+	status := http.StatusInternalServerError
+
+	var hErr httpError
+	var vErr app.ValidationError
+	switch {
+	case errors.As(err, &hErr):
+		status = hErr.status
+	case errors.As(err, &vErr), errors.Is(err, app.ErrBadLinkCode):
+		status = http.StatusBadRequest
+	case errors.Is(err, app.ErrAgentNotFound), errors.Is(err, app.ErrExchangeNotFound), errors.Is(err, app.ErrAgentDataChangeNotFound), errors.Is(err, app.ErrUserNotFound), errors.Is(err, app.ErrTradeNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, app.ErrForbidden):
+		status = http.StatusForbidden
+	}
+
+	message := err.Error()
+	if status >= http.StatusInternalServerError {
+		fmt.Printf("http api error: %s %s: %s\n", r.Method, r.URL.Path, err)
+		message = http.StatusText(status)
+	}
+
+	writeJson(w, status, map[string]string{"error": message})
+}
+
+func decodeBody(r *http.Request, value interface{}) error {
+	decoder := json.NewDecoder(http.MaxBytesReader(nil, r.Body, 1<<20))
+	decoder.DisallowUnknownFields()
+
+	err := decoder.Decode(value)
+	if err != nil {
+		return badRequest("bad request body: %s", err.Error())
+	}
+
+	return nil
+}
+
+func (s *Server) authenticate(r *http.Request) (*domain.User, error) {
+	header := r.Header.Get("Authorization")
+	if !strings.HasPrefix(header, "Bearer ") {
+		return nil, httpError{status: http.StatusUnauthorized, message: "missing bearer token"}
+	}
+
+	user, err := s.actions.UserFindByApiKey(strings.TrimPrefix(header, "Bearer "))
+	if err != nil {
+		return nil, err
+	}
+
+	if user == nil {
+		return nil, httpError{status: http.StatusUnauthorized, message: "bad api key"}
+	}
+
+	return user, nil
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !strings.HasPrefix(r.URL.Path, apiPrefix) {
+		writeError(w, r, errNotFound)
+		return
+	}
+
+	user, err := s.authenticate(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	path := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, apiPrefix), "/"), "/")
+
+	status, result, err := s.route(r, *user, path)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	writeJson(w, status, result)
+}
+
+func (s *Server) route(r *http.Request, user domain.User, path []string) (int, interface{}, error) {
+	switch {
+	case len(path) == 1 && path[0] == "me":
+		switch r.Method {
+		case http.MethodGet:
+			return s.getProfile(user)
+		case http.MethodPut:
+			return s.updateProfile(r, user)
+		}
+		return 0, nil, errMethodNotAllowed
+
+	case len(path) == 2 && path[0] == "me":
+		switch {
+		case path[1] == "email" && r.Method == http.MethodPut:
+			return s.linkEmail(r, user)
+		case path[1] == "link_code" && r.Method == http.MethodPost:
+			return s.createLinkCode(user)
+		case path[1] == "link" && r.Method == http.MethodPost:
+			return s.linkByCode(r, user)
+		case path[1] == "email" || path[1] == "link_code" || path[1] == "link":
+			return 0, nil, errMethodNotAllowed
+		}
+
+	case len(path) == 1 && path[0] == "strategies":
+		if r.Method != http.MethodGet {
+			return 0, nil, errMethodNotAllowed
+		}
+		return s.listStrategies()
+
+	case len(path) == 1 && path[0] == "risk":
+		switch r.Method {
+		case http.MethodGet:
+			return s.getRiskLimits(user, 0)
+		case http.MethodPut:
+			return s.setRiskLimits(r, user, 0)
+		}
+		return 0, nil, errMethodNotAllowed
+
+	case len(path) == 1 && path[0] == "exchanges":
+		switch r.Method {
+		case http.MethodGet:
+			return s.listExchanges(user)
+		case http.MethodPost:
+			return s.addExchange(r, user)
+		}
+		return 0, nil, errMethodNotAllowed
+
+	case len(path) >= 2 && path[0] == "exchanges":
+		exchangeId, err := strconv.Atoi(path[1])
+		if err != nil {
+			return 0, nil, badRequest("bad exchange id %q", path[1])
+		}
+
+		switch {
+		case len(path) == 2 && r.Method == http.MethodDelete:
+			return s.removeExchange(user, exchangeId)
+		case len(path) == 3 && path[2] == "name" && r.Method == http.MethodPut:
+			return s.renameExchange(r, user, exchangeId)
+		case len(path) == 3 && path[2] == "keys" && r.Method == http.MethodPut:
+			return s.rotateExchangeKeys(r, user, exchangeId)
+		case len(path) == 2, len(path) == 3 && (path[2] == "name" || path[2] == "keys"):
+			return 0, nil, errMethodNotAllowed
+		}
+
+	case len(path) == 1 && path[0] == "agents":
+		switch r.Method {
+		case http.MethodGet:
+			return s.listAgents(user)
+		case http.MethodPost:
+			return s.createAgent(r, user)
+		}
+		return 0, nil, errMethodNotAllowed
+
+	case len(path) >= 2 && path[0] == "agents":
+		agentId, err := strconv.ParseInt(path[1], 10, 64)
+		if err != nil {
+			return 0, nil, badRequest("bad agent id %q", path[1])
+		}
+
+		agent, err := s.actions.GetUserAgent(user, agentId)
+		if err != nil {
+			return 0, nil, err
+		}
+
+		switch {
+		case len(path) == 2 && r.Method == http.MethodGet:
+			return s.getAgent(*agent)
+		case len(path) == 3 && path[2] == "status" && r.Method == http.MethodPut:
+			return s.setAgentStatus(r, user, agent)
+		case len(path) == 3 && path[2] == "dry_run" && r.Method == http.MethodPut:
+			return s.setAgentDryRun(r, user, agent)
+		case len(path) == 3 && path[2] == "data" && r.Method == http.MethodPut:
+			return s.updateAgentData(r, user, agent)
+		case len(path) == 3 && path[2] == "risk" && r.Method == http.MethodGet:
+			return s.getRiskLimits(user, agent.Id)
+		case len(path) == 3 && path[2] == "risk" && r.Method == http.MethodPut:
+			return s.setRiskLimits(r, user, agent.Id)
+		case len(path) == 3 && path[2] == "history" && r.Method == http.MethodGet:
+			return s.agentHistory(user, *agent)
+		case len(path) == 3 && path[2] == "rollback" && r.Method == http.MethodPost:
+			return s.rollbackAgentData(r, user, *agent)
+		case len(path) == 3 && path[2] == "shares" && r.Method == http.MethodGet:
+			return s.listAgentShares(user, *agent)
+		case len(path) == 4 && path[2] == "shares":
+			userId, err := strconv.ParseInt(path[3], 10, 64)
+			if err != nil {
+				return 0, nil, badRequest("bad user id %q", path[3])
+			}
+
+			switch r.Method {
+			case http.MethodPut:
+				return s.shareAgent(r, user, *agent, userId)
+			case http.MethodDelete:
+				return s.unshareAgent(user, *agent, userId)
+			}
+			return 0, nil, errMethodNotAllowed
+		case len(path) <= 3:
+			return 0, nil, errMethodNotAllowed
+		}
+	}
+
+	return 0, nil, errNotFound
+}
@@ -0,0 +1,137 @@
+package test_httpapi
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/scientistnik/invest-agents/internal/app"
+	"github.com/scientistnik/invest-agents/internal/app/domain"
+	"github.com/scientistnik/invest-agents/internal/app/tests/fakes"
+	"github.com/scientistnik/invest-agents/internal/exchanges"
+	"github.com/scientistnik/invest-agents/internal/httpapi"
+	"github.com/scientistnik/invest-agents/internal/loggers"
+)
+
+// brokenStorage fails to find agents the way a locked database does.
+type brokenStorage struct {
+	*fakes.Storage
+}
+
+func (s brokenStorage) FindAgents(filter app.AgentFilter) ([]domain.Agent, error) {
+	return nil, errors.New("sql: database is locked")
+}
+
+type apiCase struct {
+	name   string
+	method string
+	path   string
+	body   string
+	key    string
+	status int
+	// contains is a part of the response body
+	contains string
+}
+
+func newServer(t *testing.T, storage app.AppStorage) (*httptest.Server, *app.Actions) {
+	actions := app.GetAppActions(storage, exchanges.AppExchange{}, loggers.ConstructorConsoleLogger{})
+	server := httptest.NewServer(httpapi.NewServer(actions))
+	t.Cleanup(server.Close)
+
+	return server, actions
+}
+
+func apiKey(t *testing.T, actions *app.Actions, user domain.User) string {
+	key, err := actions.UserCreateApiKey(user)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return key
+}
+
+func runCases(t *testing.T, server *httptest.Server, cases []apiCase) {
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			request, err := http.NewRequest(c.method, server.URL+c.path, strings.NewReader(c.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if c.key != "" {
+				request.Header.Set("Authorization", "Bearer "+c.key)
+			}
+
+			response, err := http.DefaultClient.Do(request)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer response.Body.Close()
+
+			var body map[string]interface{}
+			var list []interface{}
+			raw := json.RawMessage{}
+			err = json.NewDecoder(response.Body).Decode(&raw)
+			if err != nil {
+				t.Fatalf("bad json response: %s", err)
+			}
+			if json.Unmarshal(raw, &body) != nil && json.Unmarshal(raw, &list) != nil {
+				t.Fatalf("unexpected response %s", raw)
+			}
+
+			if response.StatusCode != c.status {
+				t.Fatalf("status %d, want %d: %s", response.StatusCode, c.status, raw)
+			}
+
+			if !strings.Contains(string(raw), c.contains) {
+				t.Fatalf("response %s doesn't contain %q", raw, c.contains)
+			}
+		})
+	}
+}
+
+func TestApiAccess(t *testing.T) {
+	storage := fakes.NewStorage()
+	owner := storage.AddUser(domain.User{Id: 1, Name: "Ann", Role: domain.TraderUserRole})
+	viewer := storage.AddUser(domain.User{Id: 2, Role: domain.TraderUserRole})
+	stranger := storage.AddUser(domain.User{Id: 3, Role: domain.TraderUserRole})
+	storage.AddAgent(domain.Agent{Id: 10, UserId: owner.Id, Status: domain.DisableAgentStatus, StrategyId: domain.SimpleStratedy})
+	storage.Shares = append(storage.Shares, app.AgentShare{AgentId: 10, UserId: viewer.Id, Role: domain.ViewerUserRole})
+
+	server, actions := newServer(t, storage)
+	ownerKey := apiKey(t, actions, owner)
+	viewerKey := apiKey(t, actions, viewer)
+	strangerKey := apiKey(t, actions, stranger)
+
+	runCases(t, server, []apiCase{
+		{name: "no key", method: http.MethodGet, path: "/api/v1/me", status: http.StatusUnauthorized, contains: "missing bearer token"},
+		{name: "bad key", method: http.MethodGet, path: "/api/v1/me", key: "nope", status: http.StatusUnauthorized, contains: "bad api key"},
+		{name: "profile", method: http.MethodGet, path: "/api/v1/me", key: ownerKey, status: http.StatusOK, contains: `"name":"Ann"`},
+		{name: "unknown path", method: http.MethodGet, path: "/api/v1/nothing", key: ownerKey, status: http.StatusNotFound},
+		{name: "wrong method", method: http.MethodDelete, path: "/api/v1/me", key: ownerKey, status: http.StatusMethodNotAllowed},
+		{name: "bad body", method: http.MethodPut, path: "/api/v1/me", body: `{"color":"red"}`, key: ownerKey, status: http.StatusBadRequest},
+		{name: "bad timezone", method: http.MethodPut, path: "/api/v1/me", body: `{"timezone":"Mars/Base"}`, key: ownerKey, status: http.StatusBadRequest, contains: "unknown timezone"},
+		{name: "owner sees agent", method: http.MethodGet, path: "/api/v1/agents/10", key: ownerKey, status: http.StatusOK, contains: `"user_id":1`},
+		{name: "shared agent is visible", method: http.MethodGet, path: "/api/v1/agents/10", key: viewerKey, status: http.StatusOK},
+		{name: "viewer can't stop", method: http.MethodPut, path: "/api/v1/agents/10/status", body: `{"status":"disable"}`, key: viewerKey, status: http.StatusForbidden},
+		{name: "stranger doesn't see agent", method: http.MethodGet, path: "/api/v1/agents/10", key: strangerKey, status: http.StatusNotFound},
+		{name: "bad agent id", method: http.MethodGet, path: "/api/v1/agents/x", key: ownerKey, status: http.StatusBadRequest},
+		{name: "strategy list items", method: http.MethodGet, path: "/api/v1/strategies", key: viewerKey, status: http.StatusOK, contains: `"items":[{"key":"pair"`},
+		{name: "only owner lists shares", method: http.MethodGet, path: "/api/v1/agents/10/shares", key: viewerKey, status: http.StatusForbidden},
+	})
+}
+
+func TestApiHidesInternalErrors(t *testing.T) {
+	storage := fakes.NewStorage()
+	user := storage.AddUser(domain.User{Id: 1, Role: domain.TraderUserRole})
+
+	server, actions := newServer(t, brokenStorage{storage})
+	key := apiKey(t, actions, user)
+
+	runCases(t, server, []apiCase{
+		{name: "storage error", method: http.MethodGet, path: "/api/v1/agents", key: key, status: http.StatusInternalServerError, contains: `"error":"Internal Server Error"`},
+	})
+}
@@ -20,15 +20,19 @@ const (
 
 type ConstructorConsoleLogger struct {
 	Color bool
+	Level domain.LogLevel
 }
 
-func (ccl ConstructorConsoleLogger) New(agentId int64) domain.Logger {
-	return ConsoleLogger{agentId: agentId, color: ccl.Color}
+var _ domain.LoggerRepo = (*ConstructorConsoleLogger)(nil)
+
+func (ccl ConstructorConsoleLogger) New(labels domain.LoggerLabels) domain.Logger {
+	return ConsoleLogger{agentId: labels.AgentId, color: ccl.Color, level: ccl.Level}
 }
 
 type ConsoleLogger struct {
 	agentId int64
 	color   bool
+	level   domain.LogLevel
 }
 
 type loggerLevels = string
@@ -41,6 +45,10 @@ const (
 )
 
 func (l ConsoleLogger) formatMessage(level loggerLevels, message string) {
+	if !isLevelEnabled(l.level, level) {
+		return
+	}
+
 	datetime := time.Now().Format(time.RFC3339)
 	if l.color {
 		datetime = colorGreen + datetime + colorReset
@@ -0,0 +1,104 @@
+package loggers
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/scientistnik/invest-agents/internal/app/domain"
+)
+
+type ConstructorJsonLogger struct {
+	writer      io.Writer
+	level       domain.LogLevel
+	agentLevels map[int64]domain.LogLevel
+	mu          *sync.Mutex
+}
+
+var _ domain.LoggerRepo = (*ConstructorJsonLogger)(nil)
+
+// NewConstructorJsonLogger writes one JSON object per line to writer. Records
+// below level are dropped, agentLevels overrides the minimum level per agent.
+func NewConstructorJsonLogger(writer io.Writer, level domain.LogLevel, agentLevels map[int64]domain.LogLevel) ConstructorJsonLogger {
+	return ConstructorJsonLogger{
+		writer:      writer,
+		level:       level,
+		agentLevels: agentLevels,
+		mu:          &sync.Mutex{},
+	}
+}
+
+func (cjl ConstructorJsonLogger) New(labels domain.LoggerLabels) domain.Logger {
+	level := cjl.level
+	if agentLevel, ok := cjl.agentLevels[labels.AgentId]; ok {
+		level = agentLevel
+	}
+
+	return JsonLogger{
+		writer: cjl.writer,
+		mu:     cjl.mu,
+		level:  level,
+		labels: labels,
+	}
+}
+
+type JsonLogger struct {
+	writer io.Writer
+	mu     *sync.Mutex
+	level  domain.LogLevel
+	labels domain.LoggerLabels
+}
+
+type jsonRecord struct {
+	Time     string `json:"time"`
+	Level    string `json:"level"`
+	AgentId  int64  `json:"agent_id"`
+	Strategy string `json:"strategy,omitempty"`
+	Pair     string `json:"pair,omitempty"`
+	Message  string `json:"message"`
+}
+
+func (l JsonLogger) write(level domain.LogLevel, message string) {
+	if level < l.level {
+		return
+	}
+
+	line, err := json.Marshal(jsonRecord{
+		Time:     time.Now().UTC().Format(time.RFC3339Nano),
+		Level:    level.String(),
+		AgentId:  l.labels.AgentId,
+		Strategy: l.labels.Strategy,
+		Pair:     l.labels.Pair,
+		Message:  message,
+	})
+	if err != nil {
+		fmt.Printf("json logger error: %#v\n", err)
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	_, err = l.writer.Write(append(line, '\n'))
+	if err != nil {
+		fmt.Printf("json logger error: %#v\n", err)
+	}
+}
+
+func (l JsonLogger) Info(message string) {
+	l.write(domain.InfoLogLevel, message)
+}
+
+func (l JsonLogger) Warn(message string) {
+	l.write(domain.WarnLogLevel, message)
+}
+
+func (l JsonLogger) Error(message string) {
+	l.write(domain.ErrorLogLevel, message)
+}
+
+func (l JsonLogger) Debug(message string) {
+	l.write(domain.DebugLogLevel, message)
+}
@@ -0,0 +1,22 @@
+package loggers
+
+import "github.com/scientistnik/invest-agents/internal/app/domain"
+
+func levelFromShort(level loggerLevels) domain.LogLevel {
+	switch level {
+	case DEBUG:
+		return domain.DebugLogLevel
+	case INFO:
+		return domain.InfoLogLevel
+	case WARN:
+		return domain.WarnLogLevel
+	case ERROR:
+		return domain.ErrorLogLevel
+	}
+
+	return 0
+}
+
+func isLevelEnabled(minLevel domain.LogLevel, level loggerLevels) bool {
+	return levelFromShort(level) >= minLevel
+}
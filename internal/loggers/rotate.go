@@ -0,0 +1,135 @@
+package loggers
+
+import (
+	"fmt"
+	"os"
+	"sync"
+)
+
+// RotateFile is an io.WriteCloser that renames the file to filename.1,
+// filename.2, ... once it grows past maxSize bytes, keeping maxBackups copies.
+// A failed rotation is tried again when the file grows by another maxSize.
+type RotateFile struct {
+	filename   string
+	maxSize    int64
+	maxBackups int
+
+	mu   sync.Mutex
+	file *os.File
+	size int64
+	// limit is the size the file is rotated at.
+	limit int64
+}
+
+func OpenRotateFile(filename string, maxSize int64, maxBackups int) (*RotateFile, error) {
+	rf := &RotateFile{filename: filename, maxSize: maxSize, maxBackups: maxBackups, limit: maxSize}
+
+	err := rf.open()
+	if err != nil {
+		return nil, err
+	}
+
+	return rf, nil
+}
+
+func (rf *RotateFile) open() error {
+	file, err := os.OpenFile(rf.filename, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("open log file error: %w", err)
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("stat log file error: %w", err)
+	}
+
+	rf.file = file
+	rf.size = info.Size()
+	return nil
+}
+
+// rotate moves the file to the backups and opens a new one. The file is
+// opened again even when moving failed, so logging goes on in the old file
+// and the next rotation waits for another maxSize, retrying on every write
+// would push out the backups each time.
+func (rf *RotateFile) rotate() error {
+	err := rf.file.Close()
+	rf.file = nil
+	if err == nil {
+		err = rf.moveBackups()
+	}
+
+	openErr := rf.open()
+	if openErr != nil {
+		return openErr
+	}
+
+	rf.limit = rf.maxSize
+	if err != nil {
+		rf.limit = rf.size + rf.maxSize
+	}
+
+	return err
+}
+
+func (rf *RotateFile) moveBackups() error {
+	if rf.maxBackups == 0 {
+		err := os.Remove(rf.filename)
+		if err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("rotate log file error: %w", err)
+		}
+		return nil
+	}
+
+	for index := rf.maxBackups - 1; index > 0; index-- {
+		err := os.Rename(fmt.Sprintf("%s.%d", rf.filename, index), fmt.Sprintf("%s.%d", rf.filename, index+1))
+		if err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("rotate log backup error: %w", err)
+		}
+	}
+
+	err := os.Rename(rf.filename, rf.filename+".1")
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("rotate log file error: %w", err)
+	}
+
+	return nil
+}
+
+// Write returns the rotation error after writing p when the file could be
+// opened again.
+func (rf *RotateFile) Write(p []byte) (int, error) {
+	rf.mu.Lock()
+	defer rf.mu.Unlock()
+
+	var rotateErr error
+	if rf.file == nil {
+		rotateErr = rf.open()
+	} else if rf.maxSize > 0 && rf.size > 0 && rf.size+int64(len(p)) > rf.limit {
+		rotateErr = rf.rotate()
+	}
+
+	if rf.file == nil {
+		return 0, rotateErr
+	}
+
+	n, err := rf.file.Write(p)
+	rf.size += int64(n)
+	if err != nil {
+		return n, err
+	}
+
+	return n, rotateErr
+}
+
+func (rf *RotateFile) Close() error {
+	rf.mu.Lock()
+	defer rf.mu.Unlock()
+
+	if rf.file == nil {
+		return nil
+	}
+
+	return rf.file.Close()
+}
@@ -0,0 +1,104 @@
+package test_loggers
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/scientistnik/invest-agents/internal/loggers"
+)
+
+// readLog returns the content of the file, "" when there is none.
+func readLog(t *testing.T, filename string) string {
+	data, err := os.ReadFile(filename)
+	if err != nil && !os.IsNotExist(err) {
+		t.Fatal(err)
+	}
+
+	return string(data)
+}
+
+func TestRotateFileBySize(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "agents.log")
+
+	file, err := loggers.OpenRotateFile(filename, 10, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	for _, line := range []string{"line 111\n", "line 222\n", "line 333\n", "line 444\n"} {
+		_, err = file.Write([]byte(line))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// the first line is pushed out of the backups
+	want := map[string]string{
+		filename:        "line 444\n",
+		filename + ".1": "line 333\n",
+		filename + ".2": "line 222\n",
+		filename + ".3": "",
+	}
+	for name, content := range want {
+		if got := readLog(t, name); got != content {
+			t.Errorf("%s: %q, want %q", filepath.Base(name), got, content)
+		}
+	}
+}
+
+func TestRotateFileFailure(t *testing.T) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "agents.log")
+
+	// the file can't be renamed to a directory that isn't empty
+	err := os.MkdirAll(filepath.Join(filename+".1", "keep"), 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	file, err := loggers.OpenRotateFile(filename, 20, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	write := func(line string) error {
+		n, err := file.Write([]byte(line))
+		if n != len(line) {
+			t.Fatalf("written %d of %q: %v", n, line, err)
+		}
+		return err
+	}
+
+	for _, line := range []string{"line 111\n", "line 222\n"} {
+		if err = write(line); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err = write("line 333\n"); err == nil {
+		t.Fatal("expected a rotation error")
+	}
+
+	// no retry until the file grows by another maxSize
+	if err = write("line 444\n"); err != nil {
+		t.Fatalf("rotation is retried at once: %v", err)
+	}
+
+	err = os.RemoveAll(filename + ".1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = write("line 555\n"); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := readLog(t, filename); got != "line 555\n" {
+		t.Fatalf("log %q after the retry", got)
+	}
+	if got, want := readLog(t, filename+".1"), "line 111\nline 222\nline 333\nline 444\n"; got != want {
+		t.Fatalf("backup %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,64 @@
+package metrics
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/scientistnik/invest-agents/internal/app/domain"
+	"github.com/shopspring/decimal"
+)
+
+const namespace = "invest_agents_"
+
+type ConstructorAgentMetrics struct {
+	Registry *Registry
+}
+
+var _ domain.MetricsRepo = (*ConstructorAgentMetrics)(nil)
+
+func (cam ConstructorAgentMetrics) New(agentId int64) domain.Metrics {
+	return AgentMetrics{registry: cam.Registry, agent: Label{Name: "agent", Value: strconv.FormatInt(agentId, 10)}}
+}
+
+type AgentMetrics struct {
+	registry *Registry
+	agent    Label
+}
+
+var _ domain.Metrics = (*AgentMetrics)(nil)
+
+func (m AgentMetrics) CycleFinished(duration time.Duration, err error) {
+	m.registry.SetGauge(namespace+"cycle_duration_seconds", "Duration of the last agent cycle.", duration.Seconds(), m.agent)
+	m.registry.AddCounter(namespace+"cycles_total", "Number of finished agent cycles.", 1, m.agent)
+
+	if err != nil {
+		m.registry.AddCounter(namespace+"run_errors_total", "Number of agent cycles finished with an error.", 1, m.agent)
+		return
+	}
+
+	m.registry.SetGauge(
+		namespace+"last_success_timestamp_seconds",
+		"Unix time of the last agent cycle finished without an error.",
+		float64(time.Now().Unix()),
+		m.agent,
+	)
+}
+
+func (m AgentMetrics) ExchangeCall(exchange string, method string, duration time.Duration, err error) {
+	labels := []Label{m.agent, {Name: "exchange", Value: exchange}, {Name: "method", Value: method}}
+
+	m.registry.Observe(namespace+"exchange_request_duration_seconds", "Latency of exchange API calls.", duration.Seconds(), labels...)
+
+	if err != nil {
+		m.registry.AddCounter(namespace+"exchange_errors_total", "Number of failed exchange API calls.", 1, labels...)
+	}
+}
+
+func (m AgentMetrics) Balance(asset string, amount decimal.Decimal) {
+	value, _ := amount.Float64()
+	m.registry.SetGauge(namespace+"balance", "Free balance seen by the agent.", value, m.agent, Label{Name: "asset", Value: asset})
+}
+
+func (m AgentMetrics) Trades(status string, count int) {
+	m.registry.SetGauge(namespace+"trades", "Open agent trades by status.", float64(count), m.agent, Label{Name: "status", Value: status})
+}
@@ -0,0 +1,159 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+type metricType = string
+
+const (
+	gaugeType   metricType = "gauge"
+	counterType metricType = "counter"
+	summaryType metricType = "summary"
+)
+
+type Label struct {
+	Name  string
+	Value string
+}
+
+type sample struct {
+	labels []Label
+	value  float64
+	count  uint64
+}
+
+type family struct {
+	name    string
+	help    string
+	kind    metricType
+	samples map[string]*sample
+}
+
+// Registry keeps metric samples in memory and renders them in the Prometheus
+// text exposition format.
+type Registry struct {
+	mu       sync.Mutex
+	families map[string]*family
+}
+
+func NewRegistry() *Registry {
+	return &Registry{families: map[string]*family{}}
+}
+
+func labelsKey(labels []Label) string {
+	parts := []string{}
+	for _, label := range labels {
+		parts = append(parts, label.Name+"="+label.Value)
+	}
+
+	return strings.Join(parts, ",")
+}
+
+func (r *Registry) sample(name string, help string, kind metricType, labels []Label) *sample {
+	f, ok := r.families[name]
+	if !ok {
+		f = &family{name: name, help: help, kind: kind, samples: map[string]*sample{}}
+		r.families[name] = f
+	}
+
+	key := labelsKey(labels)
+	s, ok := f.samples[key]
+	if !ok {
+		s = &sample{labels: labels}
+		f.samples[key] = s
+	}
+
+	return s
+}
+
+func (r *Registry) SetGauge(name string, help string, value float64, labels ...Label) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.sample(name, help, gaugeType, labels).value = value
+}
+
+func (r *Registry) AddCounter(name string, help string, value float64, labels ...Label) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.sample(name, help, counterType, labels).value += value
+}
+
+func (r *Registry) Observe(name string, help string, value float64, labels ...Label) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	s := r.sample(name, help, summaryType, labels)
+	s.value += value
+	s.count++
+}
+
+func formatLabels(labels []Label) string {
+	if len(labels) == 0 {
+		return ""
+	}
+
+	parts := []string{}
+	for _, label := range labels {
+		parts = append(parts, label.Name+"="+strconv.Quote(label.Value))
+	}
+
+	return "{" + strings.Join(parts, ",") + "}"
+}
+
+func formatValue(value float64) string {
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+func (r *Registry) WriteTo(w io.Writer) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	names := []string{}
+	for name := range r.families {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	for _, name := range names {
+		f := r.families[name]
+
+		fmt.Fprintf(&b, "# HELP %s %s\n", f.name, f.help)
+		fmt.Fprintf(&b, "# TYPE %s %s\n", f.name, f.kind)
+
+		keys := []string{}
+		for key := range f.samples {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			s := f.samples[key]
+			labels := formatLabels(s.labels)
+
+			if f.kind == summaryType {
+				fmt.Fprintf(&b, "%s_sum%s %s\n", f.name, labels, formatValue(s.value))
+				fmt.Fprintf(&b, "%s_count%s %d\n", f.name, labels, s.count)
+			} else {
+				fmt.Fprintf(&b, "%s%s %s\n", f.name, labels, formatValue(s.value))
+			}
+		}
+	}
+
+	n, err := io.WriteString(w, b.String())
+	return int64(n), err
+}
+
+func (r *Registry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	r.WriteTo(w)
+}
@@ -2,6 +2,8 @@ package storage
 
 import (
 	"database/sql"
+	"time"
+
 	"github.com/scientistnik/invest-agents/internal/app"
 	"github.com/scientistnik/invest-agents/internal/app/domain"
 )
@@ -12,13 +14,50 @@ type Driver interface {
 	connect() error
 	disconnect() error
 	getDB() *sql.DB
-	userGetOrCreate(links app.UserLinks) (*domain.User, error)
+	userFind(links app.UserLinks) (*domain.User, error)
+	userCreate(links app.UserLinks, role domain.UserRole) (*domain.User, error)
+	userFindAll() ([]domain.User, error)
+	userSetRole(userId int64, role domain.UserRole) error
+	userGet(userId int64) (*domain.User, error)
+	userFindByApiKey(apiKeyHash string) (*domain.User, error)
+	userGetLinks(userId int64) (*app.UserLinks, error)
+	userSetApiKey(user domain.User, apiKeyHash string) error
+	userUpdate(user domain.User) error
+	userAddLinks(userId int64, links app.UserLinks) error
+	userMerge(sourceId int64, target domain.User) error
+	linkCodeAdd(code app.LinkCode) error
+	linkCodeUse(code string, now time.Time) (*app.LinkCode, error)
 	agentFind(filter app.AgentFilter) ([]domain.Agent, error)
 	agentCreate(agent domain.Agent) (*domain.Agent, error)
 	agentSetStatus(agent *domain.Agent, status domain.AgentStatus) error
 	agentUpdateData(agent *domain.Agent, data []byte) error
+	agentSetDryRun(agent *domain.Agent, dryRun bool) error
 	getAgentExchanges(agentId int64) ([]app.ExchangeData, error)
 	findExchanges(filter app.ExchangeFilter) ([]app.ExchangeData, error)
-	addExchange(userId int64, exchangeNumber int, data []byte) error
+	addExchange(userId int64, name string, exchangeNumber int, data []byte) (*app.ExchangeData, error)
+	exchangeSetData(exchangeId int, data []byte) error
+	exchangeSetName(exchangeId int, name string) error
+	removeExchange(exchangeId int) error
 	agentAddExchange(agent *domain.Agent, exchanges []app.ExchangeData) error
+	agentShareFind(filter app.AgentShareFilter) ([]app.AgentShare, error)
+	agentShareSet(share app.AgentShare) error
+	agentShareRemove(agentId int64, userId int64) error
+	inviteAdd(invite app.Invite) error
+	inviteUse(code string, now time.Time) (*app.Invite, error)
+	addAgentLogs(records []app.AgentLog) error
+	findAgentLogs(filter app.AgentLogFilter) ([]app.AgentLog, error)
+	trimAgentLogs(agentId int64, maxCount int, before time.Time) error
+	getRiskLimits(userId int64, agentId int64) (*domain.RiskLimits, error)
+	setRiskLimits(userId int64, agentId int64, limits domain.RiskLimits) error
+	addRiskOrder(order domain.RiskOrder) error
+	removeRiskOrder(agentId int64, orderId string) error
+	findRiskOrders(filter domain.RiskOrderFilter) ([]domain.RiskOrder, error)
+	addPanicEvent(event app.PanicEvent) error
+	findCandles(exchange string, pair domain.Pair, interval domain.CandleInterval, since time.Time) ([]domain.Candle, error)
+	candleRange(exchange string, pair domain.Pair, interval domain.CandleInterval) (time.Time, time.Time, error)
+	saveCandles(exchange string, pair domain.Pair, interval domain.CandleInterval, candles []domain.Candle) error
+	findPaperOrders(agentId int64, exchange string, statuses []domain.OrderStatus) ([]domain.Order, error)
+	savePaperOrder(agentId int64, exchange string, order domain.Order) error
+	addAgentDataChange(change app.AgentDataChange) error
+	findAgentDataChanges(agentId int64) ([]app.AgentDataChange, error)
 }
@@ -0,0 +1,13 @@
+-- +migrate Up
+CREATE TABLE IF NOT EXISTS agent_logs (
+  id INTEGER NOT NULL PRIMARY KEY,
+  agent_id INTEGER REFERENCES agents,
+  level INTEGER NOT NULL,
+  datetime VARCHAR(32),
+  message TEXT
+);
+
+CREATE INDEX IF NOT EXISTS agent_logs_agent_id ON agent_logs (agent_id, id);
+
+-- +migrate Down
+DROP TABLE agent_logs;
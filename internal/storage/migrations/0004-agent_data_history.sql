@@ -0,0 +1,16 @@
+-- +migrate Up
+CREATE TABLE IF NOT EXISTS agent_data_history (
+  id INTEGER NOT NULL PRIMARY KEY,
+  agent_id INTEGER REFERENCES agents,
+  user_id INTEGER REFERENCES users,
+  datetime VARCHAR(32),
+  data JSON
+);
+
+CREATE INDEX IF NOT EXISTS agent_data_history_agent_id ON agent_data_history (agent_id, id);
+
+INSERT INTO agent_data_history (agent_id, user_id, datetime, data)
+  SELECT id, user_id, strftime('%Y-%m-%dT%H:%M:%SZ', 'now'), strategy_data FROM agents;
+
+-- +migrate Down
+DROP TABLE agent_data_history;
@@ -0,0 +1,10 @@
+-- +migrate Up
+ALTER TABLE st_simple_trades ADD COLUMN pair VARCHAR(32);
+
+UPDATE st_simple_trades SET pair = (
+  SELECT json_extract(strategy_data, '$.pair.base_asset') || '/' || json_extract(strategy_data, '$.pair.quote_asset')
+  FROM agents WHERE agents.id = st_simple_trades.agent_id
+);
+
+-- +migrate Down
+ALTER TABLE st_simple_trades DROP COLUMN pair;
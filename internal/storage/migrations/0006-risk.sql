@@ -0,0 +1,27 @@
+-- +migrate Up
+CREATE TABLE IF NOT EXISTS risk_limits (
+  id INTEGER NOT NULL PRIMARY KEY,
+  user_id INTEGER REFERENCES users,
+  agent_id INTEGER NOT NULL,
+  data JSON,
+  UNIQUE (user_id, agent_id)
+);
+
+CREATE TABLE IF NOT EXISTS risk_orders (
+  id INTEGER NOT NULL PRIMARY KEY,
+  agent_id INTEGER REFERENCES agents,
+  user_id INTEGER REFERENCES users,
+  order_id VARCHAR(256),
+  datetime VARCHAR(32),
+  side VARCHAR(8),
+  pair VARCHAR(32),
+  amount VARCHAR(32),
+  price VARCHAR(32)
+);
+
+CREATE INDEX IF NOT EXISTS risk_orders_agent_id ON risk_orders (agent_id, datetime);
+CREATE INDEX IF NOT EXISTS risk_orders_user_id ON risk_orders (user_id);
+
+-- +migrate Down
+DROP TABLE risk_limits;
+DROP TABLE risk_orders;
@@ -0,0 +1,11 @@
+-- +migrate Up
+CREATE TABLE IF NOT EXISTS panic_events (
+  id INTEGER NOT NULL PRIMARY KEY,
+  user_id INTEGER REFERENCES users,
+  datetime VARCHAR(32),
+  global BOOLEAN NOT NULL,
+  report JSON
+);
+
+-- +migrate Down
+DROP TABLE panic_events;
@@ -0,0 +1,24 @@
+-- +migrate Up
+ALTER TABLE agents ADD COLUMN dry_run BOOLEAN NOT NULL DEFAULT 0;
+
+ALTER TABLE st_simple_trades ADD COLUMN dry_run BOOLEAN NOT NULL DEFAULT 0;
+
+CREATE TABLE IF NOT EXISTS paper_orders (
+  id INTEGER NOT NULL PRIMARY KEY,
+  agent_id INTEGER REFERENCES agents,
+  exchange VARCHAR(64),
+  order_id VARCHAR(256),
+  datetime VARCHAR(32),
+  status INTEGER NOT NULL,
+  pair VARCHAR(32),
+  amount VARCHAR(32),
+  price VARCHAR(32),
+  commission VARCHAR(32),
+  commission_asset VARCHAR(16),
+  UNIQUE (agent_id, exchange, order_id)
+);
+
+-- +migrate Down
+DROP TABLE paper_orders;
+ALTER TABLE st_simple_trades DROP COLUMN dry_run;
+ALTER TABLE agents DROP COLUMN dry_run;
@@ -0,0 +1,17 @@
+-- +migrate Up
+CREATE TABLE IF NOT EXISTS candles (
+  id INTEGER NOT NULL PRIMARY KEY,
+  exchange VARCHAR(64),
+  pair VARCHAR(32),
+  interval VARCHAR(8),
+  open_time VARCHAR(32),
+  open VARCHAR(32),
+  high VARCHAR(32),
+  low VARCHAR(32),
+  close VARCHAR(32),
+  volume VARCHAR(32),
+  UNIQUE (exchange, pair, interval, open_time)
+);
+
+-- +migrate Down
+DROP TABLE candles;
@@ -0,0 +1,8 @@
+-- +migrate Up
+ALTER TABLE paper_orders ADD COLUMN side VARCHAR(8) NOT NULL DEFAULT 'sell';
+
+ALTER TABLE paper_orders ADD COLUMN client_order_id VARCHAR(64) NOT NULL DEFAULT '';
+
+-- +migrate Down
+ALTER TABLE paper_orders DROP COLUMN client_order_id;
+ALTER TABLE paper_orders DROP COLUMN side;
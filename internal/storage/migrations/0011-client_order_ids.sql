@@ -0,0 +1,5 @@
+-- +migrate Up
+ALTER TABLE st_simple_trades ADD COLUMN buy_client_order_id VARCHAR(64) NOT NULL DEFAULT '';
+
+-- +migrate Down
+ALTER TABLE st_simple_trades DROP COLUMN buy_client_order_id;
@@ -0,0 +1,27 @@
+-- +migrate Up
+-- existing users keep trading their own agents
+ALTER TABLE users ADD COLUMN role INTEGER NOT NULL DEFAULT 2;
+
+CREATE TABLE IF NOT EXISTS agent_shares (
+  id INTEGER NOT NULL PRIMARY KEY,
+  agent_id INTEGER REFERENCES agents,
+  user_id INTEGER REFERENCES users,
+  role INTEGER NOT NULL,
+  UNIQUE (agent_id, user_id)
+);
+
+CREATE INDEX IF NOT EXISTS agent_shares_user_id ON agent_shares (user_id);
+
+CREATE TABLE IF NOT EXISTS invites (
+  id INTEGER NOT NULL PRIMARY KEY,
+  code VARCHAR(64) NOT NULL UNIQUE,
+  role INTEGER NOT NULL,
+  created_by INTEGER REFERENCES users,
+  expires VARCHAR(32),
+  used VARCHAR(32)
+);
+
+-- +migrate Down
+DROP TABLE invites;
+DROP TABLE agent_shares;
+ALTER TABLE users DROP COLUMN role;
@@ -0,0 +1,38 @@
+-- +migrate Up
+ALTER TABLE users ADD COLUMN name VARCHAR(64) NOT NULL DEFAULT '';
+ALTER TABLE users ADD COLUMN timezone VARCHAR(64) NOT NULL DEFAULT '';
+ALTER TABLE users ADD COLUMN quote_asset VARCHAR(16) NOT NULL DEFAULT '';
+
+CREATE TABLE IF NOT EXISTS user_identities (
+  id INTEGER NOT NULL PRIMARY KEY,
+  user_id INTEGER REFERENCES users,
+  kind VARCHAR(16) NOT NULL,
+  value VARCHAR(256) NOT NULL,
+  UNIQUE (kind, value)
+);
+
+CREATE INDEX IF NOT EXISTS user_identities_user_id ON user_identities (user_id);
+
+-- links become identities, the column stays for the down migration only
+INSERT OR IGNORE INTO user_identities (user_id, kind, value)
+  SELECT id, 'telegram', CAST(json_extract(links, '$.telegram') AS TEXT) FROM users
+  WHERE json_extract(links, '$.telegram') IS NOT NULL and json_extract(links, '$.telegram') != 0;
+
+INSERT OR IGNORE INTO user_identities (user_id, kind, value)
+  SELECT id, 'api_key', json_extract(links, '$.api_key') FROM users
+  WHERE json_extract(links, '$.api_key') IS NOT NULL;
+
+CREATE TABLE IF NOT EXISTS link_codes (
+  id INTEGER NOT NULL PRIMARY KEY,
+  code VARCHAR(64) NOT NULL UNIQUE,
+  user_id INTEGER REFERENCES users,
+  expires VARCHAR(32),
+  used VARCHAR(32)
+);
+
+-- +migrate Down
+DROP TABLE link_codes;
+DROP TABLE user_identities;
+ALTER TABLE users DROP COLUMN quote_asset;
+ALTER TABLE users DROP COLUMN timezone;
+ALTER TABLE users DROP COLUMN name;
@@ -0,0 +1,8 @@
+-- +migrate Up
+-- accounts added before names were kept get one to be told apart
+UPDATE exchanges SET name = 'account ' || id WHERE name IS NULL OR name = '';
+
+CREATE UNIQUE INDEX IF NOT EXISTS exchanges_user_id_name ON exchanges (user_id, name);
+
+-- +migrate Down
+DROP INDEX exchanges_user_id_name;
@@ -0,0 +1,56 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"sync"
+
+	"github.com/scientistnik/invest-agents/internal/app/domain"
+
+	migrate "github.com/rubenv/sql-migrate"
+)
+
+// StrategyStorage creates the storage passed to Strategy.Run. Migrations are
+// applied together with the core ones, their ids must be unique.
+type StrategyStorage struct {
+	Factory    func(agent domain.Agent, db *sql.DB) interface{}
+	Migrations []*migrate.Migration
+}
+
+var strategyStoragesMu sync.RWMutex
+var strategyStorages = map[domain.StrategyId]StrategyStorage{}
+
+func RegisterStrategyStorage(id domain.StrategyId, storage StrategyStorage) {
+	strategyStoragesMu.Lock()
+	defer strategyStoragesMu.Unlock()
+
+	if _, ok := strategyStorages[id]; ok {
+		panic(fmt.Sprintf("storage for strategy id %d is already registered", id))
+	}
+
+	strategyStorages[id] = storage
+}
+
+func getStrategyStorage(id domain.StrategyId) (StrategyStorage, bool) {
+	strategyStoragesMu.RLock()
+	defer strategyStoragesMu.RUnlock()
+
+	storage, ok := strategyStorages[id]
+	return storage, ok
+}
+
+func getMigrationSource() (migrate.MigrationSource, error) {
+	all, err := migrations.FindMigrations()
+	if err != nil {
+		return nil, err
+	}
+
+	strategyStoragesMu.RLock()
+	defer strategyStoragesMu.RUnlock()
+
+	for _, storage := range strategyStorages {
+		all = append(all, storage.Migrations...)
+	}
+
+	return &migrate.MemoryMigrationSource{Migrations: all}, nil
+}
@@ -1,6 +1,8 @@
 package storage
 
 import (
+	"time"
+
 	"github.com/scientistnik/invest-agents/internal/app"
 	"github.com/scientistnik/invest-agents/internal/app/domain"
 
@@ -26,8 +28,52 @@ func (as AppStorage) Disconnect() error {
 	return as.driver.disconnect()
 }
 
-func (as AppStorage) UserGetOrCreate(links app.UserLinks) (*domain.User, error) {
-	return as.driver.userGetOrCreate(links)
+func (as AppStorage) UserFind(links app.UserLinks) (*domain.User, error) {
+	return as.driver.userFind(links)
+}
+
+func (as AppStorage) UserCreate(links app.UserLinks, role domain.UserRole) (*domain.User, error) {
+	return as.driver.userCreate(links, role)
+}
+
+func (as AppStorage) FindUsers() ([]domain.User, error) {
+	return as.driver.userFindAll()
+}
+
+func (as AppStorage) UserSetRole(userId int64, role domain.UserRole) error {
+	return as.driver.userSetRole(userId, role)
+}
+
+func (as AppStorage) UserGet(userId int64) (*domain.User, error) {
+	return as.driver.userGet(userId)
+}
+
+func (as AppStorage) UserFindByApiKey(apiKeyHash string) (*domain.User, error) {
+	return as.driver.userFindByApiKey(apiKeyHash)
+}
+
+func (as AppStorage) UserSetApiKey(user domain.User, apiKeyHash string) error {
+	return as.driver.userSetApiKey(user, apiKeyHash)
+}
+
+func (as AppStorage) UserUpdate(user domain.User) error {
+	return as.driver.userUpdate(user)
+}
+
+func (as AppStorage) UserAddLinks(userId int64, links app.UserLinks) error {
+	return as.driver.userAddLinks(userId, links)
+}
+
+func (as AppStorage) UserMerge(sourceId int64, target domain.User) error {
+	return as.driver.userMerge(sourceId, target)
+}
+
+func (as AppStorage) AddLinkCode(code app.LinkCode) error {
+	return as.driver.linkCodeAdd(code)
+}
+
+func (as AppStorage) UseLinkCode(code string, now time.Time) (*app.LinkCode, error) {
+	return as.driver.linkCodeUse(code, now)
 }
 
 func (as AppStorage) FindAgents(filter app.AgentFilter) ([]domain.Agent, error) {
@@ -44,12 +90,12 @@ func (as AppStorage) AgentSave(agent domain.Agent) (*domain.Agent, error) {
 }
 
 func (as AppStorage) GetAgentStorage(agent domain.Agent) interface{} {
-	switch agent.StrategyId {
-	case domain.SimpleStratedy:
-		return SimpleStorage{agent: agent, db: as.driver.getDB()}
+	storage, ok := getStrategyStorage(agent.StrategyId)
+	if !ok {
+		return nil
 	}
 
-	return nil
+	return storage.Factory(agent, as.driver.getDB())
 }
 
 func (as AppStorage) AgentSetStatus(agent *domain.Agent, status domain.AgentStatus) error {
@@ -60,6 +106,10 @@ func (as AppStorage) AgentUpdateData(agent *domain.Agent, data []byte) error {
 	return as.driver.agentUpdateData(agent, data)
 }
 
+func (as AppStorage) AgentSetDryRun(agent *domain.Agent, dryRun bool) error {
+	return as.driver.agentSetDryRun(agent, dryRun)
+}
+
 func (as AppStorage) GetAgentExchanges(agentId int64) ([]app.ExchangeData, error) {
 	return as.driver.getAgentExchanges(agentId)
 }
@@ -68,10 +118,110 @@ func (as AppStorage) FindExchanges(filter app.ExchangeFilter) ([]app.ExchangeDat
 	return as.driver.findExchanges(filter)
 }
 
-func (as AppStorage) AddExchange(userId int64, exchangeNumber int, data []byte) error {
-	return as.driver.addExchange(userId, exchangeNumber, data)
+func (as AppStorage) AddExchange(userId int64, name string, exchangeNumber int, data []byte) (*app.ExchangeData, error) {
+	return as.driver.addExchange(userId, name, exchangeNumber, data)
+}
+
+func (as AppStorage) ExchangeSetData(exchangeId int, data []byte) error {
+	return as.driver.exchangeSetData(exchangeId, data)
+}
+
+func (as AppStorage) ExchangeSetName(exchangeId int, name string) error {
+	return as.driver.exchangeSetName(exchangeId, name)
+}
+
+func (as AppStorage) RemoveExchange(exchangeId int) error {
+	return as.driver.removeExchange(exchangeId)
 }
 
 func (as AppStorage) AgentAddExchange(agent *domain.Agent, exchanges []app.ExchangeData) error {
 	return as.driver.agentAddExchange(agent, exchanges)
 }
+
+func (as AppStorage) FindAgentShares(filter app.AgentShareFilter) ([]app.AgentShare, error) {
+	return as.driver.agentShareFind(filter)
+}
+
+func (as AppStorage) SetAgentShare(share app.AgentShare) error {
+	return as.driver.agentShareSet(share)
+}
+
+func (as AppStorage) RemoveAgentShare(agentId int64, userId int64) error {
+	return as.driver.agentShareRemove(agentId, userId)
+}
+
+func (as AppStorage) AddInvite(invite app.Invite) error {
+	return as.driver.inviteAdd(invite)
+}
+
+func (as AppStorage) UseInvite(code string, now time.Time) (*app.Invite, error) {
+	return as.driver.inviteUse(code, now)
+}
+
+func (as AppStorage) AddAgentLogs(records []app.AgentLog) error {
+	return as.driver.addAgentLogs(records)
+}
+
+func (as AppStorage) FindAgentLogs(filter app.AgentLogFilter) ([]app.AgentLog, error) {
+	return as.driver.findAgentLogs(filter)
+}
+
+func (as AppStorage) TrimAgentLogs(agentId int64, maxCount int, before time.Time) error {
+	return as.driver.trimAgentLogs(agentId, maxCount, before)
+}
+
+func (as AppStorage) AddAgentDataChange(change app.AgentDataChange) error {
+	return as.driver.addAgentDataChange(change)
+}
+
+func (as AppStorage) FindAgentDataChanges(agentId int64) ([]app.AgentDataChange, error) {
+	return as.driver.findAgentDataChanges(agentId)
+}
+
+func (as AppStorage) UserGetLinks(userId int64) (*app.UserLinks, error) {
+	return as.driver.userGetLinks(userId)
+}
+
+func (as AppStorage) GetRiskLimits(userId int64, agentId int64) (*domain.RiskLimits, error) {
+	return as.driver.getRiskLimits(userId, agentId)
+}
+
+func (as AppStorage) SetRiskLimits(userId int64, agentId int64, limits domain.RiskLimits) error {
+	return as.driver.setRiskLimits(userId, agentId, limits)
+}
+
+func (as AppStorage) AddRiskOrder(order domain.RiskOrder) error {
+	return as.driver.addRiskOrder(order)
+}
+
+func (as AppStorage) RemoveRiskOrder(agentId int64, orderId string) error {
+	return as.driver.removeRiskOrder(agentId, orderId)
+}
+
+func (as AppStorage) FindRiskOrders(filter domain.RiskOrderFilter) ([]domain.RiskOrder, error) {
+	return as.driver.findRiskOrders(filter)
+}
+
+func (as AppStorage) AddPanicEvent(event app.PanicEvent) error {
+	return as.driver.addPanicEvent(event)
+}
+
+func (as AppStorage) FindPaperOrders(agentId int64, exchange string, statuses []domain.OrderStatus) ([]domain.Order, error) {
+	return as.driver.findPaperOrders(agentId, exchange, statuses)
+}
+
+func (as AppStorage) SavePaperOrder(agentId int64, exchange string, order domain.Order) error {
+	return as.driver.savePaperOrder(agentId, exchange, order)
+}
+
+func (as AppStorage) FindCandles(exchange string, pair domain.Pair, interval domain.CandleInterval, since time.Time) ([]domain.Candle, error) {
+	return as.driver.findCandles(exchange, pair, interval, since)
+}
+
+func (as AppStorage) CandleRange(exchange string, pair domain.Pair, interval domain.CandleInterval) (time.Time, time.Time, error) {
+	return as.driver.candleRange(exchange, pair, interval)
+}
+
+func (as AppStorage) SaveCandles(exchange string, pair domain.Pair, interval domain.CandleInterval, candles []domain.Candle) error {
+	return as.driver.saveCandles(exchange, pair, interval, candles)
+}
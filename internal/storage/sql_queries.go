@@ -2,11 +2,16 @@ package storage
 
 const UserInsertQuery = "INSERT INTO users (id) values (1)"
 
-const BaseSelectAgensQuery = "SELECT id, user_id, status, strategy_number, strategy_data FROM agents"
+const BaseSelectUsersQuery = "SELECT id, role, name, timezone, quote_asset FROM users"
+
+const BaseSelectAgensQuery = "SELECT id, user_id, status, strategy_number, strategy_data, dry_run FROM agents"
 
 const SelectAgentExchangesQuery = `
 SELECT 
-	ue.exchange_number as id,
+	ue.id as id,
+	ue.user_id as user_id,
+	ue.name as name,
+	ue.exchange_number as number,
 	ue.data as data
 FROM agents as a 
 JOIN agent_exchange as ae 
@@ -16,4 +21,4 @@ JOIN exchanges as ue
 WHERE a.id = ?
 `
 
-const SelectUserExchangesQuery = "SELECT id, data from exchanges"
+const SelectUserExchangesQuery = "SELECT id, user_id, name, exchange_number, data from exchanges"
@@ -8,6 +8,9 @@ import (
 	"github.com/scientistnik/invest-agents/internal/app"
 	"github.com/scientistnik/invest-agents/internal/app/domain"
 	"log"
+	"strconv"
+	"strings"
+	"time"
 
 	_ "github.com/mattn/go-sqlite3"
 	migrate "github.com/rubenv/sql-migrate"
@@ -52,7 +55,12 @@ func (s SqliteDriver) migrateRollUp() error {
 	}
 	defer db.Close()
 
-	n, err := migrate.Exec(db, "sqlite3", migrations, migrate.Up)
+	source, err := getMigrationSource()
+	if err != nil {
+		return err
+	}
+
+	n, err := migrate.Exec(db, "sqlite3", source, migrate.Up)
 	if err != nil {
 		return err
 	}
@@ -72,48 +80,335 @@ func (s SqliteDriver) getDB() *sql.DB {
 	return s.db
 }
 
-func (s SqliteDriver) userGetOrCreate(links app.UserLinks) (*domain.User, error) {
-	rows, err := s.db.Query("SELECT id from users where json_extract(links, '$.telegram') = ?", links.Telegram)
+// Kinds of user identities, a user has any number of them.
+const (
+	telegramIdentity = "telegram"
+	emailIdentity    = "email"
+	// apiKeyIdentity values are hashes of HTTP API keys.
+	apiKeyIdentity = "api_key"
+)
+
+// linkIdentities returns kinds and values of the set links.
+func linkIdentities(links app.UserLinks) [][2]string {
+	identities := [][2]string{}
+	if links.Telegram != 0 {
+		identities = append(identities, [2]string{telegramIdentity, strconv.FormatInt(links.Telegram, 10)})
+	}
+
+	if links.Email != "" {
+		identities = append(identities, [2]string{emailIdentity, links.Email})
+	}
+
+	return identities
+}
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanUser(row rowScanner) (*domain.User, error) {
+	user := domain.User{}
+	err := row.Scan(&user.Id, &user.Role, &user.Name, &user.Timezone, &user.QuoteAsset)
+	if err != nil {
+		return nil, err
+	}
+
+	return &user, nil
+}
+
+// userFindIdentity returns the user with the identity, nil when there is none.
+func (s SqliteDriver) userFindIdentity(kind string, value string) (*domain.User, error) {
+	user, err := scanUser(s.db.QueryRow(
+		BaseSelectUsersQuery+" where id=(SELECT user_id FROM user_identities WHERE kind=? and value=?)",
+		kind,
+		value,
+	))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error in userFindIdentity (scan row): %w", err)
+	}
+
+	return user, nil
+}
+
+func (s SqliteDriver) userFind(links app.UserLinks) (*domain.User, error) {
+	var found *domain.User
+	for _, identity := range linkIdentities(links) {
+		user, err := s.userFindIdentity(identity[0], identity[1])
+		if err != nil {
+			return nil, err
+		}
+
+		if user == nil {
+			continue
+		}
+
+		if found != nil && found.Id != user.Id {
+			return nil, errors.New("links belong to more than one User")
+		}
+
+		found = user
+	}
+
+	return found, nil
+}
+
+func (s SqliteDriver) userCreate(links app.UserLinks, role domain.UserRole) (*domain.User, error) {
+	tx, err := s.db.Begin()
 	if err != nil {
 		return nil, err
 	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec("INSERT INTO users (links, role) values ('{}',?)", role)
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, identity := range linkIdentities(links) {
+		_, err = tx.Exec("INSERT INTO user_identities (user_id, kind, value) values (?,?,?)", id, identity[0], identity[1])
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &domain.User{Id: id, Role: role}, tx.Commit()
+}
+
+func (s SqliteDriver) userFindAll() ([]domain.User, error) {
+	rows, err := s.db.Query(BaseSelectUsersQuery + " ORDER BY id")
+	if err != nil {
+		return nil, fmt.Errorf("error in userFindAll (query): %w", err)
+	}
 	defer rows.Close()
 
-	user := domain.User{}
-	userCount := 0
+	users := []domain.User{}
 	for rows.Next() {
-		if userCount != 0 {
-			return nil, errors.New("found more than one User")
+		user, err := scanUser(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error in userFindAll (scan row): %w", err)
 		}
 
-		err = rows.Scan(&user.Id)
+		users = append(users, *user)
+	}
+
+	return users, nil
+}
+
+func (s SqliteDriver) userSetRole(userId int64, role domain.UserRole) error {
+	_, err := s.db.Exec("UPDATE users set role=? where id=?", role, userId)
+	return err
+}
+
+func (s SqliteDriver) userUpdate(user domain.User) error {
+	_, err := s.db.Exec(
+		"UPDATE users set name=?, timezone=?, quote_asset=? where id=?",
+		user.Name,
+		user.Timezone,
+		user.QuoteAsset,
+		user.Id,
+	)
+
+	return err
+}
+
+func (s SqliteDriver) userGet(userId int64) (*domain.User, error) {
+	user, err := scanUser(s.db.QueryRow(BaseSelectUsersQuery+" where id=?", userId))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error in userGet (scan row): %w", err)
+	}
+
+	return user, nil
+}
+
+func (s SqliteDriver) userFindByApiKey(apiKeyHash string) (*domain.User, error) {
+	return s.userFindIdentity(apiKeyIdentity, apiKeyHash)
+}
+
+// userGetLinks returns the first identity of each kind.
+func (s SqliteDriver) userGetLinks(userId int64) (*app.UserLinks, error) {
+	user, err := s.userGet(userId)
+	if err != nil || user == nil {
+		return nil, err
+	}
+
+	rows, err := s.db.Query("SELECT kind, value FROM user_identities WHERE user_id=? ORDER BY id DESC", userId)
+	if err != nil {
+		return nil, fmt.Errorf("error in userGetLinks (query): %w", err)
+	}
+	defer rows.Close()
+
+	links := app.UserLinks{}
+	for rows.Next() {
+		var kind, value string
+		err = rows.Scan(&kind, &value)
 		if err != nil {
-			return nil, fmt.Errorf("error in get user (scan row): %w", err)
+			return nil, fmt.Errorf("error in userGetLinks (scan row): %w", err)
 		}
 
-		userCount++
+		switch kind {
+		case telegramIdentity:
+			links.Telegram, _ = strconv.ParseInt(value, 10, 64)
+		case emailIdentity:
+			links.Email = value
+		}
 	}
 
-	if userCount == 1 {
-		return &user, nil
+	return &links, nil
+}
+
+// userAddLinks adds identities of the links to the user, a new email replaces
+// the previous one.
+func (s SqliteDriver) userAddLinks(userId int64, links app.UserLinks) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
 	}
+	defer tx.Rollback()
 
-	jsonLinks, err := json.Marshal(links)
+	if links.Email != "" {
+		_, err = tx.Exec("DELETE FROM user_identities WHERE user_id=? and kind=?", userId, emailIdentity)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, identity := range linkIdentities(links) {
+		// identities of other users fail on the unique constraint
+		_, err = tx.Exec(
+			`INSERT INTO user_identities (user_id, kind, value) SELECT :user, :kind, :value
+			WHERE NOT EXISTS (SELECT 1 FROM user_identities WHERE user_id=:user and kind=:kind and value=:value)`,
+			sql.Named("user", userId),
+			sql.Named("kind", identity[0]),
+			sql.Named("value", identity[1]),
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (s SqliteDriver) userSetApiKey(user domain.User, apiKeyHash string) error {
+	tx, err := s.db.Begin()
 	if err != nil {
-		return nil, err
+		return err
 	}
+	defer tx.Rollback()
 
-	result, err := s.db.Exec("INSERT INTO users (links) values (?)", jsonLinks)
+	_, err = tx.Exec("DELETE FROM user_identities WHERE user_id=? and kind=?", user.Id, apiKeyIdentity)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec("INSERT INTO user_identities (user_id, kind, value) values (?,?,?)", user.Id, apiKeyIdentity, apiKeyHash)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// userMerge moves everything of the source user to the target one and deletes
+// the source. Rows the target already has, like its own user risk limits or
+// its email, win.
+func (s SqliteDriver) userMerge(sourceId int64, target domain.User) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	queries := []string{
+		"DELETE FROM user_identities WHERE user_id=:source and kind='email' and exists (SELECT 1 FROM user_identities WHERE user_id=:target and kind='email')",
+		"UPDATE user_identities SET user_id=:target WHERE user_id=:source",
+		"UPDATE agents SET user_id=:target WHERE user_id=:source",
+		"UPDATE exchanges SET name=name || ' (' || id || ')' WHERE user_id=:source and name in (SELECT name FROM exchanges WHERE user_id=:target)",
+		"UPDATE exchanges SET user_id=:target WHERE user_id=:source",
+		"UPDATE agent_data_history SET user_id=:target WHERE user_id=:source",
+		"UPDATE OR IGNORE risk_limits SET user_id=:target WHERE user_id=:source",
+		"DELETE FROM risk_limits WHERE user_id=:source",
+		"UPDATE risk_orders SET user_id=:target WHERE user_id=:source",
+		"UPDATE panic_events SET user_id=:target WHERE user_id=:source",
+		"UPDATE OR IGNORE agent_shares SET user_id=:target WHERE user_id=:source",
+		"DELETE FROM agent_shares WHERE user_id=:source",
+		"DELETE FROM agent_shares WHERE user_id=:target and agent_id in (SELECT id FROM agents WHERE user_id=:target)",
+		"UPDATE invites SET created_by=:target WHERE created_by=:source",
+		"DELETE FROM link_codes WHERE user_id=:source",
+		"UPDATE users SET role=:role, name=:name, timezone=:timezone, quote_asset=:quote_asset WHERE id=:target",
+		"DELETE FROM users WHERE id=:source",
+	}
+
+	for _, query := range queries {
+		_, err = tx.Exec(
+			query,
+			sql.Named("source", sourceId),
+			sql.Named("target", target.Id),
+			sql.Named("role", target.Role),
+			sql.Named("name", target.Name),
+			sql.Named("timezone", target.Timezone),
+			sql.Named("quote_asset", target.QuoteAsset),
+		)
+		if err != nil {
+			return fmt.Errorf("error in userMerge (%s): %w", query, err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (s SqliteDriver) linkCodeAdd(code app.LinkCode) error {
+	_, err := s.db.Exec(
+		"INSERT INTO link_codes (code, user_id, expires) values (?,?,?)",
+		code.Code,
+		code.UserId,
+		code.Expires.UTC().Format(time.RFC3339),
+	)
+
+	return err
+}
+
+// linkCodeUse marks the code used in one statement like inviteUse.
+func (s SqliteDriver) linkCodeUse(code string, now time.Time) (*app.LinkCode, error) {
+	result, err := s.db.Exec(
+		"UPDATE link_codes SET used=? WHERE code=? and used IS NULL and expires>?",
+		now.UTC().Format(time.RFC3339),
+		code,
+		now.UTC().Format(time.RFC3339),
+	)
 	if err != nil {
 		return nil, err
 	}
 
-	id, err := result.LastInsertId()
+	count, err := result.RowsAffected()
 	if err != nil {
 		return nil, err
 	}
 
-	return &domain.User{Id: id}, nil
+	if count == 0 {
+		return nil, nil
+	}
+
+	linkCode := app.LinkCode{Code: code}
+	var expires string
+	err = s.db.QueryRow("SELECT user_id, expires FROM link_codes WHERE code=?", code).Scan(&linkCode.UserId, &expires)
+	if err != nil {
+		return nil, fmt.Errorf("error in linkCodeUse (scan row): %w", err)
+	}
+
+	linkCode.Expires, _ = time.Parse(time.RFC3339, expires)
+	return &linkCode, nil
 }
 
 func (s SqliteDriver) agentFind(filter app.AgentFilter) ([]domain.Agent, error) {
@@ -139,6 +434,16 @@ func (s SqliteDriver) agentFind(filter app.AgentFilter) ([]domain.Agent, error)
 		queryArgs = append(queryArgs, filter.UserId)
 	}
 
+	if filter.VisibleTo != 0 {
+		predicats = append(predicats, "(user_id=? or id in (SELECT agent_id FROM agent_shares WHERE user_id=?))")
+		queryArgs = append(queryArgs, filter.VisibleTo, filter.VisibleTo)
+	}
+
+	if filter.ExchangeId != 0 {
+		predicats = append(predicats, "(id in (SELECT agent_id FROM agent_exchange WHERE exchange_id=?))")
+		queryArgs = append(queryArgs, filter.ExchangeId)
+	}
+
 	if len(predicats) > 0 {
 		query += " where "
 		for index, predicat := range predicats {
@@ -153,10 +458,11 @@ func (s SqliteDriver) agentFind(filter app.AgentFilter) ([]domain.Agent, error)
 	if err != nil {
 		return nil, fmt.Errorf("error in agentFind (query): %w", err)
 	}
+	defer rows.Close()
 
 	for rows.Next() {
 		agent := domain.Agent{}
-		err = rows.Scan(&agent.Id, &agent.UserId, &agent.Status, &agent.StrategyId, &agent.StrategyData)
+		err = rows.Scan(&agent.Id, &agent.UserId, &agent.Status, &agent.StrategyId, &agent.StrategyData, &agent.DryRun)
 		if err != nil {
 			return nil, fmt.Errorf("error in agentFind (scan row): %w", err)
 		}
@@ -169,11 +475,12 @@ func (s SqliteDriver) agentFind(filter app.AgentFilter) ([]domain.Agent, error)
 
 func (s SqliteDriver) agentCreate(agent domain.Agent) (*domain.Agent, error) {
 	result, err := s.db.Exec(
-		"INSERT INTO agents (user_id, status, strategy_number, strategy_data) values (?,?,?,?)",
+		"INSERT INTO agents (user_id, status, strategy_number, strategy_data, dry_run) values (?,?,?,?,?)",
 		agent.UserId,
 		agent.Status,
 		agent.StrategyId,
 		agent.StrategyData,
+		agent.DryRun,
 	)
 	if err != nil {
 		return nil, err
@@ -202,6 +509,13 @@ func (s SqliteDriver) agentUpdateData(agent *domain.Agent, data []byte) error {
 	return err
 }
 
+func (s SqliteDriver) agentSetDryRun(agent *domain.Agent, dryRun bool) error {
+	agent.DryRun = dryRun
+
+	_, err := s.db.Exec("UPDATE agents set dry_run=? where id=?", agent.DryRun, agent.Id)
+	return err
+}
+
 func (s SqliteDriver) getAgentExchanges(agentId int64) ([]app.ExchangeData, error) {
 	exchanges := []app.ExchangeData{}
 
@@ -213,7 +527,7 @@ func (s SqliteDriver) getAgentExchanges(agentId int64) ([]app.ExchangeData, erro
 
 	for rows.Next() {
 		exchange := app.ExchangeData{}
-		err = rows.Scan(&exchange.Id, &exchange.Data)
+		err = rows.Scan(&exchange.Id, &exchange.UserId, &exchange.Name, &exchange.Number, &exchange.Data)
 		if err != nil {
 			return nil, fmt.Errorf("error in getAgentExchanges (scan row): %w", err)
 		}
@@ -260,9 +574,9 @@ func (s SqliteDriver) findExchanges(filter app.ExchangeFilter) ([]app.ExchangeDa
 
 	for rows.Next() {
 		exchange := app.ExchangeData{}
-		err = rows.Scan(&exchange.Id, &exchange.Data)
+		err = rows.Scan(&exchange.Id, &exchange.UserId, &exchange.Name, &exchange.Number, &exchange.Data)
 		if err != nil {
-			return nil, fmt.Errorf("error in getAgentExchanges (scan row): %w", err)
+			return nil, fmt.Errorf("error in findExchanges (scan row): %w", err)
 		}
 
 		exchanges = append(exchanges, exchange)
@@ -271,18 +585,57 @@ func (s SqliteDriver) findExchanges(filter app.ExchangeFilter) ([]app.ExchangeDa
 	return exchanges, nil
 }
 
-func (s SqliteDriver) addExchange(userId int64, exchangeNumber int, data []byte) error {
-	_, err := s.db.Exec(
-		"INSERT INTO exchanges (user_id, exchange_number, data) values (?,?,?)",
+func (s SqliteDriver) addExchange(userId int64, name string, exchangeNumber int, data []byte) (*app.ExchangeData, error) {
+	result, err := s.db.Exec(
+		"INSERT INTO exchanges (user_id, name, exchange_number, data) values (?,?,?,?)",
 		userId,
+		name,
 		exchangeNumber,
 		data,
 	)
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+
+	return &app.ExchangeData{Id: int(id), UserId: userId, Name: name, Number: exchangeNumber, Data: data}, nil
+}
+
+// exchangeSetData replaces the keys of the account in one statement, agents
+// read them from the same row.
+func (s SqliteDriver) exchangeSetData(exchangeId int, data []byte) error {
+	_, err := s.db.Exec("UPDATE exchanges SET data=? WHERE id=?", data, exchangeId)
+	return err
+}
+
+func (s SqliteDriver) exchangeSetName(exchangeId int, name string) error {
+	_, err := s.db.Exec("UPDATE exchanges SET name=? WHERE id=?", name, exchangeId)
+	return err
+}
+
+// removeExchange removes the account and its links to agents.
+func (s SqliteDriver) removeExchange(exchangeId int) error {
+	tx, err := s.db.Begin()
 	if err != nil {
 		return err
 	}
+	defer tx.Rollback()
 
-	return nil
+	_, err = tx.Exec("DELETE FROM agent_exchange WHERE exchange_id=?", exchangeId)
+	if err != nil {
+		return fmt.Errorf("error in removeExchange (agent_exchange): %w", err)
+	}
+
+	_, err = tx.Exec("DELETE FROM exchanges WHERE id=?", exchangeId)
+	if err != nil {
+		return fmt.Errorf("error in removeExchange (exchanges): %w", err)
+	}
+
+	return tx.Commit()
 }
 
 func (s SqliteDriver) agentAddExchange(agent *domain.Agent, exchanges []app.ExchangeData) error {
@@ -299,3 +652,500 @@ func (s SqliteDriver) agentAddExchange(agent *domain.Agent, exchanges []app.Exch
 
 	return nil
 }
+
+func (s SqliteDriver) agentShareFind(filter app.AgentShareFilter) ([]app.AgentShare, error) {
+	query := "SELECT agent_id, user_id, role FROM agent_shares WHERE "
+
+	predicats := []string{}
+	queryArgs := []interface{}{}
+
+	if filter.AgentId != 0 {
+		predicats = append(predicats, "(agent_id=?)")
+		queryArgs = append(queryArgs, filter.AgentId)
+	}
+
+	if filter.UserId != 0 {
+		predicats = append(predicats, "(user_id=?)")
+		queryArgs = append(queryArgs, filter.UserId)
+	}
+
+	if len(predicats) == 0 {
+		predicats = append(predicats, "1")
+	}
+
+	rows, err := s.db.Query(query+strings.Join(predicats, " and ")+" ORDER BY id", queryArgs...)
+	if err != nil {
+		return nil, fmt.Errorf("error in agentShareFind (query): %w", err)
+	}
+	defer rows.Close()
+
+	shares := []app.AgentShare{}
+	for rows.Next() {
+		share := app.AgentShare{}
+		err = rows.Scan(&share.AgentId, &share.UserId, &share.Role)
+		if err != nil {
+			return nil, fmt.Errorf("error in agentShareFind (scan row): %w", err)
+		}
+
+		shares = append(shares, share)
+	}
+
+	return shares, nil
+}
+
+func (s SqliteDriver) agentShareSet(share app.AgentShare) error {
+	_, err := s.db.Exec(
+		"INSERT INTO agent_shares (agent_id, user_id, role) values (?,?,?) ON CONFLICT (agent_id, user_id) DO UPDATE SET role=excluded.role",
+		share.AgentId,
+		share.UserId,
+		share.Role,
+	)
+
+	return err
+}
+
+func (s SqliteDriver) agentShareRemove(agentId int64, userId int64) error {
+	_, err := s.db.Exec("DELETE FROM agent_shares WHERE agent_id=? and user_id=?", agentId, userId)
+	return err
+}
+
+func (s SqliteDriver) inviteAdd(invite app.Invite) error {
+	_, err := s.db.Exec(
+		"INSERT INTO invites (code, role, created_by, expires) values (?,?,?,?)",
+		invite.Code,
+		invite.Role,
+		invite.CreatedBy,
+		invite.Expires.UTC().Format(time.RFC3339),
+	)
+
+	return err
+}
+
+// inviteUse marks the invite used in one statement, so a code can't be
+// redeemed twice.
+func (s SqliteDriver) inviteUse(code string, now time.Time) (*app.Invite, error) {
+	result, err := s.db.Exec(
+		"UPDATE invites SET used=? WHERE code=? and used IS NULL and expires>?",
+		now.UTC().Format(time.RFC3339),
+		code,
+		now.UTC().Format(time.RFC3339),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	count, err := result.RowsAffected()
+	if err != nil {
+		return nil, err
+	}
+
+	if count == 0 {
+		return nil, nil
+	}
+
+	invite := app.Invite{Code: code}
+	var expires string
+	err = s.db.QueryRow("SELECT role, created_by, expires FROM invites WHERE code=?", code).Scan(&invite.Role, &invite.CreatedBy, &expires)
+	if err != nil {
+		return nil, fmt.Errorf("error in inviteUse (scan row): %w", err)
+	}
+
+	invite.Expires, _ = time.Parse(time.RFC3339, expires)
+	return &invite, nil
+}
+
+func (s SqliteDriver) addAgentLogs(records []app.AgentLog) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, record := range records {
+		_, err = tx.Exec(
+			"INSERT INTO agent_logs (agent_id, level, datetime, message) values (?,?,?,?)",
+			record.AgentId,
+			record.Level,
+			record.Datetime.UTC().Format(time.RFC3339),
+			record.Message,
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (s SqliteDriver) findAgentLogs(filter app.AgentLogFilter) ([]app.AgentLog, error) {
+	query := "SELECT id, agent_id, level, datetime, message FROM agent_logs WHERE (agent_id=?)"
+	queryArgs := []interface{}{filter.AgentId}
+
+	if filter.MinLevel != 0 {
+		query += " and (level>=?)"
+		queryArgs = append(queryArgs, filter.MinLevel)
+	}
+
+	query += " ORDER BY id DESC"
+
+	if filter.Limit > 0 {
+		query += " LIMIT ?"
+		queryArgs = append(queryArgs, filter.Limit)
+	}
+
+	rows, err := s.db.Query(query, queryArgs...)
+	if err != nil {
+		return nil, fmt.Errorf("error in findAgentLogs (query): %w", err)
+	}
+	defer rows.Close()
+
+	records := []app.AgentLog{}
+	for rows.Next() {
+		record := app.AgentLog{}
+		var datetime string
+
+		err = rows.Scan(&record.Id, &record.AgentId, &record.Level, &datetime, &record.Message)
+		if err != nil {
+			return nil, fmt.Errorf("error in findAgentLogs (scan row): %w", err)
+		}
+
+		record.Datetime, _ = time.Parse(time.RFC3339, datetime)
+		records = append(records, record)
+	}
+
+	for i, j := 0, len(records)-1; i < j; i, j = i+1, j-1 {
+		records[i], records[j] = records[j], records[i]
+	}
+
+	return records, nil
+}
+
+func (s SqliteDriver) trimAgentLogs(agentId int64, maxCount int, before time.Time) error {
+	if maxCount > 0 {
+		_, err := s.db.Exec(
+			"DELETE FROM agent_logs WHERE agent_id=? and id <= (SELECT id FROM agent_logs WHERE agent_id=? ORDER BY id DESC LIMIT 1 OFFSET ?)",
+			agentId,
+			agentId,
+			maxCount,
+		)
+		if err != nil {
+			return fmt.Errorf("error in trimAgentLogs (count): %w", err)
+		}
+	}
+
+	if !before.IsZero() {
+		_, err := s.db.Exec(
+			"DELETE FROM agent_logs WHERE agent_id=? and datetime < ?",
+			agentId,
+			before.UTC().Format(time.RFC3339),
+		)
+		if err != nil {
+			return fmt.Errorf("error in trimAgentLogs (age): %w", err)
+		}
+	}
+
+	return nil
+}
+
+func (s SqliteDriver) addAgentDataChange(change app.AgentDataChange) error {
+	_, err := s.db.Exec(
+		"INSERT INTO agent_data_history (agent_id, user_id, datetime, data) values (?,?,?,?)",
+		change.AgentId,
+		change.UserId,
+		change.Datetime.UTC().Format(time.RFC3339),
+		change.Data,
+	)
+
+	return err
+}
+
+func (s SqliteDriver) findAgentDataChanges(agentId int64) ([]app.AgentDataChange, error) {
+	rows, err := s.db.Query("SELECT id, agent_id, user_id, datetime, data FROM agent_data_history WHERE agent_id=? ORDER BY id", agentId)
+	if err != nil {
+		return nil, fmt.Errorf("error in findAgentDataChanges (query): %w", err)
+	}
+	defer rows.Close()
+
+	changes := []app.AgentDataChange{}
+	for rows.Next() {
+		change := app.AgentDataChange{}
+		var datetime string
+
+		err = rows.Scan(&change.Id, &change.AgentId, &change.UserId, &datetime, &change.Data)
+		if err != nil {
+			return nil, fmt.Errorf("error in findAgentDataChanges (scan row): %w", err)
+		}
+
+		change.Datetime, _ = time.Parse(time.RFC3339, datetime)
+		changes = append(changes, change)
+	}
+
+	return changes, nil
+}
+
+func (s SqliteDriver) getRiskLimits(userId int64, agentId int64) (*domain.RiskLimits, error) {
+	limits := domain.RiskLimits{}
+
+	var data []byte
+	err := s.db.QueryRow("SELECT data FROM risk_limits WHERE user_id=? and agent_id=?", userId, agentId).Scan(&data)
+	if err == sql.ErrNoRows {
+		return &limits, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error in getRiskLimits (scan row): %w", err)
+	}
+
+	err = json.Unmarshal(data, &limits)
+	if err != nil {
+		return nil, fmt.Errorf("error in getRiskLimits (unmarshal): %w", err)
+	}
+
+	return &limits, nil
+}
+
+func (s SqliteDriver) setRiskLimits(userId int64, agentId int64, limits domain.RiskLimits) error {
+	data, err := json.Marshal(limits)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.db.Exec(
+		"INSERT INTO risk_limits (user_id, agent_id, data) values (?,?,?) ON CONFLICT (user_id, agent_id) DO UPDATE SET data=excluded.data",
+		userId,
+		agentId,
+		data,
+	)
+
+	return err
+}
+
+func (s SqliteDriver) addRiskOrder(order domain.RiskOrder) error {
+	_, err := s.db.Exec(
+		"INSERT INTO risk_orders (agent_id, user_id, order_id, datetime, side, pair, amount, price) values (?,?,?,?,?,?,?,?)",
+		order.AgentId,
+		order.UserId,
+		order.OrderId,
+		order.Datetime.UTC().Format(time.RFC3339),
+		order.Side,
+		order.Pair.String(),
+		order.Amount,
+		order.Price,
+	)
+
+	return err
+}
+
+func (s SqliteDriver) removeRiskOrder(agentId int64, orderId string) error {
+	_, err := s.db.Exec("DELETE FROM risk_orders WHERE agent_id=? and order_id=?", agentId, orderId)
+	return err
+}
+
+func (s SqliteDriver) findRiskOrders(filter domain.RiskOrderFilter) ([]domain.RiskOrder, error) {
+	query := "SELECT id, agent_id, user_id, order_id, datetime, side, pair, amount, price FROM risk_orders WHERE "
+
+	predicats := []string{}
+	queryArgs := []interface{}{}
+
+	if filter.AgentId != 0 {
+		predicats = append(predicats, "(agent_id=?)")
+		queryArgs = append(queryArgs, filter.AgentId)
+	}
+
+	if filter.UserId != 0 {
+		predicats = append(predicats, "(user_id=?)")
+		queryArgs = append(queryArgs, filter.UserId)
+	}
+
+	if !filter.Since.IsZero() {
+		predicats = append(predicats, "(datetime>=?)")
+		queryArgs = append(queryArgs, filter.Since.UTC().Format(time.RFC3339))
+	}
+
+	if len(predicats) == 0 {
+		predicats = append(predicats, "1")
+	}
+
+	rows, err := s.db.Query(query+strings.Join(predicats, " and ")+" ORDER BY id", queryArgs...)
+	if err != nil {
+		return nil, fmt.Errorf("error in findRiskOrders (query): %w", err)
+	}
+	defer rows.Close()
+
+	orders := []domain.RiskOrder{}
+	for rows.Next() {
+		order := domain.RiskOrder{}
+		var datetime, pair string
+
+		err = rows.Scan(&order.Id, &order.AgentId, &order.UserId, &order.OrderId, &datetime, &order.Side, &pair, &order.Amount, &order.Price)
+		if err != nil {
+			return nil, fmt.Errorf("error in findRiskOrders (scan row): %w", err)
+		}
+
+		order.Datetime, _ = time.Parse(time.RFC3339, datetime)
+		order.Pair = parsePair(pair)
+		orders = append(orders, order)
+	}
+
+	return orders, nil
+}
+
+func (s SqliteDriver) addPanicEvent(event app.PanicEvent) error {
+	report, err := json.Marshal(event.Report)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.db.Exec(
+		"INSERT INTO panic_events (user_id, datetime, global, report) values (?,?,?,?)",
+		event.UserId,
+		event.Datetime.UTC().Format(time.RFC3339),
+		event.Global,
+		report,
+	)
+
+	return err
+}
+
+func (s SqliteDriver) findPaperOrders(agentId int64, exchange string, statuses []domain.OrderStatus) ([]domain.Order, error) {
+	query := "SELECT order_id, status, side, pair, amount, price, commission, commission_asset, client_order_id FROM paper_orders WHERE agent_id=? and exchange=?"
+	queryArgs := []interface{}{agentId, exchange}
+
+	if len(statuses) > 0 {
+		questions := []string{}
+		for _, status := range statuses {
+			questions = append(questions, "?")
+			queryArgs = append(queryArgs, status)
+		}
+
+		query += " and status in (" + strings.Join(questions, ",") + ")"
+	}
+
+	rows, err := s.db.Query(query+" ORDER BY id", queryArgs...)
+	if err != nil {
+		return nil, fmt.Errorf("error in findPaperOrders (query): %w", err)
+	}
+	defer rows.Close()
+
+	orders := []domain.Order{}
+	for rows.Next() {
+		order := domain.Order{}
+		var pair string
+
+		err = rows.Scan(&order.Id, &order.Status, &order.Side, &pair, &order.Amount, &order.Price, &order.Commission.Amount, &order.Commission.Asset, &order.ClientOrderId)
+		if err != nil {
+			return nil, fmt.Errorf("error in findPaperOrders (scan row): %w", err)
+		}
+
+		order.Pair = parsePair(pair)
+		orders = append(orders, order)
+	}
+
+	return orders, nil
+}
+
+func (s SqliteDriver) savePaperOrder(agentId int64, exchange string, order domain.Order) error {
+	_, err := s.db.Exec(
+		`INSERT INTO paper_orders (agent_id, exchange, order_id, datetime, status, side, pair, amount, price, commission, commission_asset, client_order_id)
+		values (?,?,?,?,?,?,?,?,?,?,?,?)
+		ON CONFLICT (agent_id, exchange, order_id) DO UPDATE SET
+			datetime=excluded.datetime, status=excluded.status, commission=excluded.commission, commission_asset=excluded.commission_asset`,
+		agentId,
+		exchange,
+		order.Id,
+		time.Now().UTC().Format(time.RFC3339),
+		order.Status,
+		order.Side,
+		order.Pair.String(),
+		order.Amount,
+		order.Price,
+		order.Commission.Amount,
+		order.Commission.Asset,
+		order.ClientOrderId,
+	)
+
+	return err
+}
+
+func (s SqliteDriver) findCandles(exchange string, pair domain.Pair, interval domain.CandleInterval, since time.Time) ([]domain.Candle, error) {
+	rows, err := s.db.Query(
+		`SELECT open_time, open, high, low, close, volume FROM candles
+		WHERE exchange=? and pair=? and interval=? and open_time>=? ORDER BY open_time`,
+		exchange,
+		pair.String(),
+		interval,
+		since.UTC().Format(time.RFC3339),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("error in findCandles (query): %w", err)
+	}
+	defer rows.Close()
+
+	candles := []domain.Candle{}
+	for rows.Next() {
+		candle := domain.Candle{}
+		var openTime string
+
+		err = rows.Scan(&openTime, &candle.Open, &candle.High, &candle.Low, &candle.Close, &candle.Volume)
+		if err != nil {
+			return nil, fmt.Errorf("error in findCandles (scan row): %w", err)
+		}
+
+		candle.OpenTime, _ = time.Parse(time.RFC3339, openTime)
+		candles = append(candles, candle)
+	}
+
+	return candles, nil
+}
+
+func (s SqliteDriver) candleRange(exchange string, pair domain.Pair, interval domain.CandleInterval) (time.Time, time.Time, error) {
+	var first, last sql.NullString
+	err := s.db.QueryRow(
+		"SELECT min(open_time), max(open_time) FROM candles WHERE exchange=? and pair=? and interval=?",
+		exchange,
+		pair.String(),
+		interval,
+	).Scan(&first, &last)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("error in candleRange: %w", err)
+	}
+
+	if !first.Valid {
+		return time.Time{}, time.Time{}, nil
+	}
+
+	firstTime, _ := time.Parse(time.RFC3339, first.String)
+	lastTime, _ := time.Parse(time.RFC3339, last.String)
+
+	return firstTime, lastTime, nil
+}
+
+func (s SqliteDriver) saveCandles(exchange string, pair domain.Pair, interval domain.CandleInterval, candles []domain.Candle) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, candle := range candles {
+		_, err = tx.Exec(
+			`INSERT INTO candles (exchange, pair, interval, open_time, open, high, low, close, volume)
+			values (?,?,?,?,?,?,?,?,?)
+			ON CONFLICT (exchange, pair, interval, open_time) DO UPDATE SET
+				open=excluded.open, high=excluded.high, low=excluded.low, close=excluded.close, volume=excluded.volume`,
+			exchange,
+			pair.String(),
+			interval,
+			candle.OpenTime.UTC().Format(time.RFC3339),
+			candle.Open,
+			candle.High,
+			candle.Low,
+			candle.Close,
+			candle.Volume,
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
@@ -15,11 +15,53 @@ type SimpleStorage struct {
 }
 
 var _ domain.SimpleStorage = (*SimpleStorage)(nil)
+var _ domain.TradeCounter = (*SimpleStorage)(nil)
+
+func init() {
+	RegisterStrategyStorage(domain.SimpleStratedy, StrategyStorage{
+		Factory: func(agent domain.Agent, db *sql.DB) interface{} {
+			return SimpleStorage{agent: agent, db: db}
+		},
+	})
+}
+
+func (ss SimpleStorage) CountTrades() (map[string]int, error) {
+	rows, err := ss.db.Query(
+		"SELECT status, count(*) FROM st_simple_trades WHERE agent_id=? and dry_run=? and status in (?,?) GROUP BY status",
+		ss.agent.Id,
+		ss.agent.DryRun,
+		domain.SimpleTradeStatusBuy,
+		domain.SimpleTradeStatusSell,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("error in CountTrades (query): %w", err)
+	}
+	defer rows.Close()
+
+	counts := map[string]int{
+		domain.SimpleTradeStatusNames[domain.SimpleTradeStatusBuy]:  0,
+		domain.SimpleTradeStatusNames[domain.SimpleTradeStatusSell]: 0,
+	}
+	for rows.Next() {
+		var status domain.SimpleTradeStatus
+		var count int
+
+		err = rows.Scan(&status, &count)
+		if err != nil {
+			return nil, fmt.Errorf("error in CountTrades (scan row): %w", err)
+		}
+
+		counts[domain.SimpleTradeStatusNames[status]] = count
+	}
+
+	return counts, nil
+}
 
 func (ss SimpleStorage) GetTrades(filter *domain.SimpleTradeFilter) ([]domain.SimpleTrade, error) {
 	query := `
 	SELECT 
 		id, 
+		pair,
 		status,
 		amount,
 		buy_order_id,
@@ -27,16 +69,18 @@ func (ss SimpleStorage) GetTrades(filter *domain.SimpleTradeFilter) ([]domain.Si
 		buy_price,
 		buy_commission,
 		buy_commission_asset,
+		buy_client_order_id,
 		sell_order_id,
 		sell_datetime,
 		sell_price,
 		sell_commission,
-		sell_commission_asset 
+		sell_commission_asset,
+		sell_client_order_id
 	FROM st_simple_trades
 	WHERE `
 
-	predicats := []string{"(agent_id=?)"}
-	queryArgs := []interface{}{ss.agent.Id}
+	predicats := []string{"(agent_id=?)", "(dry_run=?)"}
+	queryArgs := []interface{}{ss.agent.Id, ss.agent.DryRun}
 
 	if filter != nil {
 
@@ -64,6 +108,7 @@ func (ss SimpleStorage) GetTrades(filter *domain.SimpleTradeFilter) ([]domain.Si
 		trade := domain.SimpleTrade{}
 
 		result := struct {
+			pair                 sql.NullString
 			sellOrderId          sql.NullString
 			sellDatetime         sql.NullString
 			sellPrice            sql.NullString
@@ -73,6 +118,7 @@ func (ss SimpleStorage) GetTrades(filter *domain.SimpleTradeFilter) ([]domain.Si
 
 		err = rows.Scan(
 			&trade.Id,
+			&result.pair,
 			&trade.Status,
 			&trade.Amount,
 			&trade.Buy.OrderId,
@@ -80,13 +126,16 @@ func (ss SimpleStorage) GetTrades(filter *domain.SimpleTradeFilter) ([]domain.Si
 			&trade.Buy.Price,
 			&trade.Buy.Commission.Amount,
 			&trade.Buy.Commission.Asset,
+			&trade.Buy.ClientOrderId,
 			&result.sellOrderId,
 			&result.sellDatetime,
 			&result.sellPrice,
 			&result.sellCommissionAmount,
 			&result.sellCommissionAsset,
+			&trade.Sell.ClientOrderId,
 		)
 
+		trade.Pair = parsePair(result.pair.String)
 		trade.Sell.OrderId = result.sellOrderId.String
 		trade.Sell.Datetime = result.sellDatetime.String
 		trade.Sell.Price, _ = decimal.NewFromString(result.sellPrice.String)
@@ -109,6 +158,7 @@ func (ss SimpleStorage) SaveTrade(trade *domain.SimpleTrade) error {
 		res, err := ss.db.Exec(`
 		INSERT INTO st_simple_trades (
 			agent_id,
+			pair,
 			status,
 			amount,
 			buy_order_id,
@@ -116,14 +166,18 @@ func (ss SimpleStorage) SaveTrade(trade *domain.SimpleTrade) error {
 			buy_price,
 			buy_commission,
 			buy_commission_asset,
+			buy_client_order_id,
 			sell_order_id,
 			sell_datetime,
 			sell_price,
 			sell_commission,
-			sell_commission_asset
+			sell_commission_asset,
+			sell_client_order_id,
+			dry_run
 		)
-		VALUES (?,?,?,?,?,?,?,?,?,?,?,?,?)`,
+		VALUES (?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?)`,
 			ss.agent.Id,
+			pairString(trade.Pair),
 			trade.Status,
 			trade.Amount,
 			trade.Buy.OrderId,
@@ -131,11 +185,14 @@ func (ss SimpleStorage) SaveTrade(trade *domain.SimpleTrade) error {
 			trade.Buy.Price,
 			trade.Buy.Commission.Amount,
 			trade.Buy.Commission.Asset,
+			trade.Buy.ClientOrderId,
 			trade.Sell.OrderId,
 			trade.Sell.Datetime,
 			trade.Sell.Price,
 			trade.Sell.Commission.Amount,
 			trade.Sell.Commission.Asset,
+			trade.Sell.ClientOrderId,
+			ss.agent.DryRun,
 		)
 
 		if err != nil {
@@ -150,6 +207,7 @@ func (ss SimpleStorage) SaveTrade(trade *domain.SimpleTrade) error {
 		_, commonError = ss.db.Exec(`
 		UPDATE st_simple_trades set
 			agent_id=?,
+			pair=?,
 			status=?,
 			amount=?,
 			buy_order_id=?,
@@ -157,13 +215,16 @@ func (ss SimpleStorage) SaveTrade(trade *domain.SimpleTrade) error {
 			buy_price=?,
 			buy_commission=?,
 			buy_commission_asset=?,
+			buy_client_order_id=?,
 			sell_order_id=?,
 			sell_datetime=?,
 			sell_price=?,
 			sell_commission=?,
-			sell_commission_asset=?
+			sell_commission_asset=?,
+			sell_client_order_id=?
 		WHERE id=?`,
 			ss.agent.Id,
+			pairString(trade.Pair),
 			trade.Status,
 			trade.Amount,
 			trade.Buy.OrderId,
@@ -171,11 +232,13 @@ func (ss SimpleStorage) SaveTrade(trade *domain.SimpleTrade) error {
 			trade.Buy.Price,
 			trade.Buy.Commission.Amount,
 			trade.Buy.Commission.Asset,
+			trade.Buy.ClientOrderId,
 			trade.Sell.OrderId,
 			trade.Sell.Datetime,
 			trade.Sell.Price,
 			trade.Sell.Commission.Amount,
 			trade.Sell.Commission.Asset,
+			trade.Sell.ClientOrderId,
 			trade.Id,
 		)
 	}
@@ -185,3 +248,22 @@ func (ss SimpleStorage) SaveTrade(trade *domain.SimpleTrade) error {
 
 	return nil
 }
+
+// pairString keeps trades without a pair NULL, the strategy assigns them to
+// its first pair.
+func pairString(pair domain.Pair) interface{} {
+	if pair == (domain.Pair{}) {
+		return nil
+	}
+
+	return pair.String()
+}
+
+func parsePair(value string) domain.Pair {
+	assets := strings.SplitN(value, "/", 2)
+	if len(assets) != 2 {
+		return domain.Pair{}
+	}
+
+	return domain.Pair{BaseAsset: assets[0], QuoteAsset: assets[1]}
+}
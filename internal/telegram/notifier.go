@@ -0,0 +1,33 @@
+package telegram
+
+import (
+	"fmt"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/scientistnik/invest-agents/internal/app"
+)
+
+// Notifier sends agent notifications to the telegram chat of the user.
+type Notifier struct {
+	bot *tgbotapi.BotAPI
+}
+
+var _ app.UserNotifier = (*Notifier)(nil)
+
+func NewNotifier(token string) (*Notifier, error) {
+	bot, err := tgbotapi.NewBotAPI(token)
+	if err != nil {
+		return nil, fmt.Errorf("telegram error, %w", err)
+	}
+
+	return &Notifier{bot: bot}, nil
+}
+
+func (n *Notifier) Notify(links app.UserLinks, message string) error {
+	if links.Telegram == 0 {
+		return nil
+	}
+
+	_, err := n.bot.Send(tgbotapi.NewMessage(links.Telegram, message))
+	return err
+}
@@ -2,11 +2,11 @@ package telegram
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 	"github.com/scientistnik/invest-agents/internal/app"
 	"github.com/scientistnik/invest-agents/internal/app/domain"
-	"github.com/shopspring/decimal"
 	"strconv"
 	"strings"
 )
@@ -17,7 +17,8 @@ func Start(ctx context.Context, token string, actions *app.Actions) error {
 		return fmt.Errorf("telegram error, %w", err)
 	}
 
-	bot.Debug = true
+	// debug output prints sent messages and they carry api keys
+	bot.Debug = false
 
 	//log.Printf("Authorized on account %s", bot.Self.UserName)
 
@@ -53,53 +54,33 @@ func Start(ctx context.Context, token string, actions *app.Actions) error {
 				// Extract the command from the Message.
 				switch update.Message.Command() {
 				case "start":
-					user, err := actions.UserGetOrCreate("", app.UserLinks{Telegram: update.Message.Chat.ID})
-					if err != nil {
-						msg.Text += fmt.Sprintf("%#v\n", err)
-					}
-					//msg.Text += fmt.Sprintf("%#v\n", user)
-					agents, err := actions.GetUserAgents(*user)
-					if err != nil {
-						msg.Text += fmt.Sprintf("%#v\n", err)
-					}
-					//msg.Text += fmt.Sprintf("%#v\n", agents)
-					msg.Text += fmt.Sprintf("You have %d active agents:\n", len(agents))
-					for _, agent := range agents {
-						agentInfo := actions.GetAgentInfo(agent)
-						msg.Text += fmt.Sprintf(
-							"Name: %s\nExchanges: %s\nStrategy:\n  Name: %s\n",
-							agentInfo.Name,
-							strings.Join(agentInfo.Exchanges, ","),
-							agentInfo.StrategyName,
-						)
-
-						for _, param := range agentInfo.Parameters {
-							var value string
-							switch param.Type {
-							case domain.BoolParameterType:
-								val := param.Value.(bool)
-								value = strconv.FormatBool(val)
-							case domain.IntParameterType:
-								val := param.Value.(int)
-								value = strconv.FormatInt(int64(val), 10)
-							case domain.StringParameterType:
-								value = param.Value.(string)
-							case domain.PercentParameterType:
-								val := param.Value.(decimal.Decimal).Mul(decimal.NewFromInt(100))
-								value = val.String() + " %"
-							case domain.PairParameterType:
-								val := param.Value.(domain.Pair)
-								value = val.BaseAsset + "/" + val.QuoteAsset
-							case domain.BalanceParameterType:
-								val := param.Value.(domain.Balance)
-								value = fmt.Sprintf("%s %s", val.Amount, val.Asset)
-							}
-
-							msg.Text += fmt.Sprintf("  %s: %s\n", param.Name, value)
-						}
-					}
+					msg.Text = startCommand(actions, update.Message.Chat.ID, update.Message.CommandArguments())
+				case "strategies":
+					msg.Text = strategiesCommand(actions)
+				case "apikey":
+					msg.Text = apiKeyCommand(actions, update.Message.Chat.ID)
+				case "logs":
+					msg.Text = logsCommand(actions, update.Message.Chat.ID, update.Message.CommandArguments())
+				case "panic":
+					msg.Text = panicCommand(actions, update.Message.Chat.ID, update.Message.CommandArguments())
+				case "share":
+					msg.Text = shareCommand(actions, update.Message.Chat.ID, update.Message.CommandArguments())
+				case "unshare":
+					msg.Text = unshareCommand(actions, update.Message.Chat.ID, update.Message.CommandArguments())
+				case "invite":
+					msg.Text = inviteCommand(actions, update.Message.Chat.ID, update.Message.CommandArguments())
+				case "role":
+					msg.Text = roleCommand(actions, update.Message.Chat.ID, update.Message.CommandArguments())
+				case "profile":
+					msg.Text = profileCommand(actions, update.Message.Chat.ID, update.Message.CommandArguments())
+				case "exchanges":
+					msg.Text = exchangesCommand(actions, update.Message.Chat.ID)
+				case "link":
+					msg.Text = linkCommand(actions, update.Message.Chat.ID, update.Message.CommandArguments())
 				case "help":
-					msg.Text = "I understand /start and /status."
+					msg.Text = "I understand /start [invite or link code], /strategies, /logs <agent> [level], /apikey, /panic [sell] [all], " +
+						"/share <agent> <user> [viewer|trader], /unshare <agent> <user>, /invite [role], /role <user> <role>, " +
+						"/profile [name|timezone|quote|email <value>], /link [code], /exchanges and /status."
 				case "status":
 					msg.Text = "I'm ok."
 				default:
@@ -123,3 +104,451 @@ func Start(ctx context.Context, token string, actions *app.Actions) error {
 		}
 	}
 }
+
+const notRegisteredText = "You are not registered, send /start <invite code>"
+
+// getUser returns the registered user of the chat or a text for the chat.
+func getUser(actions *app.Actions, chatId int64) (*domain.User, string) {
+	user, err := actions.UserFind(app.UserLinks{Telegram: chatId})
+	if err != nil {
+		return nil, fmt.Sprintf("%#v\n", err)
+	}
+
+	if user == nil {
+		return nil, notRegisteredText
+	}
+
+	return user, ""
+}
+
+func startCommand(actions *app.Actions, chatId int64, arguments string) string {
+	user, err := actions.UserRegister(app.UserLinks{Telegram: chatId}, strings.TrimSpace(arguments))
+	if errors.Is(err, app.ErrNotRegistered) {
+		return notRegisteredText
+	}
+	if err != nil {
+		return err.Error()
+	}
+
+	text := ""
+	if user.Name != "" {
+		text = fmt.Sprintf("Hello, %s!\n", user.Name)
+	}
+	text += fmt.Sprintf("Your user id: %d, role: %s\n", user.Id, domain.UserRoleNames[user.Role])
+
+	agents, err := actions.GetUserAgents(*user)
+	if err != nil {
+		return text + err.Error()
+	}
+
+	text += fmt.Sprintf("You have %d agents:\n", len(agents))
+	for _, agent := range agents {
+		agentInfo := actions.GetAgentInfo(agent)
+		if agentInfo == nil {
+			continue
+		}
+		text += fmt.Sprintf(
+			"Name: %s\nExchanges: %s\nStrategy:\n  Name: %s\n",
+			agentInfo.Name,
+			strings.Join(agentInfo.Exchanges, ","),
+			agentInfo.StrategyName,
+		)
+
+		if agent.UserId != user.Id {
+			role, err := actions.AgentRole(*user, agent)
+			if err == nil {
+				text += fmt.Sprintf("Owner: user %d, your access: %s\n", agent.UserId, domain.UserRoleNames[role])
+			}
+		}
+
+		for _, param := range agentInfo.Parameters {
+			text += fmt.Sprintf("  %s: %s\n", param.Name, domain.FormatParameter(param))
+		}
+	}
+
+	return text
+}
+
+const (
+	logsLimit         = 20
+	messageTextLength = 4096
+)
+
+func logsCommand(actions *app.Actions, chatId int64, arguments string) string {
+	args := strings.Fields(arguments)
+	if len(args) == 0 || len(args) > 2 {
+		return "Usage: /logs <agent> [debug|info|warn|error]"
+	}
+
+	agentId, err := strconv.ParseInt(args[0], 10, 64)
+	if err != nil {
+		return "Bad agent id: " + args[0]
+	}
+
+	var minLevel domain.LogLevel
+	if len(args) == 2 {
+		minLevel, err = domain.ParseLogLevel(args[1])
+		if err != nil {
+			return err.Error()
+		}
+	}
+
+	user, text := getUser(actions, chatId)
+	if user == nil {
+		return text
+	}
+
+	records, err := actions.GetAgentLogs(*user, agentId, minLevel, logsLimit)
+	if err != nil {
+		return err.Error()
+	}
+
+	if len(records) == 0 {
+		return fmt.Sprintf("Agent %d has no logs", agentId)
+	}
+
+	location := app.UserLocation(*user)
+
+	text = ""
+	for index := len(records) - 1; index >= 0; index-- {
+		record := records[index]
+		line := fmt.Sprintf("%s %s %s\n", record.Datetime.In(location).Format("01-02 15:04:05"), strings.ToUpper(record.Level.String()), record.Message)
+		if len(text)+len(line) > messageTextLength {
+			break
+		}
+		text = line + text
+	}
+
+	return text
+}
+
+func apiKeyCommand(actions *app.Actions, chatId int64) string {
+	user, text := getUser(actions, chatId)
+	if user == nil {
+		return text
+	}
+
+	apiKey, err := actions.UserCreateApiKey(*user)
+	if err != nil {
+		return fmt.Sprintf("%#v\n", err)
+	}
+
+	return "Your new HTTP API key (the previous one no longer works):\n" + apiKey
+}
+
+func strategiesCommand(actions *app.Actions) string {
+	text := "Strategies:\n"
+	for _, info := range actions.GetStrategies() {
+		text += fmt.Sprintf("%d. %s\n", info.Id, info.Name)
+		text += describeSchemas(info.Schema, "  ")
+	}
+
+	return text
+}
+
+// describeSchemas renders parameters one per line, list items are nested
+// under their list.
+func describeSchemas(schemas []domain.ParameterSchema, indent string) string {
+	text := ""
+	for _, schema := range schemas {
+		text += fmt.Sprintf("%s%s (%s): %s\n", indent, schema.Key, domain.StrategyParameterTypeNames[schema.Type], schema.Description)
+		text += describeSchemas(schema.Items, indent+"  ")
+	}
+
+	return text
+}
+
+func panicCommand(actions *app.Actions, chatId int64, arguments string) string {
+	options := app.PanicOptions{}
+	for _, arg := range strings.Fields(arguments) {
+		switch arg {
+		case "sell":
+			options.SellPositions = true
+		case "all":
+			options.Global = true
+		default:
+			return "Usage: /panic [sell] [all]"
+		}
+	}
+
+	user, text := getUser(actions, chatId)
+	if user == nil {
+		return text
+	}
+
+	report, err := actions.Panic(*user, options)
+	if err != nil {
+		return err.Error()
+	}
+
+	text = "Panic: " + report.Summary() + "\n"
+	for _, message := range report.Errors {
+		text += message + "\n"
+	}
+
+	return text
+}
+
+func shareCommand(actions *app.Actions, chatId int64, arguments string) string {
+	args := strings.Fields(arguments)
+	if len(args) < 2 || len(args) > 3 {
+		return "Usage: /share <agent> <user> [viewer|trader]"
+	}
+
+	agentId, err := strconv.ParseInt(args[0], 10, 64)
+	if err != nil {
+		return "Bad agent id: " + args[0]
+	}
+
+	userId, err := strconv.ParseInt(args[1], 10, 64)
+	if err != nil {
+		return "Bad user id: " + args[1]
+	}
+
+	role := domain.ViewerUserRole
+	if len(args) == 3 {
+		role, err = domain.ParseUserRole(args[2])
+		if err != nil {
+			return err.Error()
+		}
+	}
+
+	user, text := getUser(actions, chatId)
+	if user == nil {
+		return text
+	}
+
+	err = actions.ShareAgent(*user, agentId, userId, role)
+	if err != nil {
+		return err.Error()
+	}
+
+	return fmt.Sprintf("Agent %d is shared with user %d as %s", agentId, userId, domain.UserRoleNames[role])
+}
+
+func unshareCommand(actions *app.Actions, chatId int64, arguments string) string {
+	args := strings.Fields(arguments)
+	if len(args) != 2 {
+		return "Usage: /unshare <agent> <user>"
+	}
+
+	agentId, err := strconv.ParseInt(args[0], 10, 64)
+	if err != nil {
+		return "Bad agent id: " + args[0]
+	}
+
+	userId, err := strconv.ParseInt(args[1], 10, 64)
+	if err != nil {
+		return "Bad user id: " + args[1]
+	}
+
+	user, text := getUser(actions, chatId)
+	if user == nil {
+		return text
+	}
+
+	err = actions.UnshareAgent(*user, agentId, userId)
+	if err != nil {
+		return err.Error()
+	}
+
+	return fmt.Sprintf("Agent %d is no longer shared with user %d", agentId, userId)
+}
+
+func inviteCommand(actions *app.Actions, chatId int64, arguments string) string {
+	args := strings.Fields(arguments)
+	if len(args) > 1 {
+		return "Usage: /invite [viewer|trader|admin]"
+	}
+
+	role := domain.ViewerUserRole
+	if len(args) == 1 {
+		var err error
+		role, err = domain.ParseUserRole(args[0])
+		if err != nil {
+			return err.Error()
+		}
+	}
+
+	user, text := getUser(actions, chatId)
+	if user == nil {
+		return text
+	}
+
+	invite, err := actions.CreateInvite(*user, role)
+	if err != nil {
+		return err.Error()
+	}
+
+	return fmt.Sprintf(
+		"Invite for a %s, valid until %s. The new user sends:\n/start %s",
+		domain.UserRoleNames[invite.Role],
+		invite.Expires.Format("2006-01-02 15:04"),
+		invite.Code,
+	)
+}
+
+func roleCommand(actions *app.Actions, chatId int64, arguments string) string {
+	args := strings.Fields(arguments)
+	if len(args) != 2 {
+		return "Usage: /role <user> <viewer|trader|admin>"
+	}
+
+	userId, err := strconv.ParseInt(args[0], 10, 64)
+	if err != nil {
+		return "Bad user id: " + args[0]
+	}
+
+	role, err := domain.ParseUserRole(args[1])
+	if err != nil {
+		return err.Error()
+	}
+
+	user, text := getUser(actions, chatId)
+	if user == nil {
+		return text
+	}
+
+	err = actions.SetUserRole(*user, userId, role)
+	if err != nil {
+		return err.Error()
+	}
+
+	return fmt.Sprintf("User %d is %s now", userId, domain.UserRoleNames[role])
+}
+
+func profileCommand(actions *app.Actions, chatId int64, arguments string) string {
+	const usage = "Usage: /profile [name|timezone|quote|email <value>]"
+
+	user, text := getUser(actions, chatId)
+	if user == nil {
+		return text
+	}
+
+	profile, err := actions.GetUserProfile(*user)
+	if err != nil {
+		return err.Error()
+	}
+
+	arguments = strings.TrimSpace(arguments)
+	if arguments != "" {
+		field, value, _ := strings.Cut(arguments, " ")
+		value = strings.TrimSpace(value)
+
+		name, timezone, quoteAsset := profile.User.Name, profile.User.Timezone, profile.User.QuoteAsset
+		switch field {
+		case "name":
+			name = value
+		case "timezone":
+			timezone = value
+		case "quote":
+			quoteAsset = value
+		case "email":
+			err = actions.UserLinkEmail(*user, value)
+			if err != nil {
+				return err.Error()
+			}
+		default:
+			return usage
+		}
+
+		if field != "email" {
+			_, err = actions.UserUpdateProfile(*user, name, timezone, quoteAsset)
+			if err != nil {
+				return err.Error()
+			}
+		}
+
+		profile, err = actions.GetUserProfile(*user)
+		if err != nil {
+			return err.Error()
+		}
+	}
+
+	return fmt.Sprintf(
+		"User id: %d\nRole: %s\nName: %s\nTimezone: %s\nQuote asset: %s\nEmail: %s\n",
+		profile.User.Id,
+		domain.UserRoleNames[profile.User.Role],
+		orNotSet(profile.User.Name),
+		orNotSet(profile.User.Timezone),
+		orNotSet(profile.User.QuoteAsset),
+		orNotSet(profile.Links.Email),
+	)
+}
+
+func linkCommand(actions *app.Actions, chatId int64, arguments string) string {
+	args := strings.Fields(arguments)
+	if len(args) > 1 {
+		return "Usage: /link [code]"
+	}
+
+	user, text := getUser(actions, chatId)
+	if user == nil {
+		return text
+	}
+
+	if len(args) == 1 {
+		merged, err := actions.UserLinkByCode(*user, args[0])
+		if err != nil {
+			return err.Error()
+		}
+
+		return fmt.Sprintf("The accounts are linked, your user id: %d", merged.Id)
+	}
+
+	linkCode, err := actions.UserCreateLinkCode(*user)
+	if err != nil {
+		return err.Error()
+	}
+
+	return fmt.Sprintf(
+		"Link code valid until %s. Send /link %s from your other account or /start %s from a new chat, "+
+			"POST it to /me/link with the HTTP API.",
+		linkCode.Expires.In(app.UserLocation(*user)).Format("2006-01-02 15:04"),
+		linkCode.Code,
+		linkCode.Code,
+	)
+}
+
+func exchangesCommand(actions *app.Actions, chatId int64) string {
+	user, text := getUser(actions, chatId)
+	if user == nil {
+		return text
+	}
+
+	accounts, err := actions.ListExchanges(*user)
+	if err != nil {
+		return err.Error()
+	}
+
+	if len(accounts) == 0 {
+		return "You have no exchange accounts"
+	}
+
+	text = "Exchange accounts:\n"
+	for _, account := range accounts {
+		agents := "none"
+		if len(account.AgentIds) > 0 {
+			ids := []string{}
+			for _, agentId := range account.AgentIds {
+				ids = append(ids, strconv.FormatInt(agentId, 10))
+			}
+			agents = strings.Join(ids, ", ")
+		}
+
+		text += fmt.Sprintf("%d. %s (%s)\n  Keys: %s\n  Agents: %s\n", account.Id, account.Name, orNotSet(account.Exchange), account.MaskedData, agents)
+		if account.UserId != user.Id {
+			text += fmt.Sprintf("  Owner: user %d\n", account.UserId)
+		}
+	}
+
+	return text
+}
+
+func orNotSet(value string) string {
+	if value == "" {
+		return "not set"
+	}
+
+	return value
+}
@@ -0,0 +1,255 @@
+package test_websocket
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/scientistnik/invest-agents/internal/websocket"
+)
+
+const (
+	opText         = 0x1
+	opContinuation = 0x0
+	opClose        = 0x8
+	opPing         = 0x9
+	opPong         = 0xA
+)
+
+// peer is the server side of a connection, it writes plain frames and reads
+// masked client frames.
+type peer struct {
+	conn   net.Conn
+	reader *bufio.Reader
+}
+
+func (p peer) write(t *testing.T, fin bool, opcode byte, payload []byte) {
+	first := opcode
+	if fin {
+		first |= 0x80
+	}
+
+	frame := []byte{first}
+	switch {
+	case len(payload) < 126:
+		frame = append(frame, byte(len(payload)))
+	case len(payload) <= 0xFFFF:
+		frame = append(frame, 126, 0, 0)
+		binary.BigEndian.PutUint16(frame[2:], uint16(len(payload)))
+	default:
+		frame = append(frame, 127, 0, 0, 0, 0, 0, 0, 0, 0)
+		binary.BigEndian.PutUint64(frame[2:], uint64(len(payload)))
+	}
+
+	_, err := p.conn.Write(append(frame, payload...))
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func (p peer) read() (byte, []byte, error) {
+	header := make([]byte, 2)
+	_, err := io.ReadFull(p.reader, header)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	if header[1]&0x80 == 0 {
+		return 0, nil, errors.New("client frame is not masked")
+	}
+
+	length := uint64(header[1] & 0x7F)
+	switch length {
+	case 126:
+		extended := make([]byte, 2)
+		_, err = io.ReadFull(p.reader, extended)
+		length = uint64(binary.BigEndian.Uint16(extended))
+	case 127:
+		extended := make([]byte, 8)
+		_, err = io.ReadFull(p.reader, extended)
+		length = binary.BigEndian.Uint64(extended)
+	}
+	if err != nil {
+		return 0, nil, err
+	}
+
+	mask := make([]byte, 4)
+	_, err = io.ReadFull(p.reader, mask)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	payload := make([]byte, length)
+	_, err = io.ReadFull(p.reader, payload)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	for i := range payload {
+		payload[i] ^= mask[i%4]
+	}
+
+	return header[0] & 0x0F, payload, nil
+}
+
+// listen starts a server that accepts the handshake with the key returned by
+// accept and hands the connections over.
+func listen(t *testing.T, accept func(key string) string) (string, <-chan peer) {
+	peers := make(chan peer, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Upgrade") != "websocket" || r.Header.Get("Sec-Websocket-Version") != "13" {
+			t.Errorf("bad upgrade request %v", r.Header)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		conn, buffer, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		buffer.WriteString("HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n")
+		buffer.WriteString("Sec-WebSocket-Accept: " + accept(r.Header.Get("Sec-Websocket-Key")) + "\r\n\r\n")
+		buffer.Flush()
+
+		peers <- peer{conn: conn, reader: buffer.Reader}
+	}))
+	t.Cleanup(server.Close)
+
+	return "ws" + strings.TrimPrefix(server.URL, "http"), peers
+}
+
+func acceptKey(key string) string {
+	sum := sha1.Sum([]byte(key + "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"))
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+func dial(t *testing.T, accept func(key string) string) (*websocket.Conn, peer) {
+	url, peers := listen(t, accept)
+
+	conn, err := websocket.Dial(context.Background(), url)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := <-peers
+	t.Cleanup(func() {
+		conn.Close()
+		server.conn.Close()
+	})
+
+	return conn, server
+}
+
+func TestHandshakeChecksAcceptKey(t *testing.T) {
+	url, _ := listen(t, func(key string) string { return acceptKey(key + "x") })
+
+	_, err := websocket.Dial(context.Background(), url)
+	if err == nil || !strings.Contains(err.Error(), "bad accept key") {
+		t.Fatalf("expected bad accept key, got %v", err)
+	}
+}
+
+func TestMessageLengths(t *testing.T) {
+	// one byte, 16 bit and 64 bit lengths
+	for _, size := range []int{5, 200, 70000} {
+		conn, server := dial(t, acceptKey)
+		text := strings.Repeat("a", size)
+
+		received := make(chan []byte, 1)
+		go func() {
+			opcode, payload, err := server.read()
+			if err != nil || opcode != opText {
+				t.Errorf("size %d: opcode %d, %v", size, opcode, err)
+			}
+			received <- payload
+		}()
+
+		err := conn.WriteJSON(text)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var decoded string
+		if err := json.Unmarshal(<-received, &decoded); err != nil || decoded != text {
+			t.Fatalf("size %d: server got %d bytes, %v", size, len(decoded), err)
+		}
+
+		go server.write(t, true, opText, []byte(text))
+		message, err := conn.ReadMessage(time.Second)
+		if err != nil || string(message) != text {
+			t.Fatalf("size %d: client got %d bytes, %v", size, len(message), err)
+		}
+	}
+}
+
+func TestFragmentsWithPing(t *testing.T) {
+	conn, server := dial(t, acceptKey)
+
+	server.write(t, false, opText, []byte("ab"))
+	server.write(t, true, opPing, []byte("p"))
+	server.write(t, true, opContinuation, []byte("cd"))
+
+	message, err := conn.ReadMessage(time.Second)
+	if err != nil || string(message) != "abcd" {
+		t.Fatalf("got %q, %v", message, err)
+	}
+
+	opcode, payload, err := server.read()
+	if err != nil || opcode != opPong || !bytes.Equal(payload, []byte("p")) {
+		t.Fatalf("expected pong p, got %d %q %v", opcode, payload, err)
+	}
+}
+
+func TestServerClose(t *testing.T) {
+	conn, server := dial(t, acceptKey)
+
+	server.write(t, true, opClose, []byte{0x03, 0xE8})
+
+	_, err := conn.ReadMessage(time.Second)
+	if !errors.Is(err, websocket.ErrClosed) {
+		t.Fatalf("expected closed, got %v", err)
+	}
+
+	opcode, _, err := server.read()
+	if err != nil || opcode != opClose {
+		t.Fatalf("expected close answer, got %d %v", opcode, err)
+	}
+}
+
+func TestTooBigFrame(t *testing.T) {
+	conn, server := dial(t, acceptKey)
+
+	header := []byte{0x80 | opText, 127, 0, 0, 0, 0, 0, 0, 0, 0}
+	binary.BigEndian.PutUint64(header[2:], websocket.MaxMessage+1)
+	server.conn.Write(header)
+
+	_, err := conn.ReadMessage(time.Second)
+	if err == nil || !strings.Contains(err.Error(), "too big") {
+		t.Fatalf("expected too big frame, got %v", err)
+	}
+}
+
+func TestContinuationWithoutMessage(t *testing.T) {
+	conn, server := dial(t, acceptKey)
+
+	server.write(t, true, opContinuation, []byte("cd"))
+
+	_, err := conn.ReadMessage(time.Second)
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+}